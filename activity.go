@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+// heatmapWeeks is the number of weeks of the activity heatmap, a year
+const heatmapWeeks = 53
+
+// activityThresholds are the edits of a day from which it takes the next
+// shade of heatColors
+var activityThresholds = []int{2, 4, 8}
+
+// activityLog counts the notes created or edited each day, by notes
+// directory and date
+type activityLog map[string]map[string]int
+
+// activityPath returns the location of the activity file, next to the state
+func activityPath() string {
+	return filepath.Join(filepath.Dir(statePath()), "activity.yaml")
+}
+
+// loadActivity reads the activity file, a missing or broken file giving no
+// activity
+func loadActivity() activityLog {
+	activity := activityLog{}
+	if data, err := os.ReadFile(activityPath()); err == nil {
+		yaml.Unmarshal(data, &activity)
+	}
+	return activity
+}
+
+// recordActivity counts an edit of a note of a notes directory on a day,
+// but for the encrypted vaults
+func recordActivity(notesDir string, at time.Time) error {
+	if inUnlockedVault(notesDir) {
+		return nil
+	}
+	return withFileLock(activityPath(), func() error {
+		activity := loadActivity()
+		if activity[notesDir] == nil {
+			activity[notesDir] = map[string]int{}
+		}
+		activity[notesDir][at.Format("2006-01-02")]++
+		return writeStateFile(activityPath(), activity)
+	})
+}
+
+// activeDays returns the activity of each day: the recorded edits, and at
+// least one for the days having a daily note or a note last modified then,
+// which covers the days before the activity was recorded
+func activeDays(items []noteItem, notesDir, dailyNotesPattern string) map[string]int {
+	days := map[string]int{}
+	for date, edits := range loadActivity()[notesDir] {
+		days[date] = edits
+	}
+	for date := range dailyNotes(items, dailyNotesPattern) {
+		days[date] = max(days[date], 1)
+	}
+	for _, item := range items {
+		if !item.modTime.IsZero() {
+			date := item.modTime.Format("2006-01-02")
+			days[date] = max(days[date], 1)
+		}
+	}
+	return days
+}
+
+// streaks returns the number of days in a row with some activity up to
+// today, or yesterday while today is still to be written, and the longest
+// such run
+func streaks(days map[string]int, now time.Time) (current, longest int) {
+	if len(days) == 0 {
+		return 0, 0
+	}
+	first := now
+	for date := range days {
+		if day, err := time.ParseInLocation("2006-01-02", date, time.Local); err == nil && day.Before(first) {
+			first = day
+		}
+	}
+
+	run := 0
+	for day := first; !day.After(now); day = day.AddDate(0, 0, 1) {
+		if days[day.Format("2006-01-02")] > 0 {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	day := now
+	if days[day.Format("2006-01-02")] == 0 {
+		day = day.AddDate(0, 0, -1)
+	}
+	for ; days[day.Format("2006-01-02")] > 0; day = day.AddDate(0, 0, -1) {
+		current++
+	}
+	return current, longest
+}
+
+// heatmap renders the activity of the last weeks like the contributions of
+// GitHub: a column per week from monday to sunday, shaded by the edits of
+// each day, with the months on top
+func heatmap(days map[string]int, now time.Time, weeks int) []string {
+	start := periodOf(periodWeek, now).shift(1 - weeks).start
+	empty := mutedStyle.Render("· ")
+
+	months := make([]byte, weeks*2+1)
+	for i := range months {
+		months[i] = ' '
+	}
+	rows := make([]string, 7)
+	for week := 0; week < weeks; week++ {
+		monday := start.AddDate(0, 0, 7*week)
+		if monday.Day() <= 7 {
+			copy(months[week*2:], monday.Format("Jan"))
+		}
+		for weekday := 0; weekday < 7; weekday++ {
+			day := monday.AddDate(0, 0, weekday)
+			edits := days[day.Format("2006-01-02")]
+			switch {
+			case day.After(now):
+				rows[weekday] += "  "
+			case edits == 0:
+				rows[weekday] += empty
+			default:
+				level := 0
+				for level < len(activityThresholds) && edits >= activityThresholds[level] {
+					level++
+				}
+				rows[weekday] += lipgloss.NewStyle().Foreground(lipgloss.Color(heatColors[level])).Render("■ ")
+			}
+		}
+	}
+
+	labels := []string{"Mon", "", "Wed", "", "Fri", "", "Sun"}
+	lines := []string{"    " + mutedStyle.Render(strings.TrimRight(string(months), " "))}
+	for i, row := range rows {
+		lines = append(lines, mutedStyle.Render(fmt.Sprintf("%-4s", labels[i]))+row)
+	}
+	return lines
+}
+
+// openStats shows the numbers of the notes, the activity heatmap of the
+// last year and the journaling streaks
+func (m model) openStats() (tea.Model, tea.Cmd) {
+	words, tags, daily := 0, map[string]bool{}, dailyNotes(m.items, m.cfg.DailyNotes)
+	for _, item := range m.items {
+		_, body := splitTagLine(noteContents.get(item))
+		words += len(strings.Fields(stripFrontMatter(body)))
+		for _, tag := range strings.Fields(item.allTags()) {
+			tags[tag] = true
+		}
+	}
+
+	now := today()
+	days := activeDays(m.items, m.notesDir, m.cfg.DailyNotes)
+	current, longest := streaks(days, now)
+	active := 0
+	for date := range days {
+		if day, err := time.ParseInLocation("2006-01-02", date, time.Local); err == nil && now.Sub(day) < 365*24*time.Hour {
+			active++
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("%d notes, %d words, %d tags, %d daily notes", len(m.items), words, len(tags), len(daily)),
+		"",
+	}
+	lines = append(lines, heatmap(days, now, min(heatmapWeeks, max((m.width-8)/2, 4)))...)
+	lines = append(lines, "",
+		fmt.Sprintf("Current streak: %s  Longest streak: %s  Active days in the last year: %d",
+			pluralDays(current), pluralDays(longest), active))
+	return m.openPager("Stats", lines)
+}
+
+// pluralDays formats a number of days
+func pluralDays(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
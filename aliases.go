@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// aliasList are the other names of a note, written as a yaml list or as
+// names separated by commas: "k8s, kube" or [k8s, kube]
+type aliasList []string
+
+func (a *aliasList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*a = splitAliases(node.Value)
+		return nil
+	}
+
+	var aliases []string
+	if err := node.Decode(&aliases); err != nil {
+		return err
+	}
+	*a = nil
+	for _, alias := range aliases {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			*a = append(*a, alias)
+		}
+	}
+	return nil
+}
+
+// splitAliases reads names separated by commas
+func splitAliases(value string) aliasList {
+	var aliases aliasList
+	for _, alias := range strings.Split(value, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// assistantConfig sets the language model summarizing the notes, suggesting
+// their title and proposing tags. Nothing is sent without a provider.
+type assistantConfig struct {
+	// Provider is ollama, or openai for any API compatible with the one of
+	// OpenAI like llama.cpp or LocalAI
+	Provider string `yaml:"provider"`
+	// URL defaults to http://localhost:11434/v1 for ollama and
+	// https://api.openai.com/v1 for openai
+	URL string `yaml:"url"`
+	// Model defaults to llama3.2 for ollama and gpt-4o-mini for openai
+	Model string `yaml:"model"`
+	// APIKeyEnv is the environment variable holding the API key,
+	// OPENAI_API_KEY by default for openai
+	APIKeyEnv string `yaml:"api_key_env"`
+}
+
+// The actions of the assistant
+const (
+	assistSummary = "summary"
+	assistTitle   = "title"
+	assistTags    = "tags"
+)
+
+// assistantPrompts tell the model what to answer for every action
+var assistantPrompts = map[string]string{
+	assistSummary: "Summarize the note given by the user in two or three sentences, in the language of the note. Answer with the summary only.",
+	assistTitle:   "Suggest a short title for the note given by the user, in the language of the note. Answer with the title only, without quotes.",
+	assistTags:    "Propose up to five tags for the note given by the user: single lowercase words, or words joined by _. Prefer these existing tags when they fit: %s. Answer with the tags only, separated by spaces.",
+}
+
+var assistantClient = &http.Client{Timeout: 2 * time.Minute}
+
+func (c assistantConfig) validate() error {
+	switch c.Provider {
+	case "", "ollama", "openai":
+	default:
+		return fmt.Errorf("unknown assistant provider %q, expected ollama or openai", c.Provider)
+	}
+	return nil
+}
+
+// apiKey returns the API key of an OpenAI compatible provider, from an
+// environment variable
+func apiKey(env string) string {
+	if env == "" {
+		env = "OPENAI_API_KEY"
+	}
+	return os.Getenv(env)
+}
+
+// complete sends a note to the model with the instructions of the system
+// prompt, and returns its answer
+func (c assistantConfig) complete(system, note string) (string, error) {
+	endpoint := strings.TrimSuffix(c.URL, "/")
+	model := c.Model
+	switch c.Provider {
+	case "ollama":
+		if endpoint == "" {
+			endpoint = "http://localhost:11434/v1"
+		}
+		if model == "" {
+			model = "llama3.2"
+		}
+	case "openai":
+		if endpoint == "" {
+			endpoint = "https://api.openai.com/v1"
+		}
+		if model == "" {
+			model = "gpt-4o-mini"
+		}
+	default:
+		return "", fmt.Errorf("no assistant yet, add one to the assistant of the config")
+	}
+	endpoint += "/chat/completions"
+
+	payload, err := json.Marshal(map[string]any{
+		"model": model,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": note},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := apiKey(c.APIKeyEnv); key != "" && c.Provider == "openai" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := assistantClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("%s refused the note: %s%s", endpoint, resp.Status, hookOutput(string(answer)))
+	}
+
+	var answer struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return "", fmt.Errorf("unexpected answer from %s: %v", endpoint, err)
+	}
+	if len(answer.Choices) == 0 || strings.TrimSpace(answer.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("%s answered nothing", endpoint)
+	}
+	return strings.TrimSpace(answer.Choices[0].Message.Content), nil
+}
+
+// assist runs an action of the assistant on a note and returns its proposal:
+// a summary, a title, or tags separated by spaces
+func assist(c assistantConfig, action string, items []noteItem, item noteItem) (string, error) {
+	content := noteContents.get(item)
+	if strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("the note is empty")
+	}
+
+	prompt := assistantPrompts[action]
+	if action == assistTags {
+		prompt = fmt.Sprintf(prompt, strings.Join(popularTags(items, 50), ", "))
+	}
+	answer, err := c.complete(prompt, content)
+	if err != nil {
+		return "", err
+	}
+
+	switch action {
+	case assistTitle:
+		title, _, _ := strings.Cut(answer, "\n")
+		return strings.Trim(strings.TrimSpace(strings.TrimLeft(title, "# ")), `"'`), nil
+	case assistTags:
+		return proposedTags(answer), nil
+	}
+	return answer, nil
+}
+
+// popularTags returns the tags used by the most notes, without their +
+func popularTags(items []noteItem, limit int) []string {
+	used := map[string]int{}
+	for _, item := range items {
+		for _, tag := range strings.Fields(item.allTags()) {
+			used[strings.TrimPrefix(tag, "+")]++
+		}
+	}
+	tags := make([]string, 0, len(used))
+	for tag := range used {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if used[tags[i]] != used[tags[j]] {
+			return used[tags[i]] > used[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	return tags[:min(len(tags), limit)]
+}
+
+// answerSeparators split the tags answered by the model, which may use
+// commas or hashes despite the prompt
+var answerSeparators = regexp.MustCompile(`[\s,;#+]+`)
+
+// proposedTags keeps the valid tags of an answer, without their +
+func proposedTags(answer string) string {
+	var tags []string
+	for _, word := range answerSeparators.Split(strings.ToLower(answer), -1) {
+		word = strings.Trim(word, ".`*\"'")
+		if tagName.MatchString(word) && !containsTag(tags, word) {
+			tags = append(tags, word)
+		}
+	}
+	return strings.Join(tags, " ")
+}
+
+// insertSummary writes a summary at the top of a note, below its tag line,
+// front matter or org keywords and its first heading
+func insertSummary(content, summary string, org bool) string {
+	lines := strings.Split(content, "\n")
+	i := 0
+	if i < len(lines) && strings.HasPrefix(lines[i], "//") && !org {
+		i++
+	}
+	if !org && i < len(lines) && strings.TrimSpace(lines[i]) == "---" {
+		for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "---"; i++ {
+		}
+		i++
+	}
+	for i < len(lines) {
+		line := strings.TrimSpace(lines[i])
+		if _, _, keyword := orgKeyword(line); line == "" || (org && keyword) {
+			i++
+			continue
+		}
+		break
+	}
+	if i < len(lines) && (org && strings.HasPrefix(lines[i], "* ") || !org && strings.HasPrefix(lines[i], "# ")) {
+		i++
+	}
+	i = min(i, len(lines))
+
+	var block []string
+	if org {
+		block = append(block, "#+begin_quote", summary, "#+end_quote")
+	} else {
+		for _, line := range strings.Split(summary, "\n") {
+			block = append(block, strings.TrimRight("> "+line, " "))
+		}
+	}
+	block = append([]string{""}, append(block, "")...)
+	if i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		block = block[:len(block)-1]
+	}
+	if i == 0 {
+		block = block[1:]
+	}
+
+	lines = append(lines[:i], append(block, lines[i:]...)...)
+	return strings.Join(lines, "\n")
+}
+
+// applyAssistant writes a proposal of the assistant to a note, saving its
+// previous content as a version first
+func applyAssistant(notesDir string, item noteItem, action, proposal string) error {
+	saveVersion(notesDir, item.filename, time.Now())
+	switch action {
+	case assistTitle:
+		if err := setNoteField(item.path, "title", proposal); err != nil {
+			return err
+		}
+	case assistTags:
+		if err := retagNote(item.path, strings.Fields(proposal), nil); err != nil {
+			return err
+		}
+	case assistSummary:
+		data, err := os.ReadFile(item.path)
+		if err != nil {
+			return err
+		}
+		org := strings.ToLower(filepath.Ext(item.path)) == ".org"
+		if err := os.WriteFile(item.path, []byte(insertSummary(string(data), proposal, org)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assistantView shows a proposal of the assistant until it is applied or
+// discarded, the title being editable
+type assistantView struct {
+	action   string
+	item     noteItem
+	proposal string
+	input    textinput.Model
+}
+
+// assistantFinishedMsg is sent when the assistant answered
+type assistantFinishedMsg struct {
+	action   string
+	item     noteItem
+	proposal string
+	err      error
+}
+
+// openAssistantMenu asks what the assistant should do with the selected note
+func (m model) openAssistantMenu() (tea.Model, tea.Cmd) {
+	if _, ok := m.list.SelectedItem().(noteItem); !ok {
+		return m, nil
+	}
+	if m.cfg.Assistant.Provider == "" {
+		return m, m.setInfo("No assistant yet, add one to the assistant of the config")
+	}
+	m.assistantMenu = menu{
+		title: "Ask the assistant",
+		items: []menuItem{
+			{label: "Summarize", hint: "insert a summary at the top"},
+			{label: "Suggest a title"},
+			{label: "Propose tags"},
+		},
+	}
+	m.mode = modeAssistantMenu
+	return m, nil
+}
+
+func (m model) updateAssistantMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.assistantMenu.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+	m.mode = modeList
+
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	action := []string{assistSummary, assistTitle, assistTags}[m.assistantMenu.cursor]
+	c, items := m.cfg.Assistant, m.items
+	ask := func() tea.Msg {
+		proposal, err := assist(c, action, items, item)
+		return assistantFinishedMsg{action: action, item: item, proposal: proposal, err: err}
+	}
+	return m, tea.Batch(m.setInfo("Asking the assistant about %s...", item.filename), ask)
+}
+
+// assistantFinished shows the proposal of the assistant for confirmation,
+// the proposed tags in the tag prompt
+func (m model) assistantFinished(msg assistantFinishedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		return m, m.setError("The assistant failed on %s: %v", msg.item.filename, describeError(msg.err))
+	}
+	if m.mode != modeList {
+		return m, m.setInfo("Left the answer of the assistant aside, ask again from the list")
+	}
+
+	switch msg.action {
+	case assistTags:
+		tags := mergeTags(msg.item.tags, formatTagsWithPlus(msg.proposal))
+		m.retagging = msg.item
+		m.tagSuggestions = nil
+		m.tagInput.SetValue(strings.ReplaceAll(tags, "+", ""))
+		m.tagInput.CursorEnd()
+		m.tagInput.Focus()
+		m.mode = modeTagEdit
+		return m, tea.Batch(textinput.Blink, m.setInfo("Review the proposed tags, enter saves them"))
+	case assistTitle:
+		input := textinput.New()
+		input.CharLimit = 200
+		input.Width = max(m.width-6, 20)
+		input.SetValue(msg.proposal)
+		input.CursorEnd()
+		input.Focus()
+		m.assistant = assistantView{action: msg.action, item: msg.item, proposal: msg.proposal, input: input}
+		m.mode = modeAssistant
+		return m, textinput.Blink
+	}
+	m.assistant = assistantView{action: msg.action, item: msg.item, proposal: msg.proposal}
+	m.mode = modeAssistant
+	return m, nil
+}
+
+func (m model) updateAssistant(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "ctrl+c":
+			m.quitting = true
+			return m, tea.Quit
+		case "esc":
+			m.mode = modeList
+			return m, m.setInfo("Discarded the %s", m.assistant.action)
+		case "enter":
+			m.mode = modeList
+			a := m.assistant
+			proposal := a.proposal
+			if a.action == assistTitle {
+				proposal = strings.TrimSpace(a.input.Value())
+				if proposal == "" {
+					return m, m.setInfo("Discarded the title")
+				}
+			}
+			if err := applyAssistant(m.notesDir, a.item, a.action, proposal); err != nil {
+				return m, tea.Batch(m.reloadNotes(), m.setError("Cannot write the %s of %s: %v", a.action, a.item.filename, describeError(err)))
+			}
+			done := fmt.Sprintf("Wrote the %s of %s", a.action, a.item.filename)
+			return m, tea.Batch(m.reloadNotes(), m.setInfo("%s", done), m.noteSavedCmd("post_edit", a.item.filename, done))
+		}
+	}
+
+	if m.assistant.action != assistTitle {
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.assistant.input, cmd = m.assistant.input.Update(msg)
+	return m, cmd
+}
+
+func (m model) viewAssistant() string {
+	a := m.assistant
+	if a.action == assistTitle {
+		prompt := fmt.Sprintf("Suggested title of %s, edit it or press enter to set it:", a.item.filename)
+		return fmt.Sprintf("\n\n  %s\n\n  %s\n\n", prompt, a.input.View()) + "  (press ESC to discard)"
+	}
+	summary := lipgloss.NewStyle().Width(max(m.width-6, 20)).Render(a.proposal)
+	summary = lipgloss.NewStyle().PaddingLeft(2).Render(summary)
+	prompt := fmt.Sprintf("Summary of %s, press enter to insert it at the top:", a.item.filename)
+	return fmt.Sprintf("\n\n  %s\n\n%s\n\n", prompt, summary) + "  (press ESC to discard)"
+}
+
+func runAssist(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("assist", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "write the proposal without asking")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("expected the action, summary, title or tags, and the note")
+	}
+	action := flags.Arg(0)
+	if _, ok := assistantPrompts[action]; !ok {
+		return fmt.Errorf("unknown action %q, expected summary, title or tags", action)
+	}
+
+	path, err := notePath(notesDir, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+	var item noteItem
+	for _, candidate := range items {
+		if filepath.Clean(candidate.path) == filepath.Clean(path) {
+			item = candidate
+		}
+	}
+	if item.path == "" {
+		return fmt.Errorf("no note %s", flags.Arg(1))
+	}
+
+	proposal, err := assist(cfg.Assistant, action, items, item)
+	if err != nil {
+		return err
+	}
+	if proposal == "" {
+		return fmt.Errorf("the assistant proposed no %s", action)
+	}
+	fmt.Println(proposal)
+	if !*yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil
+		}
+		if !askForConfirmation(fmt.Sprintf("Write this %s to %s?", action, item.filename)) {
+			return nil
+		}
+	}
+	if err := applyAssistant(notesDir, item, action, proposal); err != nil {
+		return err
+	}
+	return noteSaved("post_edit", notesDir, item.filename)
+}
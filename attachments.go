@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// attachmentsFolder holds the files attached to notes, like pasted images,
+// relative to the notes directory
+const attachmentsFolder = "attachments"
+
+// saveAttachment writes a file in the attachments folder, renaming it if the
+// name is taken, and returns its path relative to the notes directory
+func saveAttachment(notesDir, name string, data []byte) (string, error) {
+	dir := filepath.Join(notesDir, attachmentsFolder)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create the attachments folder: %v", err)
+	}
+
+	ext := filepath.Ext(name)
+	filename := uniqueFilename(dir, strings.TrimSuffix(name, ext), ext)
+	if err := os.WriteFile(filepath.Join(dir, filename), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save attachment: %v", err)
+	}
+
+	return filepath.Join(attachmentsFolder, filename), nil
+}
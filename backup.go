@@ -0,0 +1,287 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupConfig sets where and how the notes are backed up
+type backupConfig struct {
+	// Dir holds the backups, backups/ next to the state by default
+	Dir string `yaml:"dir"`
+	// Format is tar.gz, the default, or zip
+	Format string `yaml:"format"`
+	// Keep is the number of backups of a notes directory kept, the oldest
+	// being deleted, 0 keeping them all
+	Keep int `yaml:"keep"`
+	// Auto backs up the notes once per run before deleting, replacing or
+	// merging notes
+	Auto bool `yaml:"auto"`
+}
+
+// dir returns the directory of the backups
+func (c backupConfig) dir() string {
+	if c.Dir != "" {
+		return expandTilde(c.Dir)
+	}
+	return filepath.Join(filepath.Dir(statePath()), "backups")
+}
+
+// backupPrefix starts the names of the backups of a notes directory: its
+// name, then a hash of its path telling apart the notes directories of the
+// same name, like ~/work/notes and ~/home/notes
+func backupPrefix(notesDir string) string {
+	sum := sha256.Sum256([]byte(absPath(notesDir)))
+	return filepath.Base(filepath.Clean(notesDir)) + "-" + hex.EncodeToString(sum[:4]) + "-"
+}
+
+// archiveWriter adds files to a tar.gz or zip archive
+type archiveWriter interface {
+	add(name string, info fs.FileInfo, data []byte) error
+	Close() error
+}
+
+type tarGzWriter struct {
+	gz  *gzip.Writer
+	tar *tar.Writer
+}
+
+func (w *tarGzWriter) add(name string, info fs.FileInfo, data []byte) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := w.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = w.tar.Write(data)
+	return err
+}
+
+func (w *tarGzWriter) Close() error {
+	if err := w.tar.Close(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+type zipWriter struct {
+	zip *zip.Writer
+}
+
+func (w *zipWriter) add(name string, info fs.FileInfo, data []byte) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	f, err := w.zip.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zip.Close()
+}
+
+// writeBackup archives the notes directory into the backup directory and
+// returns the path of the archive. The files are stored under the name of the
+// notes directory. Left out are git's files, the .snsm folder of the trash,
+// the versions and the locks, and the sensitive notes, which are never
+// written anywhere in plain text.
+func writeBackup(c backupConfig, notesDir string, now time.Time) (string, error) {
+	format := c.Format
+	if format == "" {
+		format = "tar.gz"
+	}
+	dir := c.dir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	// The archive only replaces an older one once complete
+	tmp, err := os.CreateTemp(dir, ".backup-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var archive archiveWriter
+	if format == "zip" {
+		archive = &zipWriter{zip: zip.NewWriter(tmp)}
+	} else {
+		gz := gzip.NewWriter(tmp)
+		archive = &tarGzWriter{gz: gz, tar: tar.NewWriter(gz)}
+	}
+
+	root := filepath.Base(filepath.Clean(notesDir))
+	stateDir := filepath.Join(notesDir, snsmDir)
+	err = filepath.WalkDir(notesDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" || path == stateDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if rel, _ := filepath.Rel(notesDir, path); isNoteFile(rel) && sensitiveFile(notesDir, rel) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(notesDir, path)
+		return archive.add(root+"/"+filepath.ToSlash(name), info, data)
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := archive.Close(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, backupPrefix(notesDir)+now.Format("2006-01-02-150405")+"."+format)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// listBackups returns the backups of a notes directory, oldest first
+func listBackups(c backupConfig, notesDir string) ([]string, error) {
+	entries, err := os.ReadDir(c.dir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	prefix := backupPrefix(notesDir)
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !(strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".zip")) {
+			continue
+		}
+		// Only the prefix followed by a date, nothing else
+		if _, err := time.Parse("2006-01-02-150405", strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".zip"), ".tar.gz")); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(c.dir(), name))
+	}
+	// Dates sort by name
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// pruneBackups deletes the oldest backups of a notes directory past the
+// number kept
+func pruneBackups(c backupConfig, notesDir string) error {
+	if c.Keep <= 0 {
+		return nil
+	}
+	backups, err := listBackups(c, notesDir)
+	if err != nil {
+		return err
+	}
+	for len(backups) > c.Keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// backup writes a backup of the notes directory and prunes the old ones
+func backup(c backupConfig, notesDir string) (string, error) {
+	path, err := writeBackup(c, notesDir, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to back up the notes: %v", err)
+	}
+	if err := pruneBackups(c, notesDir); err != nil {
+		return path, fmt.Errorf("failed to delete the old backups: %v", err)
+	}
+	return path, nil
+}
+
+// backupBeforeChange backs up the notes before a destructive operation, once
+// per run, when automatic backups are on. The operation is refused when the
+// backup fails.
+func (m *model) backupBeforeChange() error {
+	// Backups of an encrypted vault would hold its notes decrypted
+	if !m.cfg.Backup.Auto || m.backedUp || inUnlockedVault(m.notesDir) {
+		return nil
+	}
+	if _, err := backup(m.cfg.Backup, m.notesDir); err != nil {
+		return err
+	}
+	m.backedUp = true
+	return nil
+}
+
+func runBackup(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dir := flags.String("dir", cfg.Backup.Dir, "directory of the backups")
+	format := flags.String("format", cfg.Backup.Format, "tar.gz or zip")
+	keep := flags.Int("keep", cfg.Backup.Keep, "number of backups kept, 0 for all")
+	list := flags.Bool("list", false, "list the backups instead")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	c := backupConfig{Dir: *dir, Format: *format, Keep: *keep}
+	switch c.Format {
+	case "", "tar.gz", "zip":
+	default:
+		return fmt.Errorf("unknown format %q, expected tar.gz or zip", c.Format)
+	}
+
+	if *list {
+		backups, err := listBackups(c, notesDir)
+		if err != nil {
+			return err
+		}
+		for _, path := range backups {
+			fmt.Println(path)
+		}
+		return nil
+	}
+
+	path, err := backup(c, notesDir)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// boardConfig configures the board, its columns being tags
+type boardConfig struct {
+	// Columns are the tags of the columns, from left to right
+	Columns tagList `yaml:"columns"`
+	// Query limits the cards to the notes matching it, like tag:acme
+	Query string `yaml:"query"`
+}
+
+// validate checks the columns and the query of the board
+func (c boardConfig) validate() error {
+	if len(c.Columns) < 2 {
+		return fmt.Errorf("the board needs at least two columns")
+	}
+	seen := map[string]bool{}
+	for _, column := range c.Columns {
+		tag := "+" + strings.TrimPrefix(column, "+")
+		if seen[tag] {
+			return fmt.Errorf("the board has column %s twice", tag)
+		}
+		seen[tag] = true
+	}
+	if _, err := parseQuery(c.Query); err != nil {
+		return fmt.Errorf("invalid board query: %v", err)
+	}
+	return nil
+}
+
+// boardView shows the notes having the tags of the columns as cards, moved
+// from column to column by retagging them
+type boardView struct {
+	// columns are the tags of the columns, with their +
+	columns []string
+	cards   [][]string
+	column  int
+	cursors []int
+	query   string
+}
+
+// openBoard shows the board of the notes matching a query, the one of the
+// config when empty
+func (m model) openBoard(q string) (tea.Model, tea.Cmd) {
+	if q == "" {
+		q = m.cfg.Board.Query
+	}
+	parsed, err := parseQuery(q)
+	if err != nil {
+		return m, m.setError("Invalid query: %v", err)
+	}
+
+	v := boardView{query: q}
+	for _, column := range m.cfg.Board.Columns {
+		v.columns = append(v.columns, "+"+strings.TrimPrefix(column, "+"))
+	}
+	v.cards = make([][]string, len(v.columns))
+	v.cursors = make([]int, len(v.columns))
+	for _, item := range sortNotes(m.items, m.sortMode, m.history[m.notesDir]) {
+		if !parsed.matches(item) {
+			continue
+		}
+		// A note tagged with several columns is in the rightmost one, the
+		// furthest along
+		if i := v.columnOf(item); i >= 0 {
+			v.cards[i] = append(v.cards[i], item.filename)
+		}
+	}
+
+	if item, ok := m.list.SelectedItem().(noteItem); ok {
+		v.focus(item.filename)
+	}
+	m.board = v
+	m.mode = modeBoard
+	return m, nil
+}
+
+// columnOf returns the rightmost column a note is tagged with, or -1
+func (v boardView) columnOf(item noteItem) int {
+	tags := strings.Fields(item.allTags())
+	for i := len(v.columns) - 1; i >= 0; i-- {
+		if containsTag(tags, v.columns[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// focus moves the cursor to the card of a note, if it is on the board
+func (v *boardView) focus(filename string) {
+	for i, cards := range v.cards {
+		for j, card := range cards {
+			if card == filename {
+				v.column, v.cursors[i] = i, j
+				return
+			}
+		}
+	}
+}
+
+// selected returns the note of the card under the cursor
+func (v boardView) selected() (string, bool) {
+	cards := v.cards[v.column]
+	if len(cards) == 0 {
+		return "", false
+	}
+	return cards[v.cursors[v.column]], true
+}
+
+// moveCard moves the selected card to the column on its left or right,
+// replacing the tag of its column with the one of the other column
+func (m model) moveCard(step int) (tea.Model, tea.Cmd) {
+	v := &m.board
+	target := v.column + step
+	filename, ok := v.selected()
+	if !ok || target < 0 || target >= len(v.columns) {
+		return m, nil
+	}
+	item, ok := m.findItem(filename)
+	if !ok {
+		return m, nil
+	}
+
+	var kept []string
+	for _, tag := range strings.Fields(item.tags) {
+		if !containsTag(v.columns, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	tags := mergeTags(strings.Join(kept, " "), v.columns[target])
+	updated := item
+	updated.tags = tags
+	if v.columnOf(updated) != target {
+		// The tag of the column comes from the .snsm.yaml of its folder
+		return m, m.setError("%s inherits %s from its folder", item.filename, v.columns[v.column])
+	}
+
+	if err := m.retag(item, tags); err != nil {
+		return m, m.setError("Cannot move %s: %v", item.filename, describeError(err))
+	}
+	m.updateItemTags(item.filename, tags)
+
+	cards := v.cards[v.column]
+	cursor := v.cursors[v.column]
+	v.cards[v.column] = append(cards[:cursor:cursor], cards[cursor+1:]...)
+	v.cursors[v.column] = max(min(cursor, len(v.cards[v.column])-1), 0)
+	v.cards[target] = append([]string{filename}, v.cards[target]...)
+	v.column, v.cursors[target] = target, 0
+
+	done := fmt.Sprintf("Moved %s to %s", item.Title(), v.columns[target])
+	return m, tea.Batch(m.reloadNotes(), m.setInfo("%s", done), m.noteSavedCmd("post_edit", item.filename, done))
+}
+
+// updateItemTags sets the tags of a note of the list before the notes are
+// scanned again
+func (m *model) updateItemTags(filename, tags string) {
+	for i := range m.items {
+		if m.items[i].filename == filename {
+			m.items[i].tags = tags
+		}
+	}
+}
+
+func (m model) updateBoard(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	v := &m.board
+	switch keyMsg.String() {
+	case "up", "k":
+		if v.cursors[v.column] > 0 {
+			v.cursors[v.column]--
+		}
+	case "down", "j":
+		if v.cursors[v.column] < len(v.cards[v.column])-1 {
+			v.cursors[v.column]++
+		}
+	case "left", "h":
+		if v.column > 0 {
+			v.column--
+		}
+	case "right", "l":
+		if v.column < len(v.columns)-1 {
+			v.column++
+		}
+	case "shift+left", "H", "<":
+		return m.moveCard(-1)
+	case "shift+right", "L", ">":
+		return m.moveCard(1)
+	case "enter", "e":
+		if filename, ok := v.selected(); ok {
+			return m, m.openNote(filename)
+		}
+	case "esc", "q":
+		// Leave the list on the selected card
+		if filename, ok := v.selected(); ok {
+			m.selectNote(filename)
+		}
+		m.mode = modeList
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m model) viewBoard() string {
+	v := m.board
+	var b strings.Builder
+
+	cards := 0
+	for _, column := range v.cards {
+		cards += len(column)
+	}
+	header := fmt.Sprintf("%d cards", cards)
+	if v.query != "" {
+		header += " • " + v.query
+	}
+	b.WriteString("\n" + titleStyle.Render("Board") + "  " + mutedStyle.Render(header) + "\n\n")
+
+	width := max((m.width-4)/len(v.columns)-1, 12)
+	height := max(m.height-8, 3)
+	var columns []string
+	for i, tag := range v.columns {
+		title := fmt.Sprintf("%s (%d)", tag, len(v.cards[i]))
+		style := lipgloss.NewStyle().Width(width).Bold(true)
+		if i == v.column {
+			style = style.Foreground(titleStyle.GetForeground())
+		}
+		lines := []string{style.Render(truncateWidth(title, width))}
+
+		offset := max(v.cursors[i]-height+1, 0)
+		for j, filename := range v.cards[i] {
+			if j < offset || j >= offset+height {
+				continue
+			}
+			line := truncateWidth(m.titleOf(filename), width-2)
+			if i == v.column && j == v.cursors[i] {
+				lines = append(lines, selectedItemStyle.Copy().PaddingLeft(0).Render("▌"+line))
+			} else {
+				lines = append(lines, " "+line)
+			}
+		}
+		if len(v.cards[i]) == 0 {
+			lines = append(lines, mutedStyle.Render(" none"))
+		}
+		columns = append(columns, lipgloss.NewStyle().Width(width).Render(strings.Join(lines, "\n")), " ")
+	}
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, append([]string{"  "}, columns...)...))
+
+	return m.withHelp(b.String(), "←/→ column • ↑/↓ card • shift+←/→ move card • enter edit • esc close")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// folderEntry is a subfolder of the folder browsed, listed before its notes
+type folderEntry struct {
+	path string
+	// count is the number of notes of the folder, subfolders included
+	count int
+}
+
+// FilterValue is the name of the folder, so that the filter finds it
+func (f folderEntry) FilterValue() string { return filepath.Base(f.path) }
+
+// browseItems returns the subfolders and the notes of the folder browsed, and
+// the notes aligned with them for the query filter, empty for the folders
+func (m model) browseItems(notes []noteItem) ([]list.Item, []noteItem) {
+	counts := map[string]int{}
+	var here []noteItem
+	for _, item := range notes {
+		if !inFolder(item.filename, m.browseDir) {
+			continue
+		}
+		rest := item.filename
+		if m.browseDir != "" {
+			rest = strings.TrimPrefix(item.filename, m.browseDir+string(filepath.Separator))
+		}
+		if parts := splitFolder(filepath.Dir(rest)); len(parts) > 0 {
+			counts[filepath.Join(m.browseDir, parts[0])]++
+		} else {
+			here = append(here, item)
+		}
+	}
+
+	folders := make([]string, 0, len(counts))
+	for folder := range counts {
+		folders = append(folders, folder)
+	}
+	sort.Slice(folders, func(i, j int) bool { return strings.ToLower(folders[i]) < strings.ToLower(folders[j]) })
+
+	var items []list.Item
+	var shown []noteItem
+	for _, folder := range folders {
+		items = append(items, folderEntry{path: folder, count: counts[folder]})
+		shown = append(shown, noteItem{})
+	}
+	for _, item := range here {
+		items = append(items, item)
+		shown = append(shown, item)
+	}
+	return items, shown
+}
+
+// toggleBrowse switches between the notes of every folder and browsing the
+// folders level by level, from the folder of the selected note
+func (m model) toggleBrowse() (tea.Model, tea.Cmd) {
+	var selected string
+	if item, ok := m.list.SelectedItem().(noteItem); ok {
+		selected = item.filename
+	}
+
+	m.browsing = !m.browsing
+	m.browseDir = ""
+	if m.browsing && selected != "" {
+		if folder := filepath.Dir(selected); folder != "." {
+			m.browseDir = folder
+		}
+	}
+	m.list.ResetFilter()
+	m.list.Title = m.listTitle()
+	cmd := m.setListItems()
+	m.selectNote(selected)
+	return m, cmd
+}
+
+// browseTo lists a folder, selecting one of its entries
+func (m model) browseTo(folder, selected string) (tea.Model, tea.Cmd) {
+	m.browseDir = folder
+	m.list.ResetFilter()
+	m.list.Title = m.listTitle()
+	cmd := m.setListItems()
+	m.list.Select(0)
+	for i, listItem := range m.list.VisibleItems() {
+		if entry, ok := listItem.(folderEntry); ok && entry.path == selected {
+			m.list.Select(i)
+		}
+	}
+	return m, cmd
+}
+
+// browseUp lists the parent of the folder browsed, on the folder left
+func (m model) browseUp() (tea.Model, tea.Cmd) {
+	if m.browseDir == "" {
+		return m, nil
+	}
+	parent := filepath.Dir(m.browseDir)
+	if parent == "." {
+		parent = ""
+	}
+	return m.browseTo(parent, m.browseDir)
+}
+
+// renderFolder draws a folder of the folder browsed, with its number of notes
+func (d customItemDelegate) renderFolder(w io.Writer, entry folderEntry, selected bool) {
+	line := filepath.Base(entry.path) + "/  " + mutedStyle.Render(fmt.Sprint(entry.count))
+	if selected {
+		line = d.Styles.SelectedTitle.Copy().Bold(true).Render(line)
+	} else {
+		line = d.Styles.NormalTitle.Copy().Bold(true).Render(line)
+	}
+	fmt.Fprint(w, line)
+}
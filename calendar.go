@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// heatColors shade the days of the calendar by the number of words of their
+// note, from the shortest to the longest notes
+var heatColors = []string{"22", "28", "34", "40"}
+
+// heatThresholds are the word counts from which a note takes the next shade
+var heatThresholds = []int{100, 300, 700}
+
+// dailyNote is the note of a day of the calendar
+type dailyNote struct {
+	filename string
+	words    int
+}
+
+// dailyPattern returns the regexp matching the filenames of the daily notes,
+// without their extension, capturing their date
+func dailyPattern(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(filepath.ToSlash(pattern))
+	return regexp.MustCompile("^" + strings.Replace(quoted, "%t", `(\d{4}-\d{2}-\d{2})`, 1) + "$")
+}
+
+// dailyNotes returns the daily notes by day
+func dailyNotes(items []noteItem, pattern string) map[string]dailyNote {
+	daily := dailyPattern(pattern)
+	notes := map[string]dailyNote{}
+	for _, item := range items {
+		match := daily.FindStringSubmatch(filepath.ToSlash(trimNoteExt(item.filename)))
+		if match == nil {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", match[1]); err != nil {
+			continue
+		}
+		_, body := splitTagLine(noteContents.get(item))
+		notes[match[1]] = dailyNote{filename: item.filename, words: len(strings.Fields(stripFrontMatter(body)))}
+	}
+	return notes
+}
+
+// heatStyle returns the style of a day with a note of that many words
+func heatStyle(words int) lipgloss.Style {
+	level := 0
+	for level < len(heatThresholds) && words >= heatThresholds[level] {
+		level++
+	}
+	return lipgloss.NewStyle().Background(lipgloss.Color(heatColors[level])).Foreground(lipgloss.Color("255"))
+}
+
+// calendarView shows the days of a month, the ones having a daily note
+// shaded by their number of words
+type calendarView struct {
+	notes map[string]dailyNote
+	// activity counts the edits of each day, for the streak
+	activity map[string]int
+	// day is the selected day, its month being shown
+	day time.Time
+}
+
+// today returns the current day at midnight
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// openCalendar shows the calendar on the current month
+func (m model) openCalendar() (tea.Model, tea.Cmd) {
+	m.calendar = calendarView{
+		notes:    dailyNotes(m.items, m.cfg.DailyNotes),
+		activity: activeDays(m.items, m.notesDir, m.cfg.DailyNotes),
+		day:      today(),
+	}
+	m.mode = modeCalendar
+	return m, nil
+}
+
+// openDay opens the note of the selected day, creating it if needed
+func (m model) openDay() (tea.Model, tea.Cmd) {
+	date := m.calendar.day.Format("2006-01-02")
+	if note, ok := m.calendar.notes[date]; ok {
+		return m, m.openNote(note.filename)
+	}
+	filename, cmd := m.openPeriodNote(periodOf(periodDay, m.calendar.day))
+	if filename != "" {
+		m.calendar.notes[date] = dailyNote{filename: filename}
+	}
+	return m, cmd
+}
+
+func (m model) updateCalendar(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	day := m.calendar.day
+	switch keyMsg.String() {
+	case "left", "h":
+		m.calendar.day = day.AddDate(0, 0, -1)
+	case "right", "l":
+		m.calendar.day = day.AddDate(0, 0, 1)
+	case "up", "k":
+		m.calendar.day = day.AddDate(0, 0, -7)
+	case "down", "j":
+		m.calendar.day = day.AddDate(0, 0, 7)
+	case "pgup", "[", "p":
+		m.calendar.day = day.AddDate(0, -1, 0)
+	case "pgdown", "]", "n":
+		m.calendar.day = day.AddDate(0, 1, 0)
+	case "t":
+		m.calendar.day = today()
+	case "enter":
+		return m.openDay()
+	case "w":
+		return m.openPeriod(periodOf(periodWeek, day))
+	case "m":
+		return m.openPeriod(periodOf(periodMonth, day))
+	case "Q":
+		return m.openPeriod(periodOf(periodQuarter, day))
+	case "esc", "q", "C":
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m model) viewCalendar() string {
+	v := m.calendar
+	first := time.Date(v.day.Year(), v.day.Month(), 1, 0, 0, 0, 0, time.Local)
+	now := today()
+
+	var b strings.Builder
+	b.WriteString("\n" + titleStyle.Render(first.Format("January 2006")) + "\n\n")
+
+	var rows []string
+	rows = append(rows, mutedStyle.Render(" Mo  Tu  We  Th  Fr  Sa  Su "))
+
+	// Weeks start on monday
+	var cells []string
+	for i := 0; i < (int(first.Weekday())+6)%7; i++ {
+		cells = append(cells, "    ")
+	}
+	written, words := 0, 0
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		style := lipgloss.NewStyle()
+		if note, ok := v.notes[day.Format("2006-01-02")]; ok {
+			style = heatStyle(note.words)
+			written++
+			words += note.words
+		}
+		if day.Equal(now) {
+			style = style.Copy().Underline(true).Bold(true)
+		}
+		cell := style.Render(fmt.Sprintf(" %2d ", day.Day()))
+		if day.Equal(v.day) {
+			cell = selectedItemStyle.Copy().PaddingLeft(0).Reverse(true).Render(fmt.Sprintf(" %2d ", day.Day()))
+		}
+		cells = append(cells, cell)
+
+		if len(cells) == 7 {
+			rows = append(rows, strings.Join(cells, ""))
+			cells = nil
+		}
+	}
+	if len(cells) > 0 {
+		rows = append(rows, strings.Join(cells, ""))
+	}
+	b.WriteString(lipgloss.NewStyle().PaddingLeft(2).Render(strings.Join(rows, "\n")))
+
+	date := v.day.Format("2006-01-02")
+	selected := "No note, enter to create it"
+	if note, ok := v.notes[date]; ok {
+		selected = fmt.Sprintf("%s · %d words", note.filename, note.words)
+	}
+	b.WriteString("\n\n" + itemStyle.Copy().PaddingLeft(2).Render(v.day.Format("Monday 2 January")+"  "+mutedStyle.Render(selected)))
+	current, _ := streaks(v.activity, now)
+	b.WriteString("\n" + itemStyle.Copy().PaddingLeft(2).Render(mutedStyle.Render(
+		fmt.Sprintf("%d notes this month, %d words · %s streak", written, words, pluralDays(current)))))
+
+	return m.withHelp(b.String(), "←/→ day • ↑/↓ week • [/] month • t today • enter open • w/m/Q week/month/quarter note • esc back")
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// captureEntry formats text as a timestamped bullet, continuation lines
+// being indented under it
+func captureEntry(text string, now time.Time) string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	return fmt.Sprintf("- %s %s\n", now.Format("2006-01-02 15:04"), strings.ReplaceAll(text, "\n", "\n  "))
+}
+
+// appendEntry appends an entry at the end of a note, creating the note if it
+// doesn't exist and adding tags to its tag line. It reports whether the note
+// was created.
+func appendEntry(fullPath, tags, entry string) (bool, error) {
+	content, err := os.ReadFile(fullPath)
+	created := os.IsNotExist(err)
+	switch {
+	case created:
+		if err := createNote(fullPath, tags, ""); err != nil {
+			return false, err
+		}
+		content, err = os.ReadFile(fullPath)
+		if err != nil {
+			return false, err
+		}
+	case err != nil:
+		return false, err
+	case strings.TrimSpace(tags) == "":
+	case strings.ToLower(filepath.Ext(fullPath)) == ".org":
+		content = []byte(withOrgTags(string(content), tags))
+	default:
+		content = withTags(content, tags)
+	}
+
+	text := string(content)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return created, os.WriteFile(fullPath, []byte(text+entry), 0644)
+}
+
+// withTags adds tags to the tag line of a note, writing one if the note has
+// none
+func withTags(content []byte, tags string) []byte {
+	if strings.TrimSpace(tags) == "" {
+		return content
+	}
+
+	existing, body := splitTagLine(string(content))
+	merged := mergeTags(formatTagsWithPlus(existing), formatTagsWithPlus(tags))
+	if merged == formatTagsWithPlus(existing) {
+		return content
+	}
+	firstLine, _, _ := strings.Cut(string(content), "\n")
+	return []byte("// " + merged + lineEnding(firstLine) + body)
+}
+
+// startAppend asks for a line to append to the selected note
+func (m model) startAppend() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	m.appending = item
+	m.appendInput.Reset()
+	m.appendInput.Focus()
+	m.mode = modeAppend
+	return m, textinput.Blink
+}
+
+func (m model) updateAppend(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "ctrl+c":
+			m.quitting = true
+			return m, tea.Quit
+		case "esc":
+			m.mode = modeList
+			return m, nil
+		case "enter":
+			text := m.appendInput.Value()
+			if strings.TrimSpace(text) == "" {
+				return m, nil
+			}
+			m.mode = modeList
+			item := m.appending
+			before, err := readForUndo(m.notesDir, item.filename)
+			if err == nil {
+				_, err = appendEntry(item.path, "", captureEntry(text, time.Now()))
+			}
+			if err != nil {
+				return m, m.setError("Cannot append to %s: %v", item.filename, describeError(err))
+			}
+			m.pushUndo("appending to "+item.filename, restoreNotes(m.notesDir, before))
+			done := "Appended to " + item.filename
+			return m, tea.Batch(m.reloadNotes(), m.setInfo("%s", done), m.noteSavedCmd("post_edit", item.filename, done))
+		}
+	}
+
+	var cmd tea.Cmd
+	m.appendInput, cmd = m.appendInput.Update(msg)
+	return m, cmd
+}
+
+func (m model) viewAppend() string {
+	prompt := fmt.Sprintf("Append a timestamped line to %s:", m.appending.Title())
+	return fmt.Sprintf("\n\n  %s\n\n  %s\n\n", prompt, m.appendInput.View()) + "  (press ESC to cancel)"
+}
+
+// parseInterspersed parses flags placed before, between or after the
+// positional arguments, and returns the positional arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+func runCapture(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("capture", flag.ContinueOnError)
+	tags := flags.String("tags", "", "tags to add to the inbox note, e.g. \"work todo\"")
+	note := flags.String("note", cfg.Inbox, "note to capture to, relative to the notes directory")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	text := strings.Join(positional, " ")
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("nothing to capture")
+	}
+
+	fullPath, err := notePath(notesDir, *note)
+	if err != nil {
+		return err
+	}
+	created, err := appendEntry(fullPath, *tags, captureEntry(text, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Captured to %s\n", fullPath)
+	return runAppendHook(notesDir, fullPath, created)
+}
+
+// notePath returns the path of a note named on the command line, relative to
+// the notes directory and with or without its extension
+func notePath(notesDir, name string) (string, error) {
+	filename, ok := cleanFolder(name)
+	if !ok || filename == "" {
+		return "", fmt.Errorf("note %s is outside of the notes directory", name)
+	}
+	return filepath.Join(notesDir, noteFilename(notesDir, filename)), nil
+}
+
+func runAppend(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("append", flag.ContinueOnError)
+	tags := flags.String("tags", "", "tags to add to the note, e.g. \"work todo\"")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("expected the note to append to")
+	}
+
+	text := strings.Join(positional[1:], " ")
+	// Read the text from stdin when it isn't given, e.g. piped from a command
+	if text == "" || text == "-" {
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("nothing to append, give the text as argument or on stdin")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read stdin: %v", err)
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("nothing to append")
+	}
+
+	fullPath, err := notePath(notesDir, positional[0])
+	if err != nil {
+		return err
+	}
+	created, err := appendEntry(fullPath, *tags, captureEntry(text, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	if created {
+		fmt.Printf("Created %s\n", fullPath)
+	} else {
+		fmt.Printf("Appended to %s\n", fullPath)
+	}
+	return runAppendHook(notesDir, fullPath, created)
+}
+
+// runAppendHook runs the hook of a note appended to, created or edited
+func runAppendHook(notesDir, fullPath string, created bool) error {
+	filename, err := filepath.Rel(notesDir, fullPath)
+	if err != nil {
+		return err
+	}
+	if created {
+		return noteSaved("post_create", notesDir, filename)
+	}
+	return noteSaved("post_edit", notesDir, filename)
+}
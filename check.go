@@ -0,0 +1,468 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// markdownLinkRegex matches a [markdown](link) or an ![image](link), capturing
+// the ! of images, the text and the destination, with an optional "title"
+var markdownLinkRegex = regexp.MustCompile(`(!?)\[([^\]]*)\]\(<?([^)\s>]+)>?(?:\s+"[^"]*")?\)`)
+
+// Kinds of the problems found by the checker
+const (
+	problemWikilink   = "broken wikilink"
+	problemLink       = "missing file"
+	problemAttachment = "orphaned attachment"
+)
+
+// problem is a broken link of a note, or an attachment no note links to
+type problem struct {
+	kind string
+	// filename of the note, or of the attachment, relative to the notes
+	// directory
+	filename string
+	// line of the link in the note, starting at 1
+	line int
+	// link is the link as written in the note, target what it links to
+	link   string
+	target string
+	// text is what's left of the link once removed
+	text string
+}
+
+func (p problem) String() string {
+	if p.kind == problemAttachment {
+		return fmt.Sprintf("%s: %s", p.filename, p.kind)
+	}
+	return fmt.Sprintf("%s:%d: %s %s", p.filename, p.line, p.kind, p.link)
+}
+
+// canCreate reports whether the missing target of a link can be created as a
+// note
+func (p problem) canCreate() bool {
+	switch p.kind {
+	case problemWikilink:
+		return true
+	case problemLink:
+		return isNoteFile(linkedFileName(p.target))
+	}
+	return false
+}
+
+// isExternalLink reports whether a link destination leaves the notes: URLs,
+// mail addresses and anchors of the note itself
+func isExternalLink(dest string) bool {
+	return strings.Contains(dest, "://") || strings.HasPrefix(dest, "#") ||
+		strings.HasPrefix(dest, "mailto:") || strings.HasPrefix(dest, "data:")
+}
+
+// linkedFile returns the path of the file a markdown link of a note targets,
+// without its anchor and query
+func linkedFile(notesDir, filename, dest string) string {
+	path, _ := resolveImage(notesDir, filename, linkedFileName(dest))
+	return filepath.Clean(path)
+}
+
+// checkNotes returns the broken links of the notes and the attachments none of
+// them links to. Links in code blocks are left out.
+func checkNotes(notesDir string, items []noteItem) ([]problem, error) {
+	idx := newNoteIndex(itemFilenames(items)).withAliases(items)
+
+	var problems []problem
+	linked := map[string]bool{}
+	for _, item := range items {
+		noteProblems, err := checkNote(notesDir, item, idx, linked)
+		if err != nil {
+			continue
+		}
+		problems = append(problems, noteProblems...)
+	}
+
+	attachments := filepath.Join(notesDir, attachmentsFolder)
+	err := filepath.WalkDir(attachments, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || linked[filepath.Clean(path)] {
+			return nil
+		}
+		rel, _ := filepath.Rel(notesDir, path)
+		problems = append(problems, problem{kind: problemAttachment, filename: rel})
+		return nil
+	})
+	return problems, err
+}
+
+// checkNote returns the broken links of a note, adding the files it links to
+// to linked
+func checkNote(notesDir string, item noteItem, idx noteIndex, linked map[string]bool) ([]problem, error) {
+	file, err := os.Open(item.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var problems []problem
+	fence := ""
+	scanner := newLineScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if match := fenceRegex.FindStringSubmatch(text); match != nil {
+			if fence == "" {
+				fence = match[1]
+			} else if strings.HasPrefix(strings.TrimSpace(text), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		for _, match := range wikilinkRegex.FindAllStringSubmatch(text, -1) {
+			target := strings.TrimSpace(match[1])
+			if target == "" {
+				continue
+			}
+			if _, ok := idx.resolve(target); ok {
+				continue
+			}
+			// Embedded files like ![[diagram.png]] are looked for next to the
+			// note, then in the notes directory and the attachments
+			if ext := filepath.Ext(target); ext != "" && !isNoteFile(target) {
+				if path, ok := findEmbedded(notesDir, item.filename, target); ok {
+					linked[path] = true
+					continue
+				}
+			}
+
+			label := target
+			if strings.HasPrefix(match[2], "|") {
+				label = match[2][1:]
+			}
+			problems = append(problems, problem{
+				kind: problemWikilink, filename: item.filename, line: line,
+				link: match[0], target: target, text: label,
+			})
+		}
+
+		for _, match := range markdownLinkRegex.FindAllStringSubmatch(text, -1) {
+			if isExternalLink(match[3]) {
+				continue
+			}
+			path := linkedFile(notesDir, item.filename, match[3])
+			if _, err := os.Stat(path); err == nil {
+				linked[path] = true
+				continue
+			}
+
+			p := problem{
+				kind: problemLink, filename: item.filename, line: line,
+				link: match[0], target: match[3], text: match[2],
+			}
+			// Removing an image leaves nothing
+			if match[1] == "!" {
+				p.text = ""
+			}
+			problems = append(problems, p)
+		}
+	}
+
+	return problems, scanError(scanner.Err())
+}
+
+// findEmbedded returns the path of a file embedded with a wikilink
+func findEmbedded(notesDir, filename, target string) (string, bool) {
+	for _, dir := range []string{filepath.Dir(filename), ".", attachmentsFolder} {
+		path := filepath.Clean(filepath.Join(notesDir, dir, filepath.FromSlash(target)))
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// removeLink replaces a broken link by its text in its note, failing if the
+// note changed since it was checked
+func removeLink(notesDir string, p problem) error {
+	path := filepath.Join(notesDir, p.filename)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if p.line > len(lines) || !strings.Contains(lines[p.line-1], p.link) {
+		return fmt.Errorf("the note changed, check again")
+	}
+	lines[p.line-1] = strings.Replace(lines[p.line-1], p.link, p.text, 1)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode())
+}
+
+// createLinkTarget creates the missing note a link targets
+func createLinkTarget(notesDir string, p problem) (string, error) {
+	target := p.target
+	if p.kind == problemLink {
+		target = filepath.ToSlash(filepath.Join(filepath.Dir(p.filename), linkedFileName(p.target)))
+	}
+	path, err := notePath(notesDir, target)
+	if err != nil {
+		return "", err
+	}
+	if err := createNote(path, "", ""); err != nil {
+		return "", err
+	}
+	filename, _ := filepath.Rel(notesDir, path)
+	return filename, nil
+}
+
+// linkedFileName returns the file of a markdown link destination, without its
+// anchor and escapes
+func linkedFileName(dest string) string {
+	dest, _, _ = strings.Cut(dest, "#")
+	dest, _, _ = strings.Cut(dest, "?")
+	if unescaped, err := url.PathUnescape(dest); err == nil {
+		dest = unescaped
+	}
+	return dest
+}
+
+func runCheck(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+
+	problems, err := checkNotes(notesDir, items)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+	return nil
+}
+
+// checkView lists the problems found by the checker
+type checkView struct {
+	problems []problem
+	cursor   int
+	// deleting is set while the deletion of an attachment is confirmed
+	deleting bool
+}
+
+// openCheck checks the notes and shows the problems found
+func (m model) openCheck() (tea.Model, tea.Cmd) {
+	problems, err := checkNotes(m.notesDir, m.items)
+	if err != nil {
+		return m, m.setError("Cannot check the notes: %v", describeError(err))
+	}
+	if len(problems) == 0 {
+		return m, m.setInfo("No broken links or orphaned attachments")
+	}
+
+	m.check = checkView{problems: problems}
+	m.mode = modeCheck
+	return m, nil
+}
+
+// fixed removes the problem under the cursor, going back to the list when
+// none are left
+func (m *model) fixed() {
+	v := &m.check
+	v.problems = append(v.problems[:v.cursor], v.problems[v.cursor+1:]...)
+	v.cursor = min(v.cursor, max(len(v.problems)-1, 0))
+	if len(v.problems) == 0 {
+		m.mode = modeList
+	}
+}
+
+func (m model) updateCheck(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	if m.check.deleting {
+		m.check.deleting = false
+		if keyMsg.String() != "y" {
+			return m, nil
+		}
+		p := m.check.problems[m.check.cursor]
+		return m.beforeDelete(p.filename, func(m model) (tea.Model, tea.Cmd) {
+			done, err := m.deleteFile(p.filename)
+			if err != nil {
+				return m, m.setError("Cannot delete %s: %v", p.filename, err)
+			}
+			// The cursor may have moved while the hook ran
+			for i, q := range m.check.problems {
+				if q == p {
+					m.check.cursor = i
+					m.fixed()
+					break
+				}
+			}
+			return m, m.setInfo("%s", done)
+		})
+	}
+
+	if len(m.check.problems) == 0 {
+		m.mode = modeList
+		return m, nil
+	}
+	p := m.check.problems[m.check.cursor]
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if m.check.cursor > 0 {
+			m.check.cursor--
+		}
+	case "down", "j":
+		if m.check.cursor < len(m.check.problems)-1 {
+			m.check.cursor++
+		}
+	case "enter":
+		if p.kind != problemAttachment {
+			return m, m.openNoteAt(p.filename, p.line)
+		}
+	case "n":
+		if !p.canCreate() {
+			return m, nil
+		}
+		filename, err := createLinkTarget(m.notesDir, p)
+		if err != nil {
+			return m, m.setError("Cannot create %s: %v", p.target, describeError(err))
+		}
+		// Other links to the new note are fixed too
+		filenames := append(itemFilenames(m.items), filename)
+		idx := newNoteIndex(filenames).withAliases(m.items)
+		var left []problem
+		for _, other := range m.check.problems {
+			if other.kind == problemWikilink {
+				if _, ok := idx.resolve(other.target); ok {
+					continue
+				}
+			}
+			if other == p {
+				continue
+			}
+			left = append(left, other)
+		}
+		m.check.problems = left
+		m.check.cursor = min(m.check.cursor, max(len(left)-1, 0))
+		if len(left) == 0 {
+			m.mode = modeList
+		}
+		done := "Created " + filename
+		return m, tea.Batch(m.reloadNotes(), m.setInfo("%s", done), m.noteSavedCmd("post_create", filename, done))
+	case "x":
+		if p.kind == problemAttachment {
+			return m, nil
+		}
+		if err := removeLink(m.notesDir, p); err != nil {
+			return m, m.setError("Cannot update %s: %v", p.filename, describeError(err))
+		}
+		m.fixed()
+		return m, m.setInfo("Removed %s from %s", p.link, p.filename)
+	case "d":
+		if p.kind == problemAttachment {
+			m.check.deleting = true
+		}
+	case "esc", "q", "L":
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+// itemFilenames returns the filenames of notes
+func itemFilenames(items []noteItem) []string {
+	filenames := make([]string, len(items))
+	for i, item := range items {
+		filenames[i] = item.filename
+	}
+	return filenames
+}
+
+func (m model) viewCheck() string {
+	var b strings.Builder
+	v := m.check
+	b.WriteString("\n" + titleStyle.Render(fmt.Sprintf("Problems (%d)", len(v.problems))) + "\n\n")
+
+	// Lines of the problems, with a header line for every file
+	var lines []string
+	cursorLine := 0
+	previous := ""
+	for i, p := range v.problems {
+		if p.filename != previous && p.kind != problemAttachment {
+			if previous != "" {
+				lines = append(lines, "")
+			}
+			lines = append(lines, itemStyle.Copy().PaddingLeft(2).Bold(true).Render(noteTitle(p.filename))+
+				"  "+mutedStyle.Render(p.filename))
+			previous = p.filename
+		}
+
+		line := fmt.Sprintf("%d: %s %s", p.line, p.link, mutedStyle.Render(p.kind))
+		if p.kind == problemAttachment {
+			if previous != problemAttachment {
+				lines = append(lines, "", itemStyle.Copy().PaddingLeft(2).Bold(true).Render("Orphaned attachments"))
+				previous = problemAttachment
+			}
+			line = p.filename
+		}
+		if i == v.cursor {
+			cursorLine = len(lines)
+			lines = append(lines, selectedItemStyle.Render("> "+line))
+		} else {
+			lines = append(lines, itemStyle.Render(line))
+		}
+	}
+
+	// Scroll to keep the cursor visible
+	b.WriteString(strings.Join(scrollLines(lines, cursorLine, max(m.height-6, 1)), "\n"))
+
+	help := "esc back"
+	if len(v.problems) > 0 {
+		switch p := v.problems[v.cursor]; {
+		case v.deleting:
+			help = fmt.Sprintf("Delete %s? y to confirm, any key to cancel", p.filename)
+		case p.kind == problemAttachment:
+			help = "d delete • esc back"
+		case p.canCreate():
+			help = "enter edit • n create note • x remove link • esc back"
+		default:
+			help = "enter edit • x remove link • esc back"
+		}
+	}
+	return m.withHelp(b.String(), help)
+}
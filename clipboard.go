@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// osc52 returns the escape sequence asking the terminal to copy a text, which
+// works over SSH too. Inside tmux it is passed through to the outer terminal.
+func osc52(text string) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		seq = "\x1bPtmux;\x1b" + seq + "\x1b\\"
+	}
+	return seq
+}
+
+// copyToClipboard copies a text to the clipboard of the platform and to the
+// one of the terminal, failing only when neither can be used
+func copyToClipboard(text string) error {
+	err := clipboard.WriteAll(text)
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		if _, termErr := os.Stdout.WriteString(osc52(text)); termErr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// openCopyMenu asks what to copy of the selected note
+func (m model) openCopyMenu() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	path, err := filepath.Abs(item.path)
+	if err != nil {
+		path = item.path
+	}
+	m.copyMenu = menu{
+		title: "Copy " + item.filename,
+		items: []menuItem{
+			{label: "Path", hint: path},
+			{label: "Title", hint: itemTitle(item)},
+			{label: "Contents"},
+		},
+	}
+	m.mode = modeCopy
+	return m, nil
+}
+
+// itemTitle returns the title of a note: its first heading, or its name
+func itemTitle(item noteItem) string {
+	_, body := splitTagLine(noteContents.get(item))
+	return documentTitle(item.filename, stripFrontMatter(body))
+}
+
+func (m model) updateCopy(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.copyMenu.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+	m.mode = modeList
+
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	var text, what string
+	switch m.copyMenu.cursor {
+	case 0:
+		text, what = m.copyMenu.items[0].hint, "the path"
+	case 1:
+		text, what = itemTitle(item), "the title"
+	case 2:
+		data, err := os.ReadFile(item.path)
+		if err != nil {
+			return m, m.setError("Cannot read %s: %v", item.filename, describeError(err))
+		}
+		text, what = string(data), "the contents"
+	}
+
+	if err := copyToClipboard(text); err != nil {
+		return m, m.setError("Cannot copy %s of %s: %v", what, item.filename, err)
+	}
+	lines := strings.Count(strings.TrimSuffix(text, "\n"), "\n") + 1
+	if lines > 1 {
+		return m, m.setInfo("Copied %s of %s, %d lines", what, item.filename, lines)
+	}
+	return m, m.setInfo("Copied %s", truncateWidth(fmt.Sprintf("%q", text), max(m.width-10, 20)))
+}
+
+// fileURL returns the file:// URL of a file, which file managers, mail and
+// chat apps take like a dragged file
+func fileURL(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths start with their drive
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// copyFileURL copies the file:// URL of the selected note
+func (m model) copyFileURL() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	text := fileURL(item.path)
+	if err := copyToClipboard(text); err != nil {
+		return m, m.setError("Cannot copy the URL of %s: %v", item.filename, err)
+	}
+	return m, m.setInfo("Copied %s", truncateWidth(text, max(m.width-10, 20)))
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// exCommand is a command of the : command line, like :tag +work
+type exCommand struct {
+	name    string
+	summary string
+	// complete returns the candidates of the arguments
+	complete func(m model) []string
+	run      func(m model, args []string) (tea.Model, tea.Cmd)
+}
+
+// exCommands returns the commands of the command line
+func exCommands() []exCommand {
+	return []exCommand{
+		{name: "new", summary: "create a note, with a title", run: exNew},
+		{name: "tag", summary: "add +tags to the note, or remove -tags", complete: exTagCandidates, run: exTag},
+		{name: "sort", summary: "sort the notes", complete: func(model) []string { return sortModes }, run: exSort},
+		{name: "group", summary: "group the notes by tag or folder, or none", complete: func(model) []string { return []string{"none", groupTag, groupFolder} }, run: exGroup},
+		{name: "delete", summary: "delete the note", run: exDelete},
+		{name: "shred", summary: "overwrite the note and its versions, then delete it", run: exShred},
+		{name: "print", summary: "print the note, or save its PDF with :print save", complete: func(model) []string { return []string{"save"} }, run: exPrint},
+		{name: "snippet", summary: "insert a snippet at the end of the note", complete: exSnippetCandidates, run: exSnippet},
+		{name: "meeting", summary: "create a meeting note, like :meeting 1:1 with Sam", run: exMeeting},
+		{name: "week", summary: "open the note of the week: this, last, next or -2", complete: exPeriodCandidates, run: exPeriod(periodWeek)},
+		{name: "month", summary: "open the note of the month: this, last, next or -2", complete: exPeriodCandidates, run: exPeriod(periodMonth)},
+		{name: "quarter", summary: "open the note of the quarter: this, last, next or -2", complete: exPeriodCandidates, run: exPeriod(periodQuarter)},
+		{name: "board", summary: "show the notes as cards in columns by tag, like :board tag:acme", run: func(m model, args []string) (tea.Model, tea.Cmd) {
+			return m.openBoard(strings.Join(args, " "))
+		}},
+		{name: "stats", summary: "show the numbers of the notes, the activity heatmap and the streaks", run: func(m model, _ []string) (tea.Model, tea.Cmd) {
+			return m.openStats()
+		}},
+		{name: "vault", summary: "switch to a vault", complete: exVaultCandidates, run: exVault},
+		{name: "run", summary: "run a command of the config on the note", complete: exRunCandidates, run: exRun},
+		{name: "undo", summary: "undo the last delete, move, tag edit or merge", run: func(m model, _ []string) (tea.Model, tea.Cmd) {
+			return m.undoLast()
+		}},
+		{name: "history", summary: "list the actions that can be undone", run: func(m model, _ []string) (tea.Model, tea.Cmd) {
+			return m.openUndoHistory()
+		}},
+		{name: "quit", summary: "quit snsm", run: func(m model, _ []string) (tea.Model, tea.Cmd) {
+			m.quitting = true
+			return m, tea.Quit
+		}},
+	}
+}
+
+// findExCommand returns the command with a name, or the only one it starts
+// like :so for :sort
+func findExCommand(name string) (exCommand, error) {
+	var found []exCommand
+	for _, c := range exCommands() {
+		if c.name == name {
+			return c, nil
+		}
+		if strings.HasPrefix(c.name, name) {
+			found = append(found, c)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return exCommand{}, fmt.Errorf("unknown command :%s", name)
+	case 1:
+		return found[0], nil
+	}
+	var names []string
+	for _, c := range found {
+		names = append(names, ":"+c.name)
+	}
+	return exCommand{}, fmt.Errorf(":%s is ambiguous: %s", name, strings.Join(names, ", "))
+}
+
+// commandLineView is the : command line
+type commandLineView struct {
+	input textinput.Model
+	// deleting is the note whose deletion is confirmed
+	deleting noteItem
+	// shredding tells that it is shredded rather than trashed
+	shredding bool
+}
+
+// openCommandLine shows the command line at the bottom of the list
+func (m model) openCommandLine() (tea.Model, tea.Cmd) {
+	m.cmdLine.deleting = noteItem{}
+	m.cmdLine.input.Reset()
+	m.cmdLine.input.Focus()
+	m.cmdLine.input.SetSuggestions(m.commandLineSuggestions(""))
+	m.mode = modeCommandLine
+	return m, textinput.Blink
+}
+
+// commandLineSuggestions completes the command line: the names of the
+// commands, then their arguments
+func (m model) commandLineSuggestions(line string) []string {
+	name, args, found := strings.Cut(line, " ")
+	if !found {
+		var names []string
+		for _, c := range exCommands() {
+			names = append(names, c.name)
+		}
+		return names
+	}
+
+	c, err := findExCommand(name)
+	if err != nil || c.complete == nil {
+		return nil
+	}
+	// Complete the last argument, keeping the previous ones
+	prefix := line[:len(line)-len(args)]
+	if i := strings.LastIndex(args, " "); i >= 0 {
+		prefix += args[:i+1]
+	}
+	var suggestions []string
+	for _, candidate := range c.complete(m) {
+		suggestions = append(suggestions, prefix+candidate)
+	}
+	return suggestions
+}
+
+func (m model) updateCommandLine(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		var cmd tea.Cmd
+		m.cmdLine.input, cmd = m.cmdLine.input.Update(msg)
+		return m, cmd
+	}
+
+	if item := m.cmdLine.deleting; item.filename != "" {
+		m.cmdLine.deleting = noteItem{}
+		m.mode = modeList
+		if keyMsg.String() != "y" {
+			return m, nil
+		}
+		shredding := m.cmdLine.shredding
+		return m.beforeDelete(item.filename, func(m model) (tea.Model, tea.Cmd) {
+			deleteFile := m.deleteFile
+			if shredding {
+				deleteFile = m.shredFile
+			}
+			done, err := deleteFile(item.filename)
+			if err != nil {
+				return m, m.setError("Cannot delete %s: %v", item.filename, err)
+			}
+			return m, tea.Batch(m.reloadNotes(), m.setInfo("%s", done))
+		})
+	}
+
+	switch keyMsg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc":
+		m.cmdLine.input.Blur()
+		m.mode = modeList
+		return m, nil
+	case "backspace":
+		// Leave like vim when erasing past the :
+		if m.cmdLine.input.Value() == "" {
+			m.cmdLine.input.Blur()
+			m.mode = modeList
+			return m, nil
+		}
+	case "enter":
+		m.cmdLine.input.Blur()
+		m.mode = modeList
+		fields := strings.Fields(m.cmdLine.input.Value())
+		if len(fields) == 0 {
+			return m, nil
+		}
+		c, err := findExCommand(strings.TrimPrefix(fields[0], ":"))
+		if err != nil {
+			return m, m.setError("%v", err)
+		}
+		return c.run(m, fields[1:])
+	}
+
+	var cmd tea.Cmd
+	m.cmdLine.input, cmd = m.cmdLine.input.Update(msg)
+	m.cmdLine.input.SetSuggestions(m.commandLineSuggestions(m.cmdLine.input.Value()))
+	return m, cmd
+}
+
+func (m model) viewCommandLine() string {
+	view := m.listView()
+	line := m.cmdLine.input.View()
+	if item := m.cmdLine.deleting; item.filename != "" && m.cmdLine.shredding {
+		line = statusErrorStyle.Render(fmt.Sprintf("Shred %s and its versions? It can't be undone, y to confirm, any key to cancel", item.filename))
+	} else if item.filename != "" {
+		line = statusErrorStyle.Render(fmt.Sprintf("Delete %s? y to confirm, any key to cancel", item.filename))
+	} else if c, err := findExCommand(strings.TrimSpace(m.cmdLine.input.Value())); err == nil && m.cmdLine.input.Value() != "" {
+		line += "  " + mutedStyle.Render(c.summary)
+	}
+
+	// Keep the command line on the last line of the screen, like the status
+	gap := max(m.height-strings.Count(view, "\n")-1, 1)
+	return view + strings.Repeat("\n", gap) + line
+}
+
+// selectedNote returns the selected note for a command, or an error
+func (m model) selectedNote(command string) (noteItem, error) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return noteItem{}, fmt.Errorf(":%s needs a selected note", command)
+	}
+	return item, nil
+}
+
+// exNew creates a note, its title given rather than asked
+func exNew(m model, args []string) (tea.Model, tea.Cmd) {
+	title := strings.Join(args, " ")
+	m, cmd := m.startNewNote()
+	if title != "" && m.mode == modeInput {
+		m.textInput.SetValue(title)
+		return m.nextStep()
+	}
+	return m, cmd
+}
+
+// exTagCandidates returns the tags of the notes, to add or remove
+func exTagCandidates(m model) []string {
+	seen := map[string]bool{}
+	for _, item := range m.items {
+		for _, tag := range strings.Fields(item.tags) {
+			seen[tag] = true
+		}
+	}
+	var tags []string
+	for tag := range seen {
+		tags = append(tags, tag, "-"+strings.TrimPrefix(tag, "+"))
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// exTag adds the +tags, or the bare ones, to the selected note and removes
+// the -tags
+func exTag(m model, args []string) (tea.Model, tea.Cmd) {
+	item, err := m.selectedNote("tag")
+	if err != nil {
+		return m, m.setError("%v", err)
+	}
+	if len(args) == 0 {
+		return m, m.setError(":tag needs +tags to add or -tags to remove")
+	}
+
+	var added, removed []string
+	for _, arg := range args {
+		if tag, ok := strings.CutPrefix(arg, "-"); ok {
+			removed = append(removed, strings.Fields(formatTagsWithPlus(tag))...)
+		} else {
+			added = append(added, arg)
+		}
+	}
+	tags := mergeTags(item.tags, formatTagsWithPlus(strings.Join(added, " ")))
+	var kept []string
+	for _, tag := range strings.Fields(tags) {
+		if !containsTag(removed, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	tags = strings.Join(kept, " ")
+	if tags == item.tags {
+		return m, m.setInfo("The tags of %s didn't change", item.filename)
+	}
+
+	if err := m.retag(item, tags); err != nil {
+		return m, m.setError("Cannot tag %s: %v", item.filename, describeError(err))
+	}
+	return m, tea.Batch(m.reloadNotes(), m.setInfo("Tagged %s %s", item.filename, tags), m.noteSavedCmd("post_edit", item.filename, "Tagged "+item.filename))
+}
+
+// exSort sorts the notes in a sort mode
+func exSort(m model, args []string) (tea.Model, tea.Cmd) {
+	if len(args) != 1 {
+		return m, m.setError(":sort needs one of %s", strings.Join(sortModes, ", "))
+	}
+	for _, mode := range sortModes {
+		if mode == args[0] {
+			return m.sortBy(mode)
+		}
+	}
+	return m, m.setError("Unknown sort %q, expected one of %s", args[0], strings.Join(sortModes, ", "))
+}
+
+// exDelete asks before deleting the selected note
+func exDelete(m model, _ []string) (tea.Model, tea.Cmd) {
+	item, err := m.selectedNote("delete")
+	if err != nil {
+		return m, m.setError("%v", err)
+	}
+	m.cmdLine.deleting, m.cmdLine.shredding = item, item.sensitive()
+	m.mode = modeCommandLine
+	return m, nil
+}
+
+// exShred asks before shredding the selected note
+func exShred(m model, _ []string) (tea.Model, tea.Cmd) {
+	item, err := m.selectedNote("shred")
+	if err != nil {
+		return m, m.setError("%v", err)
+	}
+	m.cmdLine.deleting, m.cmdLine.shredding = item, true
+	m.mode = modeCommandLine
+	return m, nil
+}
+
+// exPeriodCandidates returns the periods of the periodic notes commands
+func exPeriodCandidates(model) []string {
+	return []string{"this", "last", "next"}
+}
+
+// exVaultCandidates returns the names of the vaults of the config
+func exVaultCandidates(m model) []string {
+	var names []string
+	for _, v := range m.cfg.Vaults {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// exVault switches to a vault by name
+func exVault(m model, args []string) (tea.Model, tea.Cmd) {
+	if len(args) != 1 {
+		return m, m.setError(":vault needs the name of a vault")
+	}
+	v, ok := m.cfg.vault(args[0])
+	if !ok {
+		return m, m.setError("No vault named %s", args[0])
+	}
+	return m, m.switchVault(v)
+}
+
+// exRunCandidates returns the names of the commands of the config
+func exRunCandidates(m model) []string {
+	var names []string
+	for _, c := range m.cfg.Commands {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// exRun runs a command of the config on the selected note, like the palette
+func exRun(m model, args []string) (tea.Model, tea.Cmd) {
+	item, err := m.selectedNote("run")
+	if err != nil {
+		return m, m.setError("%v", err)
+	}
+	name := strings.Join(args, " ")
+	for _, c := range m.cfg.Commands {
+		if c.Name == name {
+			return m, m.runNoteCommand(c, item)
+		}
+	}
+	return m, m.setError("No command named %q in the config", name)
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// minColumnsWidth is the narrowest terminal the columns are shown in
+const minColumnsWidth = 90
+
+// columnsConfig configures the columns: the folders, the notes and the
+// preview of the selected note side by side
+type columnsConfig struct {
+	// Show shows the columns on startup
+	Show bool `yaml:"show"`
+	// Folders and Notes are the widths of their panes, in percent of the
+	// terminal, the preview taking the rest
+	Folders int `yaml:"folders"`
+	Notes   int `yaml:"notes"`
+}
+
+// Bounds of the widths of the panes, in percent
+const (
+	minPaneWidth    = 10
+	minPreviewShare = 20
+)
+
+// validate checks the widths of the panes
+func (c columnsConfig) validate() error {
+	if c.Folders < minPaneWidth || c.Notes < minPaneWidth || c.Folders+c.Notes > 100-minPreviewShare {
+		return fmt.Errorf("invalid columns widths %d and %d, expected at least %d%% each and %d%% left for the preview",
+			c.Folders, c.Notes, minPaneWidth, minPreviewShare)
+	}
+	return nil
+}
+
+// Panes of the columns
+const (
+	paneFolders = iota
+	paneNotes
+	panePreview
+)
+
+// columnsView is the state of the columns
+type columnsView struct {
+	shown bool
+	focus int
+	// folder is the folder whose notes are listed, all of them when empty
+	folder string
+	cursor int
+	// folders and notes are the widths of the panes, in percent
+	folders, notes int
+}
+
+// columnsShown reports whether the columns are displayed
+func (m model) columnsShown() bool {
+	return m.columns.shown && m.width >= minColumnsWidth
+}
+
+// folderPaneWidth returns the width of the folders pane, in cells
+func (m model) folderPaneWidth() int {
+	return m.width * m.columns.folders / 100
+}
+
+// toggleColumns shows or hides the columns, listing every note again when
+// they are hidden
+func (m model) toggleColumns() (tea.Model, tea.Cmd) {
+	m.columns.shown = !m.columns.shown
+	m.columns.focus = paneNotes
+	m.previewScroll = 0
+	m.layout()
+	if !m.columns.shown && m.columns.folder != "" {
+		return m.selectFolder("")
+	}
+	if m.columns.shown && m.width < minColumnsWidth {
+		return m, m.setError("The columns need a terminal %d columns wide", minColumnsWidth)
+	}
+	return m, nil
+}
+
+// noteFolders returns the folders of the notes and their parents, sorted,
+// with the number of notes each holds, subfolders included. The notes
+// directory is the empty folder, first.
+func noteFolders(items []noteItem) ([]string, map[string]int) {
+	counts := map[string]int{"": len(items)}
+	for _, item := range items {
+		folder := ""
+		for _, part := range splitFolder(filepath.Dir(item.filename)) {
+			folder = filepath.Join(folder, part)
+			counts[folder]++
+		}
+	}
+
+	folders := make([]string, 0, len(counts))
+	for folder := range counts {
+		folders = append(folders, folder)
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		return filepath.ToSlash(folders[i]) < filepath.ToSlash(folders[j])
+	})
+	return folders, counts
+}
+
+// inFolder reports whether a note is in a folder or one of its subfolders
+func inFolder(filename, folder string) bool {
+	return folder == "" || strings.HasPrefix(filename, folder+string(filepath.Separator))
+}
+
+// selectFolder lists the notes of a folder, keeping the selected note when
+// it's in the folder
+func (m model) selectFolder(folder string) (tea.Model, tea.Cmd) {
+	m.columns.folder = folder
+	folders, _ := noteFolders(m.items)
+	m.columns.cursor = 0
+	for i, f := range folders {
+		if f == folder {
+			m.columns.cursor = i
+		}
+	}
+
+	var selected string
+	if item, ok := m.list.SelectedItem().(noteItem); ok {
+		selected = item.filename
+	}
+	cmd := m.setListItems()
+	m.selectNote(selected)
+	return m, cmd
+}
+
+// moveFolderCursor moves the cursor of the folders pane, listing the notes
+// of the folder under it
+func (m model) moveFolderCursor(step int) (tea.Model, tea.Cmd) {
+	folders, _ := noteFolders(m.items)
+	cursor := min(max(m.columns.cursor+step, 0), len(folders)-1)
+	if cursor < 0 || folders[cursor] == m.columns.folder {
+		return m, nil
+	}
+	return m.selectFolder(folders[cursor])
+}
+
+// resizePane widens or narrows the focused pane, the preview giving or
+// taking the room, or the notes when the preview is focused
+func (m model) resizePane(step int) (tea.Model, tea.Cmd) {
+	c := &m.columns
+	switch c.focus {
+	case paneFolders:
+		c.folders += step
+	case paneNotes:
+		c.notes += step
+	case panePreview:
+		c.notes -= step
+	}
+	c.folders = max(c.folders, minPaneWidth)
+	c.notes = max(c.notes, minPaneWidth)
+	if extra := c.folders + c.notes - (100 - minPreviewShare); extra > 0 {
+		if c.focus == paneFolders {
+			c.folders -= extra
+		} else {
+			c.notes -= extra
+		}
+	}
+	m.layout()
+	return m, nil
+}
+
+// updateColumns handles the keys of the columns, the keys of the list being
+// handled by the list when the notes are focused. It reports whether it
+// handled the key.
+func (m model) updateColumns(msg tea.KeyMsg) (tea.Model, tea.Cmd, bool) {
+	switch msg.String() {
+	case "tab":
+		m.columns.focus = (m.columns.focus + 1) % 3
+		return m, nil, true
+	case "shift+tab":
+		m.columns.focus = (m.columns.focus + 2) % 3
+		return m, nil, true
+	case "<":
+		model, cmd := m.resizePane(-5)
+		return model, cmd, true
+	case ">":
+		model, cmd := m.resizePane(5)
+		return model, cmd, true
+	}
+
+	switch m.columns.focus {
+	case paneFolders:
+		switch msg.String() {
+		case "up", "k":
+			model, cmd := m.moveFolderCursor(-1)
+			return model, cmd, true
+		case "down", "j":
+			model, cmd := m.moveFolderCursor(1)
+			return model, cmd, true
+		case "home", "g":
+			model, cmd := m.moveFolderCursor(-len(m.items) - 1)
+			return model, cmd, true
+		case "end", "G":
+			model, cmd := m.moveFolderCursor(len(m.items) + 1)
+			return model, cmd, true
+		case "enter", "right", "l":
+			m.columns.focus = paneNotes
+			return m, nil, true
+		}
+
+	case panePreview:
+		switch msg.String() {
+		case "up", "k":
+			m.scrollPreview(-1)
+			return m, nil, true
+		case "down", "j":
+			m.scrollPreview(1)
+			return m, nil, true
+		case "pgup", "b":
+			m.scrollPreview(-(m.height - 2))
+			return m, nil, true
+		case "pgdown", "f", " ":
+			m.scrollPreview(m.height - 2)
+			return m, nil, true
+		case "left", "h":
+			m.columns.focus = paneNotes
+			return m, nil, true
+		}
+	}
+	return m, nil, false
+}
+
+// viewColumns shows the folders, the notes and the preview of the selected
+// note side by side
+func (m model) viewColumns() string {
+	height := m.height - 1
+	folderWidth := m.folderPaneWidth()
+	return lipgloss.JoinHorizontal(lipgloss.Top,
+		m.foldersPane(folderWidth, height),
+		m.listPane(),
+		m.previewPane(m.width-folderWidth-m.list.Width(), height, m.noteMetadata()))
+}
+
+// paneTitle renders the title of a pane, highlighted when it's focused
+func (m model) paneTitle(title string, pane int) string {
+	if m.columns.focus == pane {
+		return titleStyle.Copy().MarginLeft(0).Bold(true).Render(title)
+	}
+	return mutedStyle.Render(title)
+}
+
+// folderRows is the number of rows above the folders of the folders pane
+const folderRows = 2
+
+// folderOffset returns the index of the first folder shown in a pane of the
+// given height, keeping the cursor on screen
+func (m model) folderOffset(height int) int {
+	return max(m.columns.cursor-(height-folderRows)+1, 0)
+}
+
+// foldersPane renders the folders of the notes, indented by depth
+func (m model) foldersPane(width, height int) string {
+	style := lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, true, false, false).
+		BorderForeground(lipgloss.Color(currentTheme.Muted)).
+		PaddingLeft(2).
+		Width(width - 1).
+		Height(height).
+		MaxHeight(height)
+	// Room for the border and the padding
+	width -= 3
+
+	folders, counts := noteFolders(m.items)
+	lines := []string{m.paneTitle("Folders", paneFolders), ""}
+	offset := m.folderOffset(height)
+	for i, folder := range folders {
+		if i < offset || i >= offset+height-folderRows {
+			continue
+		}
+		name := "All notes"
+		if folder != "" {
+			name = strings.Repeat("  ", len(splitFolder(folder))-1) + filepath.Base(folder)
+		}
+		count := fmt.Sprintf(" %d", counts[folder])
+		line := truncateWidth(name, max(width-lipgloss.Width(count)-1, 1))
+		switch {
+		case folder == m.columns.folder && m.columns.focus == paneFolders:
+			line = selectedItemStyle.Copy().PaddingLeft(0).Bold(true).Render("▌"+line) + mutedStyle.Render(count)
+		case folder == m.columns.folder:
+			line = selectedItemStyle.Copy().PaddingLeft(0).Render(" "+line) + mutedStyle.Render(count)
+		default:
+			line = " " + line + mutedStyle.Render(count)
+		}
+		lines = append(lines, line)
+	}
+	return style.Render(strings.Join(lines, "\n"))
+}
+
+// noteMetadata returns the lines describing the selected note above its
+// preview: its folder, its dates and its aliases
+func (m model) noteMetadata() []string {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return nil
+	}
+	var lines []string
+	field := func(name, value string) {
+		lines = append(lines, mutedStyle.Render(fmt.Sprintf("%-9s", name))+value)
+	}
+	if folder := filepath.Dir(item.filename); folder != "." {
+		field("Folder", folder)
+	}
+	field("Modified", item.modTime.Format("2006-01-02 15:04"))
+	if !item.date.IsZero() {
+		field("Date", item.date.Format("2006-01-02"))
+	}
+	if !item.due.IsZero() {
+		field("Due", strings.TrimSpace(item.due.Format("2006-01-02")+" "+dueBadge(item.due, time.Now())))
+	}
+	if len(item.aliases) > 0 {
+		field("Aliases", strings.Join(item.aliases, ", "))
+	}
+	if item.label != "" {
+		field("Label", item.label)
+	}
+	return append([]string{m.paneTitle("Preview", panePreview)}, append(lines, "")...)
+}
+
+// folderAt returns the folder shown on row y of the folders pane
+func (m model) folderAt(y int) (string, bool) {
+	folders, _ := noteFolders(m.items)
+	i := y - folderRows + m.folderOffset(m.height-1)
+	if y < folderRows || i >= len(folders) {
+		return "", false
+	}
+	return folders[i], true
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// command is a subcommand of snsm, run instead of the notes picker
+type command struct {
+	usage   string
+	summary string
+	run     func(cfg config, notesDir string, args []string) error
+}
+
+var commands = map[string]command{
+	"agenda": {
+		usage:   "agenda [--days 7] [--notify]",
+		summary: "Print what's overdue and due in the next days, from @due(2024-06-01) markers and due: front matter",
+		run:     runAgenda,
+	},
+	"append": {
+		usage:   "append [--tags TAGS] NOTE [TEXT]",
+		summary: "Append a timestamped line to a note, read from stdin without TEXT, creating the note if missing",
+		run:     runAppend,
+	},
+	"assist": {
+		usage:   "assist [--yes] summary|title|tags NOTE",
+		summary: "Ask the assistant of the config for a summary, a title or tags for a note, and write them once confirmed",
+		run:     runAssist,
+	},
+	"backup": {
+		usage:   "backup [--dir DIR] [--format tar.gz|zip] [--keep N] [--list]",
+		summary: "Archive the notes into the backup directory, keeping the last 10 backups",
+		run:     runBackup,
+	},
+	"capture": {
+		usage:   "capture [--tags TAGS] [--note NOTE] TEXT",
+		summary: "Append a timestamped line to the inbox note, without opening the picker",
+		run:     runCapture,
+	},
+	"check": {
+		usage:   "check",
+		summary: "Report broken wikilinks, links to missing files and orphaned attachments",
+		run:     runCheck,
+	},
+	"completion": {
+		usage:   "completion bash|zsh|fish",
+		summary: "Print the shell completion of the commands, notes and tags, e.g. eval \"$(snsm completion bash)\"",
+		run:     runCompletion,
+	},
+	"dedupe": {
+		usage:   "dedupe [--threshold 0.8]",
+		summary: "List the pairs of notes with identical or near-identical content",
+		run:     runDedupe,
+	},
+	"diff": {
+		usage:   "diff NOTE [OTHER]",
+		summary: "Print the changes between two notes, or between a note and its last saved version",
+		run:     runDiff,
+	},
+	"export": {
+		usage:   "export [--format html|pdf] [--out DIR] [--css FILE] [--pdf-engine NAME] note...",
+		summary: "Export notes to standalone HTML or PDF files, to share them",
+		run:     runExport,
+	},
+	"feed": {
+		usage:   "feed [--tag TAG] [--query QUERY] [--out FILE] [--url URL]",
+		summary: "Write an Atom feed of the last modified notes matching a tag or a search, e.g. a microblog",
+		run:     runFeed,
+	},
+	"import": {
+		usage:   "import --from obsidian|notion|evernote [--folder DIR] [--dry-run] PATH",
+		summary: "Import an Obsidian vault, a Notion export (folder or zip) or Evernote .enex files",
+		run:     runImport,
+	},
+	"link": {
+		usage:   "link [--title] [QUERY]",
+		summary: "Pick a note and print a [[wikilink]] to it, for editors to insert",
+		run:     runLink,
+	},
+	"meeting": {
+		usage:   "meeting [--with \"Sam, Alex\"] [--tags TAGS] [--no-open] TITLE",
+		summary: "Create a meeting note with its date and attendees, linked from today's daily note",
+		run:     runMeeting,
+	},
+	"print": {
+		usage:   "print [--save] [--out DIR] [--page-size A4] [--margin 20mm] note...",
+		summary: "Print notes as PDFs laid out for paper with lpr, or save the PDFs with --save",
+		run:     runPrint,
+	},
+	"open": {
+		usage:   "open NOTE",
+		summary: "Open a note in $EDITOR",
+		run:     runOpen,
+	},
+	"paste": {
+		usage:   "paste [--folder DIR] [--tags TAGS]",
+		summary: "Create a note from the clipboard: bookmarks for URLs, fenced JSON, titled prose or images",
+		run:     runPaste,
+	},
+	"publish": {
+		usage:   "publish [--out DIR] [--css FILE]",
+		summary: "Publish every note as a static site with an index and tag pages, e.g. for GitHub Pages",
+		run:     runPublish,
+	},
+	"pull": {
+		usage:   "pull [--remote USER@HOST:PATH] [--dry-run] [--yes]",
+		summary: "Copy the notes from the remote with rsync, asking before overwriting notes",
+		run:     runPull,
+	},
+	"push": {
+		usage:   "push [--remote USER@HOST:PATH] [--dry-run] [--yes]",
+		summary: "Copy the notes to the remote with rsync, asking before overwriting notes",
+		run:     runPush,
+	},
+	"random": {
+		usage:   "random [--tag TAG] [--print]",
+		summary: "Open a random note, optionally with a tag, to resurface old ideas",
+		run:     runRandom,
+	},
+	"render": {
+		usage:   "render [--watch] [--out DIR] [--serve ADDR [--pprof]] [note...]",
+		summary: "Render notes to HTML, continuously with --watch",
+		run:     runRender,
+	},
+	"replace": {
+		usage:   "replace --pattern REGEX --with TEXT [--tag TAG] [--dry-run] [--yes]",
+		summary: "Replace a pattern across notes, confirming the changes of every note",
+		run:     runReplace,
+	},
+	"search": {
+		usage:   "search [-l] [--semantic TEXT] QUERY",
+		summary: "Print the notes matching a query, e.g. tag:work -tag:done \"exact phrase\"",
+		run:     runSearch,
+	},
+	"serve": {
+		usage:   "serve [--addr localhost:8080] [--edit]",
+		summary: "Serve the notes to a browser, with search, read-only unless --edit is given",
+		run:     runServe,
+	},
+	"share": {
+		usage:   "share [--service gist|paste] [--yes] NOTE",
+		summary: "Upload a note as a secret gist or to a paste service, and copy its URL to the clipboard",
+		run:     runShare,
+	},
+	"sync": {
+		usage:   "sync",
+		summary: "Commit the changes of the notes, pull them with a rebase and push them with git",
+		run:     runSync,
+	},
+	"sync-storage": {
+		usage:   "sync-storage [--dry-run]",
+		summary: "Sync the notes both ways with an S3 bucket or a WebDAV folder, like Nextcloud",
+		run:     runSyncStorage,
+	},
+	"sync-tasks": {
+		usage:   "sync-tasks [--todo-txt FILE] [--taskwarrior]",
+		summary: "Sync the tasks of the notes with a todo.txt file or Taskwarrior, both ways",
+		run:     runSyncTasks,
+	},
+	"sync-tags": {
+		usage:   "sync-tags",
+		summary: "Mirror the tags of the notes to the file manager (Finder tags, user.xdg.tags)",
+		run:     runSyncTags,
+	},
+	"tidy": {
+		usage:   "tidy [--dry-run] [--yes]",
+		summary: "Move the notes matching the tidy rules of the config to their folder, like done notes to an archive",
+		run:     runTidy,
+	},
+}
+
+// runCommand runs a subcommand and returns the exit code of the program
+func runCommand(cfg config, name string, args []string) int {
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return 0
+	}
+
+	cmd, ok := commands[name]
+	if !ok && name != "__complete" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage()
+		return 2
+	}
+
+	vaultName, notesDir, err := resolveVault(cfg, loadState())
+	if err != nil {
+		debugLog.Error("vault", "err", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if v, ok := cfg.vault(vaultName); ok && v.Encrypted {
+		fmt.Fprintf(os.Stderr, "Error: vault %s is encrypted, its notes are only decrypted by the list\n", v.Name)
+		return 1
+	}
+	if _, err := os.Stat(notesDir); err != nil {
+		debugLog.Error("vault", "dir", notesDir, "err", err)
+		fmt.Fprintf(os.Stderr, "Error: cannot open notes directory: %v\n", err)
+		return 1
+	}
+
+	if name == "__complete" {
+		// Called by the completion scripts, hidden from the usage
+		for _, candidate := range completeArgs(notesDir, args) {
+			fmt.Println(candidate)
+		}
+		return 0
+	}
+
+	if err := cmd.run(cfg, notesDir, args); err != nil {
+		debugLog.Error("command", "command", name, "args", args, "err", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: snsm [--trace-startup] [--timings] [--profile cpu|mem|trace] [--debug] [--format md|org] [--popup] [command]")
+	fmt.Fprintln(os.Stderr, "\nWithout a command, snsm opens the notes picker.")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  snsm %s\n      %s\n", commands[name].usage, commands[name].summary)
+	}
+}
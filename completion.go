@@ -0,0 +1,204 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// editNote opens a note in $EDITOR from the command line, like from the
+// list
+func editNote(notesDir, filename string) error {
+	path := filepath.Join(notesDir, filename)
+	if lock, ok := heldLock(notesDir, filename); ok {
+		if !askForConfirmation(fmt.Sprintf("%s is open in %s. Edit it anyway?", filename, lock.describe())) {
+			return fmt.Errorf("%s is open in %s", filename, lock.describe())
+		}
+	}
+	cmd, err := editorCmd(path, 0)
+	if err != nil {
+		return err
+	}
+	lockNote(notesDir, filename)
+	defer unlockNote(notesDir, filename)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// Not remembering the opening or saving a version doesn't prevent
+	// editing
+	loadHistory().record(notesDir, filename, time.Now())
+	saveVersion(notesDir, filename, time.Now())
+	before, _ := os.Stat(path)
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	if after, err := os.Stat(path); err == nil && before != nil && after.ModTime() != before.ModTime() {
+		return noteSaved("post_edit", notesDir, filename)
+	}
+	return nil
+}
+
+func runOpen(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("open", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected the note to open")
+	}
+
+	path, err := notePath(notesDir, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	filename, _ := filepath.Rel(notesDir, path)
+	return editNote(notesDir, filename)
+}
+
+// The flags documented in the usages, and their values when they are a
+// choice like --format html|pdf
+var (
+	usageFlag    = regexp.MustCompile(`--[a-z-]+`)
+	usageChoices = regexp.MustCompile(`(--[a-z-]+) ([a-z.]+(?:\|[a-z.]+)+)`)
+	usageOptions = regexp.MustCompile(`\[--[^\]]*\]`)
+)
+
+// takesNotes reports whether a command is given notes, from its usage with
+// the options left out
+func takesNotes(usage string) bool {
+	positional := usageOptions.ReplaceAllString(usage, "")
+	return strings.Contains(positional, "NOTE") || strings.Contains(positional, "note...")
+}
+
+// completeArgs returns the completions of the last of the arguments given to
+// snsm: commands, flags, notes or tags
+func completeArgs(notesDir string, args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	current := args[len(args)-1]
+
+	var candidates []string
+	if len(args) == 1 {
+		for name := range commands {
+			candidates = append(candidates, name)
+		}
+		candidates = append(candidates, "help")
+	} else if cmd, ok := commands[args[0]]; ok {
+		previous := args[len(args)-2]
+		switch {
+		case previous == "--tag" || previous == "--tags":
+			candidates = completionTags(notesDir)
+		case previous == "--note":
+			candidates = completionNotes(notesDir)
+		case strings.HasPrefix(previous, "--") && choices(cmd.usage, previous) != nil:
+			candidates = choices(cmd.usage, previous)
+		case strings.HasPrefix(current, "-"):
+			candidates = usageFlag.FindAllString(cmd.usage, -1)
+		case args[0] == "completion":
+			candidates = []string{"bash", "zsh", "fish"}
+		case takesNotes(cmd.usage):
+			candidates = completionNotes(notesDir)
+		}
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) && !containsTag(matches, candidate) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// choices returns the values a flag can take according to a usage
+func choices(usage, flag string) []string {
+	for _, match := range usageChoices.FindAllStringSubmatch(usage, -1) {
+		if match[1] == flag {
+			return strings.Split(match[2], "|")
+		}
+	}
+	return nil
+}
+
+// completionNotes returns the names of the notes, without their extension
+func completionNotes(notesDir string) []string {
+	var names []string
+	walkNotes(notesDir, func(path, filename string, entry os.DirEntry) error {
+		names = append(names, filepath.ToSlash(trimNoteExt(filename)))
+		return nil
+	}, nil)
+	return names
+}
+
+// completionTags returns the tags of the notes, without their +
+func completionTags(notesDir string) []string {
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return nil
+	}
+	var tags []string
+	for _, item := range items {
+		for _, tag := range strings.Fields(item.allTags()) {
+			tags = append(tags, strings.TrimPrefix(tag, "+"))
+		}
+	}
+	return tags
+}
+
+// The completion scripts ask snsm __complete for the completions of the
+// words typed so far
+const bashCompletion = `# snsm completion for bash, add to ~/.bashrc:
+#   eval "$(snsm completion bash)"
+_snsm() {
+	local IFS=$'\n'
+	COMPREPLY=($(snsm __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _snsm snsm
+`
+
+const zshCompletion = `#compdef snsm
+# snsm completion for zsh, add to ~/.zshrc:
+#   eval "$(snsm completion zsh)"
+_snsm() {
+	local -a candidates
+	candidates=(${(f)"$(snsm __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	if (( ${#candidates} )); then
+		compadd -Q -- $candidates
+	else
+		_files
+	fi
+}
+compdef _snsm snsm
+`
+
+const fishCompletion = `# snsm completion for fish, add to ~/.config/fish/config.fish:
+#   snsm completion fish | source
+complete -c snsm -f -a '(snsm __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+`
+
+func runCompletion(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("completion", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	switch flags.Arg(0) {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		return fmt.Errorf("expected the shell: bash, zsh or fish")
+	}
+	return nil
+}
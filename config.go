@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config holds the user settings read from the config file
+type config struct {
+	// Theme is one of "auto", "dark", "light" or "high-contrast"
+	Theme string `yaml:"theme"`
+	// Colors overrides individual colors of the selected theme
+	Colors theme `yaml:"colors"`
+	// Vaults are the notes directories to switch between, the first one
+	// being opened by default
+	Vaults []vault `yaml:"vaults"`
+	// Searches are the saved searches of the ctrl+f menu
+	Searches []savedSearch `yaml:"searches"`
+	// Mouse enables clicking and scrolling in the list
+	Mouse bool `yaml:"mouse"`
+	// QuitAfterEdit quits once the editor exits rather than coming back to
+	// the list
+	QuitAfterEdit bool `yaml:"quit_after_edit"`
+	// OpenMarked are the arguments of $EDITOR before the marked notes opened
+	// together, like -p to open them in the tabs of vim
+	OpenMarked string `yaml:"open_marked"`
+	// Sort is the default order of the notes: name, modified, frecency, due
+	// or date
+	Sort string `yaml:"sort"`
+	// StaleAfter is how long a note stays unmodified before it is dimmed in
+	// the list and listed by the Stale view, like 1y (default) or 26w, or off
+	StaleAfter string `yaml:"stale_after"`
+	// Preview shows the selected note next to the list on startup
+	Preview bool `yaml:"preview"`
+	// ImagePreview is the graphics protocol drawing the images of the
+	// viewer: auto, kitty, iterm2, sixel or off
+	ImagePreview string `yaml:"image_preview"`
+	// FileManagerTags mirrors the tags of the notes to the Finder tags on
+	// macOS and to the user.xdg.tags attribute on Linux
+	FileManagerTags bool `yaml:"file_manager_tags"`
+	// Extensions are the extensions of the files listed as notes, .md by
+	// default
+	Extensions []string `yaml:"extensions"`
+	// List configures the layout of the notes in the list
+	List listConfig `yaml:"list"`
+	// Title sets the title of the list and of the terminal window
+	Title titleConfig `yaml:"title"`
+	// Columns configures the columns of the folders, the notes and the
+	// preview
+	Columns columnsConfig `yaml:"columns"`
+	// HeadingTitles displays the notes by their first heading, their
+	// filename shown next to it
+	HeadingTitles bool `yaml:"heading_titles"`
+	// TemplatesDir holds the note templates, defaults to <notes>/.templates
+	TemplatesDir string `yaml:"templates_dir"`
+	// SnippetsDir holds the snippets inserted into notes, defaults to
+	// <notes>/.snippets
+	SnippetsDir string `yaml:"snippets_dir"`
+	// Inbox is the note snsm capture appends to, relative to the notes
+	// directory, %t being replaced by the current date
+	Inbox string `yaml:"inbox"`
+	// DailyNotes is the filename of the daily notes of the calendar,
+	// relative to the notes directory, %t being replaced by their date
+	DailyNotes string `yaml:"daily_notes"`
+	// Board configures the board of the notes by tag
+	Board boardConfig `yaml:"board"`
+	// Meetings configures snsm meeting
+	Meetings meetingsConfig `yaml:"meetings"`
+	// PeriodicNotes configures the notes of the weeks, months and quarters,
+	// and the template of the daily notes
+	PeriodicNotes periodicConfig `yaml:"periodic_notes"`
+	// NewNote configures the prompts of the new note flow
+	NewNote newNoteConfig `yaml:"new_note"`
+	// Export configures the export of notes to HTML or PDF
+	Export exportConfig `yaml:"export"`
+	// Print configures the printing of notes, on paper or to PDF
+	Print printConfig `yaml:"print"`
+	// Remote is where snsm push and snsm pull copy the notes with rsync,
+	// like user@host:notes
+	Remote string `yaml:"remote"`
+	// TaskSync configures where snsm sync-tasks copies the tasks
+	TaskSync taskSyncConfig `yaml:"task_sync"`
+	// Storage is the S3 bucket or WebDAV folder snsm sync-storage syncs the
+	// notes with
+	Storage storageConfig `yaml:"storage"`
+	// Tidy moves notes out of the way with snsm tidy, like the ones done
+	// for a month to an archive folder
+	Tidy tidyConfig `yaml:"tidy"`
+	// Backup configures snsm backup and the backups made before notes are
+	// deleted, replaced or merged
+	Backup backupConfig `yaml:"backup"`
+	// Feed sets the defaults of snsm feed
+	Feed feedConfig `yaml:"feed"`
+	// Share sets where notes are uploaded to be shared
+	Share shareConfig `yaml:"share"`
+	// Serve sets the login of snsm serve
+	Serve serveConfig `yaml:"serve"`
+	// Hooks run commands when notes are created, edited or deleted
+	Hooks hooksConfig `yaml:"hooks"`
+	// Commands are run on the selected note from the command palette
+	Commands []noteCommand `yaml:"commands"`
+	// OpenWith are the applications of the open with menu, after $EDITOR,
+	// the browser and PDF, glow and VS Code when installed by default
+	OpenWith []noteCommand `yaml:"open_with"`
+	// TagRules normalize the tags, folding their case and replacing
+	// aliases
+	TagRules tagRulesConfig `yaml:"tag_rules"`
+	// Embeddings sets the model of the semantic search
+	Embeddings embeddingsConfig `yaml:"embeddings"`
+	// Assistant sets the language model summarizing notes, suggesting
+	// titles and proposing tags
+	Assistant assistantConfig `yaml:"assistant"`
+}
+
+// newNoteConfig lists the prompts shown when creating a note, in order.
+// Steps left out are not prompted for and take their default value.
+type newNoteConfig struct {
+	Steps    []string        `yaml:"steps"`
+	Defaults newNoteDefaults `yaml:"defaults"`
+	// Format is the format of the new notes: md (default) or org
+	Format string `yaml:"format"`
+	// FilenameStyle is how filenames are made from the typed titles: kebab,
+	// snake or spaces (default)
+	FilenameStyle string `yaml:"filename_style"`
+}
+
+type newNoteDefaults struct {
+	Filename string `yaml:"filename"`
+	Tags     string `yaml:"tags"`
+	Template string `yaml:"template"`
+	Folder   string `yaml:"folder"`
+}
+
+// Names of the steps of the new note flow
+const (
+	stepFilename = "filename"
+	stepTags     = "tags"
+	stepTemplate = "template"
+	stepFolder   = "folder"
+)
+
+// configPath returns the location of the config file, honoring SNSM_CONFIG
+func configPath() string {
+	if path := os.Getenv("SNSM_CONFIG"); path != "" {
+		return expandTilde(path)
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return expandTilde("~/.config/snsm/config.yaml")
+	}
+	return filepath.Join(configDir, "snsm", "config.yaml")
+}
+
+// loadConfig reads the config file. A missing file is not an error, the
+// defaults are returned instead.
+func loadConfig() (config, error) {
+	cfg := config{
+		Theme:      "auto",
+		Mouse:      true,
+		Inbox:      "inbox",
+		StaleAfter: defaultStaleAfter,
+		DailyNotes: "%t",
+		Meetings:   meetingsConfig{Folder: "meetings", Tags: "meeting"},
+		Board:      boardConfig{Columns: tagList{"todo", "doing", "done"}},
+		Columns:    columnsConfig{Folders: 20, Notes: 35},
+		PeriodicNotes: periodicConfig{
+			Weekly:    periodicNoteConfig{Path: "%t"},
+			Monthly:   periodicNoteConfig{Path: "%t"},
+			Quarterly: periodicNoteConfig{Path: "%t"},
+		},
+		Backup: backupConfig{Keep: 10, Auto: true},
+		Print:  printConfig{PageSize: "A4", Margin: "20mm"},
+		NewNote: newNoteConfig{
+			Steps:    []string{stepFilename, stepTags},
+			Defaults: newNoteDefaults{Filename: "%t"},
+		},
+	}
+
+	data, err := os.ReadFile(configPath())
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %v", configPath(), err)
+	}
+
+	return cfg, cfg.validate()
+}
+
+// validate checks the values that can't be checked by the yaml decoder
+func (cfg config) validate() error {
+	for _, step := range cfg.NewNote.Steps {
+		switch step {
+		case stepFilename, stepTags, stepTemplate, stepFolder:
+		default:
+			return fmt.Errorf("unknown new_note step %q", step)
+		}
+	}
+
+	for _, v := range cfg.Vaults {
+		if v.Name == "" || v.Path == "" {
+			return fmt.Errorf("vaults need both a name and a path")
+		}
+	}
+
+	for _, search := range cfg.Searches {
+		if search.Name == "" {
+			return fmt.Errorf("saved searches need a name")
+		}
+		if _, err := parseQuery(search.Query); err != nil {
+			return fmt.Errorf("invalid saved search %s: %v", search.Name, err)
+		}
+		switch search.Sort {
+		case "", sortName, sortModified, sortFrecency, sortDue, sortDate:
+		default:
+			return fmt.Errorf("invalid saved search %s: unknown sort %q", search.Name, search.Sort)
+		}
+	}
+
+	switch cfg.Sort {
+	case "", sortName, sortModified, sortFrecency, sortDue, sortDate:
+	default:
+		return fmt.Errorf("unknown sort %q, expected name, modified, frecency, due or date", cfg.Sort)
+	}
+
+	if _, err := parseStaleAfter(cfg.StaleAfter); err != nil {
+		return fmt.Errorf("invalid stale_after %q: %v", cfg.StaleAfter, err)
+	}
+
+	switch cfg.ImagePreview {
+	case "", "auto", imagesKitty, imagesITerm2, imagesSixel, imagesOff:
+	default:
+		return fmt.Errorf("unknown image_preview %q, expected auto, kitty, iterm2, sixel or off", cfg.ImagePreview)
+	}
+
+	switch cfg.Export.Format {
+	case "", "html", "pdf":
+	default:
+		return fmt.Errorf("unknown export format %q, expected html or pdf", cfg.Export.Format)
+	}
+
+	switch cfg.Backup.Format {
+	case "", "tar.gz", "zip":
+	default:
+		return fmt.Errorf("unknown backup format %q, expected tar.gz or zip", cfg.Backup.Format)
+	}
+
+	for _, c := range cfg.Commands {
+		if c.Name == "" || c.Run == "" {
+			return fmt.Errorf("commands need a name and a command line to run")
+		}
+	}
+	for _, app := range cfg.OpenWith {
+		if app.Name == "" || app.Run == "" {
+			return fmt.Errorf("open_with applications need a name and a command line to run")
+		}
+	}
+
+	if err := cfg.TagRules.validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Embeddings.validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Assistant.validate(); err != nil {
+		return err
+	}
+
+	switch cfg.Share.Service {
+	case "", "gist", "paste":
+	default:
+		return fmt.Errorf("unknown share service %q, expected gist or paste", cfg.Share.Service)
+	}
+
+	switch cfg.Storage.Type {
+	case "", "s3", "webdav":
+	default:
+		return fmt.Errorf("unknown storage type %q, expected s3 or webdav", cfg.Storage.Type)
+	}
+
+	switch cfg.NewNote.Format {
+	case "", "md":
+	case "org":
+		if len(cfg.Extensions) > 0 && !containsTag(cfg.Extensions, ".org") && !containsTag(cfg.Extensions, "org") {
+			return fmt.Errorf("new_note.format org needs .org in extensions")
+		}
+	default:
+		return fmt.Errorf("unknown new_note.format %q, expected md or org", cfg.NewNote.Format)
+	}
+
+	switch cfg.NewNote.FilenameStyle {
+	case "", slugKebab, slugSnake, slugSpaces:
+	default:
+		return fmt.Errorf("unknown new_note.filename_style %q, expected kebab, snake or spaces", cfg.NewNote.FilenameStyle)
+	}
+
+	if cfg.NewNote.Defaults.Filename == "" {
+		return fmt.Errorf("new_note.defaults.filename can't be empty")
+	}
+
+	for _, ext := range cfg.Extensions {
+		if strings.Trim(ext, ". ") == "" {
+			return fmt.Errorf("extensions can't be empty")
+		}
+	}
+
+	if cfg.Inbox == "" {
+		return fmt.Errorf("inbox can't be empty")
+	}
+
+	if strings.Count(cfg.DailyNotes, "%t") != 1 {
+		return fmt.Errorf("daily_notes needs %%t once, replaced by the date")
+	}
+	if _, err := newItemLayout(cfg.List); err != nil {
+		return err
+	}
+	if err := cfg.Tidy.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Columns.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Board.validate(); err != nil {
+		return err
+	}
+	if err := cfg.PeriodicNotes.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// conflictCopyPatterns match the names, without extension, of the copies sync
+// tools keep when a note changed on two devices, capturing the name of the
+// original note
+var conflictCopyPatterns = []*regexp.Regexp{
+	// Dropbox and Nextcloud: "note (conflicted copy 2024-01-12)", "note (Ann's
+	// conflicted copy 2024-01-12)"
+	regexp.MustCompile(`^(.+?) \([^()]*[Cc]onflicted copy[^()]*\)$`),
+	// Syncthing: "note.sync-conflict-20240112-101500-ABCDEFG"
+	regexp.MustCompile(`^(.+?)\.sync-conflict-\d{8}-\d{6}(?:-[A-Z0-9]+)?$`),
+	// snsm sync-storage: "note (conflict 2024-01-12 101500)"
+	regexp.MustCompile(`^(.+?) \(conflict \d{4}-\d{2}-\d{2} \d{6}\)$`),
+}
+
+// conflictOriginal returns the filename of the note a conflict copy was made
+// of, if filename is one
+func conflictOriginal(filename string) (string, bool) {
+	ext := path.Ext(filename)
+	stem := strings.TrimSuffix(filename, ext)
+	dir, base := path.Split(stem)
+	for _, pattern := range conflictCopyPatterns {
+		if match := pattern.FindStringSubmatch(base); match != nil {
+			return dir + match[1] + ext, true
+		}
+	}
+	return "", false
+}
+
+// conflictGroup is a note with its conflict copies. The original may be
+// missing, deleted on one device while edited on another.
+type conflictGroup struct {
+	original string
+	copies   []noteItem
+}
+
+// findConflictCopies groups the conflict copies among the notes by original,
+// sorted by filename
+func findConflictCopies(items []noteItem) []conflictGroup {
+	byOriginal := map[string]*conflictGroup{}
+	var groups []*conflictGroup
+	for _, item := range items {
+		original, ok := conflictOriginal(item.filename)
+		if !ok {
+			continue
+		}
+		group := byOriginal[original]
+		if group == nil {
+			group = &conflictGroup{original: original}
+			byOriginal[original] = group
+			groups = append(groups, group)
+		}
+		group.copies = append(group.copies, item)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].original < groups[j].original
+	})
+	var sorted []conflictGroup
+	for _, group := range groups {
+		sort.Slice(group.copies, func(i, j int) bool {
+			return group.copies[i].modTime.Before(group.copies[j].modTime)
+		})
+		sorted = append(sorted, *group)
+	}
+	return sorted
+}
+
+// mergeWithMarkers joins two versions of a note, the lines they differ on
+// being kept between conflict markers like git's
+func mergeWithMarkers(mine, theirs []string, theirsName string) string {
+	var b strings.Builder
+	var removed, added []string
+	flush := func() {
+		if len(removed) == 0 && len(added) == 0 {
+			return
+		}
+		b.WriteString("<<<<<<< mine\n")
+		for _, line := range removed {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("=======\n")
+		for _, line := range added {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString(">>>>>>> " + theirsName + "\n")
+		removed, added = nil, nil
+	}
+
+	for _, line := range diffLines(mine, theirs) {
+		switch line.kind {
+		case diffRemoved:
+			removed = append(removed, line.text)
+		case diffAdded:
+			added = append(added, line.text)
+		default:
+			flush()
+			b.WriteString(line.text + "\n")
+		}
+	}
+	flush()
+	return b.String()
+}
+
+// conflictCopiesView lists the conflict copies, and compares the selected one
+// with its original side by side
+type conflictCopiesView struct {
+	groups []conflictGroup
+	// group and copy select a conflict copy
+	group, copy int
+	// comparing is set while the selected copy is compared with its original
+	comparing bool
+	diff      []diffLine
+	offset    int
+}
+
+// selected returns the selected conflict copy and its original
+func (v conflictCopiesView) selected() (conflictGroup, noteItem) {
+	group := v.groups[v.group]
+	return group, group.copies[v.copy]
+}
+
+// openConflictCopies shows the conflict copies among the notes
+func (m model) openConflictCopies() (tea.Model, tea.Cmd) {
+	groups := findConflictCopies(m.items)
+	if len(groups) == 0 {
+		return m, m.setInfo("No conflicted copies found")
+	}
+
+	m.conflictCopies = conflictCopiesView{groups: groups}
+	m.mode = modeConflictCopies
+	return m, nil
+}
+
+// compareConflictCopy diffs the selected copy with its original
+func (m model) compareConflictCopy() (tea.Model, tea.Cmd) {
+	group, item := m.conflictCopies.selected()
+	mine, err := os.ReadFile(filepath.Join(m.notesDir, group.original))
+	if err != nil && !os.IsNotExist(err) {
+		return m, m.setError("Cannot read %s: %v", group.original, describeError(err))
+	}
+	theirs, err := os.ReadFile(item.path)
+	if err != nil {
+		return m, m.setError("Cannot read %s: %v", item.filename, describeError(err))
+	}
+
+	m.conflictCopies.diff = diffLines(splitNoteLines(mine), splitNoteLines(theirs))
+	m.conflictCopies.offset = 0
+	m.conflictCopies.comparing = true
+	return m, nil
+}
+
+// resolveConflictCopy resolves the selected copy: keeping mine deletes it,
+// keeping theirs replaces the original with it, and merging writes both
+// versions in the original with conflict markers, opening it in $EDITOR
+func (m model) resolveConflictCopy(action string) (tea.Model, tea.Cmd) {
+	_, item := m.conflictCopies.selected()
+	if action == "theirs" {
+		return m.resolveSelectedCopy(action)
+	}
+	// The copy is deleted once the pre_delete hook agreed
+	return m.beforeDelete(item.filename, func(m model) (tea.Model, tea.Cmd) {
+		if !m.conflictCopies.selectCopy(item.filename) {
+			return m, nil
+		}
+		return m.resolveSelectedCopy(action)
+	})
+}
+
+// selectCopy selects a conflict copy, which the cursor may have left,
+// reporting whether it is still listed
+func (v *conflictCopiesView) selectCopy(filename string) bool {
+	for g, group := range v.groups {
+		for c, item := range group.copies {
+			if item.filename == filename {
+				v.group, v.copy = g, c
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveSelectedCopy resolves the selected copy as resolveConflictCopy
+func (m model) resolveSelectedCopy(action string) (tea.Model, tea.Cmd) {
+	group, item := m.conflictCopies.selected()
+	originalPath := filepath.Join(m.notesDir, group.original)
+	if err := m.backupBeforeChange(); err != nil {
+		return m, m.setError("Cannot resolve %s: %v", item.filename, err)
+	}
+	mine, err := os.ReadFile(originalPath)
+	if err != nil && !os.IsNotExist(err) {
+		return m, m.setError("Cannot read %s: %v", group.original, describeError(err))
+	}
+	// undoOriginal gives the original its content back, or deletes it when
+	// the copy created it
+	existed, notesDir := err == nil, m.notesDir
+	undoOriginal := func() error {
+		if !existed {
+			return os.Remove(originalPath)
+		}
+		return restoreContent(notesDir, group.original, mine)()
+	}
+
+	var deleted string
+	switch action {
+	case "theirs":
+		if err := os.Rename(item.path, originalPath); err != nil {
+			return m, m.setError("Cannot keep %s: %v", item.filename, describeError(err))
+		}
+		m.pushUndo("keeping "+item.filename, func() error {
+			if err := moveBack(originalPath, item.path); err != nil {
+				return err
+			}
+			if !existed {
+				return nil
+			}
+			return os.WriteFile(originalPath, mine, 0644)
+		})
+	case "merge":
+		theirs, err := os.ReadFile(item.path)
+		if err != nil {
+			return m, m.setError("Cannot read %s: %v", item.filename, describeError(err))
+		}
+		merged := mergeWithMarkers(splitNoteLines(mine), splitNoteLines(theirs), item.filename)
+		if err := os.WriteFile(originalPath, []byte(merged), 0644); err != nil {
+			return m, m.setError("Cannot merge into %s: %v", group.original, describeError(err))
+		}
+		m.pushUndo("merging "+item.filename+" into "+group.original, undoOriginal)
+		fallthrough
+	default:
+		// The copy goes like any deleted note: trashed, or shredded when
+		// sensitive
+		deleted, err = m.deleteFile(item.filename)
+		if err != nil && !os.IsNotExist(err) {
+			return m, m.setError("Cannot delete %s: %v", item.filename, err)
+		}
+	}
+
+	reload := m.reloadNotes()
+	if action == "merge" {
+		m.mode = modeList
+		return m, tea.Batch(reload, m.openNote(group.original))
+	}
+
+	// The next copy is selected, the view closing once none is left
+	v := &m.conflictCopies
+	v.comparing = false
+	v.groups[v.group].copies = append(group.copies[:v.copy:v.copy], group.copies[v.copy+1:]...)
+	if len(v.groups[v.group].copies) == 0 {
+		v.groups = append(v.groups[:v.group:v.group], v.groups[v.group+1:]...)
+		v.group = min(v.group, max(len(v.groups)-1, 0))
+		v.copy = 0
+	} else {
+		v.copy = min(v.copy, len(v.groups[v.group].copies)-1)
+	}
+	if len(v.groups) == 0 {
+		m.mode = modeList
+	}
+
+	if deleted != "" {
+		return m, tea.Batch(reload, m.setInfo("Kept %s. %s", group.original, deleted))
+	}
+	return m, tea.Batch(reload, m.setInfo("Kept %s", group.original))
+}
+
+func (m model) updateConflictCopies(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	v := &m.conflictCopies
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if v.comparing {
+			v.offset = max(v.offset-1, 0)
+		} else if v.copy > 0 {
+			v.copy--
+		} else if v.group > 0 {
+			v.group--
+			v.copy = len(v.groups[v.group].copies) - 1
+		}
+	case "down", "j":
+		if v.comparing {
+			v.offset = min(v.offset+1, max(len(v.diff)-1, 0))
+		} else if v.copy < len(v.groups[v.group].copies)-1 {
+			v.copy++
+		} else if v.group < len(v.groups)-1 {
+			v.group++
+			v.copy = 0
+		}
+	case "enter":
+		if !v.comparing {
+			return m.compareConflictCopy()
+		}
+	case "m":
+		return m.resolveConflictCopy("mine")
+	case "t":
+		return m.resolveConflictCopy("theirs")
+	case "e":
+		return m.resolveConflictCopy("merge")
+	case "esc", "q":
+		if v.comparing {
+			v.comparing = false
+			return m, nil
+		}
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m model) viewConflictCopies() string {
+	if m.conflictCopies.comparing {
+		return m.viewConflictDiff()
+	}
+
+	var b strings.Builder
+	v := m.conflictCopies
+	count := 0
+	for _, group := range v.groups {
+		count += len(group.copies)
+	}
+	b.WriteString("\n" + titleStyle.Render(fmt.Sprintf("Conflicted copies (%d)", count)) + "\n\n")
+
+	// Every original is followed by its copies
+	var lines []string
+	cursorLine := 0
+	for i, group := range v.groups {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		header := itemStyle.Copy().PaddingLeft(2).Bold(true).Render(noteTitle(group.original)) + "  " + mutedStyle.Render(group.original)
+		if _, err := os.Stat(filepath.Join(m.notesDir, group.original)); err != nil {
+			header += "  " + mutedStyle.Render("(missing)")
+		}
+		lines = append(lines, header)
+
+		for j, item := range group.copies {
+			line := item.filename + "  " + mutedStyle.Render(item.modTime.Format("2006-01-02 15:04"))
+			if i == v.group && j == v.copy {
+				cursorLine = len(lines)
+				lines = append(lines, selectedItemStyle.Render("> "+line))
+			} else {
+				lines = append(lines, itemStyle.Render(line))
+			}
+		}
+	}
+
+	// Scroll to keep the cursor visible
+	b.WriteString(strings.Join(scrollLines(lines, cursorLine, max(m.height-6, 1)), "\n"))
+
+	return m.withHelp(b.String(), "enter compare • m keep mine • t keep theirs • e merge in editor • esc back")
+}
+
+// viewConflictDiff shows the original and the selected copy side by side, the
+// lines they differ on highlighted
+func (m model) viewConflictDiff() string {
+	var b strings.Builder
+	v := m.conflictCopies
+	group, item := v.selected()
+	b.WriteString("\n" + titleStyle.Render("Compare "+group.original) + "\n\n")
+
+	rows := sideBySide(v.diff, "mine: "+group.original, "theirs: "+item.filename, m.width)
+	b.WriteString(rows[0] + "\n")
+	rows = rows[1:]
+	height := max(m.height-7, 1)
+	offset := min(v.offset, max(len(rows)-height, 0))
+	end := min(offset+height, len(rows))
+	b.WriteString(strings.Join(rows[offset:end], "\n"))
+
+	return m.withHelp(b.String(), "j/k scroll • m keep mine • t keep theirs • e merge in editor • esc back")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// issuesURL is where to report the crashes
+const issuesURL = "https://github.com/Polensky/snsm/issues"
+
+// crashTerminal is the state of the terminal before snsm changed it, restored
+// on a crash
+var crashTerminal *term.State
+
+// crashMu lets a single goroutine report its crash, the others waiting for
+// the exit
+var crashMu sync.Mutex
+
+// saveTerminal remembers the state of the terminal to restore on a crash
+func saveTerminal() {
+	if state, err := term.GetState(int(os.Stdin.Fd())); err == nil {
+		crashTerminal = state
+	}
+}
+
+// crashLogPath returns the location of the crash log, next to the debug log
+func crashLogPath() string {
+	return filepath.Join(filepath.Dir(debugLogPath()), "crash.log")
+}
+
+// recoverCrash restores the terminal on a panic, saves the panic and its
+// stack to crash.log and exits. Deferred by main and the commands of the
+// programs, which run in their own goroutines.
+func recoverCrash() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	crashMu.Lock()
+
+	// Leave the alternate screen, show the cursor, stop the mouse reports
+	// and leave raw mode
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Fprint(os.Stdout, "\x1b[?1002l\x1b[?1003l\x1b[?1006l\x1b[?25h\x1b[?1049l")
+	}
+	if crashTerminal != nil {
+		term.Restore(int(os.Stdin.Fd()), crashTerminal)
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "snsm crashed on %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&report, "args: %q\n", os.Args[1:])
+	fmt.Fprintf(&report, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&report, "build: %s\n", info.Main.Version)
+	}
+	fmt.Fprintf(&report, "\npanic: %v\n\n%s", r, stack)
+	debugLog.Error("crash", "panic", fmt.Sprint(r), "stack", string(stack))
+
+	fmt.Fprintf(os.Stderr, "snsm crashed: %v\n\n", r)
+	sealUnlockedVault()
+	path := crashLogPath()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(report.String()), 0644)
+	}
+	if err != nil {
+		// Print the stack rather than lose it
+		fmt.Fprintf(os.Stderr, "%s\n", stack)
+		fmt.Fprintf(os.Stderr, "Please report it at %s with the stack above.\n", issuesURL)
+	} else {
+		fmt.Fprintf(os.Stderr, "The details were saved to %s.\n", path)
+		fmt.Fprintf(os.Stderr, "Please report it at %s with this file and what you were doing.\n", issuesURL)
+	}
+	os.Exit(2)
+}
+
+// crashGuard wraps the model of a program so that the panics of its
+// commands restore the terminal too
+type crashGuard struct {
+	tea.Model
+}
+
+func (g crashGuard) Init() tea.Cmd {
+	return guardCmd(g.Model.Init())
+}
+
+func (g crashGuard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m, cmd := g.Model.Update(msg)
+	return crashGuard{m}, guardCmd(cmd)
+}
+
+// guardCmd recovers the panics of a command, and of the commands of the
+// batch it returns
+func guardCmd(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		defer recoverCrash()
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for i := range batch {
+				batch[i] = guardCmd(batch[i])
+			}
+		}
+		return msg
+	}
+}
+
+// runProgram runs a program, its panics reaching recoverCrash rather than
+// the recovery of bubbletea that returns no model
+func runProgram(m tea.Model, options ...tea.ProgramOption) (tea.Model, error) {
+	options = append(options, tea.WithoutCatchPanics())
+	final, err := tea.NewProgram(crashGuard{m}, options...).Run()
+	if guard, ok := final.(crashGuard); ok {
+		final = guard.Model
+	}
+	return final, err
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// largeVault is the number of notes from which the filtering waits for a
+// pause in the typing, rather than running on every key
+const largeVault = 2000
+
+// filterDebounce is the pause in the typing filtering a large vault
+const filterDebounce = 150 * time.Millisecond
+
+// filterDebounceMsg runs the filtering for a filter typed in a large vault,
+// unless more was typed since
+type filterDebounceMsg struct {
+	id  int
+	cmd tea.Cmd
+}
+
+// debounceFilter delays the filtering of a large vault until the typing
+// pauses, only the filtering of the last filter typed running
+func (m *model) debounceFilter(cmd tea.Cmd) tea.Cmd {
+	if len(m.items) < largeVault {
+		return cmd
+	}
+	m.filterID++
+	id := m.filterID
+	return tea.Tick(filterDebounce, func(time.Time) tea.Msg {
+		return filterDebounceMsg{id: id, cmd: cmd}
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// debugLog logs the keys, the scans, the editor commands and the errors to
+// debug.log with --debug or SNSM_DEBUG=1, the full screen hiding what went
+// wrong. It drops everything until debugging starts.
+var debugLog = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.Level(100)}))
+
+// debugEnabled reports whether SNSM_DEBUG asks for the debug log
+func debugEnabled() bool {
+	switch strings.ToLower(os.Getenv("SNSM_DEBUG")) {
+	case "", "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// debugLogPath returns the location of the debug log, in the cache directory
+func debugLogPath() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = expandTilde("~/.cache")
+	}
+	return filepath.Join(cacheDir, "snsm", "debug.log")
+}
+
+// startDebugLog appends the log of the run to debug.log, returning the
+// function closing it
+func startDebugLog() (func(), error) {
+	path := debugLogPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("cannot write the debug log: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot write the debug log: %v", err)
+	}
+
+	debugLog = slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	debugLog.Info("start", "args", os.Args[1:], "pid", os.Getpid(), "editor", os.Getenv("EDITOR"), "term", os.Getenv("TERM"))
+	return func() {
+		debugLog.Info("exit")
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultSimilarity is the similarity from which notes are reported as near
+// duplicates
+const defaultSimilarity = 0.8
+
+// shingleSize is the number of words of the overlapping sequences compared
+// between notes
+const shingleSize = 3
+
+// duplicate is a pair of notes with the same or nearly the same content
+type duplicate struct {
+	a, b noteItem
+	// similarity goes from 0 to 1, 1 for identical contents
+	similarity float64
+}
+
+// noteFingerprint is the content of a note reduced to what's compared
+type noteFingerprint struct {
+	item     noteItem
+	hash     [sha256.Size]byte
+	shingles map[string]bool
+}
+
+// normalizeContent returns the words of a note lowercased, without its tags
+// and its front matter, so that formatting and tagging don't tell notes apart
+func normalizeContent(content string) []string {
+	_, body := splitTagLine(content)
+	body = stripFrontMatter(body)
+	return strings.Fields(strings.ToLower(body))
+}
+
+// fingerprint reads the content of a note for comparison, reporting false for
+// empty notes which are all alike
+func fingerprint(item noteItem) (noteFingerprint, bool) {
+	words := normalizeContent(noteContents.get(item))
+	if len(words) == 0 {
+		return noteFingerprint{}, false
+	}
+
+	shingles := map[string]bool{}
+	for i := 0; i+shingleSize <= len(words) || i == 0; i++ {
+		end := min(i+shingleSize, len(words))
+		shingles[strings.Join(words[i:end], " ")] = true
+	}
+
+	return noteFingerprint{
+		item:     item,
+		hash:     sha256.Sum256([]byte(strings.Join(words, " "))),
+		shingles: shingles,
+	}, true
+}
+
+// similarity is the Jaccard index of the shingles of two notes: the share of
+// their word sequences they have in common
+func (f noteFingerprint) similarity(other noteFingerprint) float64 {
+	if f.hash == other.hash {
+		return 1
+	}
+
+	small, large := f.shingles, other.shingles
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	common := 0
+	for shingle := range small {
+		if large[shingle] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(small)+len(large)-common)
+}
+
+// findDuplicates returns the pairs of notes at least as similar as threshold,
+// the most similar first
+func findDuplicates(items []noteItem, threshold float64) []duplicate {
+	var prints []noteFingerprint
+	for _, item := range items {
+		if f, ok := fingerprint(item); ok {
+			prints = append(prints, f)
+		}
+	}
+
+	var duplicates []duplicate
+	for i, a := range prints {
+		for _, b := range prints[i+1:] {
+			// The similarity can't exceed the ratio of the sizes, skip the
+			// comparison of notes too different in length
+			small, large := len(a.shingles), len(b.shingles)
+			if small > large {
+				small, large = large, small
+			}
+			if a.hash != b.hash && float64(small)/float64(large) < threshold {
+				continue
+			}
+
+			if similarity := a.similarity(b); similarity >= threshold {
+				duplicates = append(duplicates, duplicate{a: a.item, b: b.item, similarity: similarity})
+			}
+		}
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].similarity > duplicates[j].similarity
+	})
+	return duplicates
+}
+
+func runDedupe(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	threshold := flags.Float64("threshold", defaultSimilarity, "similarity from which notes are reported, from 0 to 1")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *threshold <= 0 || *threshold > 1 {
+		return fmt.Errorf("the threshold must be between 0 and 1")
+	}
+
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+
+	duplicates := findDuplicates(items, *threshold)
+	for _, d := range duplicates {
+		fmt.Printf("%3.0f%%\t%s\t%s\n", d.similarity*100, d.a.filename, d.b.filename)
+	}
+	if len(duplicates) == 0 {
+		fmt.Fprintln(os.Stderr, "No duplicates found")
+	}
+	return nil
+}
+
+// dedupeView lists the pairs of duplicate notes
+type dedupeView struct {
+	duplicates []duplicate
+	cursor     int
+	// second selects the second note of the pair rather than the first
+	second bool
+	// deleting is set while the deletion of the selected note is confirmed
+	deleting bool
+	// merging is set while merging the other note into the selected one is
+	// confirmed
+	merging bool
+}
+
+// selected returns the note selected in the pair under the cursor
+func (v dedupeView) selected() noteItem {
+	d := v.duplicates[v.cursor]
+	if v.second {
+		return d.b
+	}
+	return d.a
+}
+
+// other returns the note of the pair under the cursor that isn't selected
+func (v dedupeView) other() noteItem {
+	d := v.duplicates[v.cursor]
+	if v.second {
+		return d.a
+	}
+	return d.b
+}
+
+// drop removes the pairs a note was in, once it's deleted
+func (v *dedupeView) drop(filename string) {
+	var kept []duplicate
+	for _, d := range v.duplicates {
+		if d.a.filename != filename && d.b.filename != filename {
+			kept = append(kept, d)
+		}
+	}
+	v.duplicates = kept
+	v.cursor = min(v.cursor, max(len(kept)-1, 0))
+	v.second = false
+}
+
+// openDedupe compares the listed notes and shows the duplicates
+func (m model) openDedupe() (tea.Model, tea.Cmd) {
+	duplicates := findDuplicates(m.items, defaultSimilarity)
+	if len(duplicates) == 0 {
+		return m, m.setInfo("No duplicates found")
+	}
+
+	m.dedupe = dedupeView{duplicates: duplicates}
+	m.mode = modeDedupe
+	return m, nil
+}
+
+// deleteDuplicate deletes the selected note once the pre_delete hook agreed
+func (m model) deleteDuplicate() (tea.Model, tea.Cmd) {
+	item := m.dedupe.selected()
+	return m.beforeDelete(item.filename, func(m model) (tea.Model, tea.Cmd) {
+		return m.deleteDuplicateNote(item)
+	})
+}
+
+// deleteDuplicateNote deletes a note, removing the pairs it was in
+func (m model) deleteDuplicateNote(item noteItem) (tea.Model, tea.Cmd) {
+	done, err := m.deleteFile(item.filename)
+	if err != nil {
+		return m, m.setError("Cannot delete %s: %v", item.filename, err)
+	}
+
+	m.dedupe.drop(item.filename)
+	reload := m.reloadNotes()
+	if len(m.dedupe.duplicates) == 0 {
+		m.mode = modeList
+	}
+	return m, tea.Batch(reload, m.setInfo("%s", done))
+}
+
+// mergeDuplicate merges the other note of the pair into the selected one once
+// the pre_delete hook agreed to delete it
+func (m model) mergeDuplicate() (tea.Model, tea.Cmd) {
+	kept, other := m.dedupe.selected(), m.dedupe.other()
+	return m.beforeDelete(other.filename, func(m model) (tea.Model, tea.Cmd) {
+		return m.mergeDuplicateNote(kept, other)
+	})
+}
+
+// mergeDuplicateNote appends the content of other, without its tag line and
+// front matter, to kept and adds its tags, then deletes other. u brings other
+// back, and u again gives kept its former content.
+func (m model) mergeDuplicateNote(kept, other noteItem) (tea.Model, tea.Cmd) {
+	data, err := os.ReadFile(other.path)
+	if err != nil {
+		return m, m.setError("Cannot read %s: %v", other.filename, describeError(err))
+	}
+	if err := m.backupBeforeChange(); err != nil {
+		return m, m.setError("Cannot merge into %s: %v", kept.filename, err)
+	}
+	before, err := readForUndo(m.notesDir, kept.filename)
+	if err != nil {
+		return m, m.setError("Cannot merge into %s: %v", kept.filename, describeError(err))
+	}
+
+	_, body := splitTagLine(string(data))
+	content := strings.TrimRight(string(before[kept.filename]), "\n") + "\n\n" + strings.TrimSpace(stripFrontMatter(body)) + "\n"
+	err = os.WriteFile(kept.path, []byte(content), 0644)
+	if err == nil {
+		err = rewriteTags(kept.path, func(existing string) string { return mergeTags(existing, other.tags) })
+	}
+	if err != nil {
+		restoreNotes(m.notesDir, before)()
+		return m, m.setError("Cannot merge into %s: %v", kept.filename, describeError(err))
+	}
+	m.pushUndo("merging "+other.filename+" into "+kept.filename, restoreNotes(m.notesDir, before))
+
+	done, err := m.deleteFile(other.filename)
+	if err != nil {
+		return m, tea.Batch(m.reloadNotes(), m.setError("Merged %s into %s, but cannot delete it: %v", other.filename, kept.filename, err))
+	}
+	m.dedupe.drop(other.filename)
+	reload := m.reloadNotes()
+	if len(m.dedupe.duplicates) == 0 {
+		m.mode = modeList
+	}
+	return m, tea.Batch(reload, m.setInfo("Merged %s into %s. %s", other.filename, kept.filename, done))
+}
+
+func (m model) updateDedupe(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	if m.dedupe.deleting {
+		m.dedupe.deleting = false
+		if keyMsg.String() == "y" {
+			return m.deleteDuplicate()
+		}
+		return m, nil
+	}
+	if m.dedupe.merging {
+		m.dedupe.merging = false
+		if keyMsg.String() == "y" {
+			return m.mergeDuplicate()
+		}
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if m.dedupe.cursor > 0 {
+			m.dedupe.cursor--
+		}
+	case "down", "j":
+		if m.dedupe.cursor < len(m.dedupe.duplicates)-1 {
+			m.dedupe.cursor++
+		}
+	case "tab", "left", "right", "h", "l":
+		m.dedupe.second = !m.dedupe.second
+	case "enter":
+		return m, m.openNote(m.dedupe.selected().filename)
+	case "d":
+		m.dedupe.deleting = true
+	case "m":
+		m.dedupe.merging = true
+	case "esc", "q", "D":
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m model) viewDedupe() string {
+	var b strings.Builder
+	v := m.dedupe
+	b.WriteString("\n" + titleStyle.Render(fmt.Sprintf("Duplicates (%d)", len(v.duplicates))) + "\n\n")
+
+	// Every pair takes its two notes and a blank line
+	var lines []string
+	cursorLine := 0
+	for i, d := range v.duplicates {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		header := fmt.Sprintf("%.0f%% similar", d.similarity*100)
+		if d.similarity == 1 {
+			header = "identical"
+		}
+		lines = append(lines, itemStyle.Render(mutedStyle.Render(header)))
+
+		for side, item := range []noteItem{d.a, d.b} {
+			line := item.Title() + "  " + mutedStyle.Render(item.filename+" · "+item.modTime.Format("2006-01-02 15:04"))
+			if i == v.cursor && (side == 1) == v.second {
+				cursorLine = len(lines)
+				lines = append(lines, selectedItemStyle.Render("> "+line))
+			} else {
+				lines = append(lines, itemStyle.Render(line))
+			}
+		}
+	}
+
+	// Scroll to keep the cursor visible
+	b.WriteString(strings.Join(scrollLines(lines, cursorLine, max(m.height-6, 1)), "\n"))
+
+	help := "tab switch note • enter edit • m merge the other into it • d delete • esc back"
+	if v.deleting {
+		help = fmt.Sprintf("Delete %s? y to confirm, any key to cancel", v.selected().filename)
+	}
+	if v.merging {
+		help = fmt.Sprintf("Merge %s into %s and delete it? y to confirm, any key to cancel", v.other().filename, v.selected().filename)
+	}
+	return m.withHelp(b.String(), help)
+}
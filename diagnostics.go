@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxNoteSize is the size of the largest file listed as a note, larger ones
+// being logs or dumps rather than notes
+const maxNoteSize = 10 << 20
+
+// sniffSize is the length of the beginning of a note looked at to tell text
+// from binary files
+const sniffSize = 8000
+
+// maxLineSize is the length of the longest line read from a note
+const maxLineSize = 1 << 20
+
+// errBinaryNote is returned for the files with a note extension holding
+// binary data
+var errBinaryNote = errors.New("binary file")
+
+// scanWarning is a file that couldn't be listed, or listed as expected, while
+// scanning the notes
+type scanWarning struct {
+	filename string
+	problem  string
+}
+
+// newLineScanner returns a scanner of the lines of a note, reading lines up
+// to maxLineSize rather than the 64 KB of bufio
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return scanner
+}
+
+// scanError describes an error of a line scanner
+func scanError(err error) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("a line is longer than %d MB", maxLineSize>>20)
+	}
+	return err
+}
+
+// sniffText reads the beginning of a file, reporting whether it is valid
+// UTF-8, and fails with errBinaryNote on binary data like git does: when it
+// holds a NUL byte
+func sniffText(file *os.File) ([]byte, bool, error) {
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, false, err
+	}
+	head = head[:n]
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, false, errBinaryNote
+	}
+
+	// The last character may be cut
+	valid := head
+	if n == sniffSize {
+		for i := len(valid) - 1; i >= 0 && i >= len(valid)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(valid[i]) {
+				if !utf8.FullRune(valid[i:]) {
+					valid = valid[:i]
+				}
+				break
+			}
+		}
+	}
+	return head, utf8.Valid(valid), nil
+}
+
+// reportScanWarnings warns about the notes that couldn't be read or listed
+// while scanning
+func (m *model) reportScanWarnings() tea.Cmd {
+	switch len(m.scanWarnings) {
+	case 0:
+		return nil
+	case 1:
+		w := m.scanWarnings[0]
+		return m.setError("%s: %s (W for details)", w.filename, w.problem)
+	default:
+		return m.setError("%d files with problems, starting with %s: %s (W for details)",
+			len(m.scanWarnings), m.scanWarnings[0].filename, m.scanWarnings[0].problem)
+	}
+}
+
+// openScanWarnings lists the problems of the files of the last scan
+func (m model) openScanWarnings() (tea.Model, tea.Cmd) {
+	if len(m.scanWarnings) == 0 {
+		return m, m.setInfo("No problems while scanning the notes")
+	}
+	lines := make([]string, len(m.scanWarnings))
+	for i, w := range m.scanWarnings {
+		lines[i] = w.filename + ": " + mutedStyle.Render(w.problem)
+	}
+	return m.openPager(fmt.Sprintf("Scan warnings (%d)", len(m.scanWarnings)), lines)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Kinds of the lines of a diff
+const (
+	diffSame = iota
+	diffRemoved
+	diffAdded
+)
+
+type diffLine struct {
+	kind int
+	text string
+}
+
+// diffLines compares two texts line by line, from their longest common
+// subsequence
+func diffLines(a, b []string) []diffLine {
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var diff []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, diffLine{diffSame, a[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			diff = append(diff, diffLine{diffRemoved, a[i]})
+			i++
+		default:
+			diff = append(diff, diffLine{diffAdded, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, diffLine{diffRemoved, a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, diffLine{diffAdded, b[j]})
+	}
+	return diff
+}
+
+// splitNoteLines returns the lines of a note, none for an empty or missing one
+func splitNoteLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// sideBySide renders a diff in two columns fitting width, the old text on
+// the left, under their titles, the first row
+func sideBySide(diff []diffLine, leftTitle, rightTitle string, width int) []string {
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Error))
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Success))
+	columnWidth := max((width-7)/2, 10)
+	column := lipgloss.NewStyle().Width(columnWidth)
+	row := func(left, right string) string {
+		return "  " + column.Render(truncateWidth(left, columnWidth)) + mutedStyle.Render(" │ ") + truncateWidth(right, columnWidth)
+	}
+
+	rows := []string{row(mutedStyle.Render(leftTitle), mutedStyle.Render(rightTitle))}
+	// Changed lines are paired, the shorter side padded with blank lines
+	var left, right []string
+	flush := func() {
+		for i := 0; i < max(len(left), len(right)); i++ {
+			l, r := "", ""
+			if i < len(left) {
+				l = removed.Render(left[i])
+			}
+			if i < len(right) {
+				r = added.Render(right[i])
+			}
+			rows = append(rows, row(l, r))
+		}
+		left, right = nil, nil
+	}
+	for _, line := range diff {
+		switch line.kind {
+		case diffRemoved:
+			left = append(left, line.text)
+		case diffAdded:
+			right = append(right, line.text)
+		default:
+			flush()
+			rows = append(rows, row(line.text, line.text))
+		}
+	}
+	flush()
+	return rows
+}
+
+// diffContext is the number of unchanged lines shown around the changes of a
+// unified diff
+const diffContext = 3
+
+// unifiedDiff formats a diff like diff -u, the changes in hunks with the
+// unchanged lines around them, colored with the theme
+func unifiedDiff(diff []diffLine, oldName, newName string) []string {
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Error))
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Success))
+	hunkStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Title))
+
+	// oldAt[i] and newAt[i] count the lines of each side before diff[i]
+	oldAt := make([]int, len(diff)+1)
+	newAt := make([]int, len(diff)+1)
+	for i, line := range diff {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if line.kind != diffAdded {
+			oldAt[i+1]++
+		}
+		if line.kind != diffRemoved {
+			newAt[i+1]++
+		}
+	}
+
+	lines := []string{removed.Render("--- " + oldName), added.Render("+++ " + newName)}
+	hunkEnd := 0
+	for i := 0; i < len(diff); {
+		for i < len(diff) && diff[i].kind == diffSame {
+			i++
+		}
+		if i == len(diff) {
+			break
+		}
+
+		// A hunk goes on until more unchanged lines than twice the context
+		// separate its changes from the next ones
+		start := max(i-diffContext, hunkEnd)
+		end := i
+		for end < len(diff) {
+			if diff[end].kind != diffSame {
+				end++
+				continue
+			}
+			run := end
+			for run < len(diff) && diff[run].kind == diffSame {
+				run++
+			}
+			if run == len(diff) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(diff))
+				break
+			}
+			end = run
+		}
+
+		oldCount, newCount := oldAt[end]-oldAt[start], newAt[end]-newAt[start]
+		oldStart, newStart := oldAt[start], newAt[start]
+		if oldCount > 0 {
+			oldStart++
+		}
+		if newCount > 0 {
+			newStart++
+		}
+		lines = append(lines, hunkStyle.Render(fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount)))
+		for _, line := range diff[start:end] {
+			switch line.kind {
+			case diffRemoved:
+				lines = append(lines, removed.Render("-"+line.text))
+			case diffAdded:
+				lines = append(lines, added.Render("+"+line.text))
+			default:
+				lines = append(lines, " "+line.text)
+			}
+		}
+		i, hunkEnd = end, end
+	}
+	return lines
+}
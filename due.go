@@ -0,0 +1,249 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// dueMarker is a due date written in a note: @due(2024-06-01), optionally
+// with a time: @due(2024-06-01 14:00)
+var dueMarker = regexp.MustCompile(`@due\((\d{4}-\d{2}-\d{2})(?:[ T](\d{1,2}:\d{2}))?\)`)
+
+// dueItem is something due in a note: a line with a @due marker, or the
+// note itself with a due date in its front matter
+type dueItem struct {
+	filename string
+	// line of the marker in the note, starting at 1, 0 for the front matter
+	line int
+	text string
+	due  time.Time
+	// timed is set when the due date has a time
+	timed bool
+	// done is set for the checked tasks
+	done bool
+}
+
+// parseDue reads a due date and its optional time
+func parseDue(date, clock string) (time.Time, bool) {
+	if clock == "" {
+		due, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(date), time.Local)
+		return due, err == nil
+	}
+	due, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, time.Local)
+	return due, err == nil
+}
+
+// parseNoteDate reads the date of the front matter of a note, with or
+// without a time, a missing or invalid date giving the zero time
+func parseNoteDate(value string) time.Time {
+	date, clock, _ := strings.Cut(strings.TrimSpace(value), " ")
+	if at, ok := parseDue(date, clock); ok {
+		return at
+	}
+	return time.Time{}
+}
+
+// readDueItems returns the due dates of a note, due being the date of its
+// front matter. Markers in code blocks are left out.
+func readDueItems(path, filename, due string) ([]dueItem, error) {
+	var items []dueItem
+	if date, clock, _ := strings.Cut(strings.TrimSpace(due), " "); date != "" {
+		if at, ok := parseDue(date, clock); ok {
+			items = append(items, dueItem{filename: filename, due: at, timed: clock != ""})
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return items, err
+	}
+	defer file.Close()
+
+	fence := ""
+	scanner := newLineScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if match := fenceRegex.FindStringSubmatch(text); match != nil {
+			if fence == "" {
+				fence = match[1]
+			} else if strings.HasPrefix(strings.TrimSpace(text), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" || !strings.Contains(text, "@due(") {
+			continue
+		}
+
+		for _, match := range dueMarker.FindAllStringSubmatch(text, -1) {
+			at, ok := parseDue(match[1], match[2])
+			if !ok {
+				continue
+			}
+			item := dueItem{filename: filename, line: line, due: at, timed: match[2] != ""}
+			item.text, item.done = dueText(dueMarker.ReplaceAllString(text, ""))
+			items = append(items, item)
+		}
+	}
+
+	return items, scanError(scanner.Err())
+}
+
+// dueText returns the text of a line with a due date, without its list
+// marker and checkbox, and whether it's a checked task
+func dueText(line string) (string, bool) {
+	if match := taskLine.FindStringSubmatch(line); match != nil {
+		return strings.TrimSpace(match[4]), match[2] != " "
+	}
+	if match := listItemRegex.FindString(line); match != "" {
+		line = line[len(match):]
+	}
+	return strings.Join(strings.Fields(strings.TrimLeft(line, "# ")), " "), false
+}
+
+// nextDue returns the earliest due date of the items not done yet
+func nextDue(items []dueItem) time.Time {
+	var next time.Time
+	for _, item := range items {
+		if !item.done && (next.IsZero() || item.due.Before(next)) {
+			next = item.due
+		}
+	}
+	return next
+}
+
+// startOfDay returns the midnight starting the day of t
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dueBadge renders the badge of a note overdue or due today
+func dueBadge(due, now time.Time) string {
+	if due.IsZero() {
+		return ""
+	}
+	switch day := startOfDay(due); {
+	case day.Before(startOfDay(now)):
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Error)).Bold(true).Render("overdue")
+	case day.Equal(startOfDay(now)):
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Success)).Bold(true).Render("today")
+	}
+	return ""
+}
+
+// formatDue formats a due date, with its time when it has one
+func (item dueItem) formatDue() string {
+	if item.timed {
+		return item.due.Format("2006-01-02 15:04")
+	}
+	return item.due.Format("2006-01-02")
+}
+
+func runAgenda(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("agenda", flag.ContinueOnError)
+	days := flags.Int("days", 7, "number of days ahead to show")
+	notify := flags.Bool("notify", false, "also send a desktop notification of what's overdue or due today")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	notes, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+
+	now := time.Now()
+	today := startOfDay(now)
+	end := today.AddDate(0, 0, *days+1)
+	var items []dueItem
+	for _, note := range notes {
+		_, meta, _ := readHeader(note.path)
+		noteItems, _ := readDueItems(note.path, note.filename, meta.Due)
+		for _, item := range noteItems {
+			if item.done || !item.due.Before(end) {
+				continue
+			}
+			if item.text == "" {
+				item.text = note.Title()
+			}
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].due.Before(items[j].due)
+	})
+
+	if len(items) == 0 {
+		fmt.Printf("Nothing due in the next %d days\n", *days)
+		return nil
+	}
+
+	var urgent []string
+	section := ""
+	for _, item := range items {
+		heading := "Upcoming"
+		if day := startOfDay(item.due); day.Before(today) {
+			heading = "Overdue"
+		} else if day.Equal(today) {
+			heading = "Today"
+		}
+		if heading != section {
+			if section != "" {
+				fmt.Println()
+			}
+			fmt.Println(heading)
+			section = heading
+		}
+
+		location := item.filename
+		if item.line > 0 {
+			location = fmt.Sprintf("%s:%d", item.filename, item.line)
+		}
+		fmt.Printf("  %-16s  %s  %s\n", item.formatDue(), location, item.text)
+		if heading != "Upcoming" {
+			urgent = append(urgent, fmt.Sprintf("%s: %s", strings.ToLower(heading), item.text))
+		}
+	}
+
+	if *notify && len(urgent) > 0 {
+		title := fmt.Sprintf("%d notes due", len(urgent))
+		if len(urgent) == 1 {
+			title = "1 note due"
+		}
+		if err := sendNotification(title, strings.Join(urgent, "\n")); err != nil {
+			return fmt.Errorf("failed to send the notification: %v", err)
+		}
+	}
+	return nil
+}
+
+// sendNotification shows a desktop notification with notify-send on Linux
+// and osascript on macOS
+func sendNotification(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		quote := func(s string) string {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+		}
+		cmd = exec.Command("osascript", "-e", "display notification "+quote(body)+" with title "+quote(title))
+	default:
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return fmt.Errorf("notify-send is not installed")
+		}
+		cmd = exec.Command("notify-send", "--app-name=snsm", title, body)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
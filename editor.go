@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// quickEditor edits a note inside snsm, for small changes not worth starting
+// $EDITOR
+type quickEditor struct {
+	filename string
+	original string
+	area     textarea.Model
+	// discarding is set after esc on a changed note, a second esc
+	// discarding the changes
+	discarding bool
+}
+
+// changed reports whether the note was changed, the final newline aside
+func (e quickEditor) changed() bool {
+	return strings.TrimSuffix(e.area.Value(), "\n") != strings.TrimSuffix(e.original, "\n")
+}
+
+// openEditor edits the selected note in the built-in editor
+func (m model) openEditor() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	if lock, ok := heldLock(m.notesDir, item.filename); ok {
+		m.warnLocked(lockedOpen{filename: item.filename, quick: true}, lock)
+		return m, nil
+	}
+	return m.quickEdit(item.filename)
+}
+
+// quickEdit edits a note in the built-in editor, locking it until the
+// editor closes
+func (m model) quickEdit(filename string) (tea.Model, tea.Cmd) {
+	data, err := os.ReadFile(filepath.Join(m.notesDir, filename))
+	if err != nil {
+		return m, m.setError("Cannot read %s: %v", filename, describeError(err))
+	}
+	lockNote(m.notesDir, filename)
+
+	area := textarea.New()
+	// Notes are edited whole, however long
+	area.CharLimit = 0
+	area.MaxHeight = 0
+	area.ShowLineNumbers = true
+	area.Prompt = ""
+	area.SetValue(string(data))
+	// SetValue leaves the cursor at the end, edits usually start at the top
+	for area.Line() > 0 {
+		area.CursorUp()
+	}
+	area.CursorStart()
+	area.Focus()
+
+	m.editor = quickEditor{filename: filename, original: string(data), area: area}
+	m.mode = modeEditor
+	m.layout()
+	return m, textarea.Blink
+}
+
+// saveEditor writes the note being edited, saving its previous content as a
+// version first
+func (m model) saveEditor() (tea.Model, tea.Cmd) {
+	e := m.editor
+	content := e.area.Value()
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	m.mode = modeList
+	if !e.changed() {
+		unlockNote(m.notesDir, e.filename)
+		return m, m.setInfo("No changes to %s", e.filename)
+	}
+
+	before, err := readForUndo(m.notesDir, e.filename)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(m.notesDir, e.filename), []byte(content), 0644)
+	}
+	if err != nil {
+		m.mode = modeEditor
+		return m, m.setError("Cannot save %s: %v", e.filename, describeError(err))
+	}
+	m.pushUndo("saving "+e.filename, restoreNotes(m.notesDir, before))
+	unlockNote(m.notesDir, e.filename)
+	done := "Saved " + e.filename
+	return m, tea.Batch(m.reloadNotes(), m.setInfo("%s", done), m.noteSavedCmd("post_edit", e.filename, done))
+}
+
+func (m model) updateEditor(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "ctrl+s":
+			return m.saveEditor()
+		case "ctrl+t":
+			return m.openSnippets()
+		case "esc":
+			if !m.editor.changed() || m.editor.discarding {
+				unlockNote(m.notesDir, m.editor.filename)
+				m.mode = modeList
+				return m, nil
+			}
+			m.editor.discarding = true
+			return m, m.setInfo("Press esc again to discard the changes, ctrl+s to save them")
+		case "ctrl+c":
+			m.quitting = true
+			return m, tea.Quit
+		}
+		m.editor.discarding = false
+	}
+
+	var cmd tea.Cmd
+	m.editor.area, cmd = m.editor.area.Update(msg)
+	return m, cmd
+}
+
+// resizeEditor fits the editor to the window, below its title and above its
+// help
+func (m *model) resizeEditor() {
+	m.editor.area.SetWidth(max(m.width-4, 10))
+	m.editor.area.SetHeight(max(m.height-7, 1))
+}
+
+func (m model) viewEditor() string {
+	e := m.editor
+	title := "Edit " + e.filename
+	if e.changed() {
+		title += " (modified)"
+	}
+
+	view := "\n" + titleStyle.Render(title) + "\n\n" + lipgloss.NewStyle().PaddingLeft(2).Render(e.area.View())
+	return m.withHelp(view, "ctrl+s save • ctrl+t insert a snippet • esc discard")
+}
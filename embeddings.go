@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// embeddingsConfig sets the model turning the notes into embeddings, vectors
+// close to each other for texts of close meaning, for the semantic search
+type embeddingsConfig struct {
+	// Provider is ollama, or openai for any API compatible with the one of
+	// OpenAI like llama.cpp or LocalAI
+	Provider string `yaml:"provider"`
+	// URL defaults to http://localhost:11434 for ollama and
+	// https://api.openai.com/v1 for openai
+	URL string `yaml:"url"`
+	// Model defaults to nomic-embed-text for ollama and
+	// text-embedding-3-small for openai
+	Model string `yaml:"model"`
+	// APIKeyEnv is the environment variable holding the API key,
+	// OPENAI_API_KEY by default for openai
+	APIKeyEnv string `yaml:"api_key_env"`
+	// MinSimilarity is the similarity, from 0 to 1, of the notes matching a
+	// semantic search, 0.5 by default
+	MinSimilarity float64 `yaml:"min_similarity"`
+}
+
+// semanticSearch is the embeddings config, the semantic search being
+// disabled without a provider
+var semanticSearch embeddingsConfig
+
+// maxEmbeddedText is the length of the beginning of the notes that is
+// embedded, models reading a limited number of tokens
+const maxEmbeddedText = 8000
+
+// embeddingBatch is the number of notes embedded per request
+const embeddingBatch = 32
+
+var embeddingsClient = &http.Client{Timeout: 2 * time.Minute}
+
+func (c embeddingsConfig) validate() error {
+	switch c.Provider {
+	case "", "ollama", "openai":
+	default:
+		return fmt.Errorf("unknown embeddings provider %q, expected ollama or openai", c.Provider)
+	}
+	if c.MinSimilarity < 0 || c.MinSimilarity > 1 {
+		return fmt.Errorf("embeddings.min_similarity must be between 0 and 1")
+	}
+	return nil
+}
+
+// model returns the model of the embeddings, with its default
+func (c embeddingsConfig) model() string {
+	switch {
+	case c.Model != "":
+		return c.Model
+	case c.Provider == "openai":
+		return "text-embedding-3-small"
+	}
+	return "nomic-embed-text"
+}
+
+// minSimilarity returns the similarity of the matching notes, with its
+// default
+func (c embeddingsConfig) minSimilarity() float64 {
+	if c.MinSimilarity == 0 {
+		return 0.5
+	}
+	return c.MinSimilarity
+}
+
+// embed returns the embeddings of texts
+func (c embeddingsConfig) embed(texts []string) ([][]float32, error) {
+	var endpoint string
+	switch c.Provider {
+	case "ollama":
+		endpoint = strings.TrimSuffix(c.URL, "/")
+		if endpoint == "" {
+			endpoint = "http://localhost:11434"
+		}
+		endpoint += "/api/embed"
+	case "openai":
+		endpoint = strings.TrimSuffix(c.URL, "/")
+		if endpoint == "" {
+			endpoint = "https://api.openai.com/v1"
+		}
+		endpoint += "/embeddings"
+	default:
+		return nil, fmt.Errorf("semantic search needs an embeddings provider in the config")
+	}
+
+	payload, err := json.Marshal(map[string]any{"model": c.model(), "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := apiKey(c.APIKeyEnv); key != "" && c.Provider == "openai" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := embeddingsClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s refused the embeddings: %s%s", endpoint, resp.Status, hookOutput(string(answer)))
+	}
+
+	// ollama answers the embeddings, OpenAI objects holding them
+	var answer struct {
+		Embeddings [][]float32 `json:"embeddings"`
+		Data       []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("unexpected answer from %s: %v", endpoint, err)
+	}
+	vectors := answer.Embeddings
+	if vectors == nil && answer.Data != nil {
+		vectors = make([][]float32, len(answer.Data))
+		for _, d := range answer.Data {
+			if d.Index >= 0 && d.Index < len(vectors) {
+				vectors[d.Index] = d.Embedding
+			}
+		}
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("%s answered %d embeddings for %d texts", endpoint, len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+// noteEmbedding is the embedding of a note, kept while its text and the
+// model don't change
+type noteEmbedding struct {
+	Hash   string    `json:"hash"`
+	Vector []float32 `json:"vector"`
+}
+
+// embeddingIndex holds the embeddings of the notes, by absolute path. The
+// list filters in the background, hence the lock.
+type embeddingIndex struct {
+	mu     sync.Mutex
+	loaded bool
+	notes  map[string]noteEmbedding
+	// forgotten are the sensitive notes dropped since the last save, not to
+	// be taken back from the embeddings of another instance
+	forgotten map[string]bool
+}
+
+var embeddings embeddingIndex
+
+// embeddingsPath returns the location of the embeddings, next to the state
+func embeddingsPath() string {
+	return filepath.Join(filepath.Dir(statePath()), "embeddings.json")
+}
+
+// load reads the saved embeddings once, a missing or broken file giving none
+func (x *embeddingIndex) load() {
+	if x.loaded {
+		return
+	}
+	x.loaded = true
+	x.notes = map[string]noteEmbedding{}
+	if data, err := os.ReadFile(embeddingsPath()); err == nil {
+		json.Unmarshal(data, &x.notes)
+	}
+}
+
+// save writes the embeddings, merged with the ones saved meanwhile by other
+// instances and forgetting the deleted notes
+func (x *embeddingIndex) save() error {
+	err := withFileLock(embeddingsPath(), func() error {
+		saved := map[string]noteEmbedding{}
+		if data, err := os.ReadFile(embeddingsPath()); err == nil {
+			json.Unmarshal(data, &saved)
+		}
+		for path, e := range saved {
+			if _, ok := x.notes[path]; !ok && !x.forgotten[path] {
+				x.notes[path] = e
+			}
+		}
+		for path := range x.notes {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				delete(x.notes, path)
+			}
+		}
+		data, err := json.Marshal(x.notes)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(embeddingsPath(), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save embeddings.json: %v", err)
+	}
+	x.forgotten = nil
+	return nil
+}
+
+// forget drops the embedding of a note, like a shredded one
+func (x *embeddingIndex) forget(path string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.load()
+	if x.drop(path) {
+		x.save()
+	}
+}
+
+// drop removes the embedding of a note, reporting whether it had one
+func (x *embeddingIndex) drop(path string) bool {
+	if _, ok := x.notes[path]; !ok {
+		return false
+	}
+	delete(x.notes, path)
+	if x.forgotten == nil {
+		x.forgotten = map[string]bool{}
+	}
+	x.forgotten[path] = true
+	return true
+}
+
+// embeddedText returns the text of a note that is embedded, and its hash
+// with the model
+func embeddedText(item noteItem) (string, string) {
+	_, body := splitTagLine(noteContents.get(item))
+	text := item.Title() + "\n" + strings.TrimSpace(stripFrontMatter(body))
+	if runes := []rune(text); len(runes) > maxEmbeddedText {
+		text = string(runes[:maxEmbeddedText])
+	}
+	sum := sha256.Sum256([]byte(semanticSearch.model() + "\x00" + text))
+	return text, hex.EncodeToString(sum[:])
+}
+
+// update embeds the notes that are new or changed since they were embedded,
+// and saves the embeddings when some were. Sensitive notes and the ones of
+// an encrypted vault are neither sent to the embeddings API nor kept.
+func (x *embeddingIndex) update(items []noteItem) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.load()
+
+	var stale []noteItem
+	var texts, hashes []string
+	dropped := false
+	for _, item := range items {
+		if !item.cacheable() {
+			dropped = x.drop(absPath(item.path)) || dropped
+			continue
+		}
+		text, hash := embeddedText(item)
+		if x.notes[absPath(item.path)].Hash != hash {
+			stale = append(stale, item)
+			texts = append(texts, text)
+			hashes = append(hashes, hash)
+		}
+	}
+	if len(stale) == 0 {
+		if dropped {
+			return x.save()
+		}
+		return nil
+	}
+
+	for start := 0; start < len(stale); start += embeddingBatch {
+		end := min(start+embeddingBatch, len(stale))
+		vectors, err := semanticSearch.embed(texts[start:end])
+		if err != nil {
+			// Keep the notes embedded so far
+			x.save()
+			return err
+		}
+		for i, vector := range vectors {
+			x.notes[absPath(stale[start+i].path)] = noteEmbedding{Hash: hashes[start+i], Vector: vector}
+		}
+	}
+	return x.save()
+}
+
+// vector returns the embedding of a note, from the last update
+func (x *embeddingIndex) vector(item noteItem) ([]float32, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.load()
+	e, ok := x.notes[absPath(item.path)]
+	return e.Vector, ok
+}
+
+// absPath returns the absolute path of a file, or the path when it can't be
+// made absolute
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// cosineSimilarity is the cosine of the angle between two embeddings, 1 for
+// the same meaning
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// queryVectors caches the embeddings of the texts searched with about:, for
+// the filter of the list not to call the embeddings API on every pass
+var queryVectors = struct {
+	mu      sync.Mutex
+	vectors map[string][]float32
+}{vectors: map[string][]float32{}}
+
+// embedQuery returns the embedding of a text searched, calling the
+// embeddings API the first time only
+func embedQuery(text string) ([]float32, error) {
+	if vector, ok := queryVector(text); ok {
+		return vector, nil
+	}
+	vectors, err := semanticSearch.embed([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	queryVectors.mu.Lock()
+	defer queryVectors.mu.Unlock()
+	queryVectors.vectors[text] = vectors[0]
+	return vectors[0], nil
+}
+
+// queryVector returns the embedding of a text searched, if it was embedded
+func queryVector(text string) ([]float32, bool) {
+	queryVectors.mu.Lock()
+	defer queryVectors.mu.Unlock()
+	vector, ok := queryVectors.vectors[text]
+	return vector, ok
+}
+
+// semanticTerm is the about:"text" term of a query, matching the notes
+// whose meaning is close to the text
+type semanticTerm struct {
+	text   string
+	vector []float32
+}
+
+// prepare embeds the text searched and the notes that are not yet
+func (t *semanticTerm) prepare(items []noteItem) error {
+	vector, err := embedQuery(t.text)
+	if err != nil {
+		return err
+	}
+	t.vector = vector
+	return embeddings.update(items)
+}
+
+// embedded reports whether the text searched was embedded already, taking
+// its embedding without calling the embeddings API
+func (t *semanticTerm) embedded() bool {
+	vector, ok := queryVector(t.text)
+	t.vector = vector
+	return ok
+}
+
+// similarity returns how close the meaning of a note is to the text,
+// embedding the note when needed
+func (t *semanticTerm) similarity(item noteItem) float64 {
+	if t.vector == nil && t.prepare(nil) != nil {
+		return 0
+	}
+	vector, ok := embeddings.vector(item)
+	if !ok {
+		if embeddings.update([]noteItem{item}) != nil {
+			return 0
+		}
+		vector, _ = embeddings.vector(item)
+	}
+	return cosineSimilarity(t.vector, vector)
+}
+
+// matches reports whether the meaning of a note is close enough to the text
+func (t *semanticTerm) matches(item noteItem) bool {
+	return t.similarity(item) >= semanticSearch.minSimilarity()
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/term"
+)
+
+// vaultMagic starts the containers of the encrypted vaults
+const vaultMagic = "SNSMVAULT1\n"
+
+// vaultIterations is the number of PBKDF2 rounds deriving the key from the
+// passphrase, for guessing it to be slow
+const vaultIterations = 600000
+
+// The rounds read from a container must be in this range, a damaged or
+// forged header asking for none or for hours of work
+const (
+	minVaultIterations = 100000
+	maxVaultIterations = 10000000
+)
+
+// errWrongPassphrase is returned when a container can't be decrypted
+var errWrongPassphrase = errors.New("wrong passphrase, or the vault is damaged")
+
+// encryptedVault is an encrypted vault unlocked for the run: its notes are
+// decrypted to a folder of a RAM filesystem, encrypted back into the
+// container when snsm quits, and deleted
+type encryptedVault struct {
+	container  string
+	dir        string
+	passphrase []byte
+}
+
+// unlockedVault is the encrypted vault opened by this run, if any
+var unlockedVault *encryptedVault
+
+// inUnlockedVault reports whether a path is in the decrypted notes of the
+// encrypted vault, which must not be copied out of memory
+func inUnlockedVault(path string) bool {
+	if unlockedVault == nil {
+		return false
+	}
+	rel, err := filepath.Rel(unlockedVault.dir, absPath(path))
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// memoryDir returns a folder of a filesystem kept in memory, for the notes
+// decrypted never to be written on a disk: $XDG_RUNTIME_DIR, which only the
+// user can enter, or else /dev/shm, shared by all the users. macOS has none by
+// default: set $XDG_RUNTIME_DIR to a RAM disk made with hdiutil and diskutil.
+func memoryDir() (string, error) {
+	candidates := []string{os.Getenv("XDG_RUNTIME_DIR")}
+	if runtime.GOOS == "linux" {
+		candidates = append(candidates, "/dev/shm")
+	}
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); dir != "" && err == nil && info.IsDir() {
+			return filepath.Join(dir, fmt.Sprintf("snsm-%d", os.Getuid())), nil
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		return "", fmt.Errorf("encrypted vaults need a filesystem in memory, set $XDG_RUNTIME_DIR to a RAM disk made with hdiutil")
+	}
+	return "", fmt.Errorf("encrypted vaults need a filesystem in memory, $XDG_RUNTIME_DIR or /dev/shm")
+}
+
+// checkPrivateDir makes sure that only the user can read a folder the notes
+// are decrypted in: a folder rather than a symlink, of the user and with
+// mode 0700. Another user could have made it first in /dev/shm otherwise.
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", dir)
+	}
+	if owner, ok := fileOwner(info); ok && owner != os.Getuid() {
+		return fmt.Errorf("%s belongs to another user", dir)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0700 {
+		return fmt.Errorf("%s has mode %o rather than 700", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// unlockVault asks the passphrase of an encrypted vault and decrypts its
+// notes, creating the vault when its container doesn't exist yet
+func unlockVault(name, container string) (*encryptedVault, error) {
+	root, err := memoryDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(root, 0700); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	if err := checkPrivateDir(root); err != nil {
+		return nil, fmt.Errorf("cannot decrypt the notes into %s: %v", root, err)
+	}
+	sum := sha256.Sum256([]byte(absPath(container)))
+	v := &encryptedVault{container: container, dir: filepath.Join(root, hex.EncodeToString(sum[:6]))}
+
+	if _, err := os.Lstat(v.dir); err == nil {
+		if err := checkPrivateDir(v.dir); err != nil {
+			return nil, fmt.Errorf("cannot use the unlocked vault: %v", err)
+		}
+		// Left by a snsm that was killed, or open in another one
+		if !askForConfirmation(fmt.Sprintf("Vault %s is already unlocked in %s, by another snsm or one that was killed. Use these notes, sealing them when you quit?", name, v.dir)) {
+			return nil, fmt.Errorf("vault %s is already unlocked", name)
+		}
+		v.passphrase, err = v.askPassphrase(name)
+		return v, err
+	}
+
+	data, err := os.ReadFile(container)
+	if os.IsNotExist(err) {
+		if !askForConfirmation(fmt.Sprintf("Encrypted vault %s doesn't exist. Create it?", container)) {
+			return nil, fmt.Errorf("cannot continue without the vault")
+		}
+		if v.passphrase, err = newPassphrase(name); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(container), 0755); err != nil {
+			return nil, err
+		}
+		return v, os.MkdirAll(v.dir, 0700)
+	} else if err != nil {
+		return nil, err
+	}
+
+	for tries := 0; ; tries++ {
+		passphrase, err := readPassphrase(fmt.Sprintf("Passphrase of %s: ", name))
+		if err != nil {
+			return nil, err
+		}
+		archive, err := decryptVault(data, passphrase)
+		if errors.Is(err, errWrongPassphrase) && tries < 2 {
+			fmt.Fprintln(os.Stderr, "Wrong passphrase, try again.")
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		v.passphrase = passphrase
+		if err := os.MkdirAll(v.dir, 0700); err != nil {
+			return nil, err
+		}
+		if err := unpackVault(archive, v.dir); err != nil {
+			os.RemoveAll(v.dir)
+			return nil, fmt.Errorf("cannot decrypt %s: %v", container, err)
+		}
+		return v, nil
+	}
+}
+
+// askPassphrase asks the passphrase of a vault already unlocked, checking
+// it against the container so that sealing doesn't change it
+func (v *encryptedVault) askPassphrase(name string) ([]byte, error) {
+	data, err := os.ReadFile(v.container)
+	if os.IsNotExist(err) {
+		return newPassphrase(name)
+	} else if err != nil {
+		return nil, err
+	}
+	passphrase, err := readPassphrase(fmt.Sprintf("Passphrase of %s: ", name))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decryptVault(data, passphrase); err != nil {
+		return nil, err
+	}
+	return passphrase, nil
+}
+
+// newPassphrase asks the passphrase of a new vault twice
+func newPassphrase(name string) ([]byte, error) {
+	passphrase, err := readPassphrase(fmt.Sprintf("New passphrase of %s: ", name))
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("the passphrase can't be empty")
+	}
+	again, err := readPassphrase("Repeat it: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, again) {
+		return nil, fmt.Errorf("the passphrases don't match")
+	}
+	return passphrase, nil
+}
+
+// readPassphrase reads a passphrase from the terminal without echoing it
+func readPassphrase(prompt string) ([]byte, error) {
+	in, out, err := terminalFiles()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprint(out, prompt)
+	passphrase, err := term.ReadPassword(int(in.Fd()))
+	fmt.Fprintln(out)
+	if in != os.Stdin {
+		in.Close()
+	}
+	return passphrase, err
+}
+
+// seal encrypts the notes back into the container and deletes them
+func (v *encryptedVault) seal() error {
+	archive, err := packVault(v.dir)
+	if err != nil {
+		return fmt.Errorf("cannot seal %s: %v", v.container, err)
+	}
+	data, err := encryptVault(archive, v.passphrase)
+	if err != nil {
+		return fmt.Errorf("cannot seal %s: %v", v.container, err)
+	}
+	if err := writeFileAtomic(v.container, data); err != nil {
+		return fmt.Errorf("cannot seal %s: %v, the notes are still in %s", v.container, err, v.dir)
+	}
+	return os.RemoveAll(v.dir)
+}
+
+// sealUnlockedVault seals the encrypted vault of the run, if any, printing
+// the error
+func sealUnlockedVault() {
+	if unlockedVault == nil {
+		return
+	}
+	if err := unlockedVault.seal(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	unlockedVault = nil
+}
+
+// exit seals the encrypted vault of the run, if any, before exiting, the
+// notes being left decrypted otherwise
+func exit(code int) {
+	sealUnlockedVault()
+	os.Exit(code)
+}
+
+// vaultKey derives the key of a vault from its passphrase with
+// PBKDF2-HMAC-SHA256
+func vaultKey(passphrase, salt []byte, iterations int) []byte {
+	return pbkdf2.Key(passphrase, salt, iterations, 32, sha256.New)
+}
+
+// encryptVault encrypts an archive of notes with AES-256-GCM, the header
+// holding the salt and rounds of the key and being authenticated with it
+func encryptVault(archive, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	header := append([]byte(vaultMagic), salt...)
+	header = binary.BigEndian.AppendUint32(header, vaultIterations)
+
+	gcm, err := vaultCipher(vaultKey(passphrase, salt, vaultIterations))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	data := append(header, nonce...)
+	return gcm.Seal(data, nonce, archive, header), nil
+}
+
+// decryptVault decrypts a container, failing with errWrongPassphrase when it
+// can't be authenticated
+func decryptVault(data, passphrase []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(data, []byte(vaultMagic))
+	if !ok || len(rest) < 20 {
+		return nil, fmt.Errorf("not an encrypted snsm vault")
+	}
+	salt, iterations := rest[:16], binary.BigEndian.Uint32(rest[16:20])
+	header := data[:len(vaultMagic)+20]
+	if iterations < minVaultIterations || iterations > maxVaultIterations {
+		return nil, fmt.Errorf("the vault asks for %d rounds of key derivation, snsm only does %d to %d", iterations, minVaultIterations, maxVaultIterations)
+	}
+
+	gcm, err := vaultCipher(vaultKey(passphrase, salt, int(iterations)))
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[20:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errWrongPassphrase
+	}
+	archive, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return archive, nil
+}
+
+func vaultCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// packVault archives the notes of a vault in memory as a tar.gz, without
+// the locks of the notes being edited
+func packVault(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if rel == filepath.FromSlash(locksDir) {
+			return filepath.SkipDir
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unpackVault writes the notes of an archive to a folder
+func unpackVault(archive []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path %q in the vault", header.Name)
+		}
+		path := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				return err
+			}
+			os.Chtimes(path, header.ModTime, header.ModTime)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// fileOwner returns the user owning a file, which Windows doesn't tell with
+// a user ID
+func fileOwner(info fs.FileInfo) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// enexNote is a note of an Evernote export
+type enexNote struct {
+	Title     string         `xml:"title"`
+	Content   string         `xml:"content"`
+	Updated   string         `xml:"updated"`
+	Tags      []string       `xml:"tag"`
+	Resources []enexResource `xml:"resource"`
+}
+
+// enexResource is a file attached to an Evernote note
+type enexResource struct {
+	Data     string `xml:"data"`
+	Mime     string `xml:"mime"`
+	FileName string `xml:"resource-attributes>file-name"`
+}
+
+var (
+	invalidFilenameChars = regexp.MustCompile(`[/\\:*?"<>|]+`)
+	extraBlankLines      = regexp.MustCompile(`\n{3,}`)
+)
+
+// importEvernote imports an ENEX file, or every ENEX file of a folder. The
+// attached files are saved as attachments.
+func (im *importer) importEvernote(src string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	files := []string{src}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(src, "*.enex"))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		if err := im.importEnex(file); err != nil {
+			return fmt.Errorf("failed to import %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+func (im *importer) importEnex(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := xml.NewDecoder(file)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+
+		var note enexNote
+		if err := decoder.DecodeElement(&note, &start); err != nil {
+			return err
+		}
+		if err := im.importEnexNote(note); err != nil {
+			return err
+		}
+	}
+}
+
+func (im *importer) importEnexNote(note enexNote) error {
+	title := strings.TrimSpace(note.Title)
+	name := strings.Join(strings.Fields(invalidFilenameChars.ReplaceAllString(title, " ")), " ")
+	if name == "" {
+		name = "Untitled"
+	}
+
+	// Attachments are referenced by the md5 of their content
+	media := map[string]string{}
+	for _, resource := range note.Resources {
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(resource.Data), ""))
+		if err != nil {
+			im.skip(name+": "+resource.FileName, "invalid attachment data")
+			continue
+		}
+
+		sum := md5.Sum(data)
+		hash := hex.EncodeToString(sum[:])
+		filename := resource.FileName
+		if filename == "" {
+			filename = hash
+			if exts, _ := mime.ExtensionsByType(resource.Mime); len(exts) > 0 {
+				filename += exts[0]
+			}
+		}
+
+		filename = filepath.Join(attachmentsFolder, filepath.Base(filename))
+		if err := im.writeAttachment(filename, data); err != nil {
+			return err
+		}
+		media[hash] = filepath.ToSlash(filename)
+	}
+
+	body := fmt.Sprintf("# %s\n\n%s\n", title, enmlToMarkdown(note.Content, media))
+
+	var modTime time.Time
+	if updated, err := time.Parse("20060102T150405Z", note.Updated); err == nil {
+		modTime = updated
+	}
+
+	return im.writeNote(importedNote{
+		filename: name + ".md",
+		tags:     cleanTags(note.Tags),
+		body:     body,
+		modTime:  modTime,
+	})
+}
+
+// enmlConverter converts the XHTML content of Evernote notes to markdown
+type enmlConverter struct {
+	b strings.Builder
+	// lists holds the ordered state of the open lists
+	lists []bool
+	// hrefs of the open links
+	hrefs []string
+	pre   bool
+	media map[string]string
+}
+
+// enmlToMarkdown converts the content of an Evernote note, media holding the
+// path of the attachments by their hash
+func enmlToMarkdown(content string, media map[string]string) string {
+	c := enmlConverter{media: media}
+
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			c.start(token)
+		case xml.EndElement:
+			c.end(token.Name.Local)
+		case xml.CharData:
+			c.text(string(token))
+		}
+	}
+
+	return strings.TrimSpace(extraBlankLines.ReplaceAllString(c.b.String(), "\n\n"))
+}
+
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// newline ends the current line, if any
+func (c *enmlConverter) newline() {
+	if s := c.b.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		c.b.WriteString("\n")
+	}
+}
+
+// blankLine separates the next block from the current one
+func (c *enmlConverter) blankLine() {
+	c.newline()
+	if s := c.b.String(); s != "" && !strings.HasSuffix(s, "\n\n") {
+		c.b.WriteString("\n")
+	}
+}
+
+func (c *enmlConverter) atLineStart() bool {
+	s := c.b.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
+
+func (c *enmlConverter) start(element xml.StartElement) {
+	switch name := element.Name.Local; name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.blankLine()
+		c.b.WriteString(strings.Repeat("#", int(name[1]-'0')) + " ")
+	case "p", "blockquote":
+		c.blankLine()
+		if name == "blockquote" {
+			c.b.WriteString("> ")
+		}
+	case "div", "tr":
+		c.newline()
+	case "br":
+		c.b.WriteString("\n")
+	case "hr":
+		c.blankLine()
+		c.b.WriteString("---\n\n")
+	case "b", "strong":
+		c.b.WriteString("**")
+	case "i", "em":
+		c.b.WriteString("*")
+	case "s", "strike", "del":
+		c.b.WriteString("~~")
+	case "code":
+		if !c.pre {
+			c.b.WriteString("`")
+		}
+	case "pre":
+		c.blankLine()
+		c.b.WriteString("```\n")
+		c.pre = true
+	case "a":
+		c.b.WriteString("[")
+		c.hrefs = append(c.hrefs, attr(element, "href"))
+	case "ul", "ol":
+		if len(c.lists) == 0 {
+			c.blankLine()
+		}
+		c.lists = append(c.lists, name == "ol")
+	case "li":
+		c.newline()
+		marker := "- "
+		if len(c.lists) > 0 && c.lists[len(c.lists)-1] {
+			marker = "1. "
+		}
+		c.b.WriteString(strings.Repeat("  ", max(len(c.lists)-1, 0)) + marker)
+	case "td", "th":
+		c.b.WriteString("| ")
+	case "en-todo":
+		if c.atLineStart() {
+			c.b.WriteString("- ")
+		}
+		if attr(element, "checked") == "true" {
+			c.b.WriteString("[x] ")
+		} else {
+			c.b.WriteString("[ ] ")
+		}
+	case "en-media":
+		if path, ok := c.media[attr(element, "hash")]; ok {
+			c.b.WriteString("![](" + path + ")")
+		}
+	}
+}
+
+func (c *enmlConverter) end(name string) {
+	switch name {
+	case "h1", "h2", "h3", "h4", "h5", "h6", "p", "blockquote":
+		c.b.WriteString("\n\n")
+	case "div":
+		c.newline()
+	case "b", "strong":
+		c.b.WriteString("**")
+	case "i", "em":
+		c.b.WriteString("*")
+	case "s", "strike", "del":
+		c.b.WriteString("~~")
+	case "code":
+		if !c.pre {
+			c.b.WriteString("`")
+		}
+	case "pre":
+		c.newline()
+		c.b.WriteString("```\n\n")
+		c.pre = false
+	case "a":
+		if len(c.hrefs) > 0 {
+			c.b.WriteString("](" + c.hrefs[len(c.hrefs)-1] + ")")
+			c.hrefs = c.hrefs[:len(c.hrefs)-1]
+		}
+	case "ul", "ol":
+		if len(c.lists) > 0 {
+			c.lists = c.lists[:len(c.lists)-1]
+		}
+		if len(c.lists) == 0 {
+			c.b.WriteString("\n\n")
+		}
+	case "tr":
+		c.b.WriteString("|\n")
+	case "td", "th":
+		c.b.WriteString(" ")
+	}
+}
+
+func (c *enmlConverter) text(text string) {
+	if c.pre {
+		c.b.WriteString(text)
+		return
+	}
+
+	// Whitespace is collapsed like in HTML
+	collapsed := strings.Join(strings.Fields(text), " ")
+	if collapsed != "" && strings.TrimLeftFunc(text, unicode.IsSpace) != text {
+		collapsed = " " + collapsed
+	}
+	if strings.TrimRightFunc(text, unicode.IsSpace) != text {
+		collapsed += " "
+	}
+
+	// Don't start lines with spaces nor double them
+	if c.atLineStart() || strings.HasSuffix(c.b.String(), " ") {
+		collapsed = strings.TrimLeft(collapsed, " ")
+	}
+	c.b.WriteString(collapsed)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// exportConfig sets how notes are exported with e or snsm export
+type exportConfig struct {
+	// Dir is where the exported files are written, the current directory
+	// by default
+	Dir string `yaml:"dir"`
+	// Format is html (default) or pdf
+	Format string `yaml:"format"`
+	// CSS is a stylesheet replacing the default one
+	CSS string `yaml:"css"`
+	// PDFEngine converts the HTML to PDF: wkhtmltopdf or pandoc, the first
+	// one installed when empty
+	PDFEngine string `yaml:"pdf_engine"`
+}
+
+// pdfEngines are the supported HTML to PDF converters, by preference
+var pdfEngines = []string{"wkhtmltopdf", "pandoc"}
+
+// exporter converts notes to standalone HTML or PDF files
+type exporter struct {
+	notesDir string
+	outDir   string
+	format   string
+	css      string
+	engine   string
+	// pageSize and margin lay out the PDF pages, like A4 and 20mm
+	pageSize string
+	margin   string
+}
+
+// newExporter reads the stylesheet and finds the PDF engine an export needs
+func newExporter(notesDir string, cfg exportConfig) (exporter, error) {
+	e := exporter{notesDir: notesDir, outDir: expandTilde(cfg.Dir), format: cfg.Format}
+	if e.outDir == "" {
+		e.outDir = "."
+	}
+	if e.format == "" {
+		e.format = "html"
+	}
+
+	if cfg.CSS != "" {
+		data, err := os.ReadFile(expandTilde(cfg.CSS))
+		if err != nil {
+			return e, fmt.Errorf("failed to read stylesheet: %v", err)
+		}
+		e.css = string(data)
+	}
+
+	switch e.format {
+	case "html":
+	case "pdf":
+		engine, err := findPDFEngine(cfg.PDFEngine)
+		if err != nil {
+			return e, err
+		}
+		e.engine = engine
+	default:
+		return e, fmt.Errorf("unknown export format %q, expected html or pdf", e.format)
+	}
+
+	return e, nil
+}
+
+// findPDFEngine returns the given PDF engine if installed, or the first
+// installed one
+func findPDFEngine(name string) (string, error) {
+	candidates := pdfEngines
+	if name != "" {
+		candidates = []string{name}
+	}
+
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("exporting to PDF needs %s installed", strings.Join(candidates, " or "))
+}
+
+// export writes the files of the given notes and returns their paths. Links
+// between the exported notes are kept.
+func (e exporter) export(filenames []string) ([]string, error) {
+	site := htmlSite{
+		notesDir: e.notesDir,
+		index:    newNoteIndex(filenames),
+		folders:  newFolderConfigs(e.notesDir),
+		css:      e.css,
+	}
+
+	if err := os.MkdirAll(e.outDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", e.outDir, err)
+	}
+
+	var paths []string
+	for _, filename := range filenames {
+		path, err := e.exportNote(site, filename)
+		if err != nil {
+			return paths, fmt.Errorf("failed to export %s: %v", filename, describeError(err))
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func (e exporter) exportNote(site htmlSite, filename string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	outPath := filepath.Join(e.outDir, name+"."+e.format)
+
+	if e.format == "html" {
+		file, err := os.Create(outPath)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		return outPath, site.writePage(file, filename)
+	}
+
+	// The page is written next to the note for its images to be found
+	tmp, err := os.CreateTemp(filepath.Dir(filepath.Join(e.notesDir, filename)), ".snsm-export-*.html")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = site.writePage(tmp, filename)
+	tmp.Close()
+	if err != nil {
+		return "", err
+	}
+
+	var cmd *exec.Cmd
+	switch e.engine {
+	case "wkhtmltopdf":
+		args := []string{"--quiet", "--enable-local-file-access"}
+		if e.pageSize != "" {
+			args = append(args, "--page-size", e.pageSize)
+		}
+		if e.margin != "" {
+			args = append(args, "-T", e.margin, "-B", e.margin, "-L", e.margin, "-R", e.margin)
+		}
+		cmd = exec.Command("wkhtmltopdf", append(args, tmp.Name(), outPath)...)
+	default:
+		args := []string{tmp.Name(), "-o", outPath}
+		if e.pageSize != "" {
+			args = append(args, "-V", "papersize="+strings.ToLower(e.pageSize))
+		}
+		if e.margin != "" {
+			args = append(args, "-V", "geometry:margin="+e.margin)
+		}
+		cmd = exec.Command(e.engine, args...)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s failed: %v %s", e.engine, err, strings.TrimSpace(string(out)))
+	}
+	return outPath, nil
+}
+
+// exportFinishedMsg is sent once the selected note was exported
+type exportFinishedMsg struct {
+	paths []string
+	err   error
+}
+
+// exportSelected exports the selected note in the background, as set in the
+// config
+func (m model) exportSelected() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	e, err := newExporter(m.notesDir, m.cfg.Export)
+	if err != nil {
+		return m, m.setError("Cannot export: %v", err)
+	}
+
+	return m, tea.Batch(m.setInfo("Exporting %s...", item.filename), func() tea.Msg {
+		paths, err := e.export([]string{item.filename})
+		return exportFinishedMsg{paths: paths, err: err}
+	})
+}
+
+func runExport(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", cfg.Export.Format, "html or pdf")
+	out := flags.String("out", cfg.Export.Dir, "directory to write the exported files to")
+	css := flags.String("css", cfg.Export.CSS, "stylesheet replacing the default one")
+	engine := flags.String("pdf-engine", cfg.Export.PDFEngine, "wkhtmltopdf or pandoc, the first one installed by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no note to export")
+	}
+
+	e, err := newExporter(notesDir, exportConfig{Dir: *out, Format: *format, CSS: *css, PDFEngine: *engine})
+	if err != nil {
+		return err
+	}
+
+	var filenames []string
+	for _, filename := range flags.Args() {
+		filenames = append(filenames, noteFilename(notesDir, filename))
+	}
+
+	paths, err := e.export(filenames)
+	for _, path := range paths {
+		fmt.Printf("Exported %s\n", path)
+	}
+	return err
+}
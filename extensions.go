@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// noteExtensions are the extensions of the files listed as notes, lower case
+// with their dot, set from the config
+var noteExtensions = []string{".md", ".org"}
+
+// setNoteExtensions sets the extensions of the notes, markdown and org-mode
+// being used when none are configured
+func setNoteExtensions(extensions []string) {
+	noteExtensions = nil
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if !containsTag(noteExtensions, ext) {
+			noteExtensions = append(noteExtensions, ext)
+		}
+	}
+	if len(noteExtensions) == 0 {
+		noteExtensions = []string{".md", ".org"}
+	}
+}
+
+// isNoteFile reports whether a file has the extension of a note
+func isNoteFile(name string) bool {
+	return containsTag(noteExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// trimNoteExt removes the extension of a note from its filename, other
+// extensions being kept
+func trimNoteExt(filename string) string {
+	if isNoteFile(filename) {
+		return strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+	return filename
+}
+
+// noteFilename adds an extension to the name of a note given without one:
+// the one of the existing note, markdown for a new one
+func noteFilename(notesDir, name string) string {
+	if isNoteFile(name) {
+		return name
+	}
+	for _, ext := range noteExtensions {
+		if _, err := os.Stat(filepath.Join(notesDir, name+ext)); err == nil {
+			return name + ext
+		}
+	}
+	return name + ".md"
+}
+
+// parseTagLine returns the tags of the first line of a note, written the way
+// of its format: "// +tag" in markdown, also "+tag" alone in plain text
+func parseTagLine(filename, line string) (string, bool) {
+	if strings.HasPrefix(line, "//") {
+		return extractTags(line), true
+	}
+
+	if strings.ToLower(filepath.Ext(filename)) != ".txt" {
+		return "", false
+	}
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return "", false
+	}
+	for _, word := range words {
+		if !strings.HasPrefix(word, "+") {
+			return "", false
+		}
+	}
+	return extractTags(line), true
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// feedConfig sets the defaults of snsm feed
+type feedConfig struct {
+	// Title of the feed, the tag by default
+	Title string `yaml:"title"`
+	// URL is where the notes are published with snsm publish, the entries
+	// linking to their page there
+	URL string `yaml:"url"`
+	// Author of the feed, the user by default
+	Author string `yaml:"author"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// feedID returns an id standing for a name, the same every time
+func feedID(name string) string {
+	sum := sha1.Sum([]byte(name))
+	return "urn:uuid:" + nameUUID(sum[:])
+}
+
+// feedEntry builds the entry of a note: its first heading as title, dropped
+// from the content, and the rest rendered to HTML. Wikilinks lead to the
+// published pages when baseURL is set.
+func feedEntry(item noteItem, idx noteIndex, baseURL string) (atomEntry, error) {
+	data, err := os.ReadFile(item.path)
+	if err != nil {
+		return atomEntry{}, err
+	}
+	_, body := splitTagLine(string(data))
+	body = stripFrontMatter(body)
+	title := documentTitle(item.filename, body)
+
+	// The title is shown by the readers already
+	trimmed := strings.TrimLeft(body, "\n")
+	if first, rest, _ := strings.Cut(trimmed, "\n"); strings.HasPrefix(first, "# ") {
+		body = rest
+	}
+
+	renderer := htmlRenderer{resolveWikilink: func(target string) string {
+		linked, ok := idx.resolve(target)
+		if !ok || baseURL == "" {
+			return ""
+		}
+		return baseURL + filepath.ToSlash(htmlPath(linked))
+	}}
+
+	entry := atomEntry{
+		Title:   title,
+		ID:      feedID(item.filename),
+		Updated: item.modTime.UTC().Format(time.RFC3339),
+		Content: atomContent{Type: "html", Body: renderer.markdownToHTML(body)},
+	}
+	if baseURL != "" {
+		link := baseURL + filepath.ToSlash(htmlPath(item.filename))
+		entry.ID = link
+		entry.Links = []atomLink{{Href: link}}
+	}
+	for _, tag := range strings.Fields(item.allTags()) {
+		entry.Categories = append(entry.Categories, atomCategory{Term: strings.TrimPrefix(tag, "+")})
+	}
+	return entry, nil
+}
+
+// writeFeed writes an Atom feed of the notes, the last modified first
+func writeFeed(w io.Writer, items []noteItem, title, baseURL, author string, idx noteIndex) error {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].modTime.After(items[j].modTime)
+	})
+
+	feed := atomFeed{
+		Title:  title,
+		ID:     feedID("snsm feed " + title),
+		Author: atomAuthor{Name: author},
+	}
+	if baseURL != "" {
+		feed.ID = baseURL
+		feed.Links = []atomLink{{Href: baseURL}}
+	}
+	updated := time.Unix(0, 0)
+	for _, item := range items {
+		entry, err := feedEntry(item, idx, baseURL)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", item.filename, describeError(err))
+		}
+		feed.Entries = append(feed.Entries, entry)
+		if item.modTime.After(updated) {
+			updated = item.modTime
+		}
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func runFeed(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("feed", flag.ContinueOnError)
+	tag := flags.String("tag", "", "only the notes with this tag")
+	queryFlag := flags.String("query", "", "only the notes matching this search, e.g. \"tag:blog -tag:draft\"")
+	out := flags.String("out", "", "file to write the feed to, stdout by default")
+	title := flags.String("title", cfg.Feed.Title, "title of the feed")
+	baseURL := flags.String("url", cfg.Feed.URL, "URL the notes are published at with snsm publish")
+	author := flags.String("author", cfg.Feed.Author, "author of the feed")
+	limit := flags.Int("limit", 20, "number of notes in the feed, 0 for all")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	search := *queryFlag
+	if *tag != "" {
+		search = strings.TrimSpace("tag:" + quoteValue(strings.TrimPrefix(*tag, "+")) + " " + search)
+	}
+	q, err := parseQuery(search)
+	if err != nil {
+		return err
+	}
+
+	files, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+	if err := q.prepare(files); err != nil {
+		return err
+	}
+	var items []noteItem
+	for _, item := range files {
+		if item.readErr == nil && q.matches(item) {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].modTime.After(items[j].modTime)
+	})
+	if *limit > 0 && len(items) > *limit {
+		items = items[:*limit]
+	}
+
+	if *title == "" {
+		*title = "Notes"
+		if *tag != "" {
+			*title = strings.TrimPrefix(*tag, "+")
+		}
+	}
+	if *author == "" {
+		*author = os.Getenv("USER")
+	}
+	if *author == "" {
+		*author = "snsm"
+	}
+	if *baseURL != "" && !strings.HasSuffix(*baseURL, "/") {
+		*baseURL += "/"
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		file, err := os.Create(expandTilde(*out))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeFeed(w, items, *title, *baseURL, *author, newNoteIndex(itemFilenames(files)).withAliases(files)); err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d notes to %s\n", len(items), *out)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileLockWait is how long snsm waits for another instance to finish
+// writing a state or cache file
+const fileLockWait = 5 * time.Second
+
+// fileLockStale is the age of a lock left by an instance that crashed while
+// writing, removed rather than waited for
+const fileLockStale = 30 * time.Second
+
+// withFileLock runs fn while holding the lock of a file of the state
+// directory, so that the instances of snsm running at the same time read
+// and write it in turn rather than overwriting each other's changes. The
+// lock is a file next to it, created exclusively, which works on every
+// system and filesystem.
+func withFileLock(path string, fn func() error) error {
+	lock := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to lock %s: %v", filepath.Base(path), err)
+	}
+
+	deadline := time.Now().Add(fileLockWait)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to lock %s: %v", filepath.Base(path), err)
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > fileLockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s is locked by another snsm, delete %s if none is running", filepath.Base(path), lock)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	defer os.Remove(lock)
+
+	return fn()
+}
+
+// writeFileAtomic replaces a file by renaming a complete copy over it, so
+// that readers never see it half written, even when snsm is killed
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
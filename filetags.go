@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// noteFileTags returns the tags of a note as written to the file manager
+// metadata, without their + prefix
+func noteFileTags(item noteItem) []string {
+	var tags []string
+	for _, tag := range strings.Fields(item.allTags()) {
+		tags = append(tags, strings.TrimPrefix(tag, "+"))
+	}
+	return tags
+}
+
+// syncFileTags mirrors the tags of the notes to the file manager metadata of
+// their files, only writing the ones that differ. It returns the number of
+// notes updated and the last error.
+func syncFileTags(items []noteItem) (int, error) {
+	updated := 0
+	var lastErr error
+
+	for _, item := range items {
+		if item.readErr != nil {
+			continue
+		}
+
+		tags := noteFileTags(item)
+		current, err := readFileTags(item.path)
+		if err == nil && strings.Join(current, "\n") == strings.Join(tags, "\n") {
+			continue
+		}
+
+		if err := writeFileTags(item.path, tags); err != nil {
+			lastErr = fmt.Errorf("failed to tag %s: %v", item.filename, describeError(err))
+			continue
+		}
+		updated++
+	}
+
+	return updated, lastErr
+}
+
+// fileTagsSyncedMsg is sent once the tags of the notes were mirrored to the
+// file manager
+type fileTagsSyncedMsg struct {
+	err error
+}
+
+// syncFileTagsCmd mirrors the tags of the listed notes in the background,
+// when enabled in the config
+func (m model) syncFileTagsCmd() tea.Cmd {
+	if !m.cfg.FileManagerTags {
+		return nil
+	}
+
+	items := m.items
+	return func() tea.Msg {
+		_, err := syncFileTags(items)
+		return fileTagsSyncedMsg{err: err}
+	}
+}
+
+func runSyncTags(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("sync-tags", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	files, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+
+	updated, err := syncFileTags(files)
+	fmt.Printf("Tagged %d of %d notes\n", updated, len(files))
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileTagsAttr holds the Finder tags of a file, as a property list
+const fileTagsAttr = "com.apple.metadata:_kMDItemUserTags"
+
+// errNoAttr is returned when a file has no such extended attribute
+const errNoAttr = unix.ENOATTR
+
+// tagsPlist is a property list holding an array of strings
+type tagsPlist struct {
+	XMLName xml.Name `xml:"plist"`
+	Version string   `xml:"version,attr"`
+	Tags    []string `xml:"array>string"`
+}
+
+func encodeFileTags(tags []string) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	xml.NewEncoder(&b).Encode(tagsPlist{Version: "1.0", Tags: tags})
+	return b.Bytes()
+}
+
+// decodeFileTags reads the XML property lists written by snsm. The binary
+// ones written by Finder can't be read and are replaced on the next sync.
+func decodeFileTags(data []byte) ([]string, error) {
+	if bytes.HasPrefix(data, []byte("bplist")) {
+		return nil, fmt.Errorf("binary property list")
+	}
+
+	var plist tagsPlist
+	if err := xml.Unmarshal(data, &plist); err != nil {
+		return nil, err
+	}
+	return plist.Tags, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileTagsAttr holds the tags of a file for the freedesktop file managers,
+// like Dolphin, as comma separated values
+const fileTagsAttr = "user.xdg.tags"
+
+// errNoAttr is returned when a file has no such extended attribute
+const errNoAttr = unix.ENODATA
+
+func encodeFileTags(tags []string) []byte {
+	return []byte(strings.Join(tags, ","))
+}
+
+func decodeFileTags(data []byte) ([]string, error) {
+	var tags []string
+	for _, tag := range strings.Split(string(data), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
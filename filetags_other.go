@@ -0,0 +1,15 @@
+//go:build !darwin && !linux
+
+package main
+
+import "errors"
+
+var errFileTagsUnsupported = errors.New("file manager tags aren't supported on this system")
+
+func readFileTags(path string) ([]string, error) {
+	return nil, errFileTagsUnsupported
+}
+
+func writeFileTags(path string, tags []string) error {
+	return errFileTagsUnsupported
+}
@@ -0,0 +1,39 @@
+//go:build darwin || linux
+
+package main
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// readFileTags returns the tags stored in the file manager metadata of a file
+func readFileTags(path string) ([]string, error) {
+	size, err := unix.Getxattr(path, fileTagsAttr, nil)
+	if errors.Is(err, errNoAttr) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	size, err = unix.Getxattr(path, fileTagsAttr, data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFileTags(data[:size])
+}
+
+// writeFileTags replaces the tags stored in the file manager metadata of a
+// file, removing them when there is none
+func writeFileTags(path string, tags []string) error {
+	if len(tags) == 0 {
+		err := unix.Removexattr(path, fileTagsAttr)
+		if errors.Is(err, errNoAttr) {
+			return nil
+		}
+		return err
+	}
+	return unix.Setxattr(path, fileTagsAttr, encodeFileTags(tags), 0)
+}
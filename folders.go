@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// folderConfigName is the settings file of a folder of notes
+const folderConfigName = ".snsm.yaml"
+
+// folderConfig holds the settings of a folder, which also apply to its
+// subfolders
+type folderConfig struct {
+	// Tags are applied to every note of the folder
+	Tags tagList `yaml:"tags"`
+}
+
+// tagList is a list of tags, written either as a yaml list or as a string of
+// words: "work acme" or [work, acme]
+type tagList []string
+
+func (t *tagList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = strings.Fields(node.Value)
+		return nil
+	}
+
+	var tags []string
+	if err := node.Decode(&tags); err != nil {
+		return err
+	}
+	*t = tags
+	return nil
+}
+
+// folderConfigs reads the folder settings of a notes directory, reading each
+// folder only once
+type folderConfigs struct {
+	notesDir string
+	cache    map[string]folderConfig
+}
+
+func newFolderConfigs(notesDir string) *folderConfigs {
+	return &folderConfigs{notesDir: notesDir, cache: map[string]folderConfig{}}
+}
+
+// load returns the settings of a folder relative to the notes directory. A
+// missing or broken file gives empty settings.
+func (fc *folderConfigs) load(folder string) folderConfig {
+	if cfg, ok := fc.cache[folder]; ok {
+		return cfg
+	}
+
+	var cfg folderConfig
+	if data, err := os.ReadFile(filepath.Join(fc.notesDir, folder, folderConfigName)); err == nil {
+		yaml.Unmarshal(data, &cfg)
+	}
+
+	fc.cache[folder] = cfg
+	return cfg
+}
+
+// tags returns the tags inherited by a note from its folder and the parents
+// of its folder, formatted like a tag line: "+work +acme"
+func (fc *folderConfigs) tags(filename string) string {
+	var tags []string
+	seen := map[string]bool{}
+
+	// From the root of the notes directory down to the folder of the note
+	folder := ""
+	for _, part := range append([]string{""}, splitFolder(filepath.Dir(filename))...) {
+		folder = filepath.Join(folder, part)
+		for _, tag := range fc.load(folder).Tags {
+			tag = canonicalTag(tag)
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return strings.Join(tags, " ")
+}
+
+// splitFolder returns the folders of a relative path, "." giving none
+func splitFolder(folder string) []string {
+	if folder == "." || folder == "" {
+		return nil
+	}
+	return strings.Split(filepath.ToSlash(folder), "/")
+}
+
+// mergeTags joins two tag lines, dropping the tags of extra already in tags
+func mergeTags(tags, extra string) string {
+	merged := strings.Fields(tags)
+	for _, tag := range strings.Fields(extra) {
+		if !containsTag(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return strings.Join(merged, " ")
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// errConflicts is returned by gitSync when the pull stopped on conflicts
+var errConflicts = errors.New("the pull has conflicts")
+
+// notSnsmDir leaves the .snsm folder out of the commits, for the repositories
+// where it was committed before its .gitignore was written
+const notSnsmDir = ":(exclude)" + snsmDir
+
+// git runs a git command in the notes directory and returns its output
+func git(notesDir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = notesDir
+	// Never wait for an editor or a password prompt
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true", "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = strings.TrimSpace(string(out))
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// rebasing reports whether a rebase stopped on conflicts is in progress
+func rebasing(notesDir string) bool {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		path, err := git(notesDir, "rev-parse", "--git-path", dir)
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(notesDir, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// unresolved returns the conflicted files left to resolve, relative to the
+// notes directory, and marks the notes without conflict markers as resolved.
+// The other files, like images, have no markers telling whether they're
+// resolved, so they're left to resolve with git.
+func unresolved(notesDir string) ([]string, error) {
+	out, err := git(notesDir, "diff", "-z", "--name-only", "--diff-filter=U", "--relative")
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, filename := range strings.Split(out, "\x00") {
+		if filename == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(notesDir, filename))
+		if !isNoteFile(filename) || err != nil || bytes.Contains(data, []byte("\n<<<<<<< ")) || bytes.HasPrefix(data, []byte("<<<<<<< ")) {
+			conflicts = append(conflicts, filename)
+			continue
+		}
+		if _, err := git(notesDir, "add", "--", filename); err != nil {
+			return nil, err
+		}
+	}
+	return conflicts, nil
+}
+
+// stoppedOn returns the conflicts a git command failing with err stopped the
+// rebase on, or err when it failed for another reason
+func stoppedOn(notesDir string, err error) ([]string, error) {
+	if !rebasing(notesDir) {
+		return nil, err
+	}
+	conflicts, uerr := unresolved(notesDir)
+	if uerr != nil {
+		return nil, uerr
+	}
+	if len(conflicts) == 0 {
+		return nil, err
+	}
+	return conflicts, errConflicts
+}
+
+// gitSync commits the changes of the notes, pulls with a rebase and pushes,
+// reporting every step to progress. It returns errConflicts along with the
+// conflicted notes when the pull stops on conflicts, and continues the rebase
+// once they're resolved.
+func gitSync(notesDir string, progress func(step string)) ([]string, error) {
+	if _, err := git(notesDir, "rev-parse", "--show-toplevel"); err != nil {
+		return nil, fmt.Errorf("%s is not a git repository", notesDir)
+	}
+
+	if rebasing(notesDir) {
+		progress("Continuing the rebase")
+		conflicts, err := unresolved(notesDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) > 0 {
+			return conflicts, errConflicts
+		}
+		// The next commits of the rebase can stop on conflicts too
+		if _, err := git(notesDir, "rebase", "--continue"); err != nil {
+			return stoppedOn(notesDir, err)
+		}
+	}
+
+	progress("Committing")
+	if _, err := git(notesDir, "add", "-A", "--", ".", notSnsmDir); err != nil {
+		return nil, err
+	}
+	if changes, err := git(notesDir, "status", "--porcelain", "--", ".", notSnsmDir); err != nil {
+		return nil, err
+	} else if changes != "" {
+		host, _ := os.Hostname()
+		message := fmt.Sprintf("snsm sync from %s at %s", host, time.Now().Format("2006-01-02 15:04"))
+		if _, err := git(notesDir, "commit", "-m", message, "--", ".", notSnsmDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// A repository without remote is only committed
+	if _, err := git(notesDir, "rev-parse", "--abbrev-ref", "@{upstream}"); err != nil {
+		return nil, nil
+	}
+
+	progress("Pulling")
+	if _, err := git(notesDir, "pull", "--rebase"); err != nil {
+		return stoppedOn(notesDir, err)
+	}
+
+	progress("Pushing")
+	_, err := git(notesDir, "push")
+	return nil, err
+}
+
+func runSync(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	conflicts, err := gitSync(notesDir, func(step string) {
+		fmt.Println(step + "...")
+	})
+	if errors.Is(err, errConflicts) {
+		fmt.Println("Conflicts in:")
+		for _, filename := range conflicts {
+			if !isNoteFile(filename) {
+				filename += " (keep a version with git checkout --ours or --theirs, then git add it)"
+			}
+			fmt.Println("  " + filename)
+		}
+		return fmt.Errorf("fix the conflicts and run snsm sync again to finish")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Synced")
+	return nil
+}
+
+// syncProgressMsg reports a step of the sync running in the background
+type syncProgressMsg struct {
+	step string
+	next chan tea.Msg
+}
+
+// syncFinishedMsg is sent when the sync ends
+type syncFinishedMsg struct {
+	conflicts []string
+	err       error
+}
+
+// waitSync waits for the next message of the sync
+func waitSync(messages chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-messages
+	}
+}
+
+// startSync syncs the notes with git in the background
+func (m model) startSync() (tea.Model, tea.Cmd) {
+	if m.syncing {
+		return m, nil
+	}
+	m.syncing = true
+
+	messages := make(chan tea.Msg)
+	notesDir := m.notesDir
+	go func() {
+		conflicts, err := gitSync(notesDir, func(step string) {
+			messages <- syncProgressMsg{step: step, next: messages}
+		})
+		messages <- syncFinishedMsg{conflicts: conflicts, err: err}
+	}()
+	return m, waitSync(messages)
+}
+
+// updateSync handles the messages of the sync running in the background
+func (m model) updateSync(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case syncProgressMsg:
+		return m, tea.Batch(m.setInfo("%s...", msg.step), waitSync(msg.next))
+
+	case syncFinishedMsg:
+		m.syncing = false
+		reload := m.reloadNotes()
+		if errors.Is(msg.err, errConflicts) {
+			m.conflictsMenu = menu{title: "Conflicts, fix them then sync again"}
+			for _, filename := range msg.conflicts {
+				m.conflictsMenu.items = append(m.conflictsMenu.items, menuItem{label: filename})
+			}
+			m.mode = modeConflicts
+			return m, reload
+		}
+		if msg.err != nil {
+			return m, tea.Batch(reload, m.setError("Cannot sync: %v", msg.err))
+		}
+		return m, tea.Batch(reload, m.setInfo("Synced"))
+	}
+	return m, nil
+}
+
+func (m model) updateConflicts(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.conflictsMenu.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+	filename := m.conflictsMenu.items[m.conflictsMenu.cursor].label
+	// Attachments have no markers to fix in the editor
+	if !isNoteFile(filename) {
+		return m, m.setError("Keep a version of %s with git checkout --ours or --theirs, then git add it", filename)
+	}
+	return m, m.openNote(filename)
+}
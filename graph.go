@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// linkGraph holds the wikilinks between notes, by filename
+type linkGraph struct {
+	// links are the notes a note links to, backlinks the notes linking to it
+	links     map[string][]string
+	backlinks map[string][]string
+	// cluster is the index of the group of notes connected to a note, in
+	// either direction, and clusterSizes the number of notes of each group
+	cluster      map[string]int
+	clusterSizes []int
+}
+
+// buildGraph reads the wikilinks of the notes. Links to missing notes and
+// links of a note to itself are left out.
+func buildGraph(items []noteItem) linkGraph {
+	g := linkGraph{links: map[string][]string{}, backlinks: map[string][]string{}, cluster: map[string]int{}}
+	idx := newNoteIndex(itemFilenames(items)).withAliases(items)
+
+	for _, item := range items {
+		seen := map[string]bool{}
+		for _, match := range wikilinkRegex.FindAllStringSubmatch(noteContents.get(item), -1) {
+			target, ok := idx.resolve(match[1])
+			if !ok || target == item.filename || seen[target] {
+				continue
+			}
+			seen[target] = true
+			g.links[item.filename] = append(g.links[item.filename], target)
+			g.backlinks[target] = append(g.backlinks[target], item.filename)
+		}
+	}
+	for _, neighbors := range []map[string][]string{g.links, g.backlinks} {
+		for _, filenames := range neighbors {
+			sort.Strings(filenames)
+		}
+	}
+
+	// Number the clusters by walking the links in both directions
+	for _, item := range items {
+		if _, ok := g.cluster[item.filename]; ok {
+			continue
+		}
+		id := len(g.clusterSizes)
+		g.clusterSizes = append(g.clusterSizes, 0)
+		stack := []string{item.filename}
+		g.cluster[item.filename] = id
+		for len(stack) > 0 {
+			filename := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			g.clusterSizes[id]++
+			for _, next := range append(g.links[filename], g.backlinks[filename]...) {
+				if _, ok := g.cluster[next]; !ok {
+					g.cluster[next] = id
+					stack = append(stack, next)
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+// isOrphan reports whether a note has no links and no backlinks
+func (g linkGraph) isOrphan(filename string) bool {
+	return len(g.links[filename]) == 0 && len(g.backlinks[filename]) == 0
+}
+
+// Columns of the graph view
+const (
+	columnBacklinks = iota
+	columnLinks
+)
+
+// graphView explores the links of a note, moving from note to note
+type graphView struct {
+	graph linkGraph
+	// current is the note in the middle, path the notes visited before it
+	current string
+	path    []string
+	column  int
+	cursor  int
+
+	orphans     bool
+	orphansMenu menu
+}
+
+// neighbors returns the notes of the focused column
+func (v graphView) neighbors() []string {
+	if v.column == columnBacklinks {
+		return v.graph.backlinks[v.current]
+	}
+	return v.graph.links[v.current]
+}
+
+// visit centers the view on a note
+func (v *graphView) visit(filename string) {
+	v.current = filename
+	v.cursor = 0
+	// Start on the side having notes
+	v.column = columnLinks
+	if len(v.graph.links[filename]) == 0 {
+		v.column = columnBacklinks
+	}
+}
+
+// openGraph shows the links of the selected note
+func (m model) openGraph() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	m.graph = graphView{graph: buildGraph(m.items)}
+	m.graph.visit(item.filename)
+	m.mode = modeGraph
+	return m, nil
+}
+
+// showOrphans lists the notes without links nor backlinks
+func (m model) showOrphans() (tea.Model, tea.Cmd) {
+	m.graph.orphansMenu = menu{title: "Orphan notes"}
+	for _, item := range m.items {
+		if m.graph.graph.isOrphan(item.filename) {
+			m.graph.orphansMenu.items = append(m.graph.orphansMenu.items, menuItem{label: item.filename})
+		}
+	}
+	if len(m.graph.orphansMenu.items) == 0 {
+		return m, m.setInfo("No orphan notes")
+	}
+	m.graph.orphans = true
+	return m, nil
+}
+
+func (m model) updateGraph(msg tea.Msg) (tea.Model, tea.Cmd) {
+	v := &m.graph
+	if v.orphans {
+		chosen, closed := v.orphansMenu.update(msg)
+		if closed {
+			v.orphans = false
+		} else if chosen {
+			v.orphans = false
+			v.path = append(v.path, v.current)
+			v.visit(v.orphansMenu.items[v.orphansMenu.cursor].label)
+		}
+		return m, nil
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if v.cursor > 0 {
+			v.cursor--
+		}
+	case "down", "j":
+		if v.cursor < len(v.neighbors())-1 {
+			v.cursor++
+		}
+	case "left", "h":
+		v.column, v.cursor = columnBacklinks, 0
+	case "right", "l":
+		v.column, v.cursor = columnLinks, 0
+	case "enter":
+		neighbors := v.neighbors()
+		if len(neighbors) == 0 {
+			return m, nil
+		}
+		v.path = append(v.path, v.current)
+		v.visit(neighbors[v.cursor])
+	case "backspace", "b":
+		if len(v.path) > 0 {
+			previous := v.path[len(v.path)-1]
+			v.path = v.path[:len(v.path)-1]
+			v.visit(previous)
+		}
+	case "e":
+		return m, m.openNote(v.current)
+	case "O":
+		return m.showOrphans()
+	case "esc", "q":
+		// Leave the list on the note explored last
+		m.selectNote(v.current)
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m model) viewGraph() string {
+	v := m.graph
+	if v.orphans {
+		return v.orphansMenu.view(m.width, m.height)
+	}
+
+	var b strings.Builder
+	orphans := 0
+	for _, item := range m.items {
+		if v.graph.isOrphan(item.filename) {
+			orphans++
+		}
+	}
+	b.WriteString("\n" + titleStyle.Render("Links") + "  " + mutedStyle.Render(fmt.Sprintf(
+		"%d notes • %d clusters • %d orphans", len(m.items), len(v.graph.clusterSizes), orphans)) + "\n\n")
+
+	// The note in the middle, its backlinks on the left and its links on the
+	// right
+	columnWidth := max((m.width-8)/3, 12)
+	height := max(m.height-8, 3)
+
+	column := func(id int, arrow string, filenames []string) string {
+		var lines []string
+		offset := 0
+		if v.column == id && v.cursor >= height {
+			offset = v.cursor - height + 1
+		}
+		for i, filename := range filenames {
+			if i < offset || i >= offset+height {
+				continue
+			}
+			line := truncateWidth(arrow+" "+noteTitle(filename), columnWidth-2)
+			if v.column == id && i == v.cursor {
+				lines = append(lines, selectedItemStyle.Copy().PaddingLeft(0).Render(line))
+			} else {
+				lines = append(lines, line)
+			}
+		}
+		if len(filenames) == 0 {
+			lines = append(lines, mutedStyle.Render("none"))
+		}
+		return lipgloss.NewStyle().Width(columnWidth).Render(strings.Join(lines, "\n"))
+	}
+
+	backlinks := v.graph.backlinks[v.current]
+	links := v.graph.links[v.current]
+	header := lipgloss.NewStyle().Width(columnWidth).Bold(true)
+	left := header.Render(fmt.Sprintf("Linked from (%d)", len(backlinks))) + "\n" + column(columnBacklinks, "←", backlinks)
+	right := header.Render(fmt.Sprintf("Links to (%d)", len(links))) + "\n" + column(columnLinks, "→", links)
+
+	size := v.graph.clusterSizes[v.graph.cluster[v.current]]
+	center := menuStyle.Copy().Padding(0, 1).Width(columnWidth - 2).Render(
+		titleStyle.Copy().MarginLeft(0).Bold(true).Render(truncateWidth(noteTitle(v.current), columnWidth-6)) + "\n" +
+			mutedStyle.Render(truncateWidth(v.current, columnWidth-6)) + "\n" +
+			mutedStyle.Render(fmt.Sprintf("cluster of %d notes", size)))
+
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, "  ", left, " ", center, " ", right))
+
+	if len(v.path) > 0 {
+		var trail []string
+		for _, filename := range v.path[max(len(v.path)-4, 0):] {
+			trail = append(trail, noteTitle(filename))
+		}
+		b.WriteString("\n\n" + itemStyle.Copy().PaddingLeft(2).Render(mutedStyle.Render(truncateWidth(
+			strings.Join(trail, " › ")+" › "+noteTitle(v.current), max(m.width-4, 10)))))
+	}
+
+	return m.withHelp(b.String(), "←/→ backlinks/links • enter follow • b back • e edit • O orphans • esc close")
+}
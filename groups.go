@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Groupings of the list, the notes being listed flat without one
+const (
+	groupTag    = "tag"
+	groupFolder = "folder"
+)
+
+// groupModes are the groupings the group key cycles through, none first
+var groupModes = []string{"", groupTag, groupFolder}
+
+// groupHeader is the header of a section of the grouped list, listed before
+// its notes unless collapsed
+type groupHeader struct {
+	name      string
+	mode      string
+	count     int
+	collapsed bool
+}
+
+// FilterValue is empty so that the filter leaves the headers out
+func (h groupHeader) FilterValue() string { return "" }
+
+// label returns the name of the section shown in its header
+func (h groupHeader) label() string {
+	switch {
+	case h.name != "":
+		return h.name
+	case h.mode == groupTag:
+		return "Untagged"
+	default:
+		return "Top level"
+	}
+}
+
+// noteGroup returns the section of a note: its first tag, or its folder
+func noteGroup(item noteItem, mode string) string {
+	switch mode {
+	case groupTag:
+		if tags := strings.Fields(item.allTags()); len(tags) > 0 {
+			return tagLabel(tags[0])
+		}
+	case groupFolder:
+		if folder := filepath.Dir(item.filename); folder != "." {
+			return folder
+		}
+	}
+	return ""
+}
+
+// groupNotes splits the notes in sections, keeping their order in each. The
+// sections are sorted by name, the untagged notes last and the notes of the
+// notes directory first.
+func groupNotes(notes []noteItem, mode string) ([]string, map[string][]noteItem) {
+	groups := map[string][]noteItem{}
+	var names []string
+	for _, item := range notes {
+		name := noteGroup(item, mode)
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], item)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if mode == groupTag && (names[i] == "" || names[j] == "") {
+			return names[j] == ""
+		}
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+	return names, groups
+}
+
+// groupedItems returns the items of the grouped list, and the notes aligned
+// with them for the query filter, empty for the headers
+func (m model) groupedItems(notes []noteItem) ([]list.Item, []noteItem) {
+	var items []list.Item
+	var shown []noteItem
+	names, groups := groupNotes(notes, m.group)
+	for _, name := range names {
+		header := groupHeader{name: name, mode: m.group, count: len(groups[name]), collapsed: m.collapsed[name]}
+		items = append(items, header)
+		shown = append(shown, noteItem{})
+		if header.collapsed {
+			continue
+		}
+		for _, item := range groups[name] {
+			items = append(items, item)
+			shown = append(shown, item)
+		}
+	}
+	return items, shown
+}
+
+// cycleGroup switches to the next grouping of the list
+func (m model) cycleGroup() (tea.Model, tea.Cmd) {
+	next := 0
+	for i, mode := range groupModes {
+		if mode == m.group {
+			next = (i + 1) % len(groupModes)
+		}
+	}
+	return m.groupBy(groupModes[next])
+}
+
+// groupBy groups the notes of the list, keeping the selection
+func (m model) groupBy(mode string) (tea.Model, tea.Cmd) {
+	m.group = mode
+	m.collapsed = map[string]bool{}
+
+	var selected string
+	if item, ok := m.list.SelectedItem().(noteItem); ok {
+		selected = item.filename
+	}
+	cmd := m.setListItems()
+	m.selectNote(selected)
+
+	if mode == "" {
+		return m, tea.Batch(cmd, m.setInfo("Not grouped"))
+	}
+	return m, tea.Batch(cmd, m.setInfo("Grouped by %s", mode))
+}
+
+// toggleSection collapses or expands the section of the selected header or
+// note, leaving the cursor on its header
+func (m model) toggleSection() (tea.Model, tea.Cmd) {
+	var name string
+	switch item := m.list.SelectedItem().(type) {
+	case groupHeader:
+		name = item.name
+	case noteItem:
+		name = noteGroup(item, m.group)
+	default:
+		return m, nil
+	}
+	m.collapsed[name] = !m.collapsed[name]
+	cmd := m.setListItems()
+	m.selectSection(name)
+	return m, cmd
+}
+
+// setSections collapses or expands every section
+func (m model) setSections(collapsed bool) (tea.Model, tea.Cmd) {
+	var name string
+	switch item := m.list.SelectedItem().(type) {
+	case groupHeader:
+		name = item.name
+	case noteItem:
+		name = noteGroup(item, m.group)
+	}
+
+	m.collapsed = map[string]bool{}
+	if collapsed {
+		for _, item := range m.items {
+			m.collapsed[noteGroup(item, m.group)] = true
+		}
+	}
+	cmd := m.setListItems()
+	m.selectSection(name)
+	return m, cmd
+}
+
+// selectSection moves the cursor to the header of a section
+func (m *model) selectSection(name string) {
+	for i, listItem := range m.list.VisibleItems() {
+		if header, ok := listItem.(groupHeader); ok && header.name == name {
+			m.list.Select(i)
+			return
+		}
+	}
+}
+
+// renderHeader draws the header of a section, with its number of notes
+func (d customItemDelegate) renderHeader(w io.Writer, header groupHeader, selected bool) {
+	arrow := "▾"
+	if header.collapsed {
+		arrow = "▸"
+	}
+	line := arrow + " " + header.label() + "  " + mutedStyle.Render(fmt.Sprint(header.count))
+	if selected {
+		line = d.Styles.SelectedTitle.Copy().Bold(true).Render(line)
+	} else {
+		line = d.Styles.NormalTitle.Copy().Bold(true).Render(line)
+	}
+	fmt.Fprint(w, line)
+}
+
+// exGroup groups the notes of the list by tag or by folder, or lists them
+// flat with none
+func exGroup(m model, args []string) (tea.Model, tea.Cmd) {
+	if len(args) != 1 {
+		return m, m.setError(":group needs one of none, tag, folder")
+	}
+	switch args[0] {
+	case "none":
+		return m.groupBy("")
+	case groupTag, groupFolder:
+		return m.groupBy(args[0])
+	}
+	return m, m.setError("Unknown grouping %q, expected none, tag or folder", args[0])
+}
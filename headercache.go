@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headerCache keeps the headers read by the previous scans, by absolute
+// path, so that a scan only opens the notes modified since. Large vaults
+// start without reading thousands of files.
+type headerCache struct {
+	mu      sync.Mutex
+	loaded  bool
+	changed bool
+	file    headerCacheFile
+	// pruned are the notes forgotten since the last save, not to be taken
+	// back from the cache of another instance
+	pruned map[string]bool
+}
+
+// headerCacheFile is the saved cache
+type headerCacheFile struct {
+	// Settings are the settings the headers were read with, the cache
+	// being dropped when they change
+	Settings string                  `json:"settings"`
+	Notes    map[string]cachedHeader `json:"notes"`
+}
+
+// cachedHeader is the header of a note, kept while its size and
+// modification time don't change
+type cachedHeader struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Tags    string    `json:"tags,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	Label   string    `json:"label,omitempty"`
+	Heading string    `json:"heading,omitempty"`
+	Due     time.Time `json:"due"`
+	Date    time.Time `json:"date"`
+	Parent  string    `json:"parent,omitempty"`
+	Related []string  `json:"related,omitempty"`
+	Aliases []string  `json:"aliases,omitempty"`
+	NotUTF8 bool      `json:"not_utf8,omitempty"`
+	// Stats are counted when the info of the note is first shown
+	Stats *noteStats `json:"stats,omitempty"`
+}
+
+var headers headerCache
+
+// headerCachePath returns the location of the cache, next to the state
+func headerCachePath() string {
+	return filepath.Join(filepath.Dir(statePath()), "headers.json")
+}
+
+// headerCacheVersion changes when the headers hold more, for the notes to
+// be read again
+const headerCacheVersion = 4
+
+// headerSettings describes the settings changing how the headers are read
+func headerSettings() string {
+	data, _ := json.Marshal(struct {
+		Version       int
+		HeadingTitles bool
+		TagRules      tagRulesConfig
+	}{headerCacheVersion, headingTitles, tagRules})
+	return string(data)
+}
+
+// load reads the saved cache once, a missing or broken file or other
+// settings giving an empty one
+func (c *headerCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.file = readHeaderCache()
+}
+
+// readHeaderCache reads the saved cache
+func readHeaderCache() headerCacheFile {
+	var file headerCacheFile
+	settings := headerSettings()
+	if data, err := os.ReadFile(headerCachePath()); err == nil {
+		json.Unmarshal(data, &file)
+	}
+	if file.Settings != settings || file.Notes == nil {
+		file = headerCacheFile{Settings: settings, Notes: map[string]cachedHeader{}}
+	}
+	return file
+}
+
+// lookup returns the header of a note when it didn't change since it was
+// cached
+func (c *headerCache) lookup(path string, info os.FileInfo) (cachedHeader, bool) {
+	h, ok := c.file.Notes[path]
+	if !ok || h.Size != info.Size() || !h.ModTime.Equal(info.ModTime()) {
+		return cachedHeader{}, false
+	}
+	return h, true
+}
+
+// store caches the header of a note
+func (c *headerCache) store(path string, h cachedHeader) {
+	c.file.Notes[path] = h
+	c.changed = true
+}
+
+// forget drops the header of a note, like a sensitive one
+func (c *headerCache) forget(path string) {
+	if _, ok := c.file.Notes[path]; !ok {
+		return
+	}
+	delete(c.file.Notes, path)
+	if c.pruned == nil {
+		c.pruned = map[string]bool{}
+	}
+	c.pruned[path] = true
+	c.changed = true
+}
+
+// prune forgets the notes of a directory that weren't seen by its last
+// scan, deleted or renamed since
+func (c *headerCache) prune(dir string, seen map[string]bool) {
+	prefix := absPath(dir) + string(filepath.Separator)
+	for path := range c.file.Notes {
+		if strings.HasPrefix(path, prefix) && !seen[path] {
+			c.forget(path)
+		}
+	}
+}
+
+// save writes the cache when it changed, merged with the headers saved
+// meanwhile by other instances, like the ones of another vault
+func (c *headerCache) save() error {
+	if !c.changed {
+		return nil
+	}
+	err := withFileLock(headerCachePath(), func() error {
+		for path, h := range readHeaderCache().Notes {
+			if _, ok := c.file.Notes[path]; !ok && !c.pruned[path] {
+				c.file.Notes[path] = h
+			}
+		}
+		data, err := json.Marshal(c.file)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(headerCachePath(), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save headers.json: %v", err)
+	}
+	c.changed = false
+	c.pruned = nil
+	return nil
+}
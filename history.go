@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+// maxOpens is how many openings of a note are kept to compute its frecency
+const maxOpens = 10
+
+// maxRecent is how many notes the recent notes menu lists
+const maxRecent = 20
+
+// noteHistory holds when the notes were opened, by notes directory and
+// filename, the last opening first
+type noteHistory map[string]map[string][]time.Time
+
+// historyPath returns the location of the history file, next to the state
+func historyPath() string {
+	return filepath.Join(filepath.Dir(statePath()), "history.yaml")
+}
+
+// loadHistory reads the history file, a missing or broken file gives an
+// empty history
+func loadHistory() noteHistory {
+	history := noteHistory{}
+
+	data, err := os.ReadFile(historyPath())
+	if err != nil {
+		return history
+	}
+	yaml.Unmarshal(data, &history)
+
+	return history
+}
+
+// add adds an opening of a note, keeping the last maxOpens
+func (h noteHistory) add(notesDir, filename string, at time.Time) {
+	if h[notesDir] == nil {
+		h[notesDir] = map[string][]time.Time{}
+	}
+	opens := append([]time.Time{at}, h[notesDir][filename]...)
+	if len(opens) > maxOpens {
+		opens = opens[:maxOpens]
+	}
+	h[notesDir][filename] = opens
+}
+
+// record adds an opening of a note to the history and saves it, to the
+// history file read again so that the openings of other instances are kept.
+// The history of an encrypted vault stays in memory.
+func (h noteHistory) record(notesDir, filename string, at time.Time) error {
+	h.add(notesDir, filename, at)
+	if inUnlockedVault(notesDir) {
+		return nil
+	}
+	return withFileLock(historyPath(), func() error {
+		saved := loadHistory()
+		saved.add(notesDir, filename, at)
+		return writeStateFile(historyPath(), saved)
+	})
+}
+
+// frecency scores how frequently and recently a note was opened, recent
+// openings weighing more
+func frecency(opens []time.Time, now time.Time) float64 {
+	score := 0.0
+	for _, at := range opens {
+		switch age := now.Sub(at); {
+		case age < 4*24*time.Hour:
+			score += 100
+		case age < 14*24*time.Hour:
+			score += 70
+		case age < 31*24*time.Hour:
+			score += 50
+		case age < 90*24*time.Hour:
+			score += 30
+		default:
+			score += 10
+		}
+	}
+	return score
+}
+
+// timeAgo describes how long ago a time was, roughly
+func timeAgo(t time.Time) string {
+	switch age := time.Since(t); {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	}
+}
+
+// openRecent shows the menu of the notes opened last
+func (m model) openRecent() (tea.Model, tea.Cmd) {
+	opens := m.history[m.notesDir]
+
+	var notes []noteItem
+	for _, item := range m.items {
+		if len(opens[item.filename]) > 0 {
+			notes = append(notes, item)
+		}
+	}
+	if len(notes) == 0 {
+		return m, m.setError("No notes opened yet")
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		return opens[notes[i].filename][0].After(opens[notes[j].filename][0])
+	})
+	if len(notes) > maxRecent {
+		notes = notes[:maxRecent]
+	}
+
+	m.recent = notes
+	m.recentMenu = menu{title: "Recent notes"}
+	for _, item := range notes {
+		m.recentMenu.items = append(m.recentMenu.items, menuItem{label: item.Title(), hint: timeAgo(opens[item.filename][0])})
+	}
+
+	m.mode = modeRecent
+	return m, nil
+}
+
+func (m model) updateRecent(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.recentMenu.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+
+	m.mode = modeList
+	filename := m.recent[m.recentMenu.cursor].filename
+	m.selectNote(filename)
+	return m, m.openNote(filename)
+}
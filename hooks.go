@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hooksConfig holds the shell commands run when notes are created, edited or
+// deleted, with the note in their environment
+type hooksConfig struct {
+	// PostCreate runs after a note is created
+	PostCreate string `yaml:"post_create"`
+	// PostEdit runs after a note is changed in the editor or the web UI
+	PostEdit string `yaml:"post_edit"`
+	// PreDelete runs before a note is deleted, which is canceled when it
+	// fails
+	PreDelete string `yaml:"pre_delete"`
+}
+
+// noteHooks are the hooks of the config
+var noteHooks hooksConfig
+
+// hookTimeout stops hooks that hang, like a git push waiting for a password
+const hookTimeout = time.Minute
+
+// runHook runs the hook of an event on a note, given relative to the notes
+// directory, from the notes directory. The hook gets the note in
+// $SNSM_NOTE, $SNSM_FILENAME and $SNSM_TAGS. Its output is returned in the
+// error when it fails. The plugins get the event next.
+func runHook(event, notesDir, filename string) error {
+	command := hookCommand(event)
+	if command == "" {
+		return runPlugins(event, notesDir, filename)
+	}
+
+	cmd := shellCommand(command)
+	cmd.Dir = notesDir
+	cmd.Env = append(os.Environ(), noteEnv(notesDir, filename)...)
+	cmd.Env = append(cmd.Env, "SNSM_HOOK="+event)
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s hook: %v", event, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s hook: %v%s", event, err, hookOutput(output.String()))
+		}
+		return runPlugins(event, notesDir, filename)
+	case <-time.After(hookTimeout):
+		cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("%s hook: timed out after %v", event, hookTimeout)
+	}
+}
+
+// hookCommand returns the command of the hook of an event
+func hookCommand(event string) string {
+	switch event {
+	case "post_create":
+		return noteHooks.PostCreate
+	case "post_edit":
+		return noteHooks.PostEdit
+	case "pre_delete":
+		return noteHooks.PreDelete
+	}
+	return ""
+}
+
+// hasHook reports whether a hook or a plugin gets an event
+func hasHook(event string) bool {
+	return hookCommand(event) != "" || len(listPlugins()) > 0
+}
+
+// noteSaved counts a note created or edited in the activity of the day and
+// runs its hook, for the commands run without the list
+func noteSaved(event, notesDir, filename string) error {
+	if err := recordActivity(notesDir, time.Now()); err != nil {
+		debugLog.Warn("activity", "err", err)
+	}
+	return runHook(event, notesDir, filename)
+}
+
+// hookFinishedMsg is sent once a hook ran in the background
+type hookFinishedMsg struct {
+	event    string
+	filename string
+	// notesDir is the notes directory the hook ran in, which ctrl+o may have
+	// switched away from since
+	notesDir string
+	// done tells what was done to the note before the hook, for its error
+	done string
+	err  error
+	// then carries on once the pre_delete hook agreed, deleting the note
+	then func(m model) (tea.Model, tea.Cmd)
+	// before is the content of the note before the hook or the plugins
+	// changed it, retagging it for instance, for u
+	before []byte
+}
+
+// runChanging runs a hook or a plugin on a note, and returns the former
+// content of the note when they changed it
+func runChanging(notesDir, filename string, run func() error) ([]byte, error) {
+	path := filepath.Join(notesDir, filename)
+	before, readErr := os.ReadFile(path)
+	err := run()
+	if after, _ := os.ReadFile(path); readErr != nil || bytes.Equal(before, after) {
+		return nil, err
+	}
+	return before, err
+}
+
+// noteSavedCmd counts a note created or edited in the activity of the day
+// and runs its hook in the background, the list staying responsive while a
+// slow hook runs. done tells what was done to the note, like "Saved x.md".
+func (m model) noteSavedCmd(event, filename, done string) tea.Cmd {
+	if err := recordActivity(m.notesDir, time.Now()); err != nil {
+		debugLog.Warn("activity", "err", err)
+	}
+	if !hasHook(event) {
+		return nil
+	}
+	notesDir := m.notesDir
+	return func() tea.Msg {
+		before, err := runChanging(notesDir, filename, func() error { return runHook(event, notesDir, filename) })
+		return hookFinishedMsg{event: event, filename: filename, notesDir: notesDir, done: done, err: err, before: before}
+	}
+}
+
+// beforeDelete runs the pre_delete hook of a note in the background, and
+// then deletes it with del once the hook agreed
+func (m model) beforeDelete(filename string, del func(m model) (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	if !hasHook("pre_delete") {
+		return del(m)
+	}
+	notesDir := m.notesDir
+	return m, tea.Batch(m.setInfo("Deleting %s...", filename), func() tea.Msg {
+		return hookFinishedMsg{event: "pre_delete", filename: filename, notesDir: notesDir, err: runHook("pre_delete", notesDir, filename), then: del}
+	})
+}
+
+// hookFinished reloads the notes the hook may have changed, deletes the note
+// the pre_delete hook agreed to, or tells why the hook failed. The note isn't
+// deleted when another vault was opened while the hook ran.
+func (m model) hookFinished(msg hookFinishedMsg) (tea.Model, tea.Cmd) {
+	// The undo history is of the vault now open
+	if msg.before != nil && msg.notesDir == m.notesDir {
+		m.pushUndo(msg.event+" hook changes to "+msg.filename, restoreContent(m.notesDir, msg.filename, msg.before))
+	}
+	switch {
+	case msg.err != nil && msg.event == "pre_delete":
+		return m, m.setError("Cannot delete %s: %v", msg.filename, msg.err)
+	case msg.err != nil:
+		return m, tea.Batch(m.reloadNotes(), m.setError("%s, but %v", msg.done, msg.err))
+	case msg.then != nil && msg.notesDir != m.notesDir:
+		return m, m.setError("Not deleting %s: another vault was opened while its pre_delete hook ran", msg.filename)
+	case msg.then != nil:
+		return msg.then(m)
+	}
+	return m, m.reloadNotes()
+}
+
+// shellCommand runs a command line with the shell of the platform
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// noteEnv returns the environment variables giving a note to the commands run
+// on it
+func noteEnv(notesDir, filename string) []string {
+	path := filepath.Join(notesDir, filename)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	tags, _, _ := readHeader(path)
+	tags = mergeTags(tags, newFolderConfigs(notesDir).tags(filename))
+
+	return []string{
+		"SNSM_NOTE=" + path,
+		"SNSM_FILENAME=" + filepath.ToSlash(filename),
+		"SNSM_TAGS=" + strings.Join(strings.Fields(strings.ReplaceAll(tags, "+", "")), " "),
+		"SNSM_NOTES_DIR=" + notesDir,
+	}
+}
+
+// hookOutput returns the last line a failed hook printed, for the error
+func hookOutput(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return ": " + last
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// htmlRenderer renders parsed markdown to an HTML fragment
+type htmlRenderer struct {
+	// resolveWikilink returns the href of a [[wikilink]] target, links are
+	// left unresolved when nil or when it returns an empty string
+	resolveWikilink func(target string) string
+}
+
+// markdownToHTML converts a markdown document to an HTML fragment
+func (r htmlRenderer) markdownToHTML(src string) string {
+	var b strings.Builder
+	r.renderBlocks(&b, parseMarkdown(src), false)
+	return b.String()
+}
+
+// renderBlocks writes blocks, tight list items get their paragraphs unwrapped
+func (r htmlRenderer) renderBlocks(b *strings.Builder, blocks []mdBlock, tight bool) {
+	for _, block := range blocks {
+		switch block.kind {
+		case blockParagraph:
+			if tight {
+				r.renderInline(b, parseInline(block.text))
+				b.WriteString("\n")
+				continue
+			}
+			b.WriteString("<p>")
+			r.renderInline(b, parseInline(block.text))
+			b.WriteString("</p>\n")
+
+		case blockHeading:
+			spans := parseInline(block.text)
+			fmt.Fprintf(b, "<h%d id=\"%s\">", block.level, html.EscapeString(slugify(plainText(spans))))
+			r.renderInline(b, spans)
+			fmt.Fprintf(b, "</h%d>\n", block.level)
+
+		case blockCode:
+			if block.lang != "" {
+				fmt.Fprintf(b, "<pre><code class=\"language-%s\">", html.EscapeString(block.lang))
+			} else {
+				b.WriteString("<pre><code>")
+			}
+			b.WriteString(html.EscapeString(block.text))
+			b.WriteString("</code></pre>\n")
+
+		case blockQuote:
+			b.WriteString("<blockquote>\n")
+			r.renderBlocks(b, block.children, false)
+			b.WriteString("</blockquote>\n")
+
+		case blockList:
+			tag := "ul"
+			if block.ordered {
+				tag = "ol"
+				if block.start != 1 {
+					fmt.Fprintf(b, "<ol start=\"%d\">\n", block.start)
+				} else {
+					b.WriteString("<ol>\n")
+				}
+			} else {
+				b.WriteString("<ul>\n")
+			}
+			for _, item := range block.children {
+				b.WriteString("<li>")
+				if item.task {
+					checked := ""
+					if item.checked {
+						checked = " checked"
+					}
+					fmt.Fprintf(b, "<input type=\"checkbox\" disabled%s> ", checked)
+				}
+				r.renderBlocks(b, item.children, isTight(item))
+				b.WriteString("</li>\n")
+			}
+			fmt.Fprintf(b, "</%s>\n", tag)
+
+		case blockRule:
+			b.WriteString("<hr>\n")
+
+		case blockTable:
+			b.WriteString("<table>\n")
+			for i, row := range block.rows {
+				cell := "td"
+				if i == 0 {
+					cell = "th"
+					b.WriteString("<thead>\n")
+				}
+				b.WriteString("<tr>")
+				for _, text := range row {
+					fmt.Fprintf(b, "<%s>", cell)
+					r.renderInline(b, parseInline(text))
+					fmt.Fprintf(b, "</%s>", cell)
+				}
+				b.WriteString("</tr>\n")
+				if i == 0 {
+					b.WriteString("</thead>\n<tbody>\n")
+				}
+			}
+			b.WriteString("</tbody>\n</table>\n")
+		}
+	}
+}
+
+// isTight reports whether a list item only holds a paragraph and sub lists
+func isTight(item mdBlock) bool {
+	paragraphs := 0
+	for _, child := range item.children {
+		switch child.kind {
+		case blockParagraph:
+			paragraphs++
+		case blockList:
+		default:
+			return false
+		}
+	}
+	return paragraphs <= 1
+}
+
+func (r htmlRenderer) renderInline(b *strings.Builder, spans []mdInline) {
+	for _, span := range spans {
+		switch span.kind {
+		case inlineText:
+			b.WriteString(html.EscapeString(span.text))
+		case inlineCode:
+			b.WriteString("<code>" + html.EscapeString(span.text) + "</code>")
+		case inlineBreak:
+			b.WriteString("<br>\n")
+		case inlineStrong:
+			b.WriteString("<strong>")
+			r.renderInline(b, span.children)
+			b.WriteString("</strong>")
+		case inlineEmphasis:
+			b.WriteString("<em>")
+			r.renderInline(b, span.children)
+			b.WriteString("</em>")
+		case inlineStrike:
+			b.WriteString("<del>")
+			r.renderInline(b, span.children)
+			b.WriteString("</del>")
+		case inlineLink:
+			fmt.Fprintf(b, "<a href=\"%s\">", html.EscapeString(span.url))
+			r.renderInline(b, span.children)
+			b.WriteString("</a>")
+		case inlineImage:
+			fmt.Fprintf(b, "<img src=\"%s\" alt=\"%s\">", html.EscapeString(span.url), html.EscapeString(span.text))
+		case inlineWikilink:
+			href := ""
+			if r.resolveWikilink != nil {
+				href = r.resolveWikilink(span.text)
+			}
+			if href == "" {
+				b.WriteString("<span class=\"wikilink\">")
+				r.renderInline(b, span.children)
+				b.WriteString("</span>")
+				continue
+			}
+			fmt.Fprintf(b, "<a class=\"wikilink\" href=\"%s\">", html.EscapeString(href))
+			r.renderInline(b, span.children)
+			b.WriteString("</a>")
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Graphics protocols used to draw images in the preview
+const (
+	imagesOff    = "off"
+	imagesKitty  = "kitty"
+	imagesITerm2 = "iterm2"
+	imagesSixel  = "sixel"
+)
+
+// maxImageRows is the height of the tallest image drawn in the preview
+const maxImageRows = 20
+
+// detectImageProtocol returns the graphics protocol set in the config, or
+// the one of the terminal when set to auto. Sixel support can't be detected
+// reliably and must be set explicitly, except for terminals known to have it.
+func detectImageProtocol(setting string) string {
+	if setting != "" && setting != "auto" {
+		return setting
+	}
+
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty":
+		return imagesKitty
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app" || os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return imagesITerm2
+	case strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm"):
+		return imagesSixel
+	}
+	return imagesOff
+}
+
+// encodedImages caches the escape sequences drawing images, by protocol,
+// path and size
+var encodedImages = map[string][]byte{}
+
+// encodeImage returns the escape sequence drawing an image at the cursor over
+// a box of cells, the image keeping its aspect ratio
+func encodeImage(protocol, path string, cols, rows int) ([]byte, error) {
+	key := fmt.Sprintf("%s:%s@%dx%d", protocol, path, cols, rows)
+	if data, ok := encodedImages[key]; ok {
+		return data, nil
+	}
+
+	var data []byte
+	var err error
+	switch protocol {
+	case imagesKitty:
+		data, err = encodeKitty(path, cols, rows)
+	case imagesITerm2:
+		data, err = encodeITerm2(path, cols, rows)
+	case imagesSixel:
+		data, err = encodeSixel(path, cols, rows)
+	default:
+		err = fmt.Errorf("unknown image protocol %q", protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	encodedImages[key] = data
+	return data, nil
+}
+
+// encodeKitty transmits an image as PNG and displays it, in chunks of 4096
+// bytes
+func encodeKitty(path string, cols, rows int) ([]byte, error) {
+	img, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	payload := base64.StdEncoding.EncodeToString(encoded.Bytes())
+	for first := true; payload != ""; first = false {
+		chunk := payload
+		if len(chunk) > 4096 {
+			chunk = chunk[:4096]
+		}
+		payload = payload[len(chunk):]
+		more := 0
+		if payload != "" {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(&b, "\x1b_Ga=T,f=100,c=%d,r=%d,C=1,q=2,m=%d;%s\x1b\\", cols, rows, more, chunk)
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func encodeITerm2(path string, cols, rows int) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=1:%s\a",
+		len(data), cols, rows, base64.StdEncoding.EncodeToString(data))), nil
+}
+
+// encodeSixel scales an image to a box of cells and encodes it
+// with a palette of 216 colors
+func encodeSixel(path string, cols, rows int) ([]byte, error) {
+	img, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cellWidth, cellHeight := cellSize()
+	width, height := fitImage(img.Bounds().Dx(), img.Bounds().Dy(), cols*cellWidth, rows*cellHeight)
+
+	paletted := image.NewPaletted(image.Rect(0, 0, width, height), palette.WebSafe)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), scaleImage(img, width, height), image.Point{})
+
+	var b bytes.Buffer
+	// Enter sixel mode with square pixels
+	b.WriteString("\x1bP0;1q\"1;1;" + fmt.Sprintf("%d;%d", width, height))
+	for i, c := range paletted.Palette {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	// Every band of 6 rows is drawn color by color
+	for y := 0; y < height; y += 6 {
+		used := map[uint8]bool{}
+		for dy := 0; dy < 6 && y+dy < height; dy++ {
+			for x := 0; x < width; x++ {
+				used[paletted.ColorIndexAt(x, y+dy)] = true
+			}
+		}
+
+		first := true
+		for c := range paletted.Palette {
+			if !used[uint8(c)] {
+				continue
+			}
+			if !first {
+				// Back to the start of the band for the next color
+				b.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(&b, "#%d", c)
+
+			var run byte
+			count := 0
+			flush := func() {
+				if count > 3 {
+					fmt.Fprintf(&b, "!%d%c", count, run)
+				} else {
+					b.WriteString(strings.Repeat(string(run), count))
+				}
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && y+dy < height; dy++ {
+					if paletted.ColorIndexAt(x, y+dy) == uint8(c) {
+						bits |= 1 << dy
+					}
+				}
+				sixel := 63 + bits
+				if count > 0 && sixel != run {
+					flush()
+					count = 0
+				}
+				run = sixel
+				count++
+			}
+			flush()
+		}
+		// Next band
+		b.WriteByte('-')
+	}
+
+	b.WriteString("\x1b\\")
+	return b.Bytes(), nil
+}
+
+func loadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// scaleImage resizes an image with the nearest neighbor, which is enough for
+// a preview
+func scaleImage(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			scaled.Set(x, y, img.At(bounds.Min.X+x*bounds.Dx()/width, bounds.Min.Y+y*bounds.Dy()/height))
+		}
+	}
+	return scaled
+}
+
+// fitImage returns the size of an image scaled down to fit in a box,
+// keeping its aspect ratio
+func fitImage(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= 0 || height <= 0 {
+		return 1, 1
+	}
+	if width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if height > maxHeight {
+		width = width * maxHeight / height
+		height = maxHeight
+	}
+	return max(width, 1), max(height, 1)
+}
+
+// imageCells returns the size in cells of an image shown at most width cells
+// wide, or false if the file isn't an image that can be read
+func imageCells(path string, width int) (cols, rows int, ok bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	cellWidth, cellHeight := cellSize()
+	pixelWidth, pixelHeight := fitImage(config.Width, config.Height, width*cellWidth, maxImageRows*cellHeight)
+	cols = (pixelWidth + cellWidth - 1) / cellWidth
+	rows = (pixelHeight + cellHeight - 1) / cellHeight
+	return cols, rows, true
+}
+
+// resolveImage returns the path of a local image linked from a note, or
+// false for remote images
+func resolveImage(notesDir, filename, url string) (string, bool) {
+	if strings.Contains(url, "://") || strings.HasPrefix(url, "data:") {
+		return "", false
+	}
+	url = strings.TrimPrefix(url, "file:")
+	if filepath.IsAbs(url) {
+		return url, true
+	}
+	return filepath.Join(notesDir, filepath.Dir(filename), filepath.FromSlash(url)), true
+}
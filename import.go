@@ -0,0 +1,380 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// notionID is the identifier Notion appends to the names of exported pages
+	notionID = regexp.MustCompile(` [0-9a-f]{32}`)
+	// notionLinkID is the same identifier in the links between pages
+	notionLinkID = regexp.MustCompile(`%20[0-9a-f]{32}`)
+	// notionProperty is a property of a page, listed below its title
+	notionProperty = regexp.MustCompile(`^([\p{L}][\p{L}\p{N} ]*): (.*)$`)
+	// inlineTag is a #tag in the text of an Obsidian note
+	inlineTag = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]*[\p{L}_][\p{L}\p{N}_/-]*)`)
+	// invalidTagChars are replaced in imported tags, snsm tags being words
+	invalidTagChars = regexp.MustCompile(`\W+`)
+)
+
+// importedNote is a note converted to the snsm layout
+type importedNote struct {
+	// filename relative to the import folder, with the .md extension
+	filename string
+	tags     []string
+	body     string
+	modTime  time.Time
+}
+
+// importer writes converted notes and attachments to the notes directory,
+// never overwriting existing files
+type importer struct {
+	notesDir string
+	// folder of the notes directory the notes are imported in
+	folder string
+	dryRun bool
+
+	notes       int
+	attachments int
+	conflicts   []string
+	skipped     []string
+}
+
+// target returns a free path for a file of the import, reporting a conflict
+// when the file already exists
+func (im *importer) target(filename string) string {
+	filename = filepath.Join(im.folder, filename)
+	ext := filepath.Ext(filename)
+	unique := uniqueFilename(im.notesDir, strings.TrimSuffix(filename, ext), ext)
+	if unique != filename {
+		im.conflicts = append(im.conflicts, fmt.Sprintf("%s already exists, imported as %s", filename, unique))
+	}
+	return unique
+}
+
+func (im *importer) writeNote(note importedNote) error {
+	filename := im.target(note.filename)
+	im.notes++
+	if im.dryRun {
+		fmt.Printf("Would import %s\n", filename)
+		return nil
+	}
+
+	fullPath := filepath.Join(im.notesDir, filename)
+	if err := createNote(fullPath, strings.Join(note.tags, " "), note.body); err != nil {
+		return fmt.Errorf("failed to import %s: %v", filename, err)
+	}
+	if !note.modTime.IsZero() {
+		os.Chtimes(fullPath, note.modTime, note.modTime)
+	}
+
+	fmt.Printf("Imported %s\n", filename)
+	if err := noteSaved("post_create", im.notesDir, filename); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
+
+// writeAttachment copies a file linked by the notes, keeping its path
+// relative to them. An identical existing file is reused.
+func (im *importer) writeAttachment(filename string, data []byte) error {
+	existing, err := os.ReadFile(filepath.Join(im.notesDir, im.folder, filename))
+	if err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	filename = im.target(filename)
+	im.attachments++
+	if im.dryRun {
+		return nil
+	}
+
+	fullPath := filepath.Join(im.notesDir, filename)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create folder: %v", err)
+	}
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to import %s: %v", filename, err)
+	}
+	return nil
+}
+
+func (im *importer) skip(filename, reason string) {
+	im.skipped = append(im.skipped, fmt.Sprintf("%s: %s", filename, reason))
+}
+
+func (im *importer) report() {
+	for _, conflict := range im.conflicts {
+		fmt.Printf("Conflict: %s\n", conflict)
+	}
+	for _, skipped := range im.skipped {
+		fmt.Printf("Skipped %s\n", skipped)
+	}
+
+	verb := "Imported"
+	if im.dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d notes and %d attachments, %d conflicts, %d skipped\n",
+		verb, im.notes, im.attachments, len(im.conflicts), len(im.skipped))
+}
+
+// importVault imports the notes of an Obsidian vault, mapping the tags of
+// their front matter and their inline #tags to the tag line
+func (im *importer) importVault(src fs.FS) error {
+	return fs.WalkDir(src, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip the settings and the trash of the vault
+		if strings.HasPrefix(entry.Name(), ".") && name != "." {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		data, err := fs.ReadFile(src, name)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(path.Ext(name), ".md") {
+			return im.writeAttachment(filepath.FromSlash(name), data)
+		}
+
+		frontTags, body, err := splitFrontMatter(string(data))
+		if err != nil {
+			im.skip(name, fmt.Sprintf("invalid front matter: %v", err))
+			return nil
+		}
+
+		var modTime time.Time
+		if info, err := entry.Info(); err == nil {
+			modTime = info.ModTime()
+		}
+
+		return im.writeNote(importedNote{
+			filename: filepath.FromSlash(name),
+			tags:     cleanTags(append(frontTags, inlineTags(body)...)),
+			body:     body,
+			modTime:  modTime,
+		})
+	})
+}
+
+// importNotion imports a Notion markdown export, zipped or not. The page
+// identifiers are removed from the names and links, and the Tags property
+// becomes the tag line.
+func (im *importer) importNotion(src fs.FS) error {
+	return fs.WalkDir(src, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(src, name)
+		if err != nil {
+			return err
+		}
+
+		filename := filepath.FromSlash(notionID.ReplaceAllString(name, ""))
+		switch strings.ToLower(path.Ext(name)) {
+		case ".md":
+		case ".csv":
+			im.skip(name, "databases are exported as their pages only")
+			return nil
+		default:
+			return im.writeAttachment(filename, data)
+		}
+
+		var modTime time.Time
+		if info, err := entry.Info(); err == nil {
+			modTime = info.ModTime()
+		}
+
+		tags, body := splitNotionProperties(notionLinkID.ReplaceAllString(string(data), ""))
+		return im.writeNote(importedNote{
+			filename: filename,
+			tags:     cleanTags(tags),
+			body:     body,
+			modTime:  modTime,
+		})
+	})
+}
+
+// splitFrontMatter removes the tags from the YAML front matter of a note,
+// dropping the front matter once empty
+func splitFrontMatter(content string) (tags []string, body string, err error) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(content, "---\n") {
+		return nil, content, nil
+	}
+
+	front, rest, found := strings.Cut(content[len("---\n"):], "\n---")
+	if !found {
+		return nil, content, nil
+	}
+	// Skip the end of the closing line
+	_, rest, _ = strings.Cut(rest, "\n")
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(front), &doc); err != nil {
+		return nil, "", err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, content, nil
+	}
+
+	mapping := doc.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if key := mapping.Content[i].Value; key != "tags" && key != "tag" {
+			continue
+		}
+		var list tagList
+		if err := mapping.Content[i+1].Decode(&list); err != nil {
+			return nil, "", err
+		}
+		tags = append(tags, list...)
+		mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+		i -= 2
+	}
+
+	if len(mapping.Content) == 0 {
+		return tags, strings.TrimLeft(rest, "\n"), nil
+	}
+
+	out, err := yaml.Marshal(mapping)
+	if err != nil {
+		return nil, "", err
+	}
+	return tags, "---\n" + string(out) + "---\n" + rest, nil
+}
+
+// inlineTags returns the #tags of a note, outside of its code blocks
+func inlineTags(body string) []string {
+	var tags []string
+	fence := ""
+
+	for _, line := range strings.Split(body, "\n") {
+		if match := fenceRegex.FindStringSubmatch(line); match != nil {
+			if fence == "" {
+				fence = match[1]
+			} else if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+		for _, match := range inlineTag.FindAllStringSubmatch(line, -1) {
+			tags = append(tags, match[1])
+		}
+	}
+
+	return tags
+}
+
+// splitNotionProperties removes the tags from the properties Notion lists
+// below the title of a page
+func splitNotionProperties(content string) (tags []string, body string) {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	// The properties follow the title and a blank line
+	i := 0
+	if i < len(lines) && strings.HasPrefix(lines[i], "# ") {
+		i++
+	}
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+
+	for ; i < len(lines); i++ {
+		match := notionProperty.FindStringSubmatch(lines[i])
+		if match == nil {
+			break
+		}
+		switch strings.ToLower(match[1]) {
+		case "tags", "tag", "labels":
+			for _, tag := range strings.Split(match[2], ",") {
+				tags = append(tags, strings.TrimSpace(tag))
+			}
+			lines = append(lines[:i], lines[i+1:]...)
+			i--
+		}
+	}
+
+	return tags, strings.Join(lines, "\n")
+}
+
+// cleanTags turns imported tags into snsm tags, which are single words:
+// "#project/acme" becomes "project_acme"
+func cleanTags(tags []string) []string {
+	var cleaned []string
+	for _, tag := range tags {
+		tag = strings.Trim(invalidTagChars.ReplaceAllString(strings.TrimPrefix(tag, "#"), "_"), "_")
+		if tag != "" && !containsTag(cleaned, tag) {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return cleaned
+}
+
+func runImport(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := flags.String("from", "", "format of the export: obsidian, notion or evernote")
+	folder := flags.String("folder", "", "folder of the notes directory to import the notes in")
+	dryRun := flags.Bool("dry-run", false, "only report what would be imported")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected the path of the export to import")
+	}
+
+	cleaned, ok := cleanFolder(*folder)
+	if !ok {
+		return fmt.Errorf("folder %s is outside of the notes directory", *folder)
+	}
+
+	src := expandTilde(flags.Arg(0))
+	im := &importer{notesDir: notesDir, folder: cleaned, dryRun: *dryRun}
+
+	var err error
+	switch *from {
+	case "obsidian":
+		err = im.importVault(os.DirFS(src))
+	case "notion":
+		if strings.EqualFold(filepath.Ext(src), ".zip") {
+			archive, zipErr := zip.OpenReader(src)
+			if zipErr != nil {
+				return fmt.Errorf("failed to open %s: %v", src, zipErr)
+			}
+			defer archive.Close()
+			err = im.importNotion(archive)
+		} else {
+			err = im.importNotion(os.DirFS(src))
+		}
+	case "evernote":
+		err = im.importEvernote(src)
+	default:
+		return fmt.Errorf("unknown format %q, expected --from obsidian, notion or evernote", *from)
+	}
+
+	im.report()
+	return err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// keySection is a group of keys of the list shown by ?
+type keySection struct {
+	title    string
+	bindings []key.Binding
+}
+
+// keySections returns the keys of the list by category, read from the
+// keymaps so that the keys of init.lua are listed too
+func (m model) keySections() []keySection {
+	nav := m.list.KeyMap
+	sections := []keySection{
+		{"Navigation", []key.Binding{
+			nav.CursorUp,
+			nav.CursorDown,
+			nav.PrevPage,
+			nav.NextPage,
+			nav.GoToStart,
+			nav.GoToEnd,
+			nav.Filter,
+			nav.ClearFilter,
+			customListKeys.recent,
+			customListKeys.random,
+			customListKeys.searches,
+			customListKeys.tagTree,
+			customListKeys.sort,
+			customListKeys.group,
+			customListKeys.section,
+			customListKeys.browse,
+			customListKeys.switchVault,
+			nav.ShowFullHelp,
+			nav.Quit,
+		}},
+		{"Note actions", []key.Binding{
+			customListKeys.open,
+			customListKeys.mark,
+			customListKeys.openMarked,
+			customListKeys.openWith,
+			customListKeys.undo,
+			customListKeys.createNote,
+			customListKeys.paste,
+			customListKeys.quickEdit,
+			customListKeys.quickAppend,
+			customListKeys.snippet,
+			customListKeys.editTags,
+			customListKeys.label,
+			customListKeys.move,
+			customListKeys.export,
+			customListKeys.share,
+			customListKeys.copy,
+			customListKeys.copyURL,
+			customListKeys.openFolder,
+			customListKeys.versions,
+			customListKeys.diff,
+			customListKeys.commandLine,
+			customListKeys.palette,
+			customListKeys.assistant,
+		}},
+		{"Views", []key.Binding{
+			customListKeys.preview,
+			customListKeys.info,
+			customListKeys.density,
+			customListKeys.columns,
+			customListKeys.view,
+			customListKeys.outline,
+			customListKeys.tasks,
+			customListKeys.calendar,
+			customListKeys.board,
+			customListKeys.graph,
+			customListKeys.relations,
+			customListKeys.review,
+		}},
+		{"Vault", []key.Binding{
+			customListKeys.sync,
+			customListKeys.check,
+			customListKeys.dedupe,
+			customListKeys.copies,
+			customListKeys.warnings,
+		}},
+	}
+
+	// The keys bound by init.lua
+	if userScript != nil && len(userScript.bindings) > 0 {
+		script := keySection{title: "init.lua"}
+		for _, b := range userScript.bindings {
+			script.bindings = append(script.bindings, key.NewBinding(key.WithKeys(b.key), key.WithHelp(b.key, b.help)))
+		}
+		sections = append(sections, script)
+	}
+	return sections
+}
+
+// openKeyHelp lists every key of the list, by category
+func (m model) openKeyHelp() (tea.Model, tea.Cmd) {
+	sections := m.keySections()
+	width := 0
+	for _, s := range sections {
+		for _, b := range s.bindings {
+			width = max(width, lipgloss.Width(b.Help().Key))
+		}
+	}
+
+	var lines []string
+	for _, s := range sections {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, inputStyle.Bold(true).Render(s.title))
+		for _, b := range s.bindings {
+			// Listed whether or not they apply right now, like the
+			// paging keys of a short list
+			help := b.Help()
+			if help.Key == "" {
+				continue
+			}
+			pad := strings.Repeat(" ", width-lipgloss.Width(help.Key))
+			lines = append(lines, "  "+help.Key+pad+"  "+mutedStyle.Render(help.Desc))
+		}
+	}
+	return m.openPager("Keys", lines)
+}
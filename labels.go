@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+// labelColors are the color names a label can use, with their ANSI color
+var labelColors = map[string]string{
+	"red":    "9",
+	"orange": "208",
+	"yellow": "11",
+	"green":  "10",
+	"blue":   "12",
+	"purple": "13",
+	"pink":   "218",
+	"gray":   "245",
+}
+
+// colorLabel matches labels that are colors rather than icons: a hex color
+// or an ANSI color number
+var colorLabel = regexp.MustCompile(`^(#[0-9a-fA-F]{6}|#[0-9a-fA-F]{3}|[0-9]{1,3})$`)
+
+// noteMeta holds the front matter of a note read while listing the notes
+type noteMeta struct {
+	// Title is displayed in the list instead of the filename
+	Title string `yaml:"title"`
+	// Label is a color (name, hex or ANSI number) or an icon like an emoji
+	Label string `yaml:"label"`
+	// Due is the date the note is due, optionally with a time
+	Due string `yaml:"due"`
+	// Date is the date of the note, like of a meeting, optionally with a
+	// time
+	Date string `yaml:"date"`
+	// Parent and Related are the notes the note belongs to and the ones it
+	// is related to, as [[wikilinks]] or names
+	Parent  noteRef  `yaml:"parent"`
+	Related noteRefs `yaml:"related"`
+	// Aliases are other names of the note, a list or names separated by
+	// commas
+	Aliases aliasList `yaml:"aliases"`
+	// Heading is the first heading of the note, read with headingTitles
+	Heading string `yaml:"-"`
+}
+
+// headingTitles displays the notes by their first heading rather than their
+// filename, set from the config
+var headingTitles bool
+
+// labelBadge renders a label as shown before the title of a note: a colored
+// dot for colors, the label itself for icons
+func labelBadge(label string) string {
+	if label == "" {
+		return ""
+	}
+	color, ok := labelColors[strings.ToLower(label)]
+	if !ok && colorLabel.MatchString(label) {
+		color, ok = label, true
+	}
+	if !ok {
+		return label
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render("●")
+}
+
+// cutFrontMatter separates the YAML front matter starting a text, if any,
+// from the rest of the text
+func cutFrontMatter(text string) (front string, rest string, found bool) {
+	opening := "---\n"
+	if strings.HasPrefix(text, "---\r\n") {
+		// Notes saved on Windows end their lines with CRLF
+		opening = "---\r\n"
+	} else if !strings.HasPrefix(text, opening) {
+		return "", text, false
+	}
+	front, rest, found = strings.Cut(text[len(opening):], "\n---")
+	if !found {
+		return "", text, false
+	}
+	// Skip the end of the closing line
+	_, rest, _ = strings.Cut(rest, "\n")
+	front = strings.TrimSuffix(strings.ReplaceAll(front, "\r\n", "\n"), "\r")
+	return front + "\n", rest, true
+}
+
+// stripFrontMatter removes the front matter from the body of a note
+func stripFrontMatter(body string) string {
+	_, rest, _ := cutFrontMatter(body)
+	return rest
+}
+
+// readHeader returns the tags of a note and the metadata of its front matter.
+// The tags are read from the first line in markdown and plain text, from the
+// #+TAGS and #+FILETAGS keywords in org-mode.
+func readHeader(path string) (string, noteMeta, error) {
+	tags, meta, _, err := readNoteHeader(path)
+	return tags, meta, err
+}
+
+// readNoteHeader reads the header of a note like readHeader, also reporting
+// whether the note is valid UTF-8 text. Binary files fail with errBinaryNote.
+func readNoteHeader(path string) (string, noteMeta, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", noteMeta{}, false, err
+	}
+	defer file.Close()
+
+	head, valid, err := sniffText(file)
+	if err != nil {
+		return "", noteMeta{}, false, err
+	}
+	tags, meta, err := parseHeader(path, newLineScanner(io.MultiReader(bytes.NewReader(head), file)))
+	if !valid {
+		// Other encodings show as replacement characters rather than
+		// garbling the display
+		tags = strings.ToValidUTF8(tags, "\uFFFD")
+		meta.Title = strings.ToValidUTF8(meta.Title, "\uFFFD")
+		meta.Label = strings.ToValidUTF8(meta.Label, "\uFFFD")
+		meta.Heading = strings.ToValidUTF8(meta.Heading, "\uFFFD")
+	}
+	return tags, meta, valid, scanError(err)
+}
+
+// parseHeader reads the header of a note from the scanner of its lines
+func parseHeader(path string, scanner *bufio.Scanner) (string, noteMeta, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".org" {
+		return readOrgHeader(scanner)
+	}
+
+	var tags string
+	var meta noteMeta
+	if !scanner.Scan() {
+		return "", meta, scanner.Err()
+	}
+	line, more := scanner.Text(), true
+	if lineTags, ok := parseTagLine(path, line); ok {
+		tags = lineTags
+		more = scanner.Scan()
+		line = scanner.Text()
+	}
+
+	if more && strings.TrimRight(line, "\r") == "---" {
+		// Front matter is short, don't read a whole note missing its end
+		const maxFrontMatterLines = 100
+		var front strings.Builder
+		for i := 0; i < maxFrontMatterLines && scanner.Scan(); i++ {
+			line := strings.TrimRight(scanner.Text(), "\r")
+			if line == "---" {
+				// Invalid front matter only loses its metadata
+				yaml.Unmarshal([]byte(front.String()), &meta)
+				break
+			}
+			front.WriteString(line + "\n")
+		}
+		more = scanner.Scan()
+		line = scanner.Text()
+	}
+
+	if headingTitles && more {
+		meta.Heading = scanHeading(scanner, line)
+	}
+	return tags, meta, scanner.Err()
+}
+
+// scanHeading returns the text of the first "# Heading" of a note, looked for
+// in its first lines only, starting with line
+func scanHeading(scanner *bufio.Scanner, line string) string {
+	const maxHeadingLines = 20
+	for i := 0; i < maxHeadingLines; i++ {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "# ") {
+			return plainText(parseInline(strings.TrimSpace(strings.TrimRight(line[2:], "#"))))
+		}
+		// Headings in code blocks don't count
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") || !scanner.Scan() {
+			return ""
+		}
+		line = scanner.Text()
+	}
+	return ""
+}
+
+// readOrgHeader reads the title, the tags and the label of an org-mode note
+// from the keywords at its top
+func readOrgHeader(scanner *bufio.Scanner) (string, noteMeta, error) {
+	var tags string
+	var meta noteMeta
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, value, ok := orgKeyword(line)
+		if !ok {
+			// Comments can be mixed with the keywords
+			if strings.HasPrefix(line, "# ") || line == "#" {
+				continue
+			}
+			break
+		}
+		switch key {
+		case "TITLE":
+			meta.Title = value
+		case "TAGS", "FILETAGS":
+			tags = mergeTags(tags, orgTags(value))
+		case "LABEL":
+			meta.Label = value
+		case "DATE":
+			meta.Date = value
+		case "PARENT":
+			meta.Parent = noteRef(value)
+		case "RELATED":
+			meta.Related = splitNoteRefs(value)
+		case "ALIASES":
+			meta.Aliases = splitAliases(value)
+		}
+	}
+	return tags, meta, scanner.Err()
+}
+
+// setLabel writes the label of a note to its front matter, an empty label
+// removing it
+func setLabel(path, label string) error {
+	return setNoteField(path, "label", label)
+}
+
+// setNoteField writes a field of the front matter of a note, or the keyword
+// of an org-mode note, an empty value removing it
+func setNoteField(path, field, value string) error {
+	var node *yaml.Node
+	if value != "" {
+		node = &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	}
+	return setNoteNode(path, field, value, node)
+}
+
+// setNoteList writes a list to the front matter of a note, or to the keyword
+// of an org-mode note separated by commas, an empty list removing it
+func setNoteList(path, field string, values []string) error {
+	var node *yaml.Node
+	if len(values) > 0 {
+		node = &yaml.Node{Kind: yaml.SequenceNode}
+		for _, value := range values {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: value})
+		}
+	}
+	return setNoteNode(path, field, strings.Join(values, ", "), node)
+}
+
+// setNoteNode writes the value of a field of the front matter of a note, the
+// org-mode notes getting the value written as a keyword. A nil node removes
+// the field.
+func setNoteNode(path, field, orgValue string, value *yaml.Node) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".org" {
+		return os.WriteFile(path, []byte(setOrgKeyword(string(content), strings.ToUpper(field), orgValue)), 0644)
+	}
+
+	// The tag line stays first
+	var tagLine string
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	if firstLine, rest, _ := strings.Cut(text, "\n"); strings.HasPrefix(firstLine, "//") {
+		tagLine, text = firstLine+"\n", rest
+	}
+
+	front, body, _ := cutFrontMatter(text)
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(front), &doc); err != nil {
+		return fmt.Errorf("invalid front matter: %v", err)
+	}
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	if len(doc.Content) > 0 {
+		if doc.Content[0].Kind != yaml.MappingNode {
+			return fmt.Errorf("invalid front matter: not a mapping")
+		}
+		mapping = doc.Content[0]
+	}
+
+	set := false
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != field {
+			continue
+		}
+		if value == nil {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			i -= 2
+			continue
+		}
+		mapping.Content[i+1] = value
+		set = true
+	}
+	if !set && value != nil {
+		mapping.Content = append(mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: field}, value)
+	}
+
+	if len(mapping.Content) > 0 {
+		// Lists indented like they are usually written by hand
+		var out bytes.Buffer
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(mapping); err != nil {
+			return err
+		}
+		body = "---\n" + unescapeAstral(out.String()) + "---\n" + body
+	}
+	return os.WriteFile(path, []byte(tagLine+body), 0644)
+}
+
+// setOrgKeyword sets a keyword of an org-mode note like #+LABEL, written
+// after the other keywords at its top
+func setOrgKeyword(content, keyword, value string) string {
+	lines := strings.Split(content, "\n")
+	var kept []string
+	insert := 0
+	header := true
+	for _, line := range lines {
+		if header {
+			key, _, ok := orgKeyword(strings.TrimSpace(line))
+			if ok && key == keyword {
+				continue
+			}
+			if ok {
+				insert = len(kept) + 1
+			} else if strings.TrimSpace(line) != "" {
+				header = false
+			}
+		}
+		kept = append(kept, line)
+	}
+
+	if value != "" {
+		kept = append(kept[:insert], append([]string{"#+" + keyword + ": " + value}, kept[insert:]...)...)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// astralEscape is a \U escape of a double-quoted YAML string, with the
+// backslashes before it
+var astralEscape = regexp.MustCompile(`\\+U[0-9A-Fa-f]{8}`)
+
+// unescapeAstral writes the characters the YAML encoder escapes as \U, like
+// emojis, as they are. The escape is kept when its backslash is escaped.
+func unescapeAstral(s string) string {
+	return astralEscape.ReplaceAllStringFunc(s, func(escape string) string {
+		slashes := strings.Count(escape, `\`)
+		if slashes%2 == 0 {
+			return escape
+		}
+		code, err := strconv.ParseUint(escape[slashes+1:], 16, 32)
+		if err != nil {
+			return escape
+		}
+		return escape[:slashes-1] + string(rune(code))
+	})
+}
+
+// startLabel asks for the label of the selected note
+func (m model) startLabel() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	suggestions := []string{}
+	for name := range labelColors {
+		suggestions = append(suggestions, name)
+	}
+	for _, note := range m.items {
+		if note.label != "" && !containsTag(suggestions, note.label) {
+			suggestions = append(suggestions, note.label)
+		}
+	}
+	sort.Strings(suggestions)
+
+	m.labeling = item
+	m.labelInput.SetValue(item.label)
+	m.labelInput.CursorEnd()
+	m.labelInput.SetSuggestions(suggestions)
+	m.labelInput.Focus()
+	m.mode = modeLabelInput
+	return m, textinput.Blink
+}
+
+func (m model) updateLabel(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "ctrl+c":
+			m.quitting = true
+			return m, tea.Quit
+		case "esc":
+			m.mode = modeList
+			return m, nil
+		case "enter":
+			m.mode = modeList
+			before, err := readForUndo(m.notesDir, m.labeling.filename)
+			if err == nil {
+				err = setLabel(m.labeling.path, strings.TrimSpace(m.labelInput.Value()))
+			}
+			if err != nil {
+				return m, m.setError("Cannot label %s: %v", m.labeling.filename, err)
+			}
+			m.pushUndo("labeling "+m.labeling.filename, restoreNotes(m.notesDir, before))
+			return m, m.reloadNotes()
+		}
+	}
+
+	var cmd tea.Cmd
+	m.labelInput, cmd = m.labelInput.Update(msg)
+	return m, cmd
+}
+
+func (m model) viewLabel() string {
+	prompt := fmt.Sprintf("Enter the label of %s, a color (red, #ff8800, 33) or an emoji, empty to remove it:", m.labeling.Title())
+	return fmt.Sprintf("\n\n  %s\n\n  %s\n\n", prompt, m.labelInput.View()) + "  (press ESC to cancel)"
+}
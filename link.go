@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// linkTarget returns the shortest target of a wikilink to a note: its name,
+// or its path when another note has the same name
+func linkTarget(idx noteIndex, filename string) string {
+	target := trimNoteExt(filepath.ToSlash(filename))
+	if resolved, ok := idx.resolve(filepath.Base(target)); ok && resolved == filename {
+		return filepath.Base(target)
+	}
+	return target
+}
+
+// linkPicker is the list of the notes of snsm link, quitting once a note is
+// chosen
+type linkPicker struct {
+	list   list.Model
+	chosen *noteItem
+	// filter filters the notes with the words given on the command line
+	filter tea.Cmd
+}
+
+func (p linkPicker) Init() tea.Cmd {
+	return p.filter
+}
+
+func (p linkPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.list.SetSize(msg.Width, msg.Height)
+		return p, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return p, tea.Quit
+		case "esc":
+			if p.list.FilterState() == list.Unfiltered {
+				return p, tea.Quit
+			}
+		case "enter":
+			// Pick the first match without leaving the filter first
+			if p.list.SettingFilter() {
+				p.list, _ = p.list.Update(msg)
+			}
+			if item, ok := p.list.SelectedItem().(noteItem); ok {
+				p.chosen = &item
+				return p, tea.Quit
+			}
+			return p, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	p.list, cmd = p.list.Update(msg)
+	return p, cmd
+}
+
+func (p linkPicker) View() string {
+	return p.list.View()
+}
+
+// terminalFiles returns the terminal to draw the picker on, which stdout isn't
+// when the editor reads the link from it
+func terminalFiles() (in, out *os.File, err error) {
+	if term.IsTerminal(int(os.Stdout.Fd())) && term.IsTerminal(int(os.Stdin.Fd())) {
+		return os.Stdin, os.Stdout, nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("the picker needs a terminal: %v", err)
+	}
+	return tty, tty, nil
+}
+
+func runLink(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("link", flag.ContinueOnError)
+	withTitle := flags.Bool("title", false, "print [[note|title]], the link showing the title of the note")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no notes in %s", notesDir)
+	}
+
+	in, out, err := terminalFiles()
+	if err != nil {
+		return err
+	}
+	if out != os.Stdout {
+		defer out.Close()
+		// Colors follow the terminal drawn on rather than stdout
+		lipgloss.SetDefaultRenderer(lipgloss.NewRenderer(out))
+	}
+	t, err := resolveTheme(cfg)
+	if err != nil {
+		return err
+	}
+	applyTheme(t)
+
+	listItems := make([]list.Item, len(items))
+	for i, item := range items {
+		listItems[i] = item
+	}
+	l := list.New(listItems, NewCustomDelegate(), 0, 0)
+	l.Title = "Link to a note"
+	l.Styles.Title = titleStyle
+	l.Styles.PaginationStyle = paginationStyle
+	l.Styles.HelpStyle = helpStyle
+	l.SetStatusBarItemName("note", "notes")
+	shown := &shownNotes{}
+	shown.set(items)
+	l.Filter = queryFilter(shown)
+
+	// Start typing the filter right away, from the words given like the
+	// word under the cursor
+	picker := linkPicker{list: l}
+	picker.list, _ = picker.list.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	if query := strings.Join(flags.Args(), " "); query != "" {
+		picker.list, picker.filter = picker.list.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(query)})
+	}
+
+	final, err := runProgram(picker, tea.WithAltScreen(), tea.WithInput(in), tea.WithOutput(out))
+	if err != nil {
+		return err
+	}
+	chosen := final.(linkPicker).chosen
+	if chosen == nil {
+		return fmt.Errorf("canceled")
+	}
+
+	target := linkTarget(newNoteIndex(itemFilenames(items)), chosen.filename)
+	if *withTitle {
+		if title := itemTitle(*chosen); title != filepath.Base(target) {
+			target += "|" + title
+		}
+	}
+	fmt.Print("[[" + target + "]]")
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// noteIndex resolves [[wikilinks]] to the filename of notes. A link target is
+// matched case-insensitively against the note path relative to the notes
+// directory, then against its name alone, with or without extension.
+type noteIndex struct {
+	byPath map[string]string
+	byName map[string]string
+	// byAlias holds the aliases of the front matter, matched after the
+	// names
+	byAlias map[string]string
+}
+
+func newNoteIndex(filenames []string) noteIndex {
+	idx := noteIndex{byPath: map[string]string{}, byName: map[string]string{}}
+
+	for _, filename := range filenames {
+		key := strings.ToLower(trimNoteExt(filepath.ToSlash(filename)))
+		idx.byPath[key] = filename
+
+		// On name collisions across folders, the first note wins
+		name := filepath.Base(key)
+		if _, ok := idx.byName[name]; !ok {
+			idx.byName[name] = filename
+		}
+	}
+
+	return idx
+}
+
+// resolve returns the filename of the note targeted by a wikilink. Anchors
+// (#heading) are ignored.
+func (idx noteIndex) resolve(target string) (string, bool) {
+	target, _, _ = strings.Cut(target, "#")
+	key := strings.ToLower(trimNoteExt(strings.TrimSpace(target)))
+	if key == "" {
+		return "", false
+	}
+
+	if filename, ok := idx.byPath[key]; ok {
+		return filename, true
+	}
+	if filename, ok := idx.byName[filepath.Base(key)]; ok {
+		return filename, true
+	}
+	filename, ok := idx.byAlias[strings.ToLower(strings.TrimSpace(target))]
+	return filename, ok
+}
+
+// withAliases returns the index also resolving the aliases of the notes. On
+// collisions, the first note wins.
+func (idx noteIndex) withAliases(items []noteItem) noteIndex {
+	idx.byAlias = map[string]string{}
+	for _, item := range items {
+		for _, alias := range item.aliases {
+			if key := strings.ToLower(alias); idx.byAlias[key] == "" {
+				idx.byAlias[key] = item.filename
+			}
+		}
+	}
+	return idx
+}
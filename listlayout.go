@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// listConfig configures how the notes are shown in the list
+type listConfig struct {
+	// Density is comfortable (default), the tags of the notes on a second
+	// line, or compact, one line per note
+	Density string `yaml:"density"`
+	// Layout is the template of the line of a note, like
+	// {{label}} {{title}}  {{tags}}  {{mtime "Jan 2"}}
+	Layout string `yaml:"layout"`
+	// Details is the template of the second line of a note, in the
+	// comfortable density
+	Details string `yaml:"details"`
+}
+
+// Densities of the list
+const (
+	densityComfortable = "comfortable"
+	densityCompact     = "compact"
+)
+
+// Default templates of the notes of the list
+const (
+	defaultLayout        = `{{label}} {{title}}  {{filename}}  {{aliases}}  {{due}}  {{age}}`
+	defaultCompactLayout = defaultLayout + `  {{tags}}`
+	defaultDetails       = `{{tags}}`
+)
+
+// itemLayout is the parsed layout of the notes of the list
+type itemLayout struct {
+	compact bool
+	// line and compactLine are the lines of the notes in each density, the
+	// same unless the layout is the default one
+	line        *template.Template
+	compactLine *template.Template
+	details     *template.Template
+}
+
+// listLayout is the layout of the config, used by the delegate of the list
+var listLayout = mustItemLayout(listConfig{})
+
+// layoutData is the note a template of the list is rendered for
+type layoutData struct {
+	item     noteItem
+	selected bool
+	out      *layoutWriter
+	// tags and label are the columns the tag pills and the label were
+	// rendered at, for the clicks
+	tags  []fieldSpan
+	label fieldSpan
+}
+
+// fieldSpan is the columns a value was rendered at, end excluded
+type fieldSpan struct {
+	value      string
+	start, end int
+}
+
+// layoutWriter holds the line of a note being rendered
+type layoutWriter struct {
+	buf bytes.Buffer
+	// skipSpaces drops the spaces following a field without a value at the
+	// start of the line
+	skipSpaces bool
+}
+
+func (w *layoutWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.skipSpaces {
+		p = bytes.TrimLeft(p, " ")
+		w.skipSpaces = len(p) == 0
+	}
+	w.buf.Write(p)
+	return n, nil
+}
+
+// column returns the width of the line rendered so far
+func (w *layoutWriter) column() int {
+	return lipgloss.Width(w.buf.String())
+}
+
+// field returns the value of a field, removing the gap it leaves when it
+// has none: the spaces before it, or after it at the start of the line
+func (w *layoutWriter) field(value string) string {
+	if value != "" {
+		return value
+	}
+	kept := bytes.TrimRight(w.buf.Bytes(), " ")
+	w.buf.Truncate(len(kept))
+	w.skipSpaces = len(kept) == 0
+	return ""
+}
+
+// layoutFuncs are the fields of the templates. They take the note as
+// their first argument, added by passData to the calls of the templates.
+var layoutFuncs = template.FuncMap{
+	"label": func(d *layoutData) string {
+		badge := labelBadge(d.item.label)
+		if badge != "" {
+			start := d.out.column()
+			d.label = fieldSpan{value: d.item.label, start: start, end: start + lipgloss.Width(badge)}
+		}
+		return d.out.field(badge)
+	},
+	"title": func(d *layoutData) string {
+		if formatted, ok := userScript.formatItem(d.item); ok {
+			return d.out.field(formatted)
+		}
+		// Stale notes fade into the list until selected
+		if !d.selected && isStale(d.item.modTime, time.Now()) {
+			return d.out.field(mutedStyle.Render(d.item.Title()))
+		}
+		return d.out.field(d.item.Title())
+	},
+	// filename is only shown for the notes listed by another title
+	"filename": func(d *layoutData) string {
+		if name := trimNoteExt(d.item.filename); name != d.item.Title() {
+			return d.out.field(mutedStyle.Render(name))
+		}
+		return d.out.field("")
+	},
+	"folder": func(d *layoutData) string {
+		if dir := filepath.Dir(d.item.filename); dir != "." {
+			return d.out.field(mutedStyle.Render(dir))
+		}
+		return d.out.field("")
+	},
+	"aliases": func(d *layoutData) string {
+		if len(d.item.aliases) == 0 {
+			return d.out.field("")
+		}
+		return d.out.field(mutedStyle.Render("aka " + strings.Join(d.item.aliases, ", ")))
+	},
+	"due": func(d *layoutData) string { return d.out.field(dueBadge(d.item.due, time.Now())) },
+	"age": func(d *layoutData) string { return d.out.field(ageBadge(d.item.modTime, time.Now())) },
+	"tags": func(d *layoutData) string {
+		// The pills are separated by one space
+		start := d.out.column()
+		for _, tag := range strings.Fields(d.item.allTags()) {
+			width := lipgloss.Width(leftHalfCircle + tagLabel(tag) + rightHalfCircle)
+			d.tags = append(d.tags, fieldSpan{value: strings.TrimPrefix(tag, "+"), start: start, end: start + width})
+			start += width + 1
+		}
+		return d.out.field(tagPills(d.item, d.selected))
+	},
+	"mtime": func(d *layoutData, layout ...string) string {
+		return d.out.field(formatItemTime(d.item.modTime, layout))
+	},
+	"date": func(d *layoutData, layout ...string) string {
+		return d.out.field(formatItemTime(d.item.date, layout))
+	},
+}
+
+// passData adds the note as the first argument of the fields called by a
+// template: {{mtime "Jan 2"}} runs mtime $ "Jan 2", $ being the note
+// whatever the dot is
+func passData(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			passData(child)
+		}
+	case *parse.ActionNode:
+		passData(n.Pipe)
+	case *parse.IfNode:
+		passBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		passBranch(&n.BranchNode)
+	case *parse.WithNode:
+		passBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		passData(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			var args []parse.Node
+			for i, arg := range cmd.Args {
+				ident, ok := arg.(*parse.IdentifierNode)
+				if !ok || layoutFuncs[ident.Ident] == nil {
+					passData(arg)
+					args = append(args, arg)
+					continue
+				}
+				data := &parse.VariableNode{NodeType: parse.NodeVariable, Pos: ident.Pos, Ident: []string{"$"}}
+				if i == 0 {
+					args = append(args, ident, data)
+				} else {
+					// A field given to a function is called first
+					call := &parse.CommandNode{NodeType: parse.NodeCommand, Pos: ident.Pos, Args: []parse.Node{ident, data}}
+					args = append(args, &parse.PipeNode{NodeType: parse.NodePipe, Pos: ident.Pos, Cmds: []*parse.CommandNode{call}})
+				}
+			}
+			cmd.Args = args
+		}
+	}
+}
+
+func passBranch(n *parse.BranchNode) {
+	passData(n.Pipe)
+	passData(n.List)
+	passData(n.ElseList)
+}
+
+// parseLayout parses a template of the list
+func parseLayout(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(layoutFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, associated := range t.Templates() {
+		if associated.Tree != nil {
+			passData(associated.Tree.Root)
+		}
+	}
+	return t, nil
+}
+
+// formatItemTime formats a time of a note, dimmed, like Jan 2 without a
+// layout
+func formatItemTime(t time.Time, layout []string) string {
+	if t.IsZero() {
+		return ""
+	}
+	format := "Jan 2"
+	if len(layout) > 0 {
+		format = layout[0]
+	}
+	return mutedStyle.Render(t.Format(format))
+}
+
+// newItemLayout parses the templates of a list config
+func newItemLayout(c listConfig) (itemLayout, error) {
+	layout := itemLayout{compact: c.Density == densityCompact}
+	switch c.Density {
+	case "", densityComfortable, densityCompact:
+	default:
+		return layout, fmt.Errorf("unknown list density %q, expected comfortable or compact", c.Density)
+	}
+
+	line, compactLine, details := c.Layout, c.Layout, c.Details
+	if c.Layout == "" {
+		line, compactLine = defaultLayout, defaultCompactLayout
+	}
+	if details == "" {
+		details = defaultDetails
+	}
+
+	var err error
+	if layout.line, err = parseLayout("layout", line); err != nil {
+		return layout, fmt.Errorf("invalid list layout: %v", err)
+	}
+	if layout.compactLine, err = parseLayout("layout", compactLine); err != nil {
+		return layout, fmt.Errorf("invalid list layout: %v", err)
+	}
+	if layout.details, err = parseLayout("details", details); err != nil {
+		return layout, fmt.Errorf("invalid list details: %v", err)
+	}
+	return layout, nil
+}
+
+// mustItemLayout parses the templates of a list config already validated
+func mustItemLayout(c listConfig) itemLayout {
+	layout, err := newItemLayout(c)
+	if err != nil {
+		panic(err)
+	}
+	return layout
+}
+
+// current returns the template of the line of the notes in the density
+func (l itemLayout) current() *template.Template {
+	if l.compact {
+		return l.compactLine
+	}
+	return l.line
+}
+
+// density returns the name of the density of the layout
+func (l itemLayout) density() string {
+	if l.compact {
+		return densityCompact
+	}
+	return densityComfortable
+}
+
+// height returns the number of lines of a note
+func (l itemLayout) height() int {
+	if l.compact {
+		return 1
+	}
+	return 2
+}
+
+// spacing returns the number of blank lines between the notes
+func (l itemLayout) spacing() int {
+	if l.compact {
+		return 0
+	}
+	return 1
+}
+
+// renderItem fills in a template for a note. The fields without a value
+// leave no gap.
+func renderItem(t *template.Template, item noteItem, selected bool) string {
+	line, _ := renderLayout(t, item, selected)
+	return line
+}
+
+// renderLayout fills in a template for a note, and returns where its fields
+// were rendered
+func renderLayout(t *template.Template, item noteItem, selected bool) (string, *layoutData) {
+	d := &layoutData{item: item, selected: selected, out: &layoutWriter{}}
+	if err := t.Execute(d.out, d); err != nil {
+		return item.Title(), &layoutData{}
+	}
+	return strings.TrimRight(d.out.buf.String(), " "), d
+}
+
+// setDensity shows the notes of the list on one line or two
+func (m *model) setDensity(density string) {
+	listLayout.compact = density == densityCompact
+	m.list.SetDelegate(NewCustomDelegate())
+}
+
+// toggleDensity switches the list between one and two lines per note
+func (m model) toggleDensity() (tea.Model, tea.Cmd) {
+	if listLayout.compact {
+		m.setDensity(densityComfortable)
+		return m, m.setInfo("Two lines per note")
+	}
+	m.setDensity(densityCompact)
+	return m, m.setInfo("One line per note")
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// snsmDir holds the state of snsm in the notes directory: the locks, the
+// versions and the trash. It is kept out of git and the syncs, the locks of
+// another machine never going stale.
+const snsmDir = ".snsm"
+
+// locksDir holds the locks of the notes open in an editor, for the other
+// instances of the machine
+const locksDir = ".snsm/locks"
+
+// noteLock tells who is editing a note. Other tools can honor it too: the
+// note is locked while its lock file exists and its process runs.
+type noteLock struct {
+	PID   int       `json:"pid"`
+	Host  string    `json:"host"`
+	Since time.Time `json:"since"`
+}
+
+// lockPath returns the location of the lock of a note
+func lockPath(notesDir, filename string) string {
+	return filepath.Join(notesDir, filepath.FromSlash(locksDir), filename+".lock")
+}
+
+// hostname returns the name of the machine, for the locks
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
+
+// ours reports whether the lock is held by this process
+func (l noteLock) ours() bool {
+	return l.PID == os.Getpid() && l.Host == hostname()
+}
+
+// stale reports whether the process holding the lock is gone. The processes
+// of other machines can't be checked, their locks are honored.
+func (l noteLock) stale() bool {
+	return l.Host == hostname() && !processAlive(l.PID)
+}
+
+// describe tells who holds the lock, for the warning
+func (l noteLock) describe() string {
+	return fmt.Sprintf("snsm %d on %s since %s", l.PID, l.Host, l.Since.Local().Format("Jan 2 15:04"))
+}
+
+// heldLock returns the lock of a note held by another process, if any
+func heldLock(notesDir, filename string) (noteLock, bool) {
+	data, err := os.ReadFile(lockPath(notesDir, filename))
+	if err != nil {
+		return noteLock{}, false
+	}
+	var lock noteLock
+	if json.Unmarshal(data, &lock) != nil || lock.ours() || lock.stale() {
+		return noteLock{}, false
+	}
+	return lock, true
+}
+
+// makeSnsmDir creates a folder of the .snsm folder, writing the .gitignore
+// keeping the folder out of git when it is missing
+func makeSnsmDir(notesDir, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	ignore := filepath.Join(notesDir, snsmDir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		return os.WriteFile(ignore, []byte("# The locks, versions and trash of snsm stay on this machine\n*\n"), 0644)
+	}
+	return nil
+}
+
+// lockNote takes the lock of a note for this process, replacing a stale one
+// but not the lock of another process, when the note is edited anyway. Not
+// locking the note doesn't prevent editing it, the error is only logged.
+func lockNote(notesDir, filename string) {
+	if _, held := heldLock(notesDir, filename); held {
+		return
+	}
+	data, _ := json.Marshal(noteLock{PID: os.Getpid(), Host: hostname(), Since: time.Now()})
+	path := lockPath(notesDir, filename)
+	err := makeSnsmDir(notesDir, filepath.Dir(path))
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		debugLog.Warn("lock", "note", filename, "err", err)
+	}
+}
+
+// unlockNote releases the lock of a note, when this process holds it
+func unlockNote(notesDir, filename string) {
+	data, err := os.ReadFile(lockPath(notesDir, filename))
+	if err != nil {
+		return
+	}
+	var lock noteLock
+	if json.Unmarshal(data, &lock) == nil && lock.ours() {
+		os.Remove(lockPath(notesDir, filename))
+	}
+}
+
+// lockedOpen is the opening of a note locked by another process, waiting
+// for a choice in lockMenu
+type lockedOpen struct {
+	filename string
+	line     int
+	// quick is set for the built-in editor rather than $EDITOR
+	quick bool
+	// back is the mode the note was opened from
+	back int
+}
+
+// warnLocked asks what to do with a note another process is editing: view
+// it read-only, edit it anyway or cancel
+func (m *model) warnLocked(open lockedOpen, lock noteLock) {
+	open.back = m.mode
+	m.lockedOpen = open
+	m.lockMenu = menu{
+		title: open.filename + " is open in " + lock.describe(),
+		items: []menuItem{
+			{label: "Open read-only", hint: "in the viewer"},
+			{label: "Edit anyway", hint: "the last saved changes win"},
+			{label: "Cancel"},
+		},
+	}
+	m.mode = modeLocked
+}
+
+func (m model) updateLocked(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.lockMenu.update(msg)
+	open := m.lockedOpen
+	if closed {
+		m.mode = open.back
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+
+	m.mode = open.back
+	switch m.lockMenu.cursor {
+	case 0:
+		for _, item := range m.items {
+			if item.filename == open.filename {
+				return m.viewItem(item, false)
+			}
+		}
+		return m, m.setError("Cannot find %s", open.filename)
+	case 1:
+		if open.quick {
+			return m.quickEdit(open.filename)
+		}
+		return m, m.editInEditor(open.filename, open.line)
+	}
+	return m, nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// processAlive reports whether a process of this machine runs, finding it
+// failing on Windows once it exited
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
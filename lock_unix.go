@@ -0,0 +1,12 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// processAlive reports whether a process of this machine runs, signal 0
+// checking it without signaling it
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -2,13 +2,19 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -19,28 +25,44 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-var (
-	titleStyle        = lipgloss.NewStyle().MarginLeft(2).Foreground(lipgloss.Color("white"))
-	itemStyle         = lipgloss.NewStyle().PaddingLeft(4)
-	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("green"))
-	paginationStyle   = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
-	helpStyle         = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
-	quitTextStyle     = lipgloss.NewStyle().Margin(1, 0, 2, 4)
-	inputStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-
-	// Pill styling - changed to blue tones
-	tagPillStyle         = lipgloss.NewStyle().Background(lipgloss.Color("27")).Foreground(lipgloss.Color("255"))
-	selectedTagPillStyle = lipgloss.NewStyle().Background(lipgloss.Color("39")).Foreground(lipgloss.Color("255")).Bold(true)
-
-	// Circle styling - foreground matches the background of the pill
-	circleStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("27"))
-	selectedCircleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
-)
-
 const (
 	modeList = iota
 	modeInput
 	modeTagInput
+	modeTemplateInput
+	modeFolderInput
+	modeVaultSwitcher
+	modeSearches
+	modeTasks
+	modeMove
+	modeMoveFolder
+	modeLabelInput
+	modeCollision
+	modeRecent
+	modeReview
+	modeDedupe
+	modeCheck
+	modeGraph
+	modeCalendar
+	modeConflicts
+	modeConflictCopies
+	modeVersions
+	modePager
+	modeShare
+	modeCopy
+	modePalette
+	modeEditor
+	modeAppend
+	modeTagEdit
+	modeTagTree
+	modeAssistantMenu
+	modeAssistant
+	modeCommandLine
+	modeLocked
+	modeRelations
+	modeBoard
+	modeSnippets
+	modeOpenWith
 
 	// Unicode half circles for pill styling
 	leftHalfCircle  = ""
@@ -59,20 +81,33 @@ func NewCustomDelegate() list.ItemDelegate {
 
 	// Style base delegate
 	delegate.Styles.NormalTitle = delegate.Styles.NormalTitle.
-		Foreground(lipgloss.Color("255")) // White for unselected items
+		Foreground(lipgloss.Color(currentTheme.Text))
 	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
-		Foreground(lipgloss.Color("10")). // Bright green for selected items
+		Foreground(lipgloss.Color(currentTheme.Selected)).
+		BorderForeground(lipgloss.Color(currentTheme.Selected)).
 		Bold(true)
 
 	// Clear description styles (we'll handle them in Render)
 	delegate.Styles.NormalDesc = lipgloss.NewStyle()
 	delegate.Styles.SelectedDesc = lipgloss.NewStyle()
 
+	// One or two lines per note, depending on the density of the config
+	delegate.SetHeight(listLayout.height())
+	delegate.SetSpacing(listLayout.spacing())
+
 	return delegate
 }
 
 // Override Render to customize the appearance of list items
 func (d customItemDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	if header, ok := listItem.(groupHeader); ok {
+		d.renderHeader(w, header, index == m.Index())
+		return
+	}
+	if entry, ok := listItem.(folderEntry); ok {
+		d.renderFolder(w, entry, index == m.Index())
+		return
+	}
 	item, ok := listItem.(noteItem)
 	if !ok {
 		d.DefaultDelegate.Render(w, m, index, listItem)
@@ -80,98 +115,522 @@ func (d customItemDelegate) Render(w io.Writer, m list.Model, index int, listIte
 	}
 
 	isSelected := index == m.Index()
-	var title string
-	var tags string
-
+	line := renderItem(listLayout.current(), item, isSelected)
+	if markedNotes[item.filename] {
+		line = statusStyle.Copy().PaddingLeft(0).Render("✓") + " " + line
+	}
 	if isSelected {
-		title = d.Styles.SelectedTitle.Render(item.Title())
+		line = d.Styles.SelectedTitle.Render(line)
 	} else {
-		title = d.Styles.NormalTitle.Render(item.Title())
+		line = d.Styles.NormalTitle.Render(line)
+	}
+	if listLayout.compact {
+		fmt.Fprint(w, line)
+		return
 	}
 
-	// Format tags as pills
-	if item.tags != "" {
-		tagWords := strings.Fields(item.tags)
-		var formattedTags []string
-
-		for _, tag := range tagWords {
-			// Remove + prefix if present
-			tagText := tag
-			if strings.HasPrefix(tagText, "+") {
-				tagText = tagText[1:]
-			}
-
-			// Style each tag as a pill with matching circle foreground
-			if isSelected {
-				formattedTags = append(formattedTags,
-					selectedCircleStyle.Render(leftHalfCircle)+
-						selectedTagPillStyle.Render(tagText)+
-						selectedCircleStyle.Render(rightHalfCircle))
-			} else {
-				formattedTags = append(formattedTags,
-					circleStyle.Render(leftHalfCircle)+
-						tagPillStyle.Render(tagText)+
-						circleStyle.Render(rightHalfCircle))
-			}
-		}
-
-		tags = strings.Join(formattedTags, " ")
+	// Write the line and the details below it
+	fmt.Fprintf(w, "%s\n", line)
+	if details := renderItem(listLayout.details, item, isSelected); details != "" {
+		fmt.Fprintf(w, "  %s", details)
 	}
+}
 
-	// Write title and tags with spacing
-	fmt.Fprintf(w, "%s\n", title)
-	if tags != "" {
-		fmt.Fprintf(w, "  %s", tags)
+// tagPills formats the tags of a note as pills, the inherited ones dimmed
+func tagPills(item noteItem, isSelected bool) string {
+	ownTags := strings.Fields(item.tags)
+	var formattedTags []string
+	for _, tag := range strings.Fields(item.allTags()) {
+		// Remove + prefix if present
+		tagText := tagLabel(tag)
+
+		// Style each tag as a pill with matching circle foreground
+		if !containsTag(ownTags, tag) {
+			// Tags inherited from the folder are dimmed
+			formattedTags = append(formattedTags,
+				inheritedCircleStyle.Render(leftHalfCircle)+
+					inheritedTagPillStyle.Render(tagText)+
+					inheritedCircleStyle.Render(rightHalfCircle))
+		} else if isSelected {
+			formattedTags = append(formattedTags,
+				selectedCircleStyle.Render(leftHalfCircle)+
+					selectedTagPillStyle.Render(tagText)+
+					selectedCircleStyle.Render(rightHalfCircle))
+		} else {
+			formattedTags = append(formattedTags,
+				circleStyle.Render(leftHalfCircle)+
+					tagPillStyle.Render(tagText)+
+					circleStyle.Render(rightHalfCircle))
+		}
 	}
+	return strings.Join(formattedTags, " ")
 }
 
 // Custom keymaps for our list
 type listKeyMap struct {
-	createNote key.Binding
+	open        key.Binding
+	createNote  key.Binding
+	switchVault key.Binding
+	searches    key.Binding
+	paste       key.Binding
+	export      key.Binding
+	tasks       key.Binding
+	preview     key.Binding
+	view        key.Binding
+	outline     key.Binding
+	move        key.Binding
+	label       key.Binding
+	sort        key.Binding
+	recent      key.Binding
+	random      key.Binding
+	review      key.Binding
+	dedupe      key.Binding
+	check       key.Binding
+	graph       key.Binding
+	relations   key.Binding
+	board       key.Binding
+	calendar    key.Binding
+	sync        key.Binding
+	copies      key.Binding
+	versions    key.Binding
+	diff        key.Binding
+	mark        key.Binding
+	openMarked  key.Binding
+	openWith    key.Binding
+	info        key.Binding
+	share       key.Binding
+	copy        key.Binding
+	copyURL     key.Binding
+	openFolder  key.Binding
+	warnings    key.Binding
+	palette     key.Binding
+	commandLine key.Binding
+	undo        key.Binding
+	quickEdit   key.Binding
+	quickAppend key.Binding
+	snippet     key.Binding
+	density     key.Binding
+	columns     key.Binding
+	group       key.Binding
+	browse      key.Binding
+	section     key.Binding
+	editTags    key.Binding
+	tagTree     key.Binding
+	assistant   key.Binding
 }
 
 // Define our custom keybindings
 var customListKeys = listKeyMap{
+	open: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "open in $EDITOR"),
+	),
 	createNote: key.NewBinding(
 		key.WithKeys("n"),
 		key.WithHelp("n", "new note"),
 	),
+	switchVault: key.NewBinding(
+		key.WithKeys("ctrl+o"),
+		key.WithHelp("ctrl+o", "switch vault"),
+	),
+	searches: key.NewBinding(
+		key.WithKeys("ctrl+f"),
+		key.WithHelp("ctrl+f", "saved searches"),
+	),
+	paste: key.NewBinding(
+		key.WithKeys("ctrl+v"),
+		key.WithHelp("ctrl+v", "new note from clipboard"),
+	),
+	export: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "export note"),
+	),
+	tasks: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "tasks"),
+	),
+	preview: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "toggle preview"),
+	),
+	view: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "view with images"),
+	),
+	outline: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "view outline"),
+	),
+	move: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "move to folder"),
+	),
+	label: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "color label"),
+	),
+	sort: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "change sort"),
+	),
+	recent: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "recent notes"),
+	),
+	random: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("ctrl+r", "random note"),
+	),
+	review: key.NewBinding(
+		key.WithKeys("R"),
+		key.WithHelp("R", "review"),
+	),
+	dedupe: key.NewBinding(
+		key.WithKeys("D"),
+		key.WithHelp("D", "duplicates"),
+	),
+	check: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("L", "check links"),
+	),
+	graph: key.NewBinding(
+		key.WithKeys("ctrl+g"),
+		key.WithHelp("ctrl+g", "link graph"),
+	),
+	relations: key.NewBinding(
+		key.WithKeys("P"),
+		key.WithHelp("P", "relationships"),
+	),
+	board: key.NewBinding(
+		key.WithKeys("B"),
+		key.WithHelp("B", "board"),
+	),
+	density: key.NewBinding(
+		key.WithKeys("z"),
+		key.WithHelp("z", "compact list"),
+	),
+	columns: key.NewBinding(
+		key.WithKeys("|"),
+		key.WithHelp("|", "columns"),
+	),
+	group: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("w", "group by tag or folder"),
+	),
+	browse: key.NewBinding(
+		key.WithKeys("F"),
+		key.WithHelp("F", "browse folders"),
+	),
+	section: key.NewBinding(
+		key.WithKeys(" ", "-", "+"),
+		key.WithHelp("space/-/+", "collapse section/all, expand all"),
+	),
+	calendar: key.NewBinding(
+		key.WithKeys("C"),
+		key.WithHelp("C", "calendar"),
+	),
+	sync: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "git sync"),
+	),
+	copies: key.NewBinding(
+		key.WithKeys("X"),
+		key.WithHelp("X", "conflicted copies"),
+	),
+	versions: key.NewBinding(
+		key.WithKeys("H"),
+		key.WithHelp("H", "versions"),
+	),
+	diff: key.NewBinding(
+		key.WithKeys("="),
+		key.WithHelp("=", "compare notes"),
+	),
+	mark: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "mark note"),
+	),
+	openMarked: key.NewBinding(
+		key.WithKeys("E"),
+		key.WithHelp("E", "open marked notes"),
+	),
+	openWith: key.NewBinding(
+		key.WithKeys("ctrl+e"),
+		key.WithHelp("ctrl+e", "open with…"),
+	),
+	info: key.NewBinding(
+		key.WithKeys("M"),
+		key.WithHelp("M", "note info"),
+	),
+	share: key.NewBinding(
+		key.WithKeys("U"),
+		key.WithHelp("U", "share"),
+	),
+	copy: key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "copy path, title or contents"),
+	),
+	copyURL: key.NewBinding(
+		key.WithKeys("Y"),
+		key.WithHelp("Y", "copy file:// URL"),
+	),
+	openFolder: key.NewBinding(
+		key.WithKeys("O"),
+		key.WithHelp("O", "open folder"),
+	),
+	warnings: key.NewBinding(
+		key.WithKeys("W"),
+		key.WithHelp("W", "scan warnings"),
+	),
+	palette: key.NewBinding(
+		key.WithKeys("ctrl+p"),
+		key.WithHelp("ctrl+p", "run a command"),
+	),
+	commandLine: key.NewBinding(
+		key.WithKeys(":"),
+		key.WithHelp(":", "command line"),
+	),
+	undo: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "undo"),
+	),
+	quickEdit: key.NewBinding(
+		key.WithKeys("i"),
+		key.WithHelp("i", "quick edit"),
+	),
+	quickAppend: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("A", "append a line"),
+	),
+	snippet: key.NewBinding(
+		key.WithKeys("I"),
+		key.WithHelp("I", "insert a snippet"),
+	),
+	editTags: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("T", "edit tags"),
+	),
+	tagTree: key.NewBinding(
+		key.WithKeys("#"),
+		key.WithHelp("#", "tag tree"),
+	),
+	assistant: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "assistant"),
+	),
 }
 
 type noteItem struct {
+	path     string
 	filename string
 	tags     string
+	// title replaces the filename in the list when set, from the front
+	// matter or the org-mode #+TITLE
+	title string
+	// heading is the first heading of the note, read when the notes are
+	// displayed by their heading
+	heading string
+	// label is the color or icon shown before the title, from the front
+	// matter
+	label string
+	// due is the earliest due date of the note not done yet, from its front
+	// matter or its @due markers
+	due time.Time
+	// date is the date of the note from its front matter, like the one of a
+	// meeting
+	date time.Time
+	// parent and related are the notes named by the front matter, as
+	// written
+	parent  string
+	related []string
+	// aliases are the other names of the note, to find and link it by
+	aliases []string
+	// folderTags are inherited from the .snsm.yaml files of the folders
+	folderTags string
+	modTime    time.Time
+	// readErr is set when the note couldn't be read while scanning
+	readErr error
 }
 
 func (i noteItem) FilterValue() string {
-	// Use both filename and tags for filtering
-	return i.filename + " " + i.tags
+	// Use the filename, the title, the aliases and the tags for filtering
+	value := i.filename
+	if title := i.Title(); title != trimNoteExt(i.filename) {
+		value += " " + title
+	}
+	for _, alias := range i.aliases {
+		value += " " + alias
+	}
+	return value + " " + i.allTags()
+}
+
+// allTags returns the tags of the note followed by the ones it inherits
+func (i noteItem) allTags() string {
+	return mergeTags(i.tags, i.folderTags)
 }
 
 // Implement list.Item interface
 func (i noteItem) Title() string {
-	// Return filename without .md extension
-	return strings.TrimSuffix(i.filename, ".md")
+	if i.title != "" {
+		return i.title
+	}
+	if i.heading != "" {
+		return i.heading
+	}
+	// Return filename without its extension
+	return trimNoteExt(i.filename)
 }
 
 func (i noteItem) Description() string { return i.tags }
 
 type model struct {
-	list        list.Model
-	items       []noteItem
-	choice      string
-	quitting    bool
-	mode        int
-	textInput   textinput.Model
-	tagInput    textinput.Model
-	keys        listKeyMap
-	newNoteTags string
-	notesDir    string
-}
-
-func initialModel(notesDir string) model {
+	list          list.Model
+	items         []noteItem
+	quitting      bool
+	mode          int
+	textInput     textinput.Model
+	tagInput      textinput.Model
+	templateInput textinput.Model
+	folderInput   textinput.Model
+	keys          listKeyMap
+	step          int
+	notesDir      string
+	vaultName     string
+	vaultMenu     menu
+	search        savedSearch
+	searchQuery   query
+	searchMenu    menu
+	tasks         taskView
+	// moving is the filename of the note being moved
+	moving   string
+	moveMenu menu
+	// sortMode is the order of the notes in the list
+	sortMode string
+	// history tells when the notes were opened
+	history    noteHistory
+	recent     []noteItem
+	recentMenu menu
+	review     reviewSession
+	dedupe     dedupeView
+	check      checkView
+	graph      graphView
+	relations  relationsView
+	board      boardView
+	// snippets are the snippets of snippetMenu, inserted before going back
+	// to snippetReturn, the list or the built-in editor
+	snippets      []snippet
+	snippetMenu   menu
+	snippetReturn int
+	calendar      calendarView
+	// syncing is set while the notes are synced with git
+	syncing bool
+	// shownTitle is the title last given to the terminal window
+	shownTitle string
+	// gitStatus is where the notes stand against their git remote
+	gitStatus     repoStatus
+	conflictsMenu menu
+	// conflictCopies lists the copies sync tools made of conflicting notes
+	conflictCopies conflictCopiesView
+	versions       versionsView
+	pager          pagerView
+	// diffMark is the note marked to be compared with the next one
+	diffMark string
+	// sharing is the note whose upload is confirmed with shareMenu
+	sharing   string
+	shareMenu menu
+	// copyMenu chooses what to copy of the selected note
+	copyMenu menu
+	// openWithMenu chooses how to open the selected note, openWithApps
+	// being the applications listed after the builtin ways
+	openWithMenu menu
+	openWithApps []noteCommand
+	// scanWarnings are the problems of the files of the last scan
+	scanWarnings []scanWarning
+	// palette lists the commands of the config to run on the selected note
+	palette menu
+	// pluginItems are the entries of the plugins at the end of the palette
+	pluginItems []pluginItem
+	// paletteNote is the note the palette is open on, and palettePending
+	// the number of plugins yet to add their entries
+	paletteNote    string
+	palettePending int
+	// editor edits a note without leaving snsm
+	editor quickEditor
+	// backedUp is set once the notes were backed up before a change
+	backedUp bool
+	// restoring is the session being restored, until the list filtered the
+	// notes with its filter
+	restoring *session
+	// inputErr tells why the value of a new note prompt was refused
+	inputErr string
+	// newFilename and newTitle are those of the note being created
+	newFilename   string
+	newTitle      string
+	collisionMenu menu
+	// labeling is the note whose label is being set
+	labeling   noteItem
+	labelInput textinput.Model
+	// appending is the note a line is appended to
+	appending   noteItem
+	appendInput textinput.Model
+	// retagging is the note whose tags are edited with tagInput
+	retagging noteItem
+	// tagSuggestions are the tags suggested in tagInput, from the content
+	// of the note
+	tagSuggestions []string
+	// tagTree browses the tags by their hierarchy
+	tagTree tagTreeView
+	// assistantMenu chooses what the assistant does with the selected note,
+	// and assistant shows its proposal
+	assistantMenu menu
+	assistant     assistantView
+	// undo are the actions of the session that u undoes, the last one last
+	undo []undoEntry
+	// lockedOpen is the note another process is editing, lockMenu asking
+	// what to do with it
+	lockedOpen lockedOpen
+	lockMenu   menu
+	// cmdLine runs the : commands
+	cmdLine commandLineView
+	// preview shows the selected note next to the list
+	preview       bool
+	previewScroll int
+	previewPath   string
+	// columns show the folders, the notes and the preview side by side
+	columns columnsView
+	// group is how the notes are grouped in sections, tag or folder, and
+	// collapsed the sections showing only their header
+	group     string
+	collapsed map[string]bool
+	// browsing lists the notes folder by folder, browseDir being the folder
+	// listed
+	browsing  bool
+	browseDir string
+	// popup picks notes rather than editing them, picked being the notes
+	// chosen
+	popup  bool
+	picked []string
+	// imageProtocol draws the images of the viewer
+	imageProtocol string
+	// shown are the notes of the list, for the query filter
+	shown    *shownNotes
+	cfg      config
+	status   statusMessage
+	statusID int
+	width    int
+	height   int
+	click    lastClick
+	// startupCmd runs once the program starts
+	startupCmd tea.Cmd
+	// filterID identifies the last filtering of a large vault, the earlier
+	// ones being dropped
+	filterID int
+	// embedding is the filter whose about: texts are being embedded
+	embedding string
+}
+
+func initialModel(notesDir string, cfg config) model {
 	ti := textinput.New()
-	ti.Placeholder = "Enter filename (without .md extension), use %t for timestamp"
+	ti.Placeholder = "Enter a title, use %t for timestamp"
 	ti.Focus()
 	ti.CharLimit = 100
 	ti.Width = 40
@@ -181,30 +640,59 @@ func initialModel(notesDir string) model {
 	tagInput.CharLimit = 100
 	tagInput.Width = 40
 
+	templateInput := textinput.New()
+	templateInput.Placeholder = "Enter a template name"
+	templateInput.CharLimit = 100
+	templateInput.Width = 40
+	templateInput.ShowSuggestions = true
+
+	folderInput := textinput.New()
+	folderInput.Placeholder = "Enter a folder (e.g. projects/acme)"
+	folderInput.CharLimit = 100
+	folderInput.Width = 40
+	folderInput.ShowSuggestions = true
+
+	labelInput := textinput.New()
+	labelInput.Placeholder = "Enter a color or an emoji"
+	labelInput.CharLimit = 20
+	labelInput.Width = 40
+	labelInput.ShowSuggestions = true
+
+	appendInput := textinput.New()
+	appendInput.Placeholder = "Enter a line to append"
+	appendInput.Width = 60
+
+	cmdInput := textinput.New()
+	cmdInput.Prompt = ":"
+	cmdInput.Width = 60
+	cmdInput.ShowSuggestions = true
+
 	return model{
-		textInput: ti,
-		tagInput:  tagInput,
-		mode:      modeList,
-		keys:      customListKeys,
-		notesDir:  notesDir,
+		textInput:     ti,
+		tagInput:      tagInput,
+		templateInput: templateInput,
+		folderInput:   folderInput,
+		labelInput:    labelInput,
+		appendInput:   appendInput,
+		cmdLine:       commandLineView{input: cmdInput},
+		mode:          modeList,
+		keys:          customListKeys,
+		notesDir:      notesDir,
+		cfg:           cfg,
+		click:         lastClick{index: -1},
+		collapsed:     map[string]bool{},
+		shown:         &shownNotes{},
 	}
 }
 
-// Create a model ready to accept new note input
-func initialNewNoteModel(notesDir string) model {
-	m := initialModel(notesDir)
-	m.mode = modeInput
-	return m
-}
-
 func (m model) Init() tea.Cmd {
-	commands := []tea.Cmd{tea.EnterAltScreen}
+	commands := []tea.Cmd{tea.EnterAltScreen, m.startupCmd}
 
-	if m.list.Items() != nil {
+	if len(m.list.Items()) > 0 {
 		commands = append(commands, m.list.StartSpinner())
 	}
 
-	if m.mode == modeInput {
+	if m.mode != modeList {
 		commands = append(commands, textinput.Blink)
 	}
 
@@ -212,108 +700,551 @@ func (m model) Init() tea.Cmd {
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	updated, cmd := m.update(msg)
+	if m, ok := updated.(model); ok {
+		return m.syncTerminalTitle(cmd)
+	}
+	return updated, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		debugLog.Debug("key", "key", keyMsg.String(), "mode", m.mode)
+	}
+
+	// Messages handled the same way whatever the mode
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.layout()
+
+		// Return a command to redraw the UI after resize
+		return m, tea.ClearScreen
+
+	case clearStatusMsg:
+		if msg.id == m.statusID {
+			m.status = statusMessage{}
+		}
+		return m, nil
+
+	case filterDebounceMsg:
+		if msg.id == m.filterID {
+			return m, msg.cmd
+		}
+		return m, nil
+
+	case fileTagsSyncedMsg:
+		if msg.err != nil {
+			return m, m.setError("Cannot sync file manager tags: %v", msg.err)
+		}
+		return m, nil
+
+	case viewerClosedMsg:
+		if msg.err != nil {
+			return m, m.setError("Cannot view the note: %v", msg.err)
+		}
+		return m, nil
+
+	case syncProgressMsg, syncFinishedMsg:
+		return m.updateSync(msg)
+
+	case gitStatusMsg:
+		// The status of a vault switched from is dropped
+		if msg.notesDir == m.notesDir {
+			m.gitStatus = msg.status
+		}
+		return m, nil
+
+	case shareFinishedMsg:
+		return m.shared(msg)
+
+	case commandFinishedMsg:
+		return m.commandFinished(msg)
+
+	case pluginFinishedMsg:
+		return m.pluginFinished(msg)
+
+	case pluginMenuMsg:
+		return m.pluginMenuAnswered(msg)
+
+	case queryEmbeddedMsg:
+		return m.queryEmbedded(msg)
+
+	case assistantFinishedMsg:
+		return m.assistantFinished(msg)
+
+	case printFinishedMsg:
+		return m.printFinished(msg)
+
+	case exportFinishedMsg:
+		if msg.err != nil {
+			return m, m.setError("Cannot export: %v", msg.err)
+		}
+		return m, m.setInfo("Exported to %s", strings.Join(msg.paths, ", "))
+
+	case markedEditedMsg:
+		return m.markedEdited(msg)
+
+	case editorFinishedMsg:
+		debugLog.Info("editor finished", "note", msg.filename, "changed", msg.changed, "err", msg.err)
+		if msg.err != nil {
+			m.mode = modeList
+			return m, m.setError("Cannot edit %s: %v", msg.filename, msg.err)
+		}
+		if m.cfg.QuitAfterEdit {
+			// snsm is leaving, the hook runs before it rather than in the
+			// background
+			if msg.changed {
+				if err := noteSaved("post_edit", m.notesDir, msg.filename); err != nil {
+					m.mode = modeList
+					return m, tea.Batch(m.reloadNotes(), m.setError("Saved %s, but %v", msg.filename, err))
+				}
+			}
+			m.quitting = true
+			return m, tea.Quit
+		}
+		return m.editorFinished(msg)
+
+	case hookFinishedMsg:
+		return m.hookFinished(msg)
+	}
+
 	switch m.mode {
 	case modeList:
 		switch msg := msg.(type) {
+		case tea.MouseMsg:
+			return m.updateListMouse(msg)
+
 		case tea.KeyMsg:
+			if m.columnsShown() && !m.list.SettingFilter() {
+				if model, cmd, ok := m.updateColumns(msg); ok {
+					return model, cmd
+				}
+			}
+
 			switch keypress := msg.String(); keypress {
 			case "q", "ctrl+c":
+				// q is typed in the filter, like any other letter
+				if keypress == "q" && m.list.SettingFilter() {
+					break
+				}
 				m.quitting = true
 				return m, tea.Quit
 
 			case "enter":
 				i, ok := m.list.SelectedItem().(noteItem)
 				if ok {
-					m.choice = i.filename
-					return m, tea.Quit
+					return m, m.openNote(i.filename)
+				}
+				if _, ok := m.list.SelectedItem().(groupHeader); ok {
+					return m.toggleSection()
+				}
+				if entry, ok := m.list.SelectedItem().(folderEntry); ok {
+					return m.browseTo(entry.path, "")
+				}
+
+			case "?":
+				if !m.list.SettingFilter() {
+					return m.openKeyHelp()
 				}
 
 			case "n":
 				// Only trigger new note creation if not filtering
 				if !m.list.SettingFilter() {
-					m.mode = modeInput
-					return m, textinput.Blink
+					return m.startNewNote()
 				}
-			}
 
-		case tea.WindowSizeMsg:
-			// Use the full height of the terminal, minus 1 for status bar
-			h := msg.Height - 1
-			m.list.SetHeight(h)
-			m.list.SetWidth(msg.Width)
+			case "ctrl+o":
+				return m.openVaultSwitcher()
 
-			// Return a command to redraw the UI after resize
-			return m, tea.ClearScreen
-		}
+			case "ctrl+f":
+				return m.openSearches()
 
-		m.list, cmd = m.list.Update(msg)
-		return m, cmd
+			case "ctrl+r":
+				return m.openRandom()
 
-	case modeInput:
-		switch msg := msg.(type) {
-		case tea.KeyMsg:
-			switch msg.String() {
-			case "esc":
-				// Return to list mode if we came from there
-				if m.list.Items() != nil {
-					m.mode = modeList
+			case "ctrl+v":
+				return m.pasteFromClipboard()
+
+			case "e":
+				if !m.list.SettingFilter() {
+					return m.exportSelected()
+				}
+
+			case "t":
+				if !m.list.SettingFilter() {
+					return m.openTasks()
+				}
+
+			case "p":
+				if !m.list.SettingFilter() {
+					m.togglePreview()
 					return m, nil
-				} else {
-					// Otherwise just quit
-					m.quitting = true
-					return m, tea.Quit
 				}
 
-			case "enter":
-				// Create and open the new file
-				filename := m.textInput.Value()
-				if filename != "" {
-					// Replace timestamp placeholder with current date
-					filename = expandTimestamp(filename)
-
-					// Remove any .md extension the user might have added
-					filename = strings.TrimSuffix(filename, ".md")
-					// Always add .md extension
-					filename += ".md"
-
-					m.choice = filename
-					// Switch to tag input
-					m.mode = modeTagInput
-					m.tagInput.Focus()
-					return m, textinput.Blink
+			case "v":
+				if !m.list.SettingFilter() {
+					return m.viewNote(false)
 				}
-			}
-		}
 
-		m.textInput, cmd = m.textInput.Update(msg)
-		return m, cmd
+			case "o":
+				if !m.list.SettingFilter() {
+					return m.viewNote(true)
+				}
+
+			case "m":
+				if !m.list.SettingFilter() {
+					return m.openMove()
+				}
+
+			case "c":
+				if !m.list.SettingFilter() {
+					return m.startLabel()
+				}
+
+			case "s":
+				if !m.list.SettingFilter() {
+					return m.cycleSort()
+				}
+
+			case "z":
+				if !m.list.SettingFilter() {
+					return m.toggleDensity()
+				}
+
+			case "|":
+				if !m.list.SettingFilter() {
+					return m.toggleColumns()
+				}
+
+			case "w":
+				if !m.list.SettingFilter() {
+					return m.cycleGroup()
+				}
+
+			case "F":
+				if !m.list.SettingFilter() {
+					return m.toggleBrowse()
+				}
+
+			case "backspace":
+				if m.browsing && !m.list.SettingFilter() {
+					return m.browseUp()
+				}
+
+			case " ":
+				if m.group != "" && !m.list.SettingFilter() {
+					return m.toggleSection()
+				}
+
+			case "-", "+":
+				if m.group != "" && !m.list.SettingFilter() {
+					return m.setSections(keypress == "-")
+				}
+
+			case "r":
+				if !m.list.SettingFilter() {
+					return m.openRecent()
+				}
+
+			case "R":
+				if !m.list.SettingFilter() {
+					return m.openReview()
+				}
+
+			case "D":
+				if !m.list.SettingFilter() {
+					return m.openDedupe()
+				}
+
+			case "L":
+				if !m.list.SettingFilter() {
+					return m.openCheck()
+				}
+
+			case "ctrl+g":
+				return m.openGraph()
+
+			case "P":
+				if !m.list.SettingFilter() {
+					return m.openRelations()
+				}
+
+			case "B":
+				if !m.list.SettingFilter() {
+					return m.openBoard("")
+				}
+
+			case "C":
+				if !m.list.SettingFilter() {
+					return m.openCalendar()
+				}
+
+			case "S":
+				if !m.list.SettingFilter() {
+					return m.startSync()
+				}
+
+			case "X":
+				if !m.list.SettingFilter() {
+					return m.openConflictCopies()
+				}
+
+			case "H":
+				if !m.list.SettingFilter() {
+					return m.openVersions()
+				}
+
+			case "=":
+				if !m.list.SettingFilter() {
+					return m.markForDiff()
+				}
+
+			case "x":
+				if !m.list.SettingFilter() {
+					return m.toggleMark()
+				}
+
+			case "E":
+				if !m.list.SettingFilter() {
+					return m.openMarked()
+				}
+
+			case "ctrl+e":
+				if !m.list.SettingFilter() {
+					return m.openOpenWith()
+				}
+
+			case "M":
+				if !m.list.SettingFilter() {
+					return m.openNoteInfo()
+				}
+
+			case "U":
+				if !m.list.SettingFilter() {
+					return m.confirmShare()
+				}
+
+			case "y":
+				if !m.list.SettingFilter() {
+					return m.openCopyMenu()
+				}
+
+			case "Y":
+				if !m.list.SettingFilter() {
+					return m.copyFileURL()
+				}
+
+			case "O":
+				if !m.list.SettingFilter() {
+					return m.openFolder()
+				}
+
+			case "W":
+				if !m.list.SettingFilter() {
+					return m.openScanWarnings()
+				}
+
+			case ":":
+				if !m.list.SettingFilter() {
+					return m.openCommandLine()
+				}
+
+			case "u":
+				if !m.list.SettingFilter() {
+					return m.undoLast()
+				}
+
+			case "ctrl+p":
+				return m.openPalette()
+
+			case "i":
+				if !m.list.SettingFilter() {
+					return m.openEditor()
+				}
+
+			case "A":
+				if !m.list.SettingFilter() {
+					return m.startAppend()
+				}
+
+			case "I":
+				if !m.list.SettingFilter() {
+					return m.openSnippets()
+				}
+
+			case "T":
+				if !m.list.SettingFilter() {
+					return m.startTagEdit()
+				}
+
+			case "#":
+				if !m.list.SettingFilter() {
+					return m.openTagTree()
+				}
+
+			case "a":
+				if !m.list.SettingFilter() {
+					return m.openAssistantMenu()
+				}
+
+			case "J", "shift+down":
+				if (m.previewShown() || m.columnsShown()) && !m.list.SettingFilter() {
+					m.scrollPreview(1)
+					return m, nil
+				}
+
+			case "K", "shift+up":
+				if (m.previewShown() || m.columnsShown()) && !m.list.SettingFilter() {
+					m.scrollPreview(-1)
+					return m, nil
+				}
 
-	case modeTagInput:
-		switch msg := msg.(type) {
-		case tea.KeyMsg:
-			switch msg.String() {
 			case "esc":
-				// Return to filename input
-				m.mode = modeInput
-				m.textInput.Focus()
-				return m, nil
+				// Leave the saved search once the filter is cleared
+				if m.search.Name != "" && m.list.FilterState() == list.Unfiltered {
+					return m, m.clearSearch()
+				}
 
-			case "enter":
-				// Save the tags and exit
-				m.newNoteTags = m.tagInput.Value()
-				return m, tea.Quit
+			default:
+				if b, ok := userScript.binding(keypress); ok && !m.list.SettingFilter() {
+					return m.runBinding(b)
+				}
 			}
 		}
 
-		m.tagInput, cmd = m.tagInput.Update(msg)
+		filter := m.list.FilterValue()
+		m.list, cmd = m.list.Update(msg)
+		if _, ok := msg.(tea.KeyMsg); ok && m.list.SettingFilter() && m.list.FilterValue() != filter {
+			cmd = m.debounceFilter(cmd)
+		}
+		if _, ok := msg.(list.FilterMatchesMsg); ok {
+			cmd = tea.Batch(cmd, m.filterFailed())
+			if m.restoring != nil {
+				cmd = tea.Batch(cmd, m.finishRestore())
+			}
+		}
+		m.syncPreview()
 		return m, cmd
+
+	case modeInput, modeTagInput, modeTemplateInput, modeFolderInput:
+		return m.updateNewNote(msg)
+
+	case modeVaultSwitcher:
+		return m.updateVaultSwitcher(msg)
+
+	case modeSearches:
+		return m.updateSearches(msg)
+
+	case modeTasks:
+		return m.updateTasks(msg)
+
+	case modeMove:
+		return m.updateMove(msg)
+
+	case modeMoveFolder:
+		return m.updateMoveFolder(msg)
+
+	case modeLabelInput:
+		return m.updateLabel(msg)
+
+	case modeCollision:
+		return m.updateCollision(msg)
+
+	case modeRecent:
+		return m.updateRecent(msg)
+
+	case modeReview:
+		return m.updateReview(msg)
+
+	case modeDedupe:
+		return m.updateDedupe(msg)
+
+	case modeCheck:
+		return m.updateCheck(msg)
+
+	case modeGraph:
+		return m.updateGraph(msg)
+
+	case modeRelations:
+		return m.updateRelations(msg)
+
+	case modeBoard:
+		return m.updateBoard(msg)
+
+	case modeSnippets:
+		return m.updateSnippets(msg)
+
+	case modeCalendar:
+		return m.updateCalendar(msg)
+
+	case modeConflicts:
+		return m.updateConflicts(msg)
+
+	case modeConflictCopies:
+		return m.updateConflictCopies(msg)
+
+	case modeVersions:
+		return m.updateVersions(msg)
+
+	case modePager:
+		return m.updatePager(msg)
+
+	case modeShare:
+		return m.updateShare(msg)
+
+	case modeCopy:
+		return m.updateCopy(msg)
+
+	case modeOpenWith:
+		return m.updateOpenWith(msg)
+
+	case modePalette:
+		return m.updatePalette(msg)
+
+	case modeEditor:
+		return m.updateEditor(msg)
+
+	case modeAppend:
+		return m.updateAppend(msg)
+
+	case modeTagEdit:
+		return m.updateTagEdit(msg)
+
+	case modeTagTree:
+		return m.updateTagTree(msg)
+
+	case modeAssistantMenu:
+		return m.updateAssistantMenu(msg)
+
+	case modeAssistant:
+		return m.updateAssistant(msg)
+
+	case modeCommandLine:
+		return m.updateCommandLine(msg)
+
+	case modeLocked:
+		return m.updateLocked(msg)
 	}
 
 	return m, nil
 }
 
+// reloadNotes scans the notes directory again and refreshes the list
+func (m *model) reloadNotes() tea.Cmd {
+	files, warnings, err := scanNotes(m.notesDir)
+	if err != nil {
+		return m.setError("Cannot list notes: %v", err)
+	}
+
+	m.items = files
+	m.scanWarnings = warnings
+	return tea.Batch(m.setListItems(), m.reportScanWarnings(), m.syncFileTagsCmd(), readGitStatus(m.notesDir))
+}
+
 // expandTimestamp replaces %t in the filename with the current date in YYYY-MM-DD format
 func expandTimestamp(filename string) string {
 	if strings.Contains(filename, "%t") {
@@ -324,34 +1255,110 @@ func expandTimestamp(filename string) string {
 }
 
 func (m model) View() string {
-	if m.quitting {
-		return quitTextStyle.Render("Bye!")
-	}
+	defer trace.since("render", time.Now())
+	defer trace.firstRender()
 
-	if m.choice != "" && m.mode != modeTagInput && m.mode != modeInput {
+	if m.quitting {
+		// The popup closes without a word, its terminal going away
+		if m.popup {
+			return ""
+		}
 		return quitTextStyle.Render("Bye!")
 	}
 
 	switch m.mode {
 	case modeList:
-		return m.list.View()
-	case modeInput:
-		return fmt.Sprintf(
-			"\n\n  %s\n\n  %s\n\n",
-			"Enter the filename for your new note (use %t for today's date):",
-			m.textInput.View(),
-		) + "  (press ESC to cancel)"
-	case modeTagInput:
-		return fmt.Sprintf(
-			"\n\n  %s\n\n  %s\n\n",
-			"Enter tags for your note (e.g. work important todo):",
-			m.tagInput.View(),
-		) + "  (press ESC to go back to filename)"
+		return m.withStatus(m.listView())
+	case modeInput, modeTagInput, modeTemplateInput, modeFolderInput:
+		return m.withStatus(m.viewNewNote())
+	case modeVaultSwitcher:
+		return m.vaultMenu.view(m.width, m.height)
+	case modeSearches:
+		return m.searchMenu.view(m.width, m.height)
+	case modeTasks:
+		return m.withStatus(m.viewTasks())
+	case modeMove:
+		return m.moveMenu.view(m.width, m.height)
+	case modeMoveFolder:
+		return m.withStatus(m.viewMoveFolder())
+	case modeLabelInput:
+		return m.withStatus(m.viewLabel())
+	case modeCollision:
+		return m.collisionMenu.view(m.width, m.height)
+	case modeRecent:
+		return m.recentMenu.view(m.width, m.height)
+	case modeReview:
+		return m.withStatus(m.viewReview())
+	case modeDedupe:
+		return m.withStatus(m.viewDedupe())
+	case modeCheck:
+		return m.withStatus(m.viewCheck())
+	case modeGraph:
+		return m.withStatus(m.viewGraph())
+	case modeRelations:
+		return m.withStatus(m.viewRelations())
+	case modeBoard:
+		return m.withStatus(m.viewBoard())
+	case modeSnippets:
+		return m.snippetMenu.view(m.width, m.height)
+	case modeCalendar:
+		return m.withStatus(m.viewCalendar())
+	case modeConflicts:
+		return m.conflictsMenu.view(m.width, m.height)
+	case modeConflictCopies:
+		return m.withStatus(m.viewConflictCopies())
+	case modeVersions:
+		return m.withStatus(m.viewVersions())
+	case modePager:
+		return m.withStatus(m.viewPager())
+	case modeShare:
+		return m.shareMenu.view(m.width, m.height)
+	case modeCopy:
+		return m.copyMenu.view(m.width, m.height)
+	case modeOpenWith:
+		return m.openWithMenu.view(m.width, m.height)
+	case modePalette:
+		return m.palette.view(m.width, m.height)
+	case modeEditor:
+		return m.withStatus(m.viewEditor())
+	case modeAppend:
+		return m.withStatus(m.viewAppend())
+	case modeTagEdit:
+		return m.withStatus(m.viewTagEdit())
+	case modeTagTree:
+		return m.withStatus(m.viewTagTree())
+	case modeAssistantMenu:
+		return m.assistantMenu.view(m.width, m.height)
+	case modeAssistant:
+		return m.withStatus(m.viewAssistant())
+	case modeCommandLine:
+		return m.viewCommandLine()
+	case modeLocked:
+		return m.lockMenu.view(m.width, m.height)
 	}
 
 	return ""
 }
 
+// listView is the list of notes, with the preview of the selected one when
+// shown
+func (m model) listView() string {
+	if m.columnsShown() {
+		return m.viewColumns()
+	}
+	if m.previewShown() {
+		return lipgloss.JoinHorizontal(lipgloss.Top,
+			m.listPane(), m.previewView(m.width-m.list.Width(), m.height-1))
+	}
+	return m.list.View()
+}
+
+// listPane is the list next to other panes, its long lines cut so that they
+// don't push the panes on their right
+func (m model) listPane() string {
+	return lipgloss.NewStyle().MaxWidth(m.list.Width()).Render(m.list.View())
+}
+
 func formatTagsWithPlus(tags string) string {
 	words := strings.Fields(tags)
 	tagWords := make([]string, 0)
@@ -365,7 +1372,7 @@ func formatTagsWithPlus(tags string) string {
 		}
 	}
 
-	return strings.Join(tagWords, " ")
+	return normalizeTags(strings.Join(tagWords, " "))
 }
 
 // Capitalize first letter of a string
@@ -378,84 +1385,221 @@ func capitalizeFirstLetter(s string) string {
 	return string(r)
 }
 
-// Expand ~ to home directory
+// Expand ~ to home directory, ~\ too on Windows
 func expandTilde(path string) string {
-	if strings.HasPrefix(path, "~/") {
+	if path == "~" || strings.HasPrefix(path, "~/") || (runtime.GOOS == "windows" && strings.HasPrefix(path, `~\`)) {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
 			return path // Return original if we can't expand
 		}
-		return filepath.Join(homeDir, path[2:])
+		return filepath.Join(homeDir, path[1:])
 	}
 	return path
 }
 
-func openInEditor(fullPath string, tags string) error {
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		return fmt.Errorf("EDITOR environment variable not set")
+// defaultNotesDir returns where the notes are stored without vaults in the
+// config: ~/notes, or the notes folder of the documents on Windows
+func defaultNotesDir() string {
+	if runtime.GOOS == "windows" {
+		if profile := os.Getenv("USERPROFILE"); profile != "" {
+			return filepath.Join(profile, "Documents", "notes")
+		}
 	}
+	return expandTilde("~/notes/")
+}
+
+// createNote writes the header of a new note: its tags, then the template
+// body or a title heading when there is no template
+func createNote(fullPath string, tags string, templateBody string) error {
+	return createTitledNote(fullPath, noteTitle(fullPath), tags, templateBody)
+}
 
-	// Save the current working directory to return to it later
-	originalDir, err := os.Getwd()
+// createTitledNote creates a note like createNote, with a given title rather
+// than one derived from its filename
+func createTitledNote(fullPath, title, tags, templateBody string) error {
+	// Create the folder of the note if needed
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create folder: %v", err)
+	}
+
+	file, err := os.Create(fullPath)
 	if err != nil {
-		return fmt.Errorf("failed to get current directory: %v", err)
+		return fmt.Errorf("failed to create file: %v", err)
 	}
+	defer file.Close()
 
-	// Extract the directory path from the full file path
-	noteDir := filepath.Dir(fullPath)
+	// Org-mode notes start with keywords, the template following them
+	if strings.ToLower(filepath.Ext(fullPath)) == ".org" {
+		file.WriteString(orgHeader(title, formatTagsWithPlus(tags)))
+		file.WriteString(templateBody)
+		return nil
+	}
 
-	// Change to the notes directory before opening the editor
-	if err := os.Chdir(noteDir); err != nil {
-		return fmt.Errorf("failed to change directory to notes folder: %v", err)
+	// If tags were provided, write them as the first line
+	if tags != "" {
+		// Format tags with + for each word
+		formattedTags := formatTagsWithPlus(tags)
+		file.WriteString("// " + formattedTags + "\n")
 	}
 
-	// Use defer to ensure we change back to original directory even if there's an error
-	defer os.Chdir(originalDir)
+	if templateBody != "" {
+		file.WriteString(templateBody)
+	} else {
+		// Add the title as a markdown heading
+		file.WriteString("# " + title + "\n\n")
+	}
 
-	// Get just the filename without the directory path
-	filename := filepath.Base(fullPath)
+	return nil
+}
+
+// noteTitle derives a title from the filename of a note
+func noteTitle(fullPath string) string {
+	// Extract the title from filename (without extension)
+	title := trimNoteExt(filepath.Base(fullPath))
+	// Capitalize the first letter of the title
+	return capitalizeFirstLetter(title)
+}
+
+// editorFinishedMsg is sent when the editor opened on a note exits
+type editorFinishedMsg struct {
+	filename string
+	// changed is set when the note was saved
+	changed bool
+	err     error
+}
+
+// openNote suspends the program to edit a note, given relative to the notes
+// directory, in $EDITOR
+func (m *model) openNote(filename string) tea.Cmd {
+	return m.openNoteAt(filename, 0)
+}
 
-	// Check if file exists before trying to initialize it
-	fileExists := false
-	_, err = os.Stat(filename)
-	if err == nil {
-		fileExists = true
+// openNoteAt opens a note in $EDITOR with the cursor on a line, starting at
+// 1, or where the editor chooses when 0, asking first when another process
+// is editing it
+func (m *model) openNoteAt(filename string, line int) tea.Cmd {
+	if m.popup {
+		return m.pickNote(filename)
 	}
+	if lock, ok := heldLock(m.notesDir, filename); ok {
+		m.warnLocked(lockedOpen{filename: filename, line: line}, lock)
+		return nil
+	}
+	return m.editInEditor(filename, line)
+}
 
-	// Only initialize the file if it's new
-	if !fileExists {
-		// Create a new file
-		file, err := os.Create(filename)
-		if err != nil {
-			return fmt.Errorf("failed to create file: %v", err)
-		}
+// editorFinished comes back to the list once the editor exits, the notes
+// read again for the changes and the note edited selected
+func (m model) editorFinished(msg editorFinishedMsg) (tea.Model, tea.Cmd) {
+	m.mode = modeList
+	cmd := m.reloadNotes()
+	m.selectNote(msg.filename)
+	if msg.changed {
+		done := "Saved " + msg.filename
+		return m, tea.Batch(cmd, m.setInfo("%s", done), m.noteSavedCmd("post_edit", msg.filename, done))
+	}
+	return m, cmd
+}
+
+// editInEditor opens a note in $EDITOR, locking it until the editor exits
+func (m *model) editInEditor(filename string, line int) tea.Cmd {
+	cmd, err := editorCmd(filepath.Join(m.notesDir, filename), line)
+	if err != nil {
+		return m.setError("Cannot edit %s: %v", filename, err)
+	}
+	lockNote(m.notesDir, filename)
+	// Not remembering the opening or saving a version doesn't prevent
+	// editing
+	m.history.record(m.notesDir, filename, time.Now())
+	saveVersion(m.notesDir, filename, time.Now())
+
+	path := filepath.Join(m.notesDir, filename)
+	before, _ := os.Stat(path)
+	notesDir := m.notesDir
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		unlockNote(notesDir, filename)
+		after, statErr := os.Stat(path)
+		changed := statErr == nil && (before == nil || !after.ModTime().Equal(before.ModTime()))
+		return editorFinishedMsg{filename: filename, changed: changed, err: err}
+	})
+}
 
-		// Extract the title from filename (without extension)
-		title := strings.TrimSuffix(filename, ".md")
-		// Capitalize the first letter of the title
-		title = capitalizeFirstLetter(title)
+// editorCmd builds the command opening a note in $EDITOR, on a given line
+// with the +N argument most editors understand. $EDITOR may hold arguments,
+// like "code --wait", and defaults to notepad on Windows.
+func editorCmd(fullPath string, line int) (*exec.Cmd, error) {
+	editor, err := editorCommand()
+	if err != nil {
+		return nil, err
+	}
 
-		// If tags were provided, write them as the first line
-		if tags != "" {
-			// Format tags with + for each word
-			formattedTags := formatTagsWithPlus(tags)
-			file.WriteString("// " + formattedTags + "\n")
+	// Open the file from its folder (using just the filename since we're already in the right directory)
+	file := filepath.Base(fullPath)
+	args := []string{file}
+	switch strings.TrimSuffix(strings.ToLower(filepath.Base(editor[0])), ".exe") {
+	case "notepad":
+		// Notepad would open +N as a file
+	case "code", "code-insiders", "codium":
+		if line > 0 {
+			args = []string{"--goto", fmt.Sprintf("%s:%d", file, line)}
 		}
+	default:
+		if line > 0 {
+			args = []string{fmt.Sprintf("+%d", line), file}
+		}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:len(editor):len(editor)], args...)...)
+	cmd.Dir = filepath.Dir(fullPath)
+	debugLog.Info("editor", "path", cmd.Path, "args", cmd.Args, "dir", cmd.Dir, "lookup_err", cmd.Err)
 
-		// Add the title as a markdown heading
-		file.WriteString("# " + title + "\n\n")
+	return cmd, nil
+}
 
-		file.Close()
+// editorCommand returns $EDITOR split in its arguments, notepad on Windows
+// when unset
+func editorCommand() ([]string, error) {
+	editor := splitCommandLine(os.Getenv("EDITOR"))
+	if len(editor) == 0 && runtime.GOOS == "windows" {
+		editor = []string{"notepad"}
 	}
+	if len(editor) == 0 {
+		debugLog.Error("editor", "err", "EDITOR environment variable not set")
+		return nil, fmt.Errorf("EDITOR environment variable not set")
+	}
+	return editor, nil
+}
 
-	// Open the file in the editor (using just the filename since we're already in the right directory)
-	cmd := exec.Command(editor, filename)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
+// splitCommandLine splits a command line into its arguments, double quotes
+// grouping words like the path of "C:\Program Files\Vim\gvim.exe". Single
+// quotes group them too outside of Windows, where they may end a name.
+func splitCommandLine(line string) []string {
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || (r == '\'' && runtime.GOOS != "windows"):
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
 }
 
 // askForConfirmation asks the user for confirmation with y/n
@@ -482,151 +1626,496 @@ func askForConfirmation(prompt string) bool {
 }
 
 func main() {
-	// Expand the path to the notes directory
-	notesDir := expandTilde("~/notes/")
+	traceStartup := flag.Bool("trace-startup", false, "print how long each phase of the startup takes")
+	timings := flag.Bool("timings", false, "print how long the startup, the scans, the index, the filtering and the rendering take")
+	profile := flag.String("profile", "", "write a cpu, mem or trace profile of the run to the current directory")
+	debug := flag.Bool("debug", false, "log the keys, the scans, the editor commands and the errors to debug.log")
+	format := flag.String("format", "", "format of the new notes: md or org")
+	popup := flag.Bool("popup", false, "pick a note and print its path, for a tmux or zellij popup")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	if *traceStartup || *timings {
+		trace.begin()
+		trace.timings = *timings
+		defer trace.report(os.Stderr)
+	}
+	stopDebugLog := func() {}
+	if *debug || debugEnabled() {
+		stop, err := startDebugLog()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		stopDebugLog = stop
+		defer stop()
+	}
+	stopProfile := func() {}
+	if *profile != "" {
+		stop, err := startProfile(*profile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		stopProfile = stop
+		defer stop()
+	}
+	saveTerminal()
+	defer recoverCrash()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if *format != "" {
+		cfg.NewNote.Format = *format
+		if err := cfg.validate(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	setNoteExtensions(cfg.Extensions)
+	headingTitles = cfg.HeadingTitles
+	noteHooks = cfg.Hooks
+	setTagRules(cfg.TagRules)
+	listLayout = mustItemLayout(cfg.List)
+	staleAge, _ = parseStaleAfter(cfg.StaleAfter)
+	semanticSearch = cfg.Embeddings
+	userScript, err = loadScript(scriptPath())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	trace.mark("config load")
+
+	// Run a subcommand instead of the picker
+	if flag.NArg() > 0 {
+		code := runCommand(cfg, flag.Arg(0), flag.Args()[1:])
+		stopDebugLog()
+		stopProfile()
+		trace.report(os.Stderr)
+		os.Exit(code)
+	}
+
+	// The popup is drawn on the terminal even when stdout is read by a
+	// script, before the theme detects its background
+	var popupOpts []tea.ProgramOption
+	if *popup {
+		opts, closeTerminal, err := popupOptions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer closeTerminal()
+		popupOpts = opts
+	}
+
+	t, err := resolveTheme(cfg)
+	if err != nil {
+		fmt.Printf("Error loading theme: %v\n", err)
+		os.Exit(1)
+	}
+	applyTheme(t)
+	trace.mark("theme")
+
+	// Open the last used vault, or the default notes directory
+	vaultName, notesDir, err := resolveVault(cfg, loadState())
+	if err != nil {
+		fmt.Printf("Error opening vault: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Check if the notes directory exists
-	_, err := os.Stat(notesDir)
-	if os.IsNotExist(err) {
+	// Decrypt the notes of an encrypted vault in memory, or check if the
+	// notes directory exists
+	if v, ok := cfg.vault(vaultName); ok && v.Encrypted {
+		unlocked, err := unlockVault(v.Name, notesDir)
+		if err != nil {
+			fmt.Printf("Error opening vault: %v\n", err)
+			exit(1)
+		}
+		unlockedVault = unlocked
+		notesDir = unlocked.dir
+	} else if _, err = os.Stat(notesDir); os.IsNotExist(err) {
 		// Directory doesn't exist, ask user if they want to create it
 		if askForConfirmation(fmt.Sprintf("Directory %s doesn't exist. Create it?", notesDir)) {
 			// Create the directory if user confirms
 			if err := os.MkdirAll(notesDir, 0755); err != nil {
 				fmt.Printf("Error creating notes directory: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		} else {
 			fmt.Println("Cannot continue without notes directory. Exiting.")
-			os.Exit(0)
+			exit(0)
 		}
 	} else if err != nil {
 		fmt.Printf("Error checking notes directory: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
+	trace.mark("vault")
 
-	files, err := findMarkdownFiles(notesDir)
+	files, warnings, err := scanNotes(notesDir)
 	if err != nil {
 		fmt.Printf("Error finding markdown files: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
+	trace.mark("scan")
 
-	var m model
-
-	if len(files) == 0 {
-		// No markdown files found - go directly to note creation mode
-		fmt.Println("No notes found. Starting new note creation...")
-		m = initialNewNoteModel(notesDir)
-	} else {
-		// We have notes, set up the regular list UI
-		items := make([]list.Item, len(files))
-		for i, fileInfo := range files {
-			items[i] = fileInfo
-		}
-
-		delegate := NewCustomDelegate()
-		l := list.New(items, delegate, 0, 0)
-		l.Title = fmt.Sprintf("Notes at %s", notesDir)
-		l.Styles.Title = titleStyle
-		l.Styles.PaginationStyle = paginationStyle
-		l.Styles.HelpStyle = helpStyle
-
-		// Change "item/items" to "note/notes" in status messages
-		l.SetStatusBarItemName("note", "notes")
-
-		// Add additional key bindings to the help menu
-		l.AdditionalFullHelpKeys = func() []key.Binding {
-			return []key.Binding{
-				customListKeys.createNote,
-			}
+	// The notes moved by the tidy rules are listed in their new folder
+	var tidied []tidyMoved
+	if !*popup {
+		tidied = tidyOnStartup(cfg, notesDir, files)
+	}
+	if len(tidied) > 0 {
+		files, warnings, err = scanNotes(notesDir)
+		if err != nil {
+			fmt.Printf("Error finding markdown files: %v\n", err)
+			exit(1)
 		}
+	}
 
-		// Add additional active key bindings
-		l.AdditionalShortHelpKeys = func() []key.Binding {
-			return []key.Binding{
-				customListKeys.createNote,
-			}
+	// Set up the regular list UI
+	items := make([]list.Item, len(files))
+	for i, fileInfo := range files {
+		items[i] = fileInfo
+	}
+
+	delegate := NewCustomDelegate()
+	l := list.New(items, delegate, 0, 0)
+	l.Styles.Title = titleStyle
+	l.Styles.PaginationStyle = paginationStyle
+	l.Styles.HelpStyle = helpStyle
+
+	// Change "item/items" to "note/notes" in status messages
+	l.SetStatusBarItemName("note", "notes")
+
+	// ? lists every key rather than the footer
+	l.KeyMap.ShowFullHelp.SetHelp("?", "all keys")
+	// u undoes rather than going to the previous page
+	l.KeyMap.PrevPage.SetKeys("left", "h", "pgup", "b")
+
+	// Add additional active key bindings
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			customListKeys.createNote,
 		}
+	}
 
-		// Enable filter mode on startup
-		l.ShowFilter()
-		l.FilterInput.Focus() // Give focus to the filter input
+	// Enable filter mode on startup
+	l.ShowFilter()
+	l.FilterInput.Focus() // Give focus to the filter input
+
+	m := initialModel(notesDir, cfg)
+	m.vaultName = vaultName
+	m.list = l
+	m.list.Title = m.listTitle()
+	// Filter with the query language, or fuzzy when it isn't used
+	m.list.Filter = queryFilter(m.shown)
+	m.items = files
+	m.scanWarnings = warnings
+	m.shown.set(files)
+	m.preview = cfg.Preview
+	m.columns = columnsView{shown: cfg.Columns.Show, focus: paneNotes, folders: cfg.Columns.Folders, notes: cfg.Columns.Notes}
+	m.history = loadHistory()
+	m.sortMode = cfg.Sort
+	m.imageProtocol = detectImageProtocol(cfg.ImagePreview)
+	m.startupCmd = tea.Batch(m.setListItems(), m.reportScanWarnings(), m.syncFileTagsCmd(), readGitStatus(notesDir))
+	if len(tidied) > 0 {
+		m.pushUndo("tidying "+pluralNotes(len(tidied)), undoTidy(notesDir, tidied))
+	}
+	// The popup starts on a fresh filter rather than the last session
+	if *popup {
+		m.setupPopup()
+	} else if s, ok := loadState().Sessions[notesDir]; ok && len(files) > 0 {
+		m.startupCmd = tea.Batch(m.startupCmd, m.restoreSession(s))
+	}
 
-		m = initialModel(notesDir)
-		m.list = l
-		m.items = files
+	if len(files) == 0 {
+		// No markdown files found - go directly to note creation mode
+		fmt.Fprintln(os.Stderr, "No notes found. Starting new note creation...")
+		m, m.startupCmd = m.startNewNote()
 	}
 
+	trace.mark("list setup")
+
 	// Use WithAltScreen to use the full terminal space
-	p := tea.NewProgram(m, tea.WithAltScreen())
-	finalModel, err := p.Run()
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if cfg.Mouse {
+		options = append(options, tea.WithMouseCellMotion())
+	}
+	options = append(options, popupOpts...)
+
+	final, err := runProgram(m, options...)
 	if err != nil {
 		fmt.Printf("Error running program: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
-
-	// Get the final model state
-	if m, ok := finalModel.(model); ok && m.choice != "" {
-		// Create full file path in the notes directory
-		fullPath := filepath.Join(m.notesDir, m.choice)
-
-		// We need to wait until the program has completely exited before running the editor
-		if err := openInEditor(fullPath, m.newNoteTags); err != nil {
-			fmt.Printf("Error opening file in editor: %v\n", err)
-			os.Exit(1)
-		}
+	if *popup {
+		code := finishPopup(final.(model))
+		sealUnlockedVault()
+		stopDebugLog()
+		stopProfile()
+		trace.report(os.Stderr)
+		exit(code)
+	}
+	if err := final.(model).saveSession(); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot save the session: %v\n", err)
 	}
+	sealUnlockedVault()
 }
 
+// tagRegex matches the tags of a tag line: +word, nested like +work/projects
+// or quoted like +"machine learning"
+var tagRegex = regexp.MustCompile(`\+(?:"([^"]+)"|\w+(?:/\w+)*)`)
+
 // Extract tags that start with "+" from a string
 func extractTags(line string) string {
 	var tags []string
-	tagRegex := regexp.MustCompile(`\+\w+`)
-
-	matches := tagRegex.FindAllString(line, -1)
-	if matches != nil {
-		tags = matches
+	for _, match := range tagRegex.FindAllStringSubmatch(line, -1) {
+		if match[1] != "" {
+			// Tags are separated by spaces, a quoted tag keeps its words
+			// joined by _
+			if name := normalizeTag(match[1]); name != "" {
+				tags = append(tags, "+"+name)
+			}
+			continue
+		}
+		tags = append(tags, match[0])
 	}
 
-	return strings.Join(tags, " ")
+	return normalizeTags(strings.Join(tags, " "))
 }
 
-// findMarkdownFiles returns a list of all .md files in the specified directory
-// along with tags extracted from their first line
+// findMarkdownFiles returns a list of all notes in the specified directory
+// and its subfolders along with tags extracted from their header
 func findMarkdownFiles(dir string) ([]noteItem, error) {
+	files, _, err := scanNotes(dir)
+	return files, err
+}
+
+// scanNotes lists the notes like findMarkdownFiles, also returning the
+// problems of the files: the unreadable ones, the ones that aren't UTF-8 text
+// and the binary or oversized ones, which are skipped
+func scanNotes(dir string) ([]noteItem, []scanWarning, error) {
+	defer trace.since("scan", time.Now())
 	var files []noteItem
+	var warnings []scanWarning
+	folders := newFolderConfigs(dir)
 
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
+	headers.mu.Lock()
+	defer headers.mu.Unlock()
+	indexStart := time.Now()
+	headers.load()
+	indexTime := time.Since(indexStart)
+
+	skipped := func(filename string, err error) {
+		warnings = append(warnings, scanWarning{filename: filename, problem: describeError(err).Error()})
 	}
+	notUTF8 := fmt.Errorf("not UTF-8 text, shown with replacement characters")
+
+	// The notes that changed since they were cached are read afterwards,
+	// several at once
+	var stale []int
+	infos := map[int]fs.FileInfo{}
+	seen := map[string]bool{}
+	err := walkNotes(dir, func(path, filename string, entry fs.DirEntry) error {
+		item := noteItem{path: path, filename: filename, folderTags: folders.tags(filename)}
+		info, err := entry.Info()
+		if err != nil {
+			stale = append(stale, len(files))
+			files = append(files, item)
+			return nil
+		}
+		if info.Size() > maxNoteSize {
+			skipped(filename, fmt.Errorf("larger than %d MB, skipped", maxNoteSize>>20))
+			return nil
+		}
 
-	for _, entry := range entries {
-		// Skip hidden files (dot files) and directories
-		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && strings.HasSuffix(strings.ToLower(entry.Name()), ".md") {
-			filename := entry.Name()
-			tags := ""
-
-			// Open file and read first line to extract tags
-			filePath := filepath.Join(dir, filename)
-			file, err := os.Open(filePath)
-			if err == nil {
-				scanner := bufio.NewScanner(file)
-				if scanner.Scan() {
-					firstLine := scanner.Text()
-					// If the first line starts with //, extract tags
-					if strings.HasPrefix(firstLine, "//") {
-						tags = extractTags(firstLine)
-					}
-				}
-				file.Close()
+		item.modTime = info.ModTime()
+		seen[absPath(path)] = true
+		if h, ok := headers.lookup(absPath(path), info); ok {
+			item.tags, item.title, item.heading, item.label, item.due = h.Tags, h.Title, h.Heading, h.Label, h.Due.Local()
+			item.date = h.Date.Local()
+			item.parent, item.related, item.aliases = h.Parent, h.Related, h.Aliases
+			if h.NotUTF8 {
+				skipped(filename, notUTF8)
 			}
+			// Tagged sensitive through the .snsm.yaml of its folder since
+			if !item.cacheable() {
+				headers.forget(absPath(path))
+			}
+		} else {
+			infos[len(files)] = info
+			stale = append(stale, len(files))
+		}
+		files = append(files, item)
+		return nil
+	}, skipped)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			files = append(files, noteItem{
-				filename: filename,
-				tags:     tags,
+	start := time.Now()
+	results := readHeaders(files, stale)
+	trace.add("tag parse", time.Since(start))
+
+	binary := map[int]bool{}
+	for i, index := range stale {
+		item, r := &files[index], results[i]
+		switch {
+		case errors.Is(r.err, errBinaryNote):
+			skipped(item.filename, fmt.Errorf("binary file, skipped"))
+			binary[index] = true
+			continue
+		case r.err != nil:
+			skipped(item.filename, r.err)
+		case !r.text:
+			skipped(item.filename, notUTF8)
+		}
+
+		item.tags, item.title, item.heading, item.label = r.tags, r.meta.Title, r.meta.Heading, r.meta.Label
+		item.due = nextDue(r.dueItems)
+		item.date = parseNoteDate(r.meta.Date)
+		item.parent, item.related, item.aliases = string(r.meta.Parent), r.meta.Related, r.meta.Aliases
+		item.readErr = r.err
+		if !item.cacheable() {
+			headers.forget(absPath(item.path))
+		} else if info, ok := infos[index]; ok && r.err == nil {
+			headers.store(absPath(item.path), cachedHeader{
+				ModTime: info.ModTime(),
+				Size:    info.Size(),
+				Tags:    item.tags,
+				Title:   item.title,
+				Label:   item.label,
+				Date:    item.date,
+				Parent:  item.parent,
+				Related: item.related,
+				Aliases: item.aliases,
+				Heading: item.heading,
+				Due:     item.due,
+				NotUTF8: !r.text,
 			})
 		}
 	}
+	if len(binary) > 0 {
+		kept := files[:0]
+		for i, item := range files {
+			if !binary[i] {
+				kept = append(kept, item)
+			}
+		}
+		files = kept
+	}
 
-	return files, nil
+	// Not caching the headers only slows down the next scan
+	indexStart = time.Now()
+	headers.prune(dir, seen)
+	headers.save()
+	trace.record("index", indexTime+time.Since(indexStart))
+
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].filename < warnings[j].filename })
+	debugLog.Info("scan", "dir", dir, "notes", len(files), "read", len(stale), "warnings", len(warnings))
+	for _, w := range warnings {
+		debugLog.Warn("scan warning", "file", w.filename, "problem", w.problem)
+	}
+	return files, warnings, nil
+}
+
+// headerResult is the header of a note read by readHeaders
+type headerResult struct {
+	tags     string
+	meta     noteMeta
+	dueItems []dueItem
+	text     bool
+	err      error
+}
+
+// headerReaders is the number of notes read at once by a scan
+const headerReaders = 8
+
+// readHeaders reads the headers of the notes at the given indexes, several
+// at once
+func readHeaders(files []noteItem, indexes []int) []headerResult {
+	results := make([]headerResult, len(indexes))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(headerReaders, len(indexes)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				item := files[indexes[i]]
+				r := &results[i]
+				r.tags, r.meta, r.text, r.err = readNoteHeader(item.path)
+				if r.err == nil {
+					r.dueItems, r.err = readDueItems(item.path, item.filename, r.meta.Due)
+				}
+			}
+		}()
+	}
+	for i := range indexes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// walkNotes calls fn for every note of the directory and its subfolders with
+// the note path and its filename relative to the directory. The subfolders
+// that can't be read are skipped, reported to skipped when not nil.
+func walkNotes(dir string, fn func(path, filename string, entry fs.DirEntry) error, skipped func(filename string, err error)) error {
+	return filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		// Skip hidden files (dot files) and folders, like the templates
+		if entry != nil && strings.HasPrefix(entry.Name(), ".") && path != dir {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if err != nil {
+			// Only the notes directory itself has to be readable
+			if path == dir || entry == nil {
+				return err
+			}
+			if filename, relErr := filepath.Rel(dir, path); relErr == nil && skipped != nil {
+				skipped(filename, err)
+			}
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if entry.IsDir() || !isNoteFile(entry.Name()) {
+			return nil
+		}
+
+		// Notes in subfolders are listed by their path relative to the notes directory
+		filename, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		return fn(path, filename, entry)
+	})
+}
+
+// lineEnding returns the end of the lines of a note from its first line, CRLF
+// for the notes saved on Windows
+func lineEnding(firstLine string) string {
+	if strings.HasSuffix(firstLine, "\r") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// splitTagLine separates the tag line of a note, if any, from its body
+func splitTagLine(content string) (tags string, body string) {
+	firstLine, rest, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(firstLine, "//") {
+		return "", content
+	}
+	return extractTags(firstLine), rest
 }
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A small markdown parser covering what notes commonly use: headings,
+// paragraphs, fenced code, quotes, (task) lists, tables, rules, and the inline
+// emphasis, code, links, images and [[wikilinks]]. The parsed blocks are
+// rendered to HTML by htmlRenderer.
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockCode
+	blockQuote
+	blockList
+	blockListItem
+	blockRule
+	blockTable
+)
+
+// mdBlock is a block of a markdown document
+type mdBlock struct {
+	kind blockKind
+	// line is the line of the block in the source, starting at 0
+	line int
+	// level of a heading
+	level int
+	// text holds the inline markdown of paragraphs and headings, and the
+	// content of code blocks
+	text string
+	// lang of a fenced code block
+	lang string
+	// ordered lists start at start
+	ordered bool
+	start   int
+	// task is set for list items starting with [ ] or [x]
+	task    bool
+	checked bool
+	// rows of a table, the first one being the header
+	rows     [][]string
+	children []mdBlock
+}
+
+var (
+	headingRegex    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	fenceRegex      = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([^ \t`]*)")
+	ruleRegex       = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	quoteRegex      = regexp.MustCompile(`^ {0,3}> ?`)
+	listItemRegex   = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])([ \t]+|$)`)
+	taskRegex       = regexp.MustCompile(`^\[([ xX])\][ \t]+`)
+	tableSeparator  = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	nonAlphanumeric = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+)
+
+// parseMarkdown splits a markdown document in blocks
+func parseMarkdown(src string) []mdBlock {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	return parseBlocks(strings.Split(src, "\n"), 0)
+}
+
+// parseBlocks parses lines starting at line offset of the source
+func parseBlocks(lines []string, offset int) []mdBlock {
+	var blocks []mdBlock
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fenceRegex.MatchString(line):
+			match := fenceRegex.FindStringSubmatch(line)
+			fence := match[1]
+			block := mdBlock{kind: blockCode, line: offset + i, lang: match[2]}
+
+			var code []string
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					i++
+					break
+				}
+				code = append(code, lines[i])
+			}
+			block.text = strings.Join(code, "\n")
+			blocks = append(blocks, block)
+
+		case headingRegex.MatchString(line):
+			match := headingRegex.FindStringSubmatch(line)
+			blocks = append(blocks, mdBlock{
+				kind:  blockHeading,
+				line:  offset + i,
+				level: len(match[1]),
+				text:  strings.TrimSpace(match[2]),
+			})
+			i++
+
+		case ruleRegex.MatchString(line):
+			blocks = append(blocks, mdBlock{kind: blockRule, line: offset + i})
+			i++
+
+		case quoteRegex.MatchString(line):
+			start := i
+			var quoted []string
+			for ; i < len(lines) && quoteRegex.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quoteRegex.ReplaceAllString(lines[i], ""))
+			}
+			blocks = append(blocks, mdBlock{
+				kind:     blockQuote,
+				line:     offset + start,
+				children: parseBlocks(quoted, offset+start),
+			})
+
+		case listItemRegex.MatchString(line):
+			var block mdBlock
+			block, i = parseList(lines, i, offset)
+			blocks = append(blocks, block)
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableSeparator.MatchString(lines[i+1]):
+			block := mdBlock{kind: blockTable, line: offset + i}
+			block.rows = append(block.rows, splitTableRow(line))
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+				block.rows = append(block.rows, splitTableRow(lines[i]))
+			}
+			blocks = append(blocks, block)
+
+		default:
+			start := i
+			var paragraph []string
+			for ; i < len(lines) && !interruptsParagraph(lines[i]); i++ {
+				paragraph = append(paragraph, strings.TrimSpace(lines[i]))
+			}
+			blocks = append(blocks, mdBlock{
+				kind: blockParagraph,
+				line: offset + start,
+				text: strings.Join(paragraph, "\n"),
+			})
+		}
+	}
+
+	return blocks
+}
+
+// interruptsParagraph reports whether a line ends the current paragraph
+func interruptsParagraph(line string) bool {
+	return strings.TrimSpace(line) == "" ||
+		fenceRegex.MatchString(line) ||
+		headingRegex.MatchString(line) ||
+		ruleRegex.MatchString(line) ||
+		quoteRegex.MatchString(line) ||
+		listItemRegex.MatchString(line)
+}
+
+// parseList parses the list starting at line i and returns the index of the
+// first line after it
+func parseList(lines []string, i int, offset int) (mdBlock, int) {
+	first := listItemRegex.FindStringSubmatch(lines[i])
+	list := mdBlock{kind: blockList, line: offset + i}
+	if marker := first[2]; marker[0] >= '0' && marker[0] <= '9' {
+		list.ordered = true
+		list.start, _ = strconv.Atoi(marker[:len(marker)-1])
+	}
+
+	for i < len(lines) {
+		match := listItemRegex.FindStringSubmatch(lines[i])
+		if match == nil || isOrderedMarker(match[2]) != list.ordered {
+			break
+		}
+
+		// The content of the item is indented at least as much as its first line
+		indent := len(match[0])
+		if strings.TrimSpace(match[3]) == "" && len(match[3]) > 4 {
+			indent = len(match[1]) + len(match[2]) + 1
+		}
+
+		start := i
+		content := []string{lines[i][len(match[0]):]}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				// A blank line only continues the item if indented content follows
+				if i+1 < len(lines) && leadingSpaces(lines[i+1]) >= indent {
+					content = append(content, "")
+					continue
+				}
+				break
+			}
+			if leadingSpaces(line) >= indent {
+				content = append(content, dedent(line, indent))
+				continue
+			}
+			// Lazy continuation of the item paragraph
+			if !interruptsParagraph(line) && strings.TrimSpace(content[len(content)-1]) != "" {
+				content = append(content, strings.TrimSpace(line))
+				continue
+			}
+			break
+		}
+
+		item := mdBlock{kind: blockListItem, line: offset + start}
+		if task := taskRegex.FindStringSubmatch(content[0]); task != nil {
+			item.task = true
+			item.checked = task[1] != " "
+			content[0] = content[0][len(task[0]):]
+		}
+		item.children = parseBlocks(content, offset+start)
+		list.children = append(list.children, item)
+
+		// Skip the blank lines between items
+		for i < len(lines) && strings.TrimSpace(lines[i]) == "" && i+1 < len(lines) && listItemRegex.MatchString(lines[i+1]) {
+			i++
+		}
+	}
+
+	return list, i
+}
+
+func isOrderedMarker(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+// leadingSpaces counts the indentation of a line, a tab counting as 4 spaces
+func leadingSpaces(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// dedent removes n columns of indentation from a line
+func dedent(line string, n int) string {
+	for n > 0 && line != "" {
+		switch line[0] {
+		case ' ':
+			n--
+		case '\t':
+			n -= 4
+		default:
+			return line
+		}
+		line = line[1:]
+	}
+	// A tab may count for more columns than what was left to remove
+	if n < 0 {
+		line = strings.Repeat(" ", -n) + line
+	}
+	return line
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+type inlineKind int
+
+const (
+	inlineText inlineKind = iota
+	inlineCode
+	inlineStrong
+	inlineEmphasis
+	inlineStrike
+	inlineLink
+	inlineImage
+	inlineWikilink
+	inlineBreak
+)
+
+// mdInline is a span of inline markdown
+type mdInline struct {
+	kind inlineKind
+	// text of text and code spans, alt text of images and target of wikilinks
+	text string
+	// url of links and images
+	url      string
+	children []mdInline
+}
+
+// parseInline splits inline markdown in spans
+func parseInline(src string) []mdInline {
+	var spans []mdInline
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			spans = append(spans, mdInline{kind: inlineText, text: text.String()})
+			text.Reset()
+		}
+	}
+	add := func(span mdInline) {
+		flush()
+		spans = append(spans, span)
+	}
+
+	for i := 0; i < len(src); {
+		rest := src[i:]
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_{}[]()#+-.!|~<>", rune(rest[1])):
+			text.WriteByte(rest[1])
+			i += 2
+			continue
+
+		case rest[0] == '\\' && len(rest) > 1 && rest[1] == '\n':
+			add(mdInline{kind: inlineBreak})
+			i += 2
+			continue
+
+		case strings.HasPrefix(rest, "  \n"):
+			add(mdInline{kind: inlineBreak})
+			i += 3
+			continue
+
+		case rest[0] == '`':
+			ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+			fence := rest[:ticks]
+			if end := strings.Index(rest[ticks:], fence); end >= 0 {
+				code := rest[ticks : ticks+end]
+				add(mdInline{kind: inlineCode, text: strings.TrimSpace(code)})
+				i += ticks + end + ticks
+				continue
+			}
+			text.WriteString(fence)
+			i += ticks
+			continue
+
+		case strings.HasPrefix(rest, "[["):
+			if end := strings.Index(rest, "]]"); end > 2 {
+				target, label, _ := strings.Cut(rest[2:end], "|")
+				span := mdInline{kind: inlineWikilink, text: strings.TrimSpace(target)}
+				if label == "" {
+					label = target
+				}
+				span.children = []mdInline{{kind: inlineText, text: strings.TrimSpace(label)}}
+				add(span)
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(rest, "!["):
+			if label, url, n, ok := parseLink(rest[1:]); ok {
+				add(mdInline{kind: inlineImage, text: label, url: url})
+				i += 1 + n
+				continue
+			}
+
+		case rest[0] == '[':
+			if label, url, n, ok := parseLink(rest); ok {
+				add(mdInline{kind: inlineLink, url: url, children: parseInline(label)})
+				i += n
+				continue
+			}
+
+		case rest[0] == '<':
+			if end := strings.IndexByte(rest, '>'); end > 0 {
+				target := rest[1:end]
+				if isURL(target) || (strings.Contains(target, "@") && !strings.ContainsAny(target, " <")) {
+					url := target
+					if !isURL(target) {
+						url = "mailto:" + target
+					}
+					add(mdInline{kind: inlineLink, url: url, children: []mdInline{{kind: inlineText, text: target}}})
+					i += end + 1
+					continue
+				}
+			}
+
+		case strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://"):
+			if i == 0 || !isWordByte(src[i-1]) {
+				end := strings.IndexAny(rest, " \t\n<>")
+				if end < 0 {
+					end = len(rest)
+				}
+				url := strings.TrimRight(rest[:end], ".,;:!?)'\"")
+				add(mdInline{kind: inlineLink, url: url, children: []mdInline{{kind: inlineText, text: url}}})
+				i += len(url)
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if span, n, ok := parseDelimited(src, i, rest[:2], inlineStrong); ok {
+				add(span)
+				i += n
+				continue
+			}
+
+		case strings.HasPrefix(rest, "~~"):
+			if span, n, ok := parseDelimited(src, i, "~~", inlineStrike); ok {
+				add(span)
+				i += n
+				continue
+			}
+
+		case rest[0] == '*' || rest[0] == '_':
+			if span, n, ok := parseDelimited(src, i, rest[:1], inlineEmphasis); ok {
+				add(span)
+				i += n
+				continue
+			}
+		}
+
+		text.WriteByte(src[i])
+		i++
+	}
+
+	flush()
+	return spans
+}
+
+// parseLink parses [label](url "title") at the start of src and returns the
+// number of bytes consumed
+func parseLink(src string) (label, url string, n int, ok bool) {
+	depth := 0
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if i+1 >= len(src) || src[i+1] != '(' {
+				return "", "", 0, false
+			}
+			end := strings.IndexByte(src[i+2:], ')')
+			if end < 0 {
+				return "", "", 0, false
+			}
+			dest := strings.TrimSpace(src[i+2 : i+2+end])
+			// Drop the optional title
+			if space := strings.IndexAny(dest, " \t"); space >= 0 {
+				dest = dest[:space]
+			}
+			dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+			return src[1:i], dest, i + 2 + end + 1, true
+		}
+	}
+	return "", "", 0, false
+}
+
+// parseDelimited parses a span surrounded by delim, starting at src[i]
+func parseDelimited(src string, i int, delim string, kind inlineKind) (mdInline, int, bool) {
+	start := i + len(delim)
+	// The opening delimiter must be followed by text, and underscores can't
+	// be used inside words like in snake_case
+	if start >= len(src) || src[start] == ' ' || src[start] == '\n' {
+		return mdInline{}, 0, false
+	}
+	if delim[0] == '_' && i > 0 && isWordByte(src[i-1]) {
+		return mdInline{}, 0, false
+	}
+
+	for j := start + 1; j <= len(src)-len(delim); j++ {
+		if src[j] == '`' {
+			// Skip code spans, delimiters don't apply in them
+			if end := strings.IndexByte(src[j+1:], '`'); end >= 0 {
+				j += end + 1
+				continue
+			}
+		}
+		if !strings.HasPrefix(src[j:], delim) || src[j-1] == ' ' || src[j-1] == '\\' {
+			continue
+		}
+		// A single * isn't closed by the first half of a **
+		if len(delim) == 1 && j+1 < len(src) && src[j+1] == delim[0] {
+			j++
+			continue
+		}
+		end := j + len(delim)
+		if delim[0] == '_' && end < len(src) && isWordByte(src[end]) {
+			continue
+		}
+		return mdInline{kind: kind, children: parseInline(src[start:j])}, end - i, true
+	}
+
+	return mdInline{}, 0, false
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "mailto:")
+}
+
+// plainText returns the text of spans without any formatting
+func plainText(spans []mdInline) string {
+	var b strings.Builder
+	for _, span := range spans {
+		switch span.kind {
+		case inlineText, inlineCode, inlineImage:
+			b.WriteString(span.text)
+		case inlineBreak:
+			b.WriteString("\n")
+		default:
+			b.WriteString(plainText(span.children))
+		}
+	}
+	return b.String()
+}
+
+// slugify turns a text into a lowercase identifier made of letters, digits
+// and dashes, e.g. for heading anchors
+func slugify(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = nonAlphanumeric.ReplaceAllString(s, "-")
+	return strings.TrimFunc(s, func(r rune) bool { return r == '-' })
+}
+
+// firstHeading returns the text of the first heading of a document, if any
+func firstHeading(blocks []mdBlock) string {
+	for _, block := range blocks {
+		if block.kind == blockHeading {
+			return plainText(parseInline(block.text))
+		}
+	}
+	return ""
+}
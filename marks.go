@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// markedNotes are the notes marked with x to be opened together, read by the
+// delegate to show them marked
+var markedNotes = map[string]bool{}
+
+// markedEditedMsg is sent when the editor opened on the marked notes exits
+type markedEditedMsg struct {
+	filenames []string
+	changed   []string
+	err       error
+}
+
+// toggleMark marks or unmarks the selected note, moving to the next one
+func (m model) toggleMark() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	if markedNotes[item.filename] {
+		delete(markedNotes, item.filename)
+	} else {
+		markedNotes[item.filename] = true
+	}
+	m.list.CursorDown()
+	return m, nil
+}
+
+// markedFilenames returns the marked notes that still exist, sorted
+func (m model) markedFilenames() []string {
+	var filenames []string
+	for _, item := range m.items {
+		if markedNotes[item.filename] {
+			filenames = append(filenames, item.filename)
+		}
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
+// openMarked opens the marked notes in one $EDITOR, after the arguments of
+// open_marked like -p for the tabs of vim, unmarking them
+func (m model) openMarked() (tea.Model, tea.Cmd) {
+	filenames := m.markedFilenames()
+	if len(filenames) == 0 {
+		return m, m.setError("Mark notes with x first")
+	}
+	if m.popup {
+		return m, m.pickNote(filenames...)
+	}
+	for _, filename := range filenames {
+		if _, ok := heldLock(m.notesDir, filename); ok {
+			return m, m.setError("%s is open elsewhere, unmark it to open the others", filename)
+		}
+	}
+
+	editor, err := editorCommand()
+	if err != nil {
+		return m, m.setError("Cannot edit the marked notes: %v", err)
+	}
+	args := append(editor[1:len(editor):len(editor)], splitCommandLine(m.cfg.OpenMarked)...)
+	cmd := exec.Command(editor[0], append(args, filenames...)...)
+	cmd.Dir = m.notesDir
+	debugLog.Info("editor", "path", cmd.Path, "args", cmd.Args, "dir", cmd.Dir, "lookup_err", cmd.Err)
+
+	before := map[string]os.FileInfo{}
+	for _, filename := range filenames {
+		lockNote(m.notesDir, filename)
+		m.history.record(m.notesDir, filename, time.Now())
+		saveVersion(m.notesDir, filename, time.Now())
+		before[filename], _ = os.Stat(filepath.Join(m.notesDir, filename))
+	}
+	markedNotes = map[string]bool{}
+
+	notesDir := m.notesDir
+	return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+		var changed []string
+		for _, filename := range filenames {
+			unlockNote(notesDir, filename)
+			after, statErr := os.Stat(filepath.Join(notesDir, filename))
+			if statErr == nil && (before[filename] == nil || !after.ModTime().Equal(before[filename].ModTime())) {
+				changed = append(changed, filename)
+			}
+		}
+		return markedEditedMsg{filenames: filenames, changed: changed, err: err}
+	})
+}
+
+// markedEdited runs the post_edit hook on the marked notes that changed, and
+// comes back to the list like after editing one note
+func (m model) markedEdited(msg markedEditedMsg) (tea.Model, tea.Cmd) {
+	debugLog.Info("editor finished", "notes", msg.filenames, "changed", msg.changed, "err", msg.err)
+	m.mode = modeList
+	if msg.err != nil {
+		return m, tea.Batch(m.reloadNotes(), m.setError("Cannot edit the marked notes: %v", msg.err))
+	}
+	if m.cfg.QuitAfterEdit {
+		for _, filename := range msg.changed {
+			if err := noteSaved("post_edit", m.notesDir, filename); err != nil {
+				return m, tea.Batch(m.reloadNotes(), m.setError("Saved %s, but %v", filename, err))
+			}
+		}
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	cmds := []tea.Cmd{m.reloadNotes()}
+	m.selectNote(msg.filenames[0])
+	for _, filename := range msg.changed {
+		cmds = append(cmds, m.noteSavedCmd("post_edit", filename, "Saved "+filename))
+	}
+	if len(msg.changed) > 0 {
+		cmds = append(cmds, m.setInfo("Saved %s", pluralNotes(len(msg.changed))))
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// pluralNotes formats a number of notes
+func pluralNotes(n int) string {
+	if n == 1 {
+		return "1 note"
+	}
+	return fmt.Sprintf("%d notes", n)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// meetingsSearch is the name of the saved search listing the meeting notes
+const meetingsSearch = "Meetings"
+
+// meetingsConfig configures snsm meeting
+type meetingsConfig struct {
+	// Folder holds the meeting notes, relative to the notes directory
+	Folder string `yaml:"folder"`
+	// Template is the template of the meeting notes, from the templates
+	// directory, where {{attendees}} and {{time}} are filled in too
+	Template string `yaml:"template"`
+	// Tags are the tags of the meeting notes, the first one making up the
+	// Meetings view
+	Tags string `yaml:"tags"`
+}
+
+// meetingTag returns the tag of the Meetings view, without its +
+func meetingTag(c meetingsConfig) string {
+	tags := strings.Fields(formatTagsWithPlus(c.Tags))
+	if len(tags) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(tags[0], "+")
+}
+
+// attendeeSeparators split a list of attendees
+var attendeeSeparators = regexp.MustCompile(`\s*(?:,|&|\band\b)\s*`)
+
+// parseAttendees reads a list of attendees like "Sam, Alex and Kim"
+func parseAttendees(list string) []string {
+	var attendees []string
+	for _, name := range attendeeSeparators.Split(list, -1) {
+		if name = strings.TrimSpace(name); name != "" {
+			attendees = append(attendees, name)
+		}
+	}
+	return attendees
+}
+
+// titleAttendees reads the attendees from the title of a meeting, after its
+// last "with", like "1:1 with Sam"
+func titleAttendees(title string) []string {
+	i := strings.LastIndex(strings.ToLower(title), " with ")
+	if i < 0 {
+		return nil
+	}
+	return parseAttendees(title[i+len(" with "):])
+}
+
+// meetingBody returns the content of a new meeting note: its template, or a
+// heading with sections for the attendees, the notes and the action items
+func meetingBody(cfg config, notesDir, title string, attendees []string, at time.Time) (string, error) {
+	var list []string
+	for _, name := range attendees {
+		list = append(list, "- "+name)
+	}
+	if cfg.Meetings.Template == "" {
+		return fmt.Sprintf("# %s\n\n## Attendees\n%s\n\n## Notes\n\n## Action items\n- [ ] \n",
+			title, strings.Join(list, "\n")), nil
+	}
+	body, err := loadTemplate(cfg.templatesDir(notesDir), cfg.Meetings.Template, cfg.noteExt(), title)
+	if err != nil {
+		return "", err
+	}
+	body = strings.ReplaceAll(body, "{{attendees}}", strings.Join(list, "\n"))
+	return strings.ReplaceAll(body, "{{time}}", at.Format("15:04")), nil
+}
+
+// createMeeting creates the note of a meeting, its date and attendees in its
+// front matter, and links it from the daily note. It returns the filenames
+// of the meeting note and of the daily note, and whether the daily note was
+// created.
+func createMeeting(cfg config, notesDir, title string, attendees []string, tags string, at time.Time) (string, string, bool, error) {
+	name := strings.ReplaceAll(at.Format("2006-01-02")+" "+title, "/", " ")
+	filename := titleFilename(filepath.Join(cfg.Meetings.Folder, name), cfg.NewNote.FilenameStyle) + cfg.noteExt()
+	path, err := notePath(notesDir, filename)
+	if err != nil {
+		return "", "", false, err
+	}
+	filename, _ = filepath.Rel(notesDir, path)
+	if _, err := os.Stat(path); err == nil {
+		return "", "", false, fmt.Errorf("%s already exists", filename)
+	}
+
+	body, err := meetingBody(cfg, notesDir, title, attendees, at)
+	if err != nil {
+		return "", "", false, err
+	}
+	if err := createTitledNote(path, title, mergeTags(formatTagsWithPlus(cfg.Meetings.Tags), formatTagsWithPlus(tags)), body); err != nil {
+		return "", "", false, describeError(err)
+	}
+	fields := [][2]string{
+		{"title", title},
+		{"date", at.Format("2006-01-02 15:04")},
+		{"attendees", strings.Join(attendees, ", ")},
+	}
+	for _, field := range fields {
+		if err := setNoteField(path, field[0], field[1]); err != nil {
+			return "", "", false, err
+		}
+	}
+
+	dailyPath, err := notePath(notesDir, strings.Replace(cfg.DailyNotes, "%t", at.Format("2006-01-02"), 1))
+	if err != nil {
+		return "", "", false, err
+	}
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return "", "", false, err
+	}
+	link := fmt.Sprintf("[[%s|%s]]", linkTarget(newNoteIndex(itemFilenames(items)), filename), title)
+	created, err := appendEntry(dailyPath, "", captureEntry(link, at))
+	if err != nil {
+		return "", "", false, fmt.Errorf("created %s, but cannot link it from the daily note: %v", filename, err)
+	}
+	daily, _ := filepath.Rel(notesDir, dailyPath)
+	return filename, daily, created, nil
+}
+
+// runMeetingHooks runs the hooks of a new meeting note and of its daily note
+func runMeetingHooks(notesDir, filename, daily string, dailyCreated bool) error {
+	if err := noteSaved("post_create", notesDir, filename); err != nil {
+		return err
+	}
+	return runAppendHook(notesDir, filepath.Join(notesDir, daily), dailyCreated)
+}
+
+func runMeeting(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("meeting", flag.ContinueOnError)
+	with := flags.String("with", "", "attendees of the meeting, e.g. \"Sam, Alex\", by default from the title")
+	tags := flags.String("tags", "", "tags to add to the meeting note, besides the ones of the config")
+	noOpen := flags.Bool("no-open", false, "don't open the note in $EDITOR")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	title := strings.TrimSpace(strings.Join(positional, " "))
+	if title == "" {
+		return fmt.Errorf("expected the title of the meeting")
+	}
+	attendees := parseAttendees(*with)
+	if *with == "" {
+		attendees = titleAttendees(title)
+	}
+
+	filename, daily, dailyCreated, err := createMeeting(cfg, notesDir, title, attendees, *tags, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %s\n", filepath.Join(notesDir, filename))
+	if err := runMeetingHooks(notesDir, filename, daily, dailyCreated); err != nil {
+		return err
+	}
+	if *noOpen {
+		return nil
+	}
+	return editNote(notesDir, filename)
+}
+
+// exMeeting creates the note of a meeting from the command line, like
+// :meeting 1:1 with Sam, and opens it
+func exMeeting(m model, args []string) (tea.Model, tea.Cmd) {
+	title := strings.Join(args, " ")
+	if title == "" {
+		return m, m.setError(":meeting needs the title of the meeting")
+	}
+	filename, daily, dailyCreated, err := createMeeting(m.cfg, m.notesDir, title, titleAttendees(title), "", time.Now())
+	if err != nil {
+		return m, m.setError("Cannot create the meeting: %v", err)
+	}
+	dailyEvent := "post_edit"
+	if dailyCreated {
+		dailyEvent = "post_create"
+	}
+	return m, tea.Batch(
+		m.reloadNotes(),
+		m.noteSavedCmd("post_create", filename, "Created "+filename),
+		m.noteSavedCmd(dailyEvent, daily, "Linked "+filename+" from "+daily),
+		m.openNote(filename),
+	)
+}
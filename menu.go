@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// menuItem is an entry of a menu, with an optional hint shown next to it
+type menuItem struct {
+	label string
+	hint  string
+}
+
+// menu is a small list of choices drawn in a box over the screen
+type menu struct {
+	title  string
+	items  []menuItem
+	cursor int
+}
+
+// update moves the cursor and reports whether an item was chosen with enter
+// or the menu closed with esc
+func (mn *menu) update(msg tea.Msg) (chosen bool, closed bool) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return false, false
+	}
+
+	switch keyMsg.String() {
+	case "up", "k", "ctrl+p", "shift+tab":
+		if mn.cursor > 0 {
+			mn.cursor--
+		}
+	case "down", "j", "ctrl+n", "tab":
+		if mn.cursor < len(mn.items)-1 {
+			mn.cursor++
+		}
+	case "enter":
+		return len(mn.items) > 0, false
+	case "esc", "q", "ctrl+c":
+		return false, true
+	}
+	return false, false
+}
+
+// view draws the menu centered on a screen of the given size
+func (mn menu) view(width, height int) string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(mn.title))
+	b.WriteString("\n\n")
+
+	for i, item := range mn.items {
+		line := item.label
+		if item.hint != "" {
+			line += "  " + mutedStyle.Render(item.hint)
+		}
+
+		if i == mn.cursor {
+			b.WriteString(selectedItemStyle.Render("> " + line))
+		} else {
+			b.WriteString(itemStyle.Render(line))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(mutedStyle.Copy().PaddingLeft(2).Render("enter select • esc close"))
+
+	box := menuStyle.Render(b.String())
+	if width == 0 || height == 0 {
+		return box
+	}
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// doubleClickDelay is the longest delay between the clicks of a double click
+const doubleClickDelay = 400 * time.Millisecond
+
+// lastClick remembers the previous click to detect double clicks
+type lastClick struct {
+	index int
+	at    time.Time
+}
+
+// updateListMouse selects notes on click, opens them on double click, filters
+// by tag when a tag pill is clicked and pages the list with the wheel
+func (m model) updateListMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		m.list.Paginator.PrevPage()
+		m.clampCursor()
+		return m, nil
+
+	case tea.MouseButtonWheelDown:
+		m.list.Paginator.NextPage()
+		m.clampCursor()
+		return m, nil
+
+	case tea.MouseButtonLeft:
+		if msg.Action != tea.MouseActionPress {
+			return m, nil
+		}
+	default:
+		return m, nil
+	}
+
+	// The columns focus the pane clicked, the list being right of the
+	// folders
+	if m.columnsShown() {
+		switch x := msg.X - m.folderPaneWidth(); {
+		case x < 0:
+			m.columns.focus = paneFolders
+			if folder, ok := m.folderAt(msg.Y); ok {
+				return m.selectFolder(folder)
+			}
+			return m, nil
+		case x >= m.list.Width():
+			m.columns.focus = panePreview
+			return m, nil
+		default:
+			m.columns.focus = paneNotes
+			msg.X = x
+		}
+	}
+
+	index, line, ok := m.itemAt(msg.Y)
+	if !ok {
+		return m, nil
+	}
+
+	// Clicking the header of a section collapses or expands it
+	if _, ok := m.list.VisibleItems()[index].(groupHeader); ok {
+		m.list.Select(index)
+		return m.toggleSection()
+	}
+	item, isNote := m.list.VisibleItems()[index].(noteItem)
+
+	// Clicking a tag pill or the label filters on it, wherever the layout
+	// draws them
+	if isNote {
+		if d, x, ok := renderedLine(item, index == m.list.Index(), line, msg.X); ok {
+			for _, tag := range d.tags {
+				if x >= tag.start && x < tag.end {
+					return m.filterWith("tag:" + tag.value)
+				}
+			}
+			if x >= d.label.start && x < d.label.end {
+				return m.filterWith("label:" + quoteValue(d.label.value))
+			}
+		}
+	}
+
+	if m.click.index == index && time.Since(m.click.at) < doubleClickDelay {
+		m.click = lastClick{index: -1}
+		if entry, ok := m.list.VisibleItems()[index].(folderEntry); ok {
+			return m.browseTo(entry.path, "")
+		}
+		return m, m.openNote(item.filename)
+	}
+
+	m.click = lastClick{index: index, at: time.Now()}
+	m.list.Select(index)
+	return m, nil
+}
+
+// clampCursor keeps the cursor on an item after the page changed, the last
+// page may hold fewer items
+func (m *model) clampCursor() {
+	if count := len(m.list.VisibleItems()); m.list.Index() >= count && count > 0 {
+		m.list.Select(count - 1)
+	}
+}
+
+// itemAt returns the index of the list item displayed on row y of the screen
+// and the line of the item on that row
+func (m model) itemAt(y int) (index int, line int, ok bool) {
+	// The items are drawn below the title bar and the status bar of the list
+	top := 0
+	if m.list.ShowTitle() || (m.list.ShowFilter() && m.list.FilteringEnabled()) {
+		top += lipgloss.Height(m.list.Styles.TitleBar.Render("x"))
+	}
+	if m.list.ShowStatusBar() {
+		top += lipgloss.Height(m.list.Styles.StatusBar.Render("x"))
+	}
+
+	delegate := NewCustomDelegate()
+	itemHeight := delegate.Height() + delegate.Spacing()
+
+	y -= top
+	if y < 0 {
+		return 0, 0, false
+	}
+
+	row, line := y/itemHeight, y%itemHeight
+	if line >= delegate.Height() || row >= m.list.Paginator.PerPage {
+		return 0, 0, false
+	}
+
+	index = m.list.Paginator.Page*m.list.Paginator.PerPage + row
+	if index >= len(m.list.VisibleItems()) {
+		return 0, 0, false
+	}
+	return index, line, true
+}
+
+// renderedLine renders a line of a note like customItemDelegate.Render,
+// and returns where its fields are and the column x in the rendered layout
+func renderedLine(item noteItem, selected bool, line, x int) (*layoutData, int, bool) {
+	// The lines are indented by two columns, the marks taking two more
+	indent := 2
+	switch {
+	case line == 0:
+		if markedNotes[item.filename] {
+			indent += 2
+		}
+		_, d := renderLayout(listLayout.current(), item, selected)
+		return d, x - indent, true
+	case line == 1 && !listLayout.compact:
+		_, d := renderLayout(listLayout.details, item, selected)
+		return d, x - indent, true
+	}
+	return nil, 0, false
+}
+
+// filterWith fills the list filter with a query term, as if it was typed
+func (m model) filterWith(term string) (tea.Model, tea.Cmd) {
+	m.list.ResetFilter()
+
+	var cmds []tea.Cmd
+	var cmd tea.Cmd
+	// Start filtering and type the term
+	m.list, cmd = m.list.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	cmds = append(cmds, cmd)
+	m.list, cmd = m.list.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(term)})
+	cmds = append(cmds, cmd)
+
+	return m, tea.Batch(cmds...)
+}
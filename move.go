@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// wikilinkRegex matches a [[wikilink]], capturing its target and what follows
+// it: the #heading anchor and the |label
+var wikilinkRegex = regexp.MustCompile(`\[\[([^\]|#]*)([#|][^\]]*)?\]\]`)
+
+// newFolderLabel is the entry of the move menu creating a folder
+const newFolderLabel = "+ New folder"
+
+// openMove shows the folders the selected note can be moved to
+func (m model) openMove() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	current := filepath.Dir(item.filename)
+	m.moving = item.filename
+	m.moveMenu = menu{title: "Move " + item.Title() + " to"}
+	m.moveMenu.items = append(m.moveMenu.items,
+		menuItem{label: newFolderLabel, hint: "create a folder"},
+		menuItem{label: "/", hint: "the notes directory"})
+	for _, folder := range listFolders(m.notesDir) {
+		if folder == attachmentsFolder {
+			continue
+		}
+		m.moveMenu.items = append(m.moveMenu.items, menuItem{label: folder})
+		if folder == current {
+			m.moveMenu.cursor = len(m.moveMenu.items) - 1
+		}
+	}
+
+	m.mode = modeMove
+	return m, nil
+}
+
+func (m model) updateMove(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.moveMenu.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+
+	folder := m.moveMenu.items[m.moveMenu.cursor].label
+	switch folder {
+	case newFolderLabel:
+		m.folderInput.SetValue("")
+		m.folderInput.SetSuggestions(listFolders(m.notesDir))
+		m.folderInput.Focus()
+		m.mode = modeMoveFolder
+		return m, textinput.Blink
+	case "/":
+		folder = ""
+	}
+	return m.moveTo(folder)
+}
+
+func (m model) updateMoveFolder(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "ctrl+c":
+			m.quitting = true
+			return m, tea.Quit
+		case "esc":
+			m.mode = modeMove
+			return m, nil
+		case "enter":
+			return m.moveTo(m.folderInput.Value())
+		}
+	}
+
+	var cmd tea.Cmd
+	m.folderInput, cmd = m.folderInput.Update(msg)
+	return m, cmd
+}
+
+func (m model) viewMoveFolder() string {
+	prompt := fmt.Sprintf("Enter the folder to move %s to (tab to complete):", trimNoteExt(m.moving))
+	return fmt.Sprintf("\n\n  %s\n\n  %s\n\n", prompt, m.folderInput.View()) + "  (press ESC to go back)"
+}
+
+// moveTo moves the note being moved to a folder and reloads the list
+func (m model) moveTo(folder string) (tea.Model, tea.Cmd) {
+	m.mode = modeList
+
+	var filenames []string
+	for _, item := range m.items {
+		filenames = append(filenames, item.filename)
+	}
+
+	moved, links, err := moveNote(m.notesDir, m.moving, folder, filenames)
+	if err != nil {
+		return m, m.setError("Cannot move %s: %v", m.moving, err)
+	}
+	if moved == m.moving {
+		return m, nil
+	}
+	// filenames has the new name of the note, for moving it back
+	notesDir, original := m.notesDir, m.moving
+	m.pushUndo("moving "+original+" to "+moved, func() error {
+		_, _, err := moveNote(notesDir, moved, filepath.Dir(original), filenames)
+		return err
+	})
+
+	reload := m.reloadNotes()
+	return m, tea.Batch(reload, m.setInfo("Moved to %s, %d links updated", moved, links))
+}
+
+// moveNote moves a note to a folder of the notes directory, created if
+// needed, and updates the wikilinks of the other notes that no longer reach
+// it. It returns the new filename of the note and the number of links
+// updated.
+func moveNote(notesDir, filename, folder string, filenames []string) (string, int, error) {
+	cleaned, ok := cleanFolder(folder)
+	if !ok {
+		return "", 0, fmt.Errorf("folder %s is outside of the notes directory", folder)
+	}
+
+	moved := filepath.Join(cleaned, filepath.Base(filename))
+	if moved == filename {
+		return filename, 0, nil
+	}
+	if _, err := os.Stat(filepath.Join(notesDir, moved)); err == nil {
+		return "", 0, fmt.Errorf("%s already exists", moved)
+	}
+
+	if err := os.MkdirAll(filepath.Join(notesDir, cleaned), 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create folder: %v", err)
+	}
+	if err := os.Rename(filepath.Join(notesDir, filename), filepath.Join(notesDir, moved)); err != nil {
+		return "", 0, err
+	}
+
+	before := newNoteIndex(filenames)
+	for i := range filenames {
+		if filenames[i] == filename {
+			filenames[i] = moved
+		}
+	}
+	after := newNoteIndex(filenames)
+
+	links, err := updateWikilinks(notesDir, filenames, func(target string) (string, bool) {
+		if resolved, ok := before.resolve(target); !ok || resolved != filename {
+			return "", false
+		}
+		// Links by name alone keep working unless another note now wins,
+		// links by path are updated
+		if resolved, ok := after.resolve(target); ok && resolved == moved && !strings.Contains(target, "/") {
+			return "", false
+		}
+		return filepath.ToSlash(trimNoteExt(moved)), true
+	})
+	return moved, links, err
+}
+
+// updateWikilinks rewrites the targets of the wikilinks of the notes for
+// which rewrite returns a new one, and returns the number of links rewritten
+func updateWikilinks(notesDir string, filenames []string, rewrite func(target string) (string, bool)) (int, error) {
+	count := 0
+	for _, filename := range filenames {
+		path := filepath.Join(notesDir, filename)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return count, err
+		}
+
+		changed := 0
+		updated := wikilinkRegex.ReplaceAllStringFunc(string(content), func(link string) string {
+			match := wikilinkRegex.FindStringSubmatch(link)
+			target, ok := rewrite(match[1])
+			if !ok {
+				return link
+			}
+			changed++
+			return "[[" + target + match[2] + "]]"
+		})
+		if changed == 0 {
+			continue
+		}
+
+		if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+			return count, err
+		}
+		count += changed
+	}
+	return count, nil
+}
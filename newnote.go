@@ -0,0 +1,467 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Styles of the filenames of new notes, made from their title
+const (
+	slugKebab  = "kebab"
+	slugSnake  = "snake"
+	slugSpaces = "spaces"
+)
+
+// unsafeFilenameChars can't be used in filenames on some systems
+var unsafeFilenameChars = regexp.MustCompile(`[<>:"\\|?*\x00-\x1f]+`)
+
+// reservedFilename matches the device names Windows doesn't allow as
+// filenames
+var reservedFilename = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[1-9]|lpt[1-9])$`)
+
+// maxFilenameLength leaves room for the extension in the 255 bytes most
+// filesystems allow
+const maxFilenameLength = 240
+
+// stepModes maps every step of the new note flow to the mode prompting for it
+var stepModes = map[string]int{
+	stepFilename: modeInput,
+	stepTags:     modeTagInput,
+	stepTemplate: modeTemplateInput,
+	stepFolder:   modeFolderInput,
+}
+
+// startNewNote resets the new note values to their defaults and shows the
+// first configured prompt. Without any prompt the note is created right away.
+func (m model) startNewNote() (model, tea.Cmd) {
+	defaults := m.cfg.NewNote.Defaults
+
+	m.textInput.SetValue("")
+	m.tagInput.SetValue(defaults.Tags)
+	m.templateInput.SetValue(defaults.Template)
+	m.folderInput.SetValue(defaults.Folder)
+
+	m.templateInput.SetSuggestions(listTemplates(m.templatesDir()))
+	m.folderInput.SetSuggestions(listFolders(m.notesDir))
+
+	m.step = -1
+	m.inputErr = ""
+	return m.nextStep()
+}
+
+// nextStep moves to the next prompt, or finishes the flow after the last one
+func (m model) nextStep() (model, tea.Cmd) {
+	m.step++
+	if m.step >= len(m.cfg.NewNote.Steps) {
+		return m.finishNewNote()
+	}
+	return m.showStep()
+}
+
+// prevStep moves back to the previous prompt, leaving the flow from the first
+func (m model) prevStep() (model, tea.Cmd) {
+	m.step--
+	m.inputErr = ""
+	if m.step < 0 {
+		// Return to list mode if we came from there
+		if len(m.items) > 0 {
+			m.mode = modeList
+			return m, nil
+		}
+		// Otherwise just quit
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m.showStep()
+}
+
+// showStep focuses the input of the current step
+func (m model) showStep() (model, tea.Cmd) {
+	m.mode = stepModes[m.cfg.NewNote.Steps[m.step]]
+
+	m.textInput.Blur()
+	m.tagInput.Blur()
+	m.templateInput.Blur()
+	m.folderInput.Blur()
+	m.currentInput().Focus()
+	if m.mode == modeTagInput {
+		// The body is not written yet, the title tells what the note is
+		// about
+		m.tagSuggestions = suggestTags(m.items, m.textInput.Value())
+	}
+
+	return m, textinput.Blink
+}
+
+// currentInput returns the input of the current step
+func (m *model) currentInput() *textinput.Model {
+	switch m.mode {
+	case modeTagInput:
+		return &m.tagInput
+	case modeTemplateInput:
+		return &m.templateInput
+	case modeFolderInput:
+		return &m.folderInput
+	default:
+		return &m.textInput
+	}
+}
+
+// finishNewNote creates the note from the collected values and opens it,
+// asking what to do when a note already has its filename
+func (m model) finishNewNote() (model, tea.Cmd) {
+	title := strings.TrimSpace(m.textInput.Value())
+	// The filename prompt was skipped
+	if title == "" {
+		title = m.cfg.NewNote.Defaults.Filename
+	}
+	// Replace timestamp placeholder with current date, and remove any
+	// extension the user might have added
+	title = trimNoteExt(expandTimestamp(title))
+
+	m.mode = modeList
+	folder, _ := cleanFolder(m.folderInput.Value())
+	title, err := userScript.newNoteName(title, folder, m.tagInput.Value())
+	if err != nil {
+		return m, m.setError("Cannot name the note: %v", err)
+	}
+	filename := titleFilename(title, m.cfg.NewNote.FilenameStyle)
+	if filename == "" {
+		return m, m.setError("Cannot create a note named %q", title)
+	}
+	// Always add the extension of the format
+	filename += m.noteExt()
+	// The heading keeps the title as typed, without its folders
+	m.newTitle = capitalizeFirstLetter(path.Base(filepath.ToSlash(title)))
+
+	m.newFilename = filepath.Join(folder, filename)
+
+	if _, err := os.Stat(filepath.Join(m.notesDir, m.newFilename)); err == nil {
+		m.collisionMenu = menu{
+			title: m.newFilename + " already exists",
+			items: []menuItem{
+				{label: "Open existing"},
+				{label: "Choose another name"},
+				{label: "Overwrite", hint: "replaces its content, u undoes it"},
+			},
+		}
+		m.mode = modeCollision
+		return m, nil
+	}
+	return m.writeNewNote()
+}
+
+// writeNewNote writes the new note, replacing any note with its filename,
+// and opens it. The note replaced is saved as a version, u putting it back.
+func (m model) writeNewNote() (model, tea.Cmd) {
+	m.mode = modeList
+	fullPath := filepath.Join(m.notesDir, m.newFilename)
+
+	var templateBody string
+	if name := strings.TrimSpace(m.templateInput.Value()); name != "" {
+		var err error
+		templateBody, err = loadTemplate(m.templatesDir(), name, m.noteExt(), m.newTitle)
+		if err != nil {
+			return m, m.setError("Cannot create %s: %v", m.newFilename, err)
+		}
+	}
+
+	var replaced map[string][]byte
+	if _, err := os.Stat(fullPath); err == nil {
+		if replaced, err = readForUndo(m.notesDir, m.newFilename); err != nil {
+			return m, m.setError("Cannot replace %s: %v", m.newFilename, err)
+		}
+	}
+	if err := createTitledNote(fullPath, m.newTitle, m.tagInput.Value(), templateBody); err != nil {
+		return m, m.setError("Cannot create %s: %v", m.newFilename, err)
+	}
+	if replaced != nil {
+		m.pushUndo("overwriting "+m.newFilename, restoreNotes(m.notesDir, replaced))
+	}
+	return m, tea.Batch(m.reloadNotes(), m.noteSavedCmd("post_create", m.newFilename, "Created "+m.newFilename), m.openNote(m.newFilename))
+}
+
+func (m model) updateCollision(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.collisionMenu.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+
+	switch m.collisionMenu.cursor {
+	case 0:
+		m.mode = modeList
+		return m, tea.Batch(m.reloadNotes(), m.openNote(m.newFilename))
+	case 1:
+		m.inputErr = ""
+		m.textInput.SetValue(m.newTitle)
+		m.textInput.CursorEnd()
+		for i, step := range m.cfg.NewNote.Steps {
+			if step == stepFilename {
+				m.step = i
+				return m.showStep()
+			}
+		}
+		// Ask for the filename even when its prompt is skipped, as the last
+		// prompt
+		m.step = len(m.cfg.NewNote.Steps) - 1
+		m.tagInput.Blur()
+		m.templateInput.Blur()
+		m.folderInput.Blur()
+		m.textInput.Focus()
+		m.mode = modeInput
+		return m, textinput.Blink
+	}
+	return m.writeNewNote()
+}
+
+// validateTitle checks that a typed title makes a valid filename
+func validateTitle(title, style string) error {
+	title = trimNoteExt(expandTimestamp(strings.TrimSpace(title)))
+	if strings.ContainsAny(title, `/\`) {
+		return fmt.Errorf("slashes can't be used, choose the folder in its own prompt")
+	}
+	if style == slugSpaces || style == "" {
+		if chars := unsafeFilenameChars.FindString(title); chars != "" {
+			return fmt.Errorf("%q can't be used in filenames", chars)
+		}
+	}
+
+	filename := titleFilename(title, style)
+	if filename == "" {
+		return fmt.Errorf("the title has no character usable in a filename")
+	}
+	if len(filename) > maxFilenameLength {
+		return fmt.Errorf("the title is too long for a filename")
+	}
+	// Names reserved by Windows, whatever their extension
+	if reservedFilename.MatchString(filename) {
+		return fmt.Errorf("%s is a reserved filename", filename)
+	}
+	return nil
+}
+
+// titleFilename turns the title of a new note into its filename, without
+// extension, written in a style: kebab-case, snake_case or with its spaces
+// kept. Folders typed in the title are kept.
+func titleFilename(title, style string) string {
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(title), "/") {
+		switch style {
+		case slugKebab:
+			part = slugify(part)
+		case slugSnake:
+			part = strings.ReplaceAll(slugify(part), "-", "_")
+		default:
+			part = strings.Join(strings.Fields(unsafeFilenameChars.ReplaceAllString(part, " ")), " ")
+			part = strings.Trim(part, ". ")
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return filepath.Join(parts...)
+}
+
+func (m model) updateNewNote(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			return m.prevStep()
+
+		case "enter":
+			switch m.mode {
+			case modeInput:
+				// A filename is required
+				if strings.TrimSpace(m.textInput.Value()) == "" {
+					m.inputErr = "A title is required"
+					return m, nil
+				}
+				if err := validateTitle(m.textInput.Value(), m.cfg.NewNote.FilenameStyle); err != nil {
+					m.inputErr = capitalizeFirstLetter(err.Error())
+					return m, nil
+				}
+			case modeTemplateInput:
+				name := strings.TrimSpace(m.templateInput.Value())
+				if name != "" && !templateExists(m.templatesDir(), name) {
+					m.inputErr = fmt.Sprintf("No template named %s in %s", name, m.templatesDir())
+					return m, nil
+				}
+			case modeFolderInput:
+				if _, ok := cleanFolder(m.folderInput.Value()); !ok {
+					m.inputErr = "The folder must be inside of the notes directory"
+					return m, nil
+				}
+			}
+			m.inputErr = ""
+			return m.nextStep()
+
+		case "tab":
+			if m.mode == modeTagInput {
+				m.acceptSuggestion()
+				return m, nil
+			}
+		}
+
+		// The error is about the previous value
+		m.inputErr = ""
+	}
+
+	var cmd tea.Cmd
+	input := m.currentInput()
+	*input, cmd = input.Update(msg)
+	return m, cmd
+}
+
+func (m model) viewNewNote() string {
+	var prompt, input string
+
+	switch m.mode {
+	case modeInput:
+		prompt = "Enter the title of your new note (use %t for today's date):"
+		input = m.textInput.View()
+	case modeTagInput:
+		prompt = "Enter tags for your note (e.g. work important todo):"
+		input = m.tagInput.View() + m.viewSuggestions()
+	case modeTemplateInput:
+		prompt = "Enter the template for your note (tab to complete, empty for none):"
+		input = m.templateInput.View()
+	case modeFolderInput:
+		prompt = "Enter the folder for your note (tab to complete, empty for the root):"
+		input = m.folderInput.View()
+	}
+
+	help := "  (press ESC to cancel)"
+	if m.step > 0 {
+		help = "  (press ESC to go back)"
+	}
+
+	if m.inputErr != "" {
+		input += "\n\n" + statusErrorStyle.Render(m.inputErr)
+	}
+
+	return fmt.Sprintf("\n\n  %s\n\n  %s\n\n", prompt, input) + help
+}
+
+// noteExt returns the extension of the new notes, from their format
+func (m model) noteExt() string {
+	return m.cfg.noteExt()
+}
+
+func (cfg config) noteExt() string {
+	if cfg.NewNote.Format == "org" {
+		return ".org"
+	}
+	return ".md"
+}
+
+// templatesDir returns the directory holding the note templates
+func (m model) templatesDir() string {
+	return m.cfg.templatesDir(m.notesDir)
+}
+
+func (cfg config) templatesDir(notesDir string) string {
+	if cfg.TemplatesDir != "" {
+		return expandTilde(cfg.TemplatesDir)
+	}
+	return filepath.Join(notesDir, ".templates")
+}
+
+// listTemplates returns the names of the templates, without their extension
+func listTemplates(dir string) []string {
+	var names []string
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".md" && ext != ".org") {
+			continue
+		}
+		if name := strings.TrimSuffix(entry.Name(), ext); !containsTag(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func templateExists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name+".md"))
+	if err != nil {
+		_, err = os.Stat(filepath.Join(dir, name+".org"))
+	}
+	return err == nil
+}
+
+// loadTemplate reads a template and fills in its placeholders:
+// {{title}} and {{date}}. The template of the format of the note, named
+// after its extension, is preferred over the markdown one.
+func loadTemplate(dir, name, ext, title string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name+ext))
+	if os.IsNotExist(err) && ext != ".md" {
+		data, err = os.ReadFile(filepath.Join(dir, name+".md"))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read template %s: %v", name, err)
+	}
+
+	body := strings.ReplaceAll(string(data), "{{title}}", title)
+	body = strings.ReplaceAll(body, "{{date}}", time.Now().Format("2006-01-02"))
+	return body, nil
+}
+
+// listFolders returns every non hidden folder of the notes directory,
+// relative to it
+func listFolders(dir string) []string {
+	var folders []string
+
+	filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() || path == dir {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err == nil {
+			folders = append(folders, rel)
+		}
+		return nil
+	})
+
+	return folders
+}
+
+// cleanFolder normalizes a folder relative to the notes directory and
+// reports whether it stays inside of it
+func cleanFolder(folder string) (string, bool) {
+	folder = strings.TrimSpace(folder)
+	if folder == "" {
+		return "", true
+	}
+
+	folder = filepath.Clean(strings.TrimPrefix(expandTimestamp(folder), "/"))
+	if folder == ".." || strings.HasPrefix(folder, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if folder == "." {
+		return "", true
+	}
+	return folder, true
+}
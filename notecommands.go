@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// noteCommand is a command of the config run on the selected note from the
+// command palette
+type noteCommand struct {
+	Name string `yaml:"name"`
+	// Run is the command line, {{path}}, {{filename}}, {{title}}, {{tags}}
+	// and {{dir}} being replaced with the note, quoted for the shell
+	Run string `yaml:"run"`
+	// Interactive commands get the terminal, like a pager or an editor,
+	// rather than having their output shown
+	Interactive bool `yaml:"interactive"`
+}
+
+// shellQuote quotes a value to pass it as a single argument to the shell
+func shellQuote(value string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// commandLine replaces the placeholders of a command with a note
+func commandLine(run, notesDir string, item noteItem, title string) string {
+	path := item.path
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	dir, err := filepath.Abs(notesDir)
+	if err != nil {
+		dir = notesDir
+	}
+	return strings.NewReplacer(
+		"{{path}}", shellQuote(path),
+		"{{filename}}", shellQuote(filepath.ToSlash(item.filename)),
+		"{{title}}", shellQuote(title),
+		"{{tags}}", shellQuote(strings.Join(strings.Fields(strings.ReplaceAll(item.allTags(), "+", "")), " ")),
+		"{{dir}}", shellQuote(dir),
+	).Replace(run)
+}
+
+// commandFinishedMsg is sent when a command run from the palette exits
+type commandFinishedMsg struct {
+	name     string
+	filename string
+	output   string
+	err      error
+}
+
+// openPalette lists the commands of the config, then the entries of the
+// plugins, to run on the selected note
+func (m model) openPalette() (tea.Model, tea.Cmd) {
+	selected, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	plugins := listPlugins()
+	if len(m.cfg.Commands) == 0 && len(plugins) == 0 {
+		return m, m.setInfo("No commands yet, add them to the commands of the config")
+	}
+
+	m.palette = menu{}
+	m.paletteNote = selected.filename
+	m.palettePending = len(plugins)
+	m.setPaletteItems()
+	m.mode = modePalette
+	var queries []tea.Cmd
+	for _, plugin := range plugins {
+		queries = append(queries, queryPluginMenu(plugin, m.notesDir, selected.filename))
+	}
+	return m, tea.Batch(queries...)
+}
+
+// setPaletteItems lists the commands of the config, then the entries of the
+// plugins known so far, keeping the cursor
+func (m *model) setPaletteItems() {
+	var items []menuItem
+	for _, c := range m.cfg.Commands {
+		items = append(items, menuItem{label: c.Name, hint: truncateWidth(c.Run, 40)})
+	}
+	m.pluginItems = cachedPluginMenu(listPlugins(), m.paletteNote)
+	for _, p := range m.pluginItems {
+		items = append(items, menuItem{label: p.item, hint: "plugin " + filepath.Base(p.plugin)})
+	}
+
+	title := "Run on the note"
+	if m.palettePending > 0 {
+		title += " (asking the plugins...)"
+	}
+	m.palette = menu{title: title, items: items, cursor: min(m.palette.cursor, max(len(items)-1, 0))}
+}
+
+func (m model) updatePalette(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.palette.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+	m.mode = modeList
+
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	if i := m.palette.cursor - len(m.cfg.Commands); i >= 0 {
+		p := m.pluginItems[i]
+		return m, tea.Batch(m.setInfo("Running %s on %s...", p.item, item.filename), runPluginItem(p, m.notesDir, item.filename))
+	}
+	return m, m.runNoteCommand(m.cfg.Commands[m.palette.cursor], item)
+}
+
+// runNoteCommand runs a command of the config on a note, giving it the
+// terminal when interactive
+func (m *model) runNoteCommand(c noteCommand, item noteItem) tea.Cmd {
+	cmd := shellCommand(commandLine(c.Run, m.notesDir, item, itemTitle(item)))
+	cmd.Dir = m.notesDir
+	cmd.Env = append(os.Environ(), noteEnv(m.notesDir, item.filename)...)
+
+	if c.Interactive {
+		return tea.ExecProcess(cmd, func(err error) tea.Msg {
+			return commandFinishedMsg{name: c.Name, filename: item.filename, err: err}
+		})
+	}
+
+	run := func() tea.Msg {
+		var output bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &output, &output
+		err := cmd.Run()
+		return commandFinishedMsg{name: c.Name, filename: item.filename, output: output.String(), err: err}
+	}
+	return tea.Batch(m.setInfo("Running %s on %s...", c.Name, item.filename), run)
+}
+
+// commandFinished shows the output of a command run from the palette
+func (m model) commandFinished(msg commandFinishedMsg) (tea.Model, tea.Cmd) {
+	output := strings.TrimRight(strings.ReplaceAll(msg.output, "\t", "    "), "\n")
+	if output == "" {
+		if msg.err != nil {
+			return m, tea.Batch(m.reloadNotes(), m.setError("%s failed on %s: %v", msg.name, msg.filename, msg.err))
+		}
+		return m, tea.Batch(m.reloadNotes(), m.setInfo("Ran %s on %s", msg.name, msg.filename))
+	}
+
+	title := fmt.Sprintf("%s: %s", msg.name, msg.filename)
+	if msg.err != nil {
+		title += " (" + msg.err.Error() + ")"
+	}
+	model, cmd := m.openPager(title, strings.Split(output, "\n"))
+	return model, tea.Batch(cmd, m.reloadNotes())
+}
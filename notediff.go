@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// readDiff compares two files, a missing one being empty
+func readDiff(oldPath, newPath string) ([]diffLine, error) {
+	old, err := os.ReadFile(oldPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	current, err := os.ReadFile(newPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return diffLines(splitNoteLines(old), splitNoteLines(current)), nil
+}
+
+// hasChanges reports whether a diff changes anything
+func hasChanges(diff []diffLine) bool {
+	for _, line := range diff {
+		if line.kind != diffSame {
+			return true
+		}
+	}
+	return false
+}
+
+// openDiff shows the unified diff of two files
+func (m model) openDiff(oldPath, newPath, oldName, newName string) (tea.Model, tea.Cmd) {
+	diff, err := readDiff(oldPath, newPath)
+	if err != nil {
+		return m, m.setError("Cannot compare %s and %s: %v", oldName, newName, describeError(err))
+	}
+	if !hasChanges(diff) {
+		return m, m.setInfo("%s and %s are identical", oldName, newName)
+	}
+
+	return m.openPager(oldName+" → "+newName, unifiedDiff(diff, oldName, newName))
+}
+
+// markForDiff marks the selected note to compare it with the next one marked
+func (m model) markForDiff() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	switch m.diffMark {
+	case "":
+		m.diffMark = item.filename
+		return m, m.setInfo("Press = on another note to compare it with %s", item.filename)
+	case item.filename:
+		m.diffMark = ""
+		return m, m.setInfo("Comparison canceled")
+	}
+
+	marked := m.diffMark
+	m.diffMark = ""
+	return m.openDiff(filepath.Join(m.notesDir, marked), item.path, marked, item.filename)
+}
+
+func runDiff(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var oldPath, newPath, oldName, newName string
+	switch flags.NArg() {
+	case 1:
+		// The note is compared with its last version
+		newName = noteFilename(notesDir, flags.Arg(0))
+		newPath = filepath.Join(notesDir, newName)
+		versions, err := listVersions(notesDir, newName)
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return fmt.Errorf("no versions of %s", newName)
+		}
+		oldPath = versions[0].path
+		oldName = newName + " (" + versions[0].savedAt.Format("2006-01-02 15:04") + ")"
+	case 2:
+		oldName, newName = noteFilename(notesDir, flags.Arg(0)), noteFilename(notesDir, flags.Arg(1))
+		oldPath, newPath = filepath.Join(notesDir, oldName), filepath.Join(notesDir, newName)
+	default:
+		return fmt.Errorf("expected a note and another one to compare it with, or a note alone to compare it with its last version")
+	}
+
+	for _, path := range []string{oldPath, newPath} {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+	}
+	diff, err := readDiff(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	if !hasChanges(diff) {
+		fmt.Fprintln(os.Stderr, "No differences")
+		return nil
+	}
+
+	t, err := resolveTheme(cfg)
+	if err != nil {
+		return err
+	}
+	applyTheme(t)
+	for _, line := range unifiedDiff(diff, oldName, newName) {
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// readingSpeed is the number of words read in a minute, for the reading time
+const readingSpeed = 200
+
+// infoEdits is the number of edit dates listed in the info of a note, the
+// times its last versions were saved on opening it in $EDITOR
+const infoEdits = 5
+
+// noteStats are the numbers of a note shown in its info, cached with its
+// header until it changes
+type noteStats struct {
+	Words      int `json:"words"`
+	Characters int `json:"characters"`
+	Headings   int `json:"headings"`
+	Links      int `json:"links"`
+}
+
+// readingMinutes returns the minutes it takes to read the note, 1 at least
+// when it has words
+func (s noteStats) readingMinutes() int {
+	return (s.Words + readingSpeed - 1) / readingSpeed
+}
+
+// countStats counts the words, characters, headings and links of a note,
+// leaving out its tag line and front matter, and the code blocks for the
+// headings and links
+func countStats(content string) noteStats {
+	_, body := splitTagLine(content)
+	body = stripFrontMatter(body)
+	stats := noteStats{
+		Words:      len(strings.Fields(body)),
+		Characters: utf8.RuneCountInString(strings.ReplaceAll(body, "\n", "")),
+	}
+
+	var fence string
+	for _, line := range strings.Split(body, "\n") {
+		if match := fenceRegex.FindStringSubmatch(line); match != nil {
+			if fence == "" {
+				fence = match[1]
+			} else if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+		if headingRegex.MatchString(line) {
+			stats.Headings++
+		}
+		stats.Links += len(wikilinkRegex.FindAllString(line, -1))
+		for _, match := range markdownLinkRegex.FindAllStringSubmatch(line, -1) {
+			// Images aren't links
+			if match[1] == "" {
+				stats.Links++
+			}
+		}
+	}
+	return stats
+}
+
+// cachedStats returns the stats of a note from the header cache, counting
+// and caching them when the note changed since
+func cachedStats(item noteItem) noteStats {
+	headers.mu.Lock()
+	defer headers.mu.Unlock()
+	headers.load()
+
+	path := absPath(item.path)
+	info, err := os.Stat(item.path)
+	var h cachedHeader
+	cached := false
+	if err == nil {
+		h, cached = headers.lookup(path, info)
+	}
+	if cached && h.Stats != nil {
+		return *h.Stats
+	}
+
+	stats := countStats(noteContents.get(item))
+	// Not caching the stats only counts them again next time
+	if cached && item.cacheable() {
+		h.Stats = &stats
+		headers.store(path, h)
+		headers.save()
+	}
+	return stats
+}
+
+// describe tells the length of a note, like "120 words · 640 characters ·
+// 1 min read"
+func (s noteStats) describe() string {
+	return fmt.Sprintf("%d words · %d characters · %d min read", s.Words, s.Characters, s.readingMinutes())
+}
+
+// openNoteInfo shows the numbers of the selected note and when it was last
+// modified and edited
+func (m model) openNoteInfo() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	stats := cachedStats(item)
+
+	field := func(name, value string) string {
+		return mutedStyle.Render(fmt.Sprintf("%-14s", name)) + value
+	}
+	lines := []string{
+		field("Words", fmt.Sprint(stats.Words)),
+		field("Characters", fmt.Sprint(stats.Characters)),
+		field("Reading time", fmt.Sprintf("%d min", stats.readingMinutes())),
+		field("Headings", fmt.Sprint(stats.Headings)),
+		field("Links", fmt.Sprint(stats.Links)),
+		"",
+		field("Modified", item.modTime.Format("2006-01-02 15:04")),
+	}
+	versions, err := listVersions(m.notesDir, item.filename)
+	if err != nil {
+		return m, m.setError("Cannot list the versions of %s: %v", item.filename, describeError(err))
+	}
+	for i, v := range versions[:min(len(versions), infoEdits)] {
+		name := ""
+		if i == 0 {
+			name = "Edited"
+		}
+		lines = append(lines, field(name, v.savedAt.Format("2006-01-02 15:04")))
+	}
+	return m.openPager("Info: "+item.filename, lines)
+}
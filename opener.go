@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openExternal opens a URL or a file with the default application of the
+// system, without waiting for it
+func openExternal(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("cmd", "/C", "start", "", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open %s: %v", target, err)
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// openFolder opens the folder of the selected note in the file manager, or
+// the notes directory when no note is selected
+func (m model) openFolder() (tea.Model, tea.Cmd) {
+	dir := m.notesDir
+	if item, ok := m.list.SelectedItem().(noteItem); ok {
+		dir = filepath.Dir(item.path)
+	}
+	if err := openExternal(dir); err != nil {
+		return m, m.setError("%v", err)
+	}
+	return m, m.setInfo("Opened %s", dir)
+}
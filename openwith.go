@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The entries of the open with menu before the applications of the config
+const (
+	openWithEditor = iota
+	openWithSystem
+	openWithBrowser
+	openWithPDF
+	openWithBuiltins
+)
+
+// detectedApps are offered in the open with menu when installed, unless the
+// config sets open_with
+var detectedApps = []noteCommand{
+	{Name: "glow", Run: "glow -p {{path}}", Interactive: true},
+	{Name: "VS Code", Run: "code {{path}}"},
+}
+
+// openWithApps returns the applications of the open with menu: the ones of
+// the config, or the detected ones installed
+func openWithApps(cfg config) []noteCommand {
+	if cfg.OpenWith != nil {
+		return cfg.OpenWith
+	}
+	var apps []noteCommand
+	for _, app := range detectedApps {
+		if args := splitCommandLine(app.Run); len(args) > 0 {
+			if _, err := exec.LookPath(args[0]); err == nil {
+				apps = append(apps, app)
+			}
+		}
+	}
+	return apps
+}
+
+// openOpenWith lists the ways to open the selected note: $EDITOR, the
+// default application of the system, a page in the browser, a PDF and the
+// applications of the config
+func (m model) openOpenWith() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	items := []menuItem{
+		{label: "Editor", hint: os.Getenv("EDITOR")},
+		{label: "Default application"},
+		{label: "Browser", hint: "rendered to HTML"},
+		{label: "PDF", hint: "exported to " + m.cfg.Export.Dir},
+	}
+	if m.cfg.Export.Dir == "" {
+		items[openWithPDF].hint = "exported to the current directory"
+	}
+	m.openWithApps = openWithApps(m.cfg)
+	for _, app := range m.openWithApps {
+		items = append(items, menuItem{label: app.Name, hint: truncateWidth(app.Run, 40)})
+	}
+	m.openWithMenu = menu{title: "Open " + item.filename + " with", items: items}
+	m.mode = modeOpenWith
+	return m, nil
+}
+
+func (m model) updateOpenWith(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.openWithMenu.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+	m.mode = modeList
+
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	switch m.openWithMenu.cursor {
+	case openWithEditor:
+		return m, m.openNote(item.filename)
+	case openWithSystem:
+		if err := openExternal(item.path); err != nil {
+			return m, m.setError("%v", err)
+		}
+		return m, m.setInfo("Opened %s", item.filename)
+	case openWithBrowser:
+		return m.previewInBrowser(item)
+	case openWithPDF:
+		return m.exportAndOpen(item)
+	}
+	return m, m.runNoteCommand(m.openWithApps[m.openWithMenu.cursor-openWithBuiltins], item)
+}
+
+// previewInBrowser renders a note to a page of the temporary directory and
+// opens it in the browser, its images found from the folder of the note
+func (m model) previewInBrowser(item noteItem) (tea.Model, tea.Cmd) {
+	site := htmlSite{
+		notesDir: m.notesDir,
+		outDir:   filepath.Join(os.TempDir(), "snsm-preview"),
+		// The other pages aren't rendered, the wikilinks stay text
+		index:   newNoteIndex(nil),
+		folders: newFolderConfigs(m.notesDir),
+		base:    fileURL(filepath.Dir(item.path)) + "/",
+	}
+	if err := site.renderNote(item.filename); err != nil {
+		return m, m.setError("Cannot render %s: %v", item.filename, describeError(err))
+	}
+	page := filepath.Join(site.outDir, htmlPath(item.filename))
+	if err := openExternal(page); err != nil {
+		return m, m.setError("%v", err)
+	}
+	return m, m.setInfo("Opened %s in the browser", item.filename)
+}
+
+// exportAndOpen exports a note to PDF in the background, as set in the
+// config, and opens the PDF
+func (m model) exportAndOpen(item noteItem) (tea.Model, tea.Cmd) {
+	cfg := m.cfg.Export
+	cfg.Format = "pdf"
+	e, err := newExporter(m.notesDir, cfg)
+	if err != nil {
+		return m, m.setError("Cannot export: %v", err)
+	}
+
+	return m, tea.Batch(m.setInfo("Exporting %s...", item.filename), func() tea.Msg {
+		paths, err := e.export([]string{item.filename})
+		if err == nil {
+			err = openExternal(paths[0])
+		}
+		return exportFinishedMsg{paths: paths, err: err}
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+)
+
+// orgKeyword parses an org-mode "#+KEY: value" line, the key being upper
+// cased
+func orgKeyword(line string) (key, value string, ok bool) {
+	if !strings.HasPrefix(line, "#+") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(line[2:], ":")
+	return strings.ToUpper(strings.TrimSpace(key)), strings.TrimSpace(value), ok
+}
+
+// orgTags turns the value of an org-mode tags keyword, either ":work:home:"
+// or "work home", into snsm tags
+func orgTags(value string) string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ':' || r == ' ' || r == '\t' }) {
+		tags = append(tags, "+"+strings.TrimPrefix(tag, "+"))
+	}
+	return extractTags(strings.Join(tags, " "))
+}
+
+// formatOrgTags writes tags the way of the org-mode tags keywords: ":work:home:"
+func formatOrgTags(tags string) string {
+	var names []string
+	for _, tag := range strings.Fields(tags) {
+		names = append(names, strings.TrimPrefix(tag, "+"))
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return ":" + strings.Join(names, ":") + ":"
+}
+
+// orgHeader is the top of a new org-mode note: its title and its tags
+func orgHeader(title, tags string) string {
+	header := "#+TITLE: " + title + "\n"
+	if tags := formatOrgTags(tags); tags != "" {
+		header += "#+FILETAGS: " + tags + "\n"
+	}
+	return header + "\n"
+}
+
+// withOrgTags adds tags to the #+FILETAGS keyword of an org-mode note,
+// writing it after the other keywords at its top when missing
+func withOrgTags(content, tags string) string {
+	lines := strings.Split(content, "\n")
+	insert := 0
+	for i, line := range lines {
+		key, value, ok := orgKeyword(strings.TrimSpace(line))
+		if !ok {
+			if strings.TrimSpace(line) != "" {
+				break
+			}
+			continue
+		}
+		if key == "FILETAGS" {
+			lines[i] = "#+FILETAGS: " + formatOrgTags(mergeTags(orgTags(value), formatTagsWithPlus(tags)))
+			return strings.Join(lines, "\n")
+		}
+		insert = i + 1
+	}
+
+	line := "#+FILETAGS: " + formatOrgTags(formatTagsWithPlus(tags))
+	lines = append(lines[:insert], append([]string{line}, lines[insert:]...)...)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pagerView scrolls through lines of text, like a diff or the output of a
+// command, going back to the mode it was opened from
+type pagerView struct {
+	title  string
+	lines  []string
+	offset int
+	back   int
+}
+
+// openPager shows lines of text
+func (m model) openPager(title string, lines []string) (tea.Model, tea.Cmd) {
+	m.pager = pagerView{title: title, lines: lines, back: m.mode}
+	m.mode = modePager
+	return m, nil
+}
+
+func (m model) updatePager(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	v := &m.pager
+	page := max(m.height-6, 1)
+	last := max(len(v.lines)-page, 0)
+
+	switch keyMsg.String() {
+	case "up", "k":
+		v.offset = max(v.offset-1, 0)
+	case "down", "j":
+		v.offset = min(v.offset+1, last)
+	case "pgup", "b", "u":
+		v.offset = max(v.offset-page, 0)
+	case "pgdown", "f", "d", " ":
+		v.offset = min(v.offset+page, last)
+	case "home", "g":
+		v.offset = 0
+	case "end", "G":
+		v.offset = last
+	case "esc", "q":
+		m.mode = v.back
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m model) viewPager() string {
+	var b strings.Builder
+	v := m.pager
+	b.WriteString("\n" + titleStyle.Render(v.title) + "\n\n")
+
+	height := max(m.height-6, 1)
+	offset := min(v.offset, max(len(v.lines)-height, 0))
+	end := min(offset+height, len(v.lines))
+	for i, line := range v.lines[offset:end] {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("  " + truncateWidth(line, max(m.width-2, 1)))
+	}
+
+	help := "j/k scroll • f/b page • g/G top/bottom • esc back"
+	if len(v.lines) > height {
+		help = fmt.Sprintf("%d%% • ", 100*end/len(v.lines)) + help
+	}
+	return m.withHelp(b.String(), help)
+}
+
+// scrollLines returns the lines fitting in height, scrolled for the line at
+// cursor to show
+func scrollLines(lines []string, cursor, height int) []string {
+	offset := max(cursor-height+1, 0)
+	return lines[offset:min(offset+height, len(lines))]
+}
+
+// withHelp pads a full screen view for its help to show on the bottom line
+func (m model) withHelp(view, help string) string {
+	if gap := m.height - lipgloss.Height(view) - 2; gap > 0 {
+		view += strings.Repeat("\n", gap)
+	}
+	return view + "\n\n" + helpStyle.Render(help)
+}
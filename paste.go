@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pngMagic starts every PNG file
+var pngMagic = []byte("\x89PNG\r\n\x1a\n")
+
+// pastedNote is a note built from the content of the clipboard
+type pastedNote struct {
+	// filename without extension, may hold %t
+	filename string
+	tags     string
+	// body is the whole note below the tag line, heading included
+	body string
+}
+
+// detectPaste builds a note from clipboard text: URLs become bookmarks, JSON
+// is fenced and prose is titled after its first sentence
+func detectPaste(text string) pastedNote {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+
+	if u, err := url.Parse(text); err == nil && (u.Scheme == "http" || u.Scheme == "https") &&
+		u.Host != "" && !strings.ContainsAny(text, " \t\n") {
+		title := u.Host + strings.TrimSuffix(u.Path, "/")
+		return pastedNote{
+			filename: slugify(title),
+			tags:     "bookmark",
+			body:     fmt.Sprintf("# %s\n\n<%s>\n", title, text),
+		}
+	}
+
+	if (strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[")) && json.Valid([]byte(text)) {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, []byte(text), "", "  "); err == nil {
+			text = indented.String()
+		}
+		return pastedNote{
+			filename: "%t-snippet",
+			body:     fmt.Sprintf("# Snippet %s\n\n```json\n%s\n```\n", time.Now().Format("2006-01-02"), text),
+		}
+	}
+
+	title := firstSentence(text)
+	filename := slugify(title)
+	if filename == "" {
+		filename = "%t-paste"
+	}
+
+	// Pasted markdown already has its heading
+	if strings.HasPrefix(text, "# ") {
+		return pastedNote{filename: filename, body: text + "\n"}
+	}
+	return pastedNote{
+		filename: filename,
+		body:     fmt.Sprintf("# %s\n\n%s\n", title, text),
+	}
+}
+
+// firstSentence returns the first sentence of a text, on a single line and
+// cut to a reasonable title length
+func firstSentence(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	// Skip the marker of a pasted markdown heading
+	line = strings.TrimSpace(strings.TrimLeft(line, "# "))
+
+	if end := strings.IndexAny(line, ".!?"); end > 0 {
+		line = line[:end]
+	}
+
+	const maxLength = 60
+	if runes := []rune(line); len(runes) > maxLength {
+		line = strings.TrimSpace(string(runes[:maxLength]))
+	}
+	return line
+}
+
+// imageNote builds the note of a pasted image saved as an attachment, the
+// link being relative to the note
+func imageNote(attachment string) pastedNote {
+	return pastedNote{
+		filename: "%t-image",
+		body:     fmt.Sprintf("# Image %s\n\n![](%s)\n", time.Now().Format("2006-01-02"), filepath.ToSlash(attachment)),
+	}
+}
+
+// readClipboardImage returns the PNG image held by the clipboard, if any,
+// using the clipboard tool of the platform
+func readClipboardImage() ([]byte, bool) {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pngpaste", "-"}}
+	case "windows":
+		return nil, false
+	default:
+		candidates = [][]string{
+			{"wl-paste", "--no-newline", "--type", "image/png"},
+			{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"},
+		}
+	}
+
+	for _, args := range candidates {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		out, err := exec.Command(args[0], args[1:]...).Output()
+		if err == nil && bytes.HasPrefix(out, pngMagic) {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+// pasteNote creates a note in a folder of the notes directory from the
+// clipboard and returns its filename relative to the notes directory
+func pasteNote(notesDir, folder, tags string) (string, error) {
+	cleaned, ok := cleanFolder(folder)
+	if !ok {
+		return "", fmt.Errorf("folder %s is outside of the notes directory", folder)
+	}
+	folder = cleaned
+
+	var note pastedNote
+	if image, ok := readClipboardImage(); ok {
+		attachment, err := saveAttachment(notesDir, expandTimestamp("%t-image.png"), image)
+		if err != nil {
+			return "", err
+		}
+		link, err := filepath.Rel(filepath.Join(notesDir, folder), filepath.Join(notesDir, attachment))
+		if err != nil {
+			return "", err
+		}
+		note = imageNote(link)
+	} else {
+		text, err := clipboard.ReadAll()
+		if err != nil {
+			return "", fmt.Errorf("failed to read the clipboard: %v", err)
+		}
+		if strings.TrimSpace(text) == "" {
+			return "", fmt.Errorf("the clipboard is empty")
+		}
+		note = detectPaste(text)
+	}
+
+	filename := uniqueFilename(notesDir, filepath.Join(folder, expandTimestamp(note.filename)), ".md")
+	if err := createNote(filepath.Join(notesDir, filename), mergeTags(formatTagsWithPlus(note.tags), formatTagsWithPlus(tags)), note.body); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// uniqueFilename appends a number to a filename relative to dir until no
+// file has that name, and returns it with its extension
+func uniqueFilename(dir, name, ext string) string {
+	filename := name + ext
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, filename)); os.IsNotExist(err) {
+			return filename
+		}
+		filename = fmt.Sprintf("%s-%d%s", name, i, ext)
+	}
+}
+
+// pasteFromClipboard creates a note from the clipboard and opens it
+func (m model) pasteFromClipboard() (tea.Model, tea.Cmd) {
+	filename, err := pasteNote(m.notesDir, m.cfg.NewNote.Defaults.Folder, m.cfg.NewNote.Defaults.Tags)
+	if err != nil {
+		return m, m.setError("Cannot paste: %v", err)
+	}
+	return m, tea.Batch(m.reloadNotes(), m.noteSavedCmd("post_create", filename, "Created "+filename), m.openNote(filename))
+}
+
+func runPaste(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("paste", flag.ContinueOnError)
+	folder := flags.String("folder", cfg.NewNote.Defaults.Folder, "folder to create the note in")
+	tags := flags.String("tags", cfg.NewNote.Defaults.Tags, "tags of the note, e.g. \"work todo\"")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	filename, err := pasteNote(notesDir, *folder, *tags)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created %s\n", filepath.Join(notesDir, filename))
+	return noteSaved("post_create", notesDir, filename)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Lengths of the periods of the periodic notes
+const (
+	periodDay     = "day"
+	periodWeek    = "week"
+	periodMonth   = "month"
+	periodQuarter = "quarter"
+)
+
+// Markers of the list of the daily notes of a period, written in place of
+// {{days}} and brought up to date whenever the note is opened from snsm
+const (
+	daysStart = "<!-- snsm:days -->"
+	daysEnd   = "<!-- /snsm:days -->"
+)
+
+// periodicConfig configures the notes of the days, weeks, months and
+// quarters
+type periodicConfig struct {
+	// Daily takes its path from daily_notes
+	Daily     periodicNoteConfig `yaml:"daily"`
+	Weekly    periodicNoteConfig `yaml:"weekly"`
+	Monthly   periodicNoteConfig `yaml:"monthly"`
+	Quarterly periodicNoteConfig `yaml:"quarterly"`
+}
+
+type periodicNoteConfig struct {
+	// Path is the filename of the notes relative to the notes directory, %t
+	// being replaced by the period: 2024-W23, 2024-06 or 2024-Q2
+	Path string `yaml:"path"`
+	// Template is the template of the new notes, from the templates
+	// directory
+	Template string `yaml:"template"`
+}
+
+// validate checks the paths of the periodic notes
+func (c periodicConfig) validate() error {
+	if c.Daily.Path != "" {
+		return fmt.Errorf("the path of the daily notes is set with daily_notes")
+	}
+	for name, p := range map[string]periodicNoteConfig{"weekly": c.Weekly, "monthly": c.Monthly, "quarterly": c.Quarterly} {
+		if strings.Count(p.Path, "%t") != 1 {
+			return fmt.Errorf("periodic_notes %s path needs %%t once, replaced by the period", name)
+		}
+	}
+	return nil
+}
+
+// period is a day, a week, a month or a quarter
+type period struct {
+	kind  string
+	start time.Time
+}
+
+// periodOf returns the period of a kind holding a day, weeks starting on
+// monday like ISO weeks
+func periodOf(kind string, day time.Time) period {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	switch kind {
+	case periodWeek:
+		day = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case periodMonth:
+		day = day.AddDate(0, 0, 1-day.Day())
+	case periodQuarter:
+		day = time.Date(day.Year(), (day.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.Local)
+	}
+	return period{kind: kind, start: day}
+}
+
+// shift returns the period n periods later, or earlier when n is negative
+func (p period) shift(n int) period {
+	switch p.kind {
+	case periodWeek:
+		return period{kind: p.kind, start: p.start.AddDate(0, 0, 7*n)}
+	case periodMonth:
+		return period{kind: p.kind, start: p.start.AddDate(0, n, 0)}
+	case periodQuarter:
+		return period{kind: p.kind, start: p.start.AddDate(0, 3*n, 0)}
+	}
+	return period{kind: p.kind, start: p.start.AddDate(0, 0, n)}
+}
+
+// end returns the start of the next period
+func (p period) end() time.Time {
+	return p.shift(1).start
+}
+
+// key names the period in the filenames: 2024-06-03, 2024-W23, 2024-06 or
+// 2024-Q2
+func (p period) key() string {
+	switch p.kind {
+	case periodWeek:
+		year, week := p.start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case periodMonth:
+		return p.start.Format("2006-01")
+	case periodQuarter:
+		return fmt.Sprintf("%d-Q%d", p.start.Year(), (p.start.Month()-1)/3+1)
+	}
+	return p.start.Format("2006-01-02")
+}
+
+// title is the title of a new note of the period
+func (p period) title() string {
+	switch p.kind {
+	case periodWeek:
+		year, week := p.start.ISOWeek()
+		return fmt.Sprintf("Week %d, %d", week, year)
+	case periodMonth:
+		return p.start.Format("January 2006")
+	case periodQuarter:
+		return fmt.Sprintf("Q%d %d", (p.start.Month()-1)/3+1, p.start.Year())
+	}
+	return p.start.Format("2006-01-02")
+}
+
+// periodicNote returns the settings of the notes of a kind of period
+func (m model) periodicNote(kind string) periodicNoteConfig {
+	switch kind {
+	case periodWeek:
+		return m.cfg.PeriodicNotes.Weekly
+	case periodMonth:
+		return m.cfg.PeriodicNotes.Monthly
+	case periodQuarter:
+		return m.cfg.PeriodicNotes.Quarterly
+	}
+	return periodicNoteConfig{Path: m.cfg.DailyNotes, Template: m.cfg.PeriodicNotes.Daily.Template}
+}
+
+// daysList links the daily notes of a period, between the markers
+func (m model) daysList(p period) string {
+	notes := dailyNotes(m.items, m.cfg.DailyNotes)
+	idx := newNoteIndex(itemFilenames(m.items))
+	lines := []string{daysStart}
+	for day := p.start; day.Before(p.end()); day = day.AddDate(0, 0, 1) {
+		if note, ok := notes[day.Format("2006-01-02")]; ok {
+			lines = append(lines, fmt.Sprintf("- [[%s]] %s", linkTarget(idx, note.filename), day.Format("Monday 2 January")))
+		}
+	}
+	return strings.Join(append(lines, daysEnd), "\n")
+}
+
+// periodicBody returns the content of a new note of a period: its template,
+// or a heading followed by the daily notes for the longer periods
+func (m model) periodicBody(p period) (string, error) {
+	c := m.periodicNote(p.kind)
+	if c.Template == "" {
+		if p.kind == periodDay {
+			return "", nil
+		}
+		return "# " + p.title() + "\n\n## Days\n" + m.daysList(p) + "\n", nil
+	}
+	body, err := loadTemplate(m.templatesDir(), c.Template, m.noteExt(), p.title())
+	if err != nil {
+		return "", err
+	}
+	body = strings.ReplaceAll(body, "{{period}}", p.key())
+	return strings.ReplaceAll(body, "{{days}}", m.daysList(p)), nil
+}
+
+// periodicFilename returns the note of a period, relative to the notes
+// directory, creating it when it doesn't exist. The list of the daily
+// notes of an existing note is brought up to date.
+func (m model) periodicFilename(p period) (string, bool, error) {
+	path, err := notePath(m.notesDir, strings.Replace(m.periodicNote(p.kind).Path, "%t", p.key(), 1))
+	if err != nil {
+		return "", false, err
+	}
+	filename, _ := filepath.Rel(m.notesDir, path)
+
+	if _, err := os.Stat(path); err == nil {
+		if p.kind != periodDay {
+			if err := refreshDaysList(path, m.daysList(p)); err != nil {
+				return "", false, err
+			}
+		}
+		return filename, false, nil
+	}
+
+	body, err := m.periodicBody(p)
+	if err != nil {
+		return "", false, err
+	}
+	if err := createTitledNote(path, p.title(), "", body); err != nil {
+		return "", false, describeError(err)
+	}
+	return filename, true, nil
+}
+
+// refreshDaysList replaces the list of the daily notes of a periodic note,
+// leaving the notes without one alone
+func refreshDaysList(path, list string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content := string(data)
+	start := strings.Index(content, daysStart)
+	end := strings.Index(content, daysEnd)
+	if start < 0 || end < start {
+		return nil
+	}
+	updated := content[:start] + list + content[end+len(daysEnd):]
+	if updated == content {
+		return nil
+	}
+	return os.WriteFile(path, []byte(updated), 0644)
+}
+
+// openPeriod opens the note of a period in $EDITOR, creating it if needed
+func (m model) openPeriod(p period) (tea.Model, tea.Cmd) {
+	_, cmd := m.openPeriodNote(p)
+	return m, cmd
+}
+
+// openPeriodNote opens the note of a period like openPeriod, returning its
+// filename, empty when it couldn't be created
+func (m *model) openPeriodNote(p period) (string, tea.Cmd) {
+	filename, created, err := m.periodicFilename(p)
+	if err != nil {
+		return "", m.setError("Cannot open the note of %s: %v", p.key(), err)
+	}
+	if !created {
+		return filename, m.openNote(filename)
+	}
+	return filename, tea.Batch(m.reloadNotes(), m.noteSavedCmd("post_create", filename, "Created "+filename), m.openNote(filename))
+}
+
+// periodOffset reads the period asked for relative to the current one:
+// this, last, next or a number like -2
+func periodOffset(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	switch args[0] {
+	case "this":
+		return 0, nil
+	case "last", "previous":
+		return -1, nil
+	case "next":
+		return 1, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("expected this, last, next or a number like -2, not %q", args[0])
+	}
+	return n, nil
+}
+
+// exPeriod returns the command of the command line opening the notes of a
+// kind of period, like :week last
+func exPeriod(kind string) func(m model, args []string) (tea.Model, tea.Cmd) {
+	return func(m model, args []string) (tea.Model, tea.Cmd) {
+		n, err := periodOffset(args)
+		if err != nil {
+			return m, m.setError(":%s %v", kind, err)
+		}
+		return m.openPeriod(periodOf(kind, today()).shift(n))
+	}
+}
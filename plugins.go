@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pluginsDir returns the folder of the plugins, next to the config file
+func pluginsDir() string {
+	return filepath.Join(filepath.Dir(configPath()), "plugins")
+}
+
+// pluginRequest is the JSON a plugin reads on its stdin
+type pluginRequest struct {
+	Version int `json:"version"`
+	// Event is menu, run, post_create, post_edit or pre_delete
+	Event    string     `json:"event"`
+	NotesDir string     `json:"notes_dir"`
+	Note     pluginNote `json:"note"`
+	// Item is the menu item chosen, for the run event
+	Item string `json:"item,omitempty"`
+}
+
+type pluginNote struct {
+	Path     string   `json:"path"`
+	Filename string   `json:"filename"`
+	Title    string   `json:"title"`
+	Tags     []string `json:"tags"`
+}
+
+// pluginResponse is the JSON a plugin may write on its stdout, every field
+// being optional
+type pluginResponse struct {
+	// Menu adds items to the command palette, for the menu event
+	Menu       []string `json:"menu"`
+	AddTags    []string `json:"add_tags"`
+	RemoveTags []string `json:"remove_tags"`
+	// Veto cancels the deletion with a reason, for the pre_delete event
+	Veto    string `json:"veto"`
+	Message string `json:"message"`
+}
+
+// listPlugins returns the executables of the plugins folder, by name
+func listPlugins() []string {
+	dir := pluginsDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var plugins []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || strings.HasPrefix(entry.Name(), ".") || !info.Mode().IsRegular() {
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+			continue
+		}
+		plugins = append(plugins, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(plugins)
+	return plugins
+}
+
+// newPluginRequest describes an event on a note to the plugins
+func newPluginRequest(event, notesDir, filename string) pluginRequest {
+	path := filepath.Join(notesDir, filename)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	tags, _, _ := readHeader(path)
+	item := noteItem{path: path, filename: filename, tags: tags, folderTags: newFolderConfigs(notesDir).tags(filename)}
+
+	note := pluginNote{Path: path, Filename: filepath.ToSlash(filename), Title: itemTitle(item), Tags: []string{}}
+	for _, tag := range strings.Fields(item.allTags()) {
+		note.Tags = append(note.Tags, strings.TrimPrefix(tag, "+"))
+	}
+	return pluginRequest{Version: 1, Event: event, NotesDir: notesDir, Note: note}
+}
+
+// callPlugin runs a plugin on a request and applies the tags it changes
+func callPlugin(plugin string, req pluginRequest) (pluginResponse, error) {
+	var resp pluginResponse
+	name := filepath.Base(plugin)
+	input, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, plugin)
+	cmd.Dir = req.NotesDir
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return resp, fmt.Errorf("plugin %s: %v%s", name, err, hookOutput(stderr.String()))
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return resp, nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return resp, fmt.Errorf("plugin %s answered invalid JSON: %v", name, err)
+	}
+	if len(resp.AddTags) > 0 || len(resp.RemoveTags) > 0 {
+		if err := retagNote(req.Note.Path, resp.AddTags, resp.RemoveTags); err != nil {
+			return resp, fmt.Errorf("plugin %s: cannot change the tags: %v", name, err)
+		}
+	}
+	return resp, nil
+}
+
+// runPlugins sends a lifecycle event to every plugin. A plugin vetoing a
+// deletion stops it.
+func runPlugins(event, notesDir, filename string) error {
+	plugins := listPlugins()
+	if len(plugins) == 0 {
+		return nil
+	}
+
+	req := newPluginRequest(event, notesDir, filename)
+	for _, plugin := range plugins {
+		resp, err := callPlugin(plugin, req)
+		if err != nil {
+			return err
+		}
+		if event == "pre_delete" && resp.Veto != "" {
+			return fmt.Errorf("plugin %s refused: %s", filepath.Base(plugin), resp.Veto)
+		}
+	}
+	return nil
+}
+
+// retagNote adds and removes tags of a note
+func retagNote(path string, add, remove []string) error {
+	var removed []string
+	for _, tag := range remove {
+		removed = append(removed, strings.TrimPrefix(tag, "+"))
+	}
+
+	return rewriteTags(path, func(existing string) string {
+		var kept []string
+		for _, tag := range strings.Fields(existing) {
+			if !containsTag(removed, strings.TrimPrefix(tag, "+")) {
+				kept = append(kept, tag)
+			}
+		}
+		return mergeTags(strings.Join(kept, " "), formatTagsWithPlus(strings.Join(add, " ")))
+	})
+}
+
+// pluginItem is an entry a plugin added to the command palette
+type pluginItem struct {
+	plugin string
+	item   string
+}
+
+// pluginMenuKey is the plugin and the note of entries of the palette
+type pluginMenuKey struct {
+	plugin   string
+	filename string
+}
+
+// pluginMenus caches the entries the plugins last added to the palette for
+// each note, shown right away while the plugins are asked again
+var pluginMenus = map[pluginMenuKey][]string{}
+
+// pluginMenuMsg is sent when a plugin answered the menu event
+type pluginMenuMsg struct {
+	plugin   string
+	filename string
+	items    []string
+	err      error
+}
+
+// queryPluginMenu asks a plugin for its entries of the palette in the
+// background, a slow plugin leaving the palette usable
+func queryPluginMenu(plugin, notesDir, filename string) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := callPlugin(plugin, newPluginRequest("menu", notesDir, filename))
+		return pluginMenuMsg{plugin: plugin, filename: filename, items: resp.Menu, err: err}
+	}
+}
+
+// cachedPluginMenu returns the cached entries of the plugins for a note
+func cachedPluginMenu(plugins []string, filename string) []pluginItem {
+	var items []pluginItem
+	for _, plugin := range plugins {
+		for _, item := range pluginMenus[pluginMenuKey{plugin: plugin, filename: filename}] {
+			items = append(items, pluginItem{plugin: plugin, item: item})
+		}
+	}
+	return items
+}
+
+// pluginMenuAnswered caches the entries of a plugin, adding them to the
+// palette when it is still open on the note
+func (m model) pluginMenuAnswered(msg pluginMenuMsg) (tea.Model, tea.Cmd) {
+	if msg.err == nil {
+		pluginMenus[pluginMenuKey{plugin: msg.plugin, filename: msg.filename}] = msg.items
+	}
+	if m.mode != modePalette || m.paletteNote != msg.filename {
+		return m, nil
+	}
+	m.palettePending = max(m.palettePending-1, 0)
+	m.setPaletteItems()
+	if msg.err != nil {
+		return m, m.setError("%v", msg.err)
+	}
+	return m, nil
+}
+
+// pluginFinishedMsg is sent when a plugin ran an entry of the palette
+type pluginFinishedMsg struct {
+	item     string
+	filename string
+	message  string
+	err      error
+	// before is the content of the note before the plugin changed it, for u
+	before []byte
+}
+
+// runPluginItem runs the entry of a plugin chosen in the palette
+func runPluginItem(p pluginItem, notesDir, filename string) tea.Cmd {
+	return func() tea.Msg {
+		req := newPluginRequest("run", notesDir, filename)
+		req.Item = p.item
+		var resp pluginResponse
+		before, err := runChanging(notesDir, filename, func() (err error) {
+			resp, err = callPlugin(p.plugin, req)
+			return err
+		})
+		return pluginFinishedMsg{item: p.item, filename: filename, message: resp.Message, err: err, before: before}
+	}
+}
+
+// pluginFinished reports what a plugin did
+func (m model) pluginFinished(msg pluginFinishedMsg) (tea.Model, tea.Cmd) {
+	if msg.before != nil {
+		m.pushUndo(msg.item+" on "+msg.filename, restoreContent(m.notesDir, msg.filename, msg.before))
+	}
+	if msg.err != nil {
+		return m, tea.Batch(m.reloadNotes(), m.setError("%s failed on %s: %v", msg.item, msg.filename, msg.err))
+	}
+	if msg.message != "" {
+		return m, tea.Batch(m.reloadNotes(), m.setInfo("%s", msg.message))
+	}
+	return m, tea.Batch(m.reloadNotes(), m.setInfo("Ran %s on %s", msg.item, msg.filename))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// exitCanceled is the exit code of snsm --popup closed without choosing a
+// note, the one of a shell command interrupted with ctrl+c
+const exitCanceled = 130
+
+// setupPopup fits the list to a popup: one line per note, no preview, no
+// columns and no help footer, the filter taking the keys right away
+func (m *model) setupPopup() {
+	m.popup = true
+	m.preview = false
+	m.columns.shown = false
+	m.setDensity(densityCompact)
+	m.list.SetShowHelp(false)
+}
+
+// pickNote ends the popup with notes chosen, whichever way they were opened
+func (m *model) pickNote(filenames ...string) tea.Cmd {
+	m.picked = filenames
+	m.quitting = true
+	return tea.Quit
+}
+
+// popupOptions draw the popup on the terminal rather than on stdout, which
+// the path of the chosen note goes to
+func popupOptions() ([]tea.ProgramOption, func(), error) {
+	in, out, err := terminalFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	if out == os.Stdout {
+		return nil, func() {}, nil
+	}
+	// Colors follow the terminal drawn on rather than stdout
+	lipgloss.SetDefaultRenderer(lipgloss.NewRenderer(out))
+	return []tea.ProgramOption{tea.WithInput(in), tea.WithOutput(out)}, func() { out.Close() }, nil
+}
+
+// finishPopup prints the paths of the chosen notes, one per line, and
+// returns the exit code telling whether one was chosen
+func finishPopup(m model) int {
+	if len(m.picked) == 0 {
+		return exitCanceled
+	}
+	for _, filename := range m.picked {
+		path, err := filepath.Abs(filepath.Join(m.notesDir, filename))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(path)
+	}
+	return 0
+}
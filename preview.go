@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// minPreviewWidth is the narrowest terminal the preview pane is shown in
+const minPreviewWidth = 60
+
+// previewShown reports whether the preview pane is displayed next to the list
+func (m model) previewShown() bool {
+	return m.preview && m.width >= minPreviewWidth
+}
+
+// layout sizes the list to the screen, leaving room for the preview pane or
+// the columns
+func (m *model) layout() {
+	width := m.width
+	switch {
+	case m.columnsShown():
+		width = m.width * m.columns.notes / 100
+	case m.previewShown():
+		width = m.width * 2 / 5
+	}
+	// Minus 1 for the status bar
+	m.list.SetHeight(m.height - 1)
+	m.list.SetWidth(width)
+	if m.mode == modeEditor {
+		m.resizeEditor()
+	}
+}
+
+// togglePreview shows or hides the preview pane
+func (m *model) togglePreview() {
+	m.preview = !m.preview
+	m.previewScroll = 0
+	m.layout()
+}
+
+// scrollPreview scrolls the preview pane by a number of lines
+func (m *model) scrollPreview(lines int) {
+	m.previewScroll = max(m.previewScroll+lines, 0)
+}
+
+// syncPreview scrolls the preview back to the top when another note is
+// selected
+func (m *model) syncPreview() {
+	item, _ := m.list.SelectedItem().(noteItem)
+	if item.path != m.previewPath {
+		m.previewPath = item.path
+		m.previewScroll = 0
+	}
+}
+
+// previewView renders the selected note in a pane of the given size
+func (m model) previewView(width, height int) string {
+	return m.previewPane(width, height, nil)
+}
+
+// previewPane renders the selected note in a pane of the given size, below
+// some header lines
+func (m model) previewPane(width, height int, header []string) string {
+	style := lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(lipgloss.Color(currentTheme.Muted)).
+		PaddingLeft(1).
+		Height(height).
+		MaxHeight(height)
+	// Room for the border and the padding
+	width -= 2
+
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok || width < 10 {
+		return style.Render("")
+	}
+
+	lines := renderedPreview.get(item, width)
+	for i, line := range header {
+		header[i] = truncateWidth(line, width)
+	}
+	height = max(height-len(header), 1)
+
+	// Keep the end of the note on screen when scrolled past it
+	offset := min(m.previewScroll, max(len(lines)-height, 0))
+	end := min(offset+height, len(lines))
+
+	return style.Render(strings.Join(append(header, lines[offset:end]...), "\n"))
+}
+
+// previewCache holds the rendered lines of the note last previewed, which is
+// drawn again on every frame while scrolling through the list
+type previewCache struct {
+	path    string
+	modTime time.Time
+	tags    string
+	width   int
+	lines   []string
+}
+
+var renderedPreview previewCache
+
+// get returns the lines of the preview of a note, rendering it when another
+// note is previewed, it changed or the pane was resized
+func (c *previewCache) get(item noteItem, width int) []string {
+	if c.lines != nil && c.path == item.path && c.modTime.Equal(item.modTime) && c.tags == item.allTags() && c.width == width {
+		return c.lines
+	}
+
+	content := noteContents.get(item)
+	_, body := splitTagLine(content)
+	body = stripFrontMatter(body)
+	lines := []string{titleStyle.Copy().MarginLeft(0).Bold(true).Render(truncateWidth(documentTitle(item.filename, body), width))}
+	if tags := item.allTags(); tags != "" {
+		lines = append(lines, mutedStyle.Render(truncateWidth(tags, width)))
+	}
+	lines = append(lines, mutedStyle.Render(truncateWidth(countStats(content).describe(), width)))
+	lines = append(lines, "")
+	lines = append(lines, termRenderer{width: width}.render(body)...)
+
+	*c = previewCache{path: item.path, modTime: item.modTime, tags: item.allTags(), width: width, lines: lines}
+	return lines
+}
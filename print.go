@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// printConfig sets how notes are printed with :print or snsm print
+type printConfig struct {
+	// PageSize is the size of the paper, like A4 (default) or Letter
+	PageSize string `yaml:"page_size"`
+	// Margin is the margin around the page, like 20mm (default) or 1in
+	Margin string `yaml:"margin"`
+	// Command sends a PDF to the printer, given its path after the
+	// arguments: lpr by default
+	Command string `yaml:"command"`
+}
+
+// printCSS is added to the stylesheet of printed notes: black on white, the
+// code blocks wrapped at the edge of the paper rather than cut, and headings
+// kept with what follows them
+const printCSS = `body { font-size: 11pt; color: #000; background: #fff; }
+main { max-width: none; margin: 0; padding: 0; }
+pre, code { font-family: "DejaVu Sans Mono", Menlo, Consolas, monospace; background: none; }
+pre { white-space: pre-wrap; word-wrap: break-word; border: 1px solid #d0d7de; }
+a, .wikilink { color: #000; }
+h1, h2, h3, h4 { page-break-after: avoid; }
+pre, blockquote, table, img { page-break-inside: avoid; }
+`
+
+// newPrinter returns the exporter of the PDFs to print, written to outDir
+func newPrinter(notesDir, outDir string, cfg config) (exporter, error) {
+	export := cfg.Export
+	export.Dir, export.Format = outDir, "pdf"
+	e, err := newExporter(notesDir, export)
+	if err != nil {
+		return e, err
+	}
+	if e.css == "" {
+		e.css = pageCSS
+	}
+	e.css += printCSS
+	e.pageSize, e.margin = cfg.Print.PageSize, cfg.Print.Margin
+	return e, nil
+}
+
+// sendToPrinter prints a PDF with the print command of the config
+func sendToPrinter(cfg printConfig, pdf string) error {
+	command := splitCommandLine(cfg.Command)
+	if len(command) == 0 {
+		command = []string{"lpr"}
+	}
+	cmd := exec.Command(command[0], append(command[1:len(command):len(command)], pdf)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v %s", command[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// printNotes prints notes, or saves their PDFs to the directory given, and
+// returns the paths of the saved PDFs
+func printNotes(cfg config, notesDir string, filenames []string, saveDir string) ([]string, error) {
+	outDir := saveDir
+	if outDir == "" {
+		tmp, err := os.MkdirTemp("", "snsm-print-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+		outDir = tmp
+	}
+
+	e, err := newPrinter(notesDir, outDir, cfg)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := e.export(filenames)
+	if err != nil || saveDir != "" {
+		return paths, err
+	}
+	for _, path := range paths {
+		if err := sendToPrinter(cfg.Print, path); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// printFinishedMsg is sent once the selected note was printed or saved
+type printFinishedMsg struct {
+	filename string
+	saved    []string
+	err      error
+}
+
+// exPrint prints the selected note, or saves its PDF with :print save
+func exPrint(m model, args []string) (tea.Model, tea.Cmd) {
+	item, err := m.selectedNote("print")
+	if err != nil {
+		return m, m.setError("%v", err)
+	}
+	var saveDir string
+	switch strings.Join(args, " ") {
+	case "":
+	case "save":
+		saveDir = m.cfg.Export.Dir
+		if saveDir == "" {
+			saveDir = "."
+		}
+	default:
+		return m, m.setError(":print takes nothing or save")
+	}
+
+	cfg, notesDir := m.cfg, m.notesDir
+	return m, tea.Batch(m.setInfo("Printing %s...", item.filename), func() tea.Msg {
+		saved, err := printNotes(cfg, notesDir, []string{item.filename}, saveDir)
+		return printFinishedMsg{filename: item.filename, saved: saved, err: err}
+	})
+}
+
+// printFinished tells where the note went
+func (m model) printFinished(msg printFinishedMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.err != nil:
+		return m, m.setError("Cannot print %s: %v", msg.filename, msg.err)
+	case len(msg.saved) > 0:
+		return m, m.setInfo("Saved %s", strings.Join(msg.saved, ", "))
+	}
+	return m, m.setInfo("Sent %s to the printer", msg.filename)
+}
+
+func runPrint(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("print", flag.ContinueOnError)
+	save := flags.Bool("save", false, "save the PDFs rather than printing them")
+	out := flags.String("out", cfg.Export.Dir, "directory to save the PDFs to, with --save")
+	pageSize := flags.String("page-size", cfg.Print.PageSize, "size of the paper, like A4 or Letter")
+	margin := flags.String("margin", cfg.Print.Margin, "margin around the page, like 20mm or 1in")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no note to print")
+	}
+	cfg.Print.PageSize, cfg.Print.Margin = *pageSize, *margin
+
+	var filenames []string
+	for _, filename := range flags.Args() {
+		filenames = append(filenames, noteFilename(notesDir, filename))
+	}
+
+	saveDir := ""
+	if *save {
+		saveDir = *out
+		if saveDir == "" {
+			saveDir = "."
+		}
+	}
+	saved, err := printNotes(cfg, notesDir, filenames, saveDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range saved {
+		fmt.Printf("Saved %s\n", path)
+	}
+	if saveDir == "" {
+		fmt.Printf("Sent %s to the printer\n", pluralNotes(len(filenames)))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	rtrace "runtime/trace"
+)
+
+// startProfile starts profiling the run, kind being cpu, mem or trace, and
+// returns the function writing the profile to the current directory
+func startProfile(kind string) (func(), error) {
+	var path string
+	switch kind {
+	case "cpu", "mem":
+		path = "snsm-" + kind + ".pprof"
+	case "trace":
+		path = "snsm.trace"
+	default:
+		return nil, fmt.Errorf("unknown profile %q, expected cpu, mem or trace", kind)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot write the profile: %v", err)
+	}
+
+	var stop func() error
+	switch kind {
+	case "cpu":
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+		stop = func() error {
+			pprof.StopCPUProfile()
+			return nil
+		}
+	case "mem":
+		// The heap profile is taken when the run ends
+		stop = func() error {
+			runtime.GC()
+			return pprof.WriteHeapProfile(file)
+		}
+	case "trace":
+		if err := rtrace.Start(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+		stop = func() error {
+			rtrace.Stop()
+			return nil
+		}
+	}
+
+	stopped := false
+	return func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		err := stop()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot write the %s profile: %v\n", kind, err)
+			return
+		}
+		tool := "go tool pprof"
+		if kind == "trace" {
+			tool = "go tool trace"
+		}
+		fmt.Fprintf(os.Stderr, "Wrote the %s profile to %s, open it with %s\n", kind, path, tool)
+	}, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tagPagePath returns the path of the page listing the notes of a tag,
+// relative to the site
+func tagPagePath(tag string) string {
+	return filepath.Join("tags", slugify(tag)+".html")
+}
+
+// publishedNote is an entry of the index and tag pages
+type publishedNote struct {
+	filename string
+	title    string
+	tags     []string
+}
+
+// publishSite renders every note of a site along with an index page and a
+// page per tag, and returns the number of notes published
+func publishSite(site htmlSite, files []noteItem) (int, error) {
+	var notes []publishedNote
+	byTag := map[string][]publishedNote{}
+
+	for _, item := range files {
+		if item.readErr != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", item.filename, describeError(item.readErr))
+			continue
+		}
+		data, err := os.ReadFile(item.path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", item.filename, describeError(err))
+			continue
+		}
+		if err := site.renderNote(item.filename); err != nil {
+			return 0, fmt.Errorf("failed to render %s: %v", item.filename, describeError(err))
+		}
+
+		_, body := splitTagLine(string(data))
+		body = stripFrontMatter(body)
+		note := publishedNote{filename: item.filename, title: documentTitle(item.filename, body)}
+		for _, tag := range strings.Fields(item.allTags()) {
+			tag = strings.TrimPrefix(tag, "+")
+			note.tags = append(note.tags, tag)
+		}
+		for _, tag := range note.tags {
+			byTag[tag] = append(byTag[tag], note)
+		}
+		notes = append(notes, note)
+	}
+
+	tags := make([]string, 0, len(byTag))
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	// The index lists the tags, then every note by path
+	var body strings.Builder
+	if len(tags) > 0 {
+		body.WriteString("<h2 id=\"tags\">Tags</h2>\n<p class=\"tags\">")
+		for _, tag := range tags {
+			fmt.Fprintf(&body, "<a class=\"tag\" href=\"%s\">%s (%d)</a>",
+				html.EscapeString(filepath.ToSlash(tagPagePath(tag))), html.EscapeString(tag), len(byTag[tag]))
+		}
+		body.WriteString("</p>\n")
+	}
+	body.WriteString("<h2 id=\"notes\">Notes</h2>\n")
+	writeNoteList(&body, "index.html", notes)
+
+	if err := site.writeListPage("index.html", "Notes", "", body.String()); err != nil {
+		return 0, err
+	}
+
+	for _, tag := range tags {
+		var body strings.Builder
+		writeNoteList(&body, tagPagePath(tag), byTag[tag])
+		if err := site.writeListPage(tagPagePath(tag), "Tag: "+tag, "../index.html", body.String()); err != nil {
+			return 0, err
+		}
+	}
+
+	// Serve the pages as they are on GitHub Pages
+	if err := os.WriteFile(filepath.Join(site.outDir, ".nojekyll"), nil, 0644); err != nil {
+		return 0, err
+	}
+
+	return len(notes), nil
+}
+
+// writeNoteList writes the links to notes from the page at path
+func writeNoteList(b *strings.Builder, path string, notes []publishedNote) {
+	b.WriteString("<ul>\n")
+	for _, note := range notes {
+		fmt.Fprintf(b, "<li><a href=\"%s\">%s</a>",
+			html.EscapeString(relativeHref(path, htmlPath(note.filename))), html.EscapeString(note.title))
+		if folder := filepath.Dir(note.filename); folder != "." {
+			fmt.Fprintf(b, " <small>%s</small>", html.EscapeString(filepath.ToSlash(folder)))
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n")
+}
+
+// writeListPage writes a page of the site that isn't a note
+func (s htmlSite) writeListPage(path, title, home, body string) error {
+	outPath := filepath.Join(s.outDir, path)
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return s.writeList(file, title, home, body)
+}
+
+// writeList renders a page that isn't a note to w
+func (s htmlSite) writeList(w io.Writer, title, home, body string) error {
+	css := s.css
+	if css == "" {
+		css = pageCSS
+	}
+
+	return pageTemplate.Execute(w, page{
+		Title: title,
+		Home:  home,
+		CSS:   template.CSS(css),
+		Body:  template.HTML("<h1>" + html.EscapeString(title) + "</h1>\n" + body),
+	})
+}
+
+func runPublish(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("publish", flag.ContinueOnError)
+	out := flags.String("out", "./site", "directory to write the site to")
+	css := flags.String("css", cfg.Export.CSS, "stylesheet replacing the default one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	files, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+
+	filenames := make([]string, len(files))
+	for i, item := range files {
+		filenames[i] = item.filename
+	}
+
+	site := htmlSite{
+		notesDir:  notesDir,
+		outDir:    *out,
+		index:     newNoteIndex(filenames).withAliases(files),
+		folders:   newFolderConfigs(notesDir),
+		published: true,
+	}
+	if *css != "" {
+		data, err := os.ReadFile(expandTilde(*css))
+		if err != nil {
+			return fmt.Errorf("failed to read stylesheet: %v", err)
+		}
+		site.css = string(data)
+	}
+
+	count, err := publishSite(site, files)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Published %d notes to %s\n", count, *out)
+	return nil
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// query is a parsed search, matching the notes that satisfy all of its terms:
+//
+//	tag:work -tag:done title:meeting modified:>2024-01-01 "exact phrase"
+//
+// Terms are separated by spaces, the AND keyword being optional, and a term
+// prefixed with - is negated. The supported terms are:
+//
+//	tag:name              the note has the tag or one nested under it, own or
+//	                      inherited from its folder
+//	title:text            the title contains the text
+//	label:red             the note has the label, a color or an icon
+//	modified<7d           modified less than 7 days ago (also h, w and y)
+//	modified>2w           modified more than 2 weeks ago
+//	modified:>2024-01-01  modified after a day, also <, >=, <= and : for that day
+//	filter:name           the filter of init.lua with that name matches
+//	about:"text"          the note is about the text, by meaning rather than
+//	                      words (semantic search, with the embeddings config)
+//	"exact phrase"        the content of the note contains the phrase
+//	word                  the filename or the tags contain the word
+//
+// Values holding spaces are quoted: title:"weekly sync".
+type query struct {
+	terms []queryTerm
+}
+
+// queryTerm is a single condition of a query
+type queryTerm struct {
+	negate bool
+	match  func(item noteItem) bool
+	// semantic is set for the about: terms, ranking the notes
+	semantic *semanticTerm
+}
+
+// parseQuery parses a search query
+func parseQuery(s string) (query, error) {
+	var q query
+
+	words, err := splitQuery(s)
+	if err != nil {
+		return query{}, err
+	}
+
+	for _, word := range words {
+		if word == "AND" {
+			continue
+		}
+
+		term := queryTerm{}
+		if strings.HasPrefix(word, "-") && len(word) > 1 {
+			term.negate = true
+			word = word[1:]
+		}
+
+		if field, op, value := splitTerm(word); field == "about" {
+			text, _ := unquote(value)
+			if op != ":" || strings.TrimSpace(text) == "" {
+				return query{}, fmt.Errorf("invalid about condition %q, expected about:\"text\"", word)
+			}
+			term.semantic = &semanticTerm{text: text}
+			term.match = term.semantic.matches
+			q.terms = append(q.terms, term)
+			continue
+		}
+
+		match, err := parseTerm(word)
+		if err != nil {
+			return query{}, err
+		}
+		term.match = match
+		q.terms = append(q.terms, term)
+	}
+
+	return q, nil
+}
+
+// splitQuery splits a query on spaces, keeping quoted text together with the
+// quotes
+func splitQuery(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	quoted := false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			word.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n':
+			if quoted {
+				word.WriteRune(r)
+			} else if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// unquote removes the quotes around a value
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return s[1 : len(s)-1], true
+	}
+	return s, false
+}
+
+// quoteValue quotes the value of a term when it holds spaces
+func quoteValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// parseTerm parses a single term of a query
+func parseTerm(word string) (func(item noteItem) bool, error) {
+	// A quoted phrase is searched in the content
+	if phrase, ok := unquote(word); ok {
+		if phrase == "" {
+			return nil, fmt.Errorf("empty phrase")
+		}
+		phrase = strings.ToLower(phrase)
+		return func(item noteItem) bool {
+			return strings.Contains(strings.ToLower(noteContents.get(item)), phrase)
+		}, nil
+	}
+
+	field, op, value := splitTerm(word)
+	value, _ = unquote(value)
+
+	switch field {
+	case "tag":
+		if op != ":" || value == "" {
+			return nil, fmt.Errorf("invalid tag condition %q, expected tag:name", word)
+		}
+		tag := canonicalTag(value)
+		return func(item noteItem) bool {
+			return hasTag(strings.Fields(item.allTags()), tag)
+		}, nil
+
+	case "title":
+		if op != ":" || value == "" {
+			return nil, fmt.Errorf("invalid title condition %q, expected title:text", word)
+		}
+		text := strings.ToLower(value)
+		return func(item noteItem) bool {
+			return strings.Contains(strings.ToLower(item.Title()+"\n"+strings.Join(item.aliases, "\n")), text)
+		}, nil
+
+	case "label":
+		if op != ":" || value == "" {
+			return nil, fmt.Errorf("invalid label condition %q, expected label:color", word)
+		}
+		return func(item noteItem) bool {
+			return strings.EqualFold(item.label, value)
+		}, nil
+
+	case "modified":
+		return parseModified(word, op, value)
+
+	case "filter":
+		match, ok := userScript.filter(value)
+		if op != ":" || !ok {
+			return nil, fmt.Errorf("unknown filter %q, define it in init.lua with snsm.filter", value)
+		}
+		return match, nil
+	}
+
+	text := strings.ToLower(word)
+	return func(item noteItem) bool {
+		return strings.Contains(strings.ToLower(item.filename+" "+strings.Join(item.aliases, " ")+" "+item.allTags()), text)
+	}, nil
+}
+
+// parseModified parses a condition on the modification time, either relative
+// to now (modified<7d) or to a day (modified:>2024-01-01)
+func parseModified(word, op, value string) (func(item noteItem) bool, error) {
+	if day, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		next := day.AddDate(0, 0, 1)
+		switch op {
+		case ":", "=":
+			return func(item noteItem) bool { return !item.modTime.Before(day) && item.modTime.Before(next) }, nil
+		case ">":
+			return func(item noteItem) bool { return !item.modTime.Before(next) }, nil
+		case ">=":
+			return func(item noteItem) bool { return !item.modTime.Before(day) }, nil
+		case "<":
+			return func(item noteItem) bool { return item.modTime.Before(day) }, nil
+		case "<=":
+			return func(item noteItem) bool { return item.modTime.Before(next) }, nil
+		}
+	}
+
+	age, err := parseAge(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modified condition %q: expected a date (2024-01-01) or an age (7d)", word)
+	}
+	switch op {
+	case "<", "<=":
+		return func(item noteItem) bool { return time.Since(item.modTime) < age }, nil
+	case ">", ">=":
+		return func(item noteItem) bool { return time.Since(item.modTime) > age }, nil
+	}
+	return nil, fmt.Errorf("invalid modified condition %q, expected modified<7d or modified>7d", word)
+}
+
+// splitTerm splits a condition in its field, operator and value. Words that
+// aren't conditions are returned as the value with no field.
+func splitTerm(word string) (field, op, value string) {
+	i := strings.IndexAny(word, ":<>=")
+	if i < 0 || !isQueryField(word[:i]) {
+		return "", "", word
+	}
+
+	field, rest := word[:i], word[i:]
+	// modified:<7d is the same as modified<7d
+	rest = strings.TrimPrefix(rest, ":")
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(rest, candidate) {
+			return field, candidate, rest[len(candidate):]
+		}
+	}
+	return field, ":", rest
+}
+
+func isQueryField(field string) bool {
+	switch field {
+	case "tag", "title", "label", "modified", "filter", "about":
+		return true
+	}
+	return false
+}
+
+// isQuery reports whether a filter uses the query language rather than being
+// plain text for the fuzzy filter
+func isQuery(s string) bool {
+	words, err := splitQuery(s)
+	if err != nil {
+		return strings.Contains(s, `"`)
+	}
+
+	for _, word := range words {
+		word = strings.TrimPrefix(word, "-")
+		if field, _, _ := splitTerm(word); field != "" || strings.HasPrefix(word, `"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAge parses a duration relative to now: 12h, 7d or 2w
+func parseAge(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("expected a number followed by h, d, w or y")
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a number followed by h, d, w or y")
+	}
+
+	switch s[len(s)-1] {
+	case 'h':
+		return time.Duration(n) * time.Hour, nil
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	case 'y':
+		return time.Duration(n) * 365 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("unknown unit %q, expected h, d, w or y", s[len(s)-1:])
+}
+
+// matches reports whether a note satisfies every term of the query
+func (q query) matches(item noteItem) bool {
+	for _, term := range q.terms {
+		if term.match(item) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// prepare readies the semantic terms of the query to match notes, embedding
+// the ones that are new or changed
+func (q query) prepare(items []noteItem) error {
+	for _, term := range q.terms {
+		if term.semantic != nil {
+			if err := term.semantic.prepare(items); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// embedded reports whether the texts of the semantic terms were embedded
+// already, the filter of the list not waiting for the embeddings API
+func (q query) embedded() bool {
+	for _, term := range q.terms {
+		if term.semantic != nil && !term.semantic.embedded() {
+			return false
+		}
+	}
+	return true
+}
+
+// ranked reports whether the query orders the notes by their relevance,
+// which the semantic terms do
+func (q query) ranked() bool {
+	for _, term := range q.terms {
+		if term.semantic != nil && !term.negate {
+			return true
+		}
+	}
+	return false
+}
+
+// score returns the relevance of a note to the semantic terms of the query
+func (q query) score(item noteItem) float64 {
+	score := 0.0
+	for _, term := range q.terms {
+		if term.semantic != nil && !term.negate {
+			score += term.semantic.similarity(item)
+		}
+	}
+	return score
+}
+
+// rank orders notes by their relevance to the query, most relevant first,
+// when it is ranked
+func (q query) rank(items []noteItem) {
+	if !q.ranked() {
+		return
+	}
+	indexes := make([]int, len(items))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	q.sortByScore(items, indexes)
+	ranked := make([]noteItem, len(items))
+	for i, index := range indexes {
+		ranked[i] = items[index]
+	}
+	copy(items, ranked)
+}
+
+// sortByScore orders the indexes of items by the relevance of their notes to
+// the query, most relevant first
+func (q query) sortByScore(items []noteItem, indexes []int) {
+	scores := map[int]float64{}
+	for _, index := range indexes {
+		scores[index] = q.score(items[index])
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return scores[indexes[i]] > scores[indexes[j]]
+	})
+}
+
+// shownNotes holds the notes of the list, in the order of the filter targets,
+// the headers and the folders of the list being empty notes. The list filters
+// in the background, hence the lock.
+type shownNotes struct {
+	mu    sync.Mutex
+	items []noteItem
+	// filter and err are the last query filtered with and why it matched
+	// nothing, to show in the status bar
+	filter string
+	err    error
+}
+
+func (s *shownNotes) set(items []noteItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = items
+}
+
+func (s *shownNotes) get() []noteItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.items
+}
+
+// failed records the error of the filtering with a query, nil once it
+// worked
+func (s *shownNotes) failed(filter string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filter, s.err = filter, err
+}
+
+// filterErr returns the error of the last filtering with a query, if it was
+// done with the filter
+func (s *shownNotes) filterErr(filter string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.filter != filter {
+		return nil
+	}
+	return s.err
+}
+
+// queryFilter is the filter of the notes list: the query language when the
+// filter uses it, the default fuzzy filter otherwise
+func queryFilter(shown *shownNotes) list.FilterFunc {
+	return func(term string, targets []string) []list.Rank {
+		defer trace.since("filter", time.Now())
+		if !isQuery(term) {
+			return list.DefaultFilter(term, targets)
+		}
+
+		q, err := parseQuery(term)
+		if err == nil && !q.embedded() {
+			// Embedded by filterFailed, which filters again
+			err = errNotEmbedded
+		}
+		shown.failed(term, err)
+		if err != nil {
+			return nil
+		}
+		items := shown.get()
+
+		var matched []int
+		for i, item := range items {
+			// The headers and the folders of the list are left out
+			if i < len(targets) && item.filename != "" && q.matches(item) {
+				matched = append(matched, i)
+			}
+		}
+		// The most relevant notes first
+		if q.ranked() {
+			q.sortByScore(items, matched)
+		}
+		var ranks []list.Rank
+		for _, index := range matched {
+			ranks = append(ranks, list.Rank{Index: index})
+		}
+		return ranks
+	}
+}
+
+// errNotEmbedded is the error of the filtering when the texts searched with
+// about: are not embedded yet
+var errNotEmbedded = errors.New("not embedded yet")
+
+// queryEmbeddedMsg tells that the texts searched with about: by a filter
+// were embedded, with the notes changed since the last time, or couldn't be
+type queryEmbeddedMsg struct {
+	filter string
+	err    error
+}
+
+// filterFailed shows why the query of the filter matched nothing, once the
+// list filtered with it. The texts searched with about: not being embedded
+// yet, it embeds them in the background rather than in every filtering.
+func (m *model) filterFailed() tea.Cmd {
+	filter := m.list.FilterValue()
+	err := m.shown.filterErr(filter)
+	if err == nil {
+		return nil
+	} else if err != errNotEmbedded {
+		return m.setError("Invalid query: %v", err)
+	}
+	if m.embedding == filter {
+		return nil
+	}
+	m.embedding = filter
+
+	var notes []noteItem
+	for _, item := range m.shown.get() {
+		if item.filename != "" {
+			notes = append(notes, item)
+		}
+	}
+	return tea.Batch(m.setInfo("Searching by meaning..."), func() tea.Msg {
+		q, err := parseQuery(filter)
+		if err == nil {
+			err = q.prepare(notes)
+		}
+		return queryEmbeddedMsg{filter: filter, err: err}
+	})
+}
+
+// queryEmbedded filters the list again once the texts searched are
+// embedded, or shows why they couldn't be
+func (m model) queryEmbedded(msg queryEmbeddedMsg) (tea.Model, tea.Cmd) {
+	m.embedding = ""
+	if msg.err != nil {
+		return m, m.setError("Cannot search by meaning: %v", msg.err)
+	}
+	if m.list.FilterValue() != msg.filter || m.list.FilterState() == list.Unfiltered {
+		return m, nil
+	}
+	m.status = statusMessage{}
+	return m, m.list.SetItems(m.list.Items())
+}
+
+// contentCache keeps the content of the notes searched for phrases, as long
+// as they aren't modified
+type contentCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedContent
+}
+
+type cachedContent struct {
+	modTime time.Time
+	content string
+}
+
+var noteContents = contentCache{entries: map[string]cachedContent{}}
+
+// get returns the content of a note, empty if it can't be read
+func (c *contentCache) get(item noteItem) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[item.path]; ok && entry.modTime.Equal(item.modTime) {
+		return entry.content
+	}
+
+	data, err := os.ReadFile(item.path)
+	if err != nil {
+		return ""
+	}
+
+	// Other encodings than UTF-8 would garble the display
+	content := strings.ToValidUTF8(string(data), "\uFFFD")
+	c.entries[item.path] = cachedContent{modTime: item.modTime, content: content}
+	return content
+}
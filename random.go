@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openRandom opens a random note among the ones shown in the list, so the
+// filter restricts the choice
+func (m model) openRandom() (tea.Model, tea.Cmd) {
+	// The headers and the folders of the list are left out
+	var indexes []int
+	for i, listItem := range m.list.VisibleItems() {
+		if _, ok := listItem.(noteItem); ok {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return m, m.setError("No notes to pick from")
+	}
+
+	index := indexes[rand.Intn(len(indexes))]
+	item := m.list.VisibleItems()[index].(noteItem)
+	m.list.Select(index)
+	return m, m.openNote(item.filename)
+}
+
+func runRandom(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("random", flag.ContinueOnError)
+	tag := flags.String("tag", "", "only pick a note with this tag")
+	printPath := flags.Bool("print", false, "print the path of the note instead of opening it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return err
+	}
+
+	var candidates []noteItem
+	wanted := canonicalTag(*tag)
+	for _, item := range items {
+		if *tag == "" || hasTag(strings.Fields(item.allTags()), wanted) {
+			candidates = append(candidates, item)
+		}
+	}
+	if len(candidates) == 0 {
+		if *tag != "" {
+			return fmt.Errorf("no note tagged %s", *tag)
+		}
+		return fmt.Errorf("no notes")
+	}
+
+	item := candidates[rand.Intn(len(candidates))]
+	if *printPath {
+		fmt.Println(item.path)
+		return nil
+	}
+
+	return editNote(notesDir, item.filename)
+}
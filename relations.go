@@ -0,0 +1,444 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+// noteRefs are the notes named by a field of the front matter, written as a
+// yaml list or as a single note. [[wikilinks]] left unquoted, which yaml
+// reads as nested lists, are taken as links too.
+type noteRefs []string
+
+func (r *noteRefs) UnmarshalYAML(node *yaml.Node) error {
+	*r = nodeRefs(node)
+	return nil
+}
+
+// noteRef is the note named by a field of the front matter, like parent
+type noteRef string
+
+func (r *noteRef) UnmarshalYAML(node *yaml.Node) error {
+	if refs := nodeRefs(node); len(refs) > 0 {
+		*r = noteRef(refs[0])
+	}
+	return nil
+}
+
+// nodeRefs reads the notes of a yaml value
+func nodeRefs(node *yaml.Node) []string {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if value := strings.TrimSpace(node.Value); value != "" {
+			return []string{value}
+		}
+	case yaml.SequenceNode:
+		// [[note]] unquoted is a list holding a list of the note
+		if len(node.Content) == 1 && node.Content[0].Kind == yaml.SequenceNode &&
+			len(node.Content[0].Content) == 1 && node.Content[0].Content[0].Kind == yaml.ScalarNode {
+			return []string{"[[" + node.Content[0].Content[0].Value + "]]"}
+		}
+		var refs []string
+		for _, child := range node.Content {
+			refs = append(refs, nodeRefs(child)...)
+		}
+		return refs
+	}
+	return nil
+}
+
+// splitNoteRefs reads notes separated by commas, like the RELATED keyword of
+// org-mode notes
+func splitNoteRefs(value string) noteRefs {
+	var refs noteRefs
+	for _, ref := range strings.Split(value, ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// refTarget returns the target of a note of the front matter, a [[wikilink]]
+// or a bare name
+func refTarget(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if inner, ok := strings.CutPrefix(ref, "[["); ok {
+		ref = strings.TrimSuffix(inner, "]]")
+	}
+	ref, _, _ = strings.Cut(ref, "|")
+	return ref
+}
+
+// relationGraph holds the parents, children and related notes of the notes,
+// by filename. Related notes go both ways, whichever note names the other.
+type relationGraph struct {
+	parent   map[string]string
+	children map[string][]string
+	related  map[string][]string
+}
+
+// buildRelations resolves the parent and related notes of the front matter.
+// Missing notes and notes naming themselves are left out.
+func buildRelations(items []noteItem) relationGraph {
+	g := relationGraph{parent: map[string]string{}, children: map[string][]string{}, related: map[string][]string{}}
+	idx := newNoteIndex(itemFilenames(items)).withAliases(items)
+
+	for _, item := range items {
+		if parent, ok := idx.resolve(refTarget(item.parent)); ok && item.parent != "" && parent != item.filename {
+			g.parent[item.filename] = parent
+			g.children[parent] = append(g.children[parent], item.filename)
+		}
+		for _, ref := range item.related {
+			other, ok := idx.resolve(refTarget(ref))
+			if !ok || other == item.filename {
+				continue
+			}
+			if !containsTag(g.related[item.filename], other) {
+				g.related[item.filename] = append(g.related[item.filename], other)
+			}
+			if !containsTag(g.related[other], item.filename) {
+				g.related[other] = append(g.related[other], item.filename)
+			}
+		}
+	}
+	for _, notes := range []map[string][]string{g.children, g.related} {
+		for _, filenames := range notes {
+			sort.Strings(filenames)
+		}
+	}
+	return g
+}
+
+// ancestor reports whether a note is a parent of another one, or a parent of
+// its parent and so on
+func (g relationGraph) ancestor(filename, of string) bool {
+	seen := map[string]bool{}
+	for parent, ok := g.parent[of]; ok && !seen[parent]; parent, ok = g.parent[parent] {
+		if parent == filename {
+			return true
+		}
+		seen[parent] = true
+	}
+	return false
+}
+
+// Kinds of the relationships of a note
+const (
+	relationParent  = "parent"
+	relationChild   = "child"
+	relationRelated = "related"
+)
+
+// relation is a note related to the one of the relationships panel
+type relation struct {
+	filename string
+	kind     string
+}
+
+// relationsView shows the parent, the children and the related notes of a
+// note, to move between them and attach or detach them
+type relationsView struct {
+	graph   relationGraph
+	current string
+	entries []relation
+	cursor  int
+	// attaching is the kind of note typed in input, parent or related
+	attaching string
+	input     textinput.Model
+}
+
+// visit centers the panel on a note
+func (v *relationsView) visit(filename string) {
+	v.current = filename
+	v.cursor = 0
+	v.entries = nil
+	if parent, ok := v.graph.parent[filename]; ok {
+		v.entries = append(v.entries, relation{parent, relationParent})
+	}
+	for _, child := range v.graph.children[filename] {
+		v.entries = append(v.entries, relation{child, relationChild})
+	}
+	for _, other := range v.graph.related[filename] {
+		v.entries = append(v.entries, relation{other, relationRelated})
+	}
+}
+
+// openRelations shows the relationships of the selected note
+func (m model) openRelations() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	input := textinput.New()
+	input.Width = 40
+	input.ShowSuggestions = true
+	m.relations = relationsView{graph: buildRelations(m.items), input: input}
+	m.relations.visit(item.filename)
+	m.mode = modeRelations
+	return m, nil
+}
+
+// relationNotes returns the names of the notes to attach, as linked to
+func (m model) relationNotes() []string {
+	idx := newNoteIndex(itemFilenames(m.items))
+	var names []string
+	for _, item := range m.items {
+		if item.filename != m.relations.current {
+			names = append(names, linkTarget(idx, item.filename))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// startAttach asks for the parent or a related note of the current note
+func (m model) startAttach(kind string) (tea.Model, tea.Cmd) {
+	v := &m.relations
+	v.attaching = kind
+	v.input.Reset()
+	v.input.Placeholder = "Enter the " + kind + " note (tab to complete)"
+	v.input.SetSuggestions(m.relationNotes())
+	v.input.Focus()
+	return m, textinput.Blink
+}
+
+// attach writes the parent or a related note to the front matter of the
+// current note
+func (m model) attach(kind, name string) (tea.Model, tea.Cmd) {
+	v := &m.relations
+	idx := newNoteIndex(itemFilenames(m.items)).withAliases(m.items)
+	other, ok := idx.resolve(name)
+	if !ok {
+		return m, m.setError("No note named %s", name)
+	}
+	if other == v.current {
+		return m, m.setError("A note can't be its own %s", kind)
+	}
+	item, ok := m.findItem(v.current)
+	if !ok {
+		return m, nil
+	}
+
+	ref := "[[" + linkTarget(idx, other) + "]]"
+	before, err := readForUndo(m.notesDir, item.filename)
+	if err != nil {
+		return m, m.setError("Cannot attach %s: %v", other, describeError(err))
+	}
+	switch kind {
+	case relationParent:
+		if v.graph.ancestor(v.current, other) {
+			return m, m.setError("%s is already under %s", m.titleOf(other), m.titleOf(v.current))
+		}
+		err = setNoteField(item.path, "parent", ref)
+	default:
+		if containsTag(v.graph.related[v.current], other) {
+			return m, m.setInfo("%s and %s are already related", m.titleOf(v.current), m.titleOf(other))
+		}
+		err = setNoteList(item.path, "related", append(append([]string(nil), item.related...), ref))
+	}
+	if err != nil {
+		return m, m.setError("Cannot attach %s: %v", other, describeError(err))
+	}
+	m.pushUndo("attaching "+other+" to "+item.filename, restoreNotes(m.notesDir, before))
+	info := fmt.Sprintf("%s is now the parent of %s", m.titleOf(other), m.titleOf(v.current))
+	if kind == relationRelated {
+		info = fmt.Sprintf("%s is now related to %s", m.titleOf(v.current), m.titleOf(other))
+	}
+	return m.relationsChanged([]string{item.filename}, info)
+}
+
+// detach removes the selected relationship from the front matter of the
+// notes naming it
+func (m model) detach() (tea.Model, tea.Cmd) {
+	v := &m.relations
+	if len(v.entries) == 0 {
+		return m, nil
+	}
+	entry := v.entries[v.cursor]
+	idx := newNoteIndex(itemFilenames(m.items)).withAliases(m.items)
+
+	var edited []string
+	switch entry.kind {
+	case relationParent:
+		edited = []string{v.current}
+	case relationChild:
+		edited = []string{entry.filename}
+	default:
+		edited = []string{v.current, entry.filename}
+	}
+	var found []string
+	for _, filename := range edited {
+		if _, ok := m.findItem(filename); ok {
+			found = append(found, filename)
+		}
+	}
+	before, err := readForUndo(m.notesDir, found...)
+	if err != nil {
+		return m, m.setError("Cannot detach %s: %v", m.titleOf(entry.filename), describeError(err))
+	}
+	for _, filename := range found {
+		item, _ := m.findItem(filename)
+		if entry.kind != relationRelated {
+			err = setNoteField(item.path, "parent", "")
+		} else {
+			other := entry.filename
+			if filename == entry.filename {
+				other = v.current
+			}
+			var kept []string
+			for _, ref := range item.related {
+				if target, ok := idx.resolve(refTarget(ref)); !ok || target != other {
+					kept = append(kept, ref)
+				}
+			}
+			if len(kept) != len(item.related) {
+				err = setNoteList(item.path, "related", kept)
+			}
+		}
+		if err != nil {
+			return m, m.setError("Cannot detach %s: %v", m.titleOf(entry.filename), describeError(err))
+		}
+	}
+	m.pushUndo("detaching "+entry.filename+" from "+v.current, restoreNotes(m.notesDir, before))
+	return m.relationsChanged(edited, fmt.Sprintf("Detached %s from %s", m.titleOf(entry.filename), m.titleOf(v.current)))
+}
+
+// relationsChanged reads the front matter of the edited notes again, and
+// shows the relationships as they are now
+func (m model) relationsChanged(edited []string, info string) (tea.Model, tea.Cmd) {
+	var hooks []tea.Cmd
+	for i := range m.items {
+		item := &m.items[i]
+		if !containsTag(edited, item.filename) {
+			continue
+		}
+		if _, meta, err := readHeader(item.path); err == nil {
+			item.parent, item.related = string(meta.Parent), meta.Related
+		}
+		hooks = append(hooks, m.noteSavedCmd("post_edit", item.filename, info))
+	}
+	m.relations.graph = buildRelations(m.items)
+	m.relations.visit(m.relations.current)
+	return m, tea.Batch(append(hooks, m.reloadNotes(), m.setInfo("%s", info))...)
+}
+
+// titleOf returns the title of a note as listed
+func (m model) titleOf(filename string) string {
+	if item, ok := m.findItem(filename); ok {
+		return item.Title()
+	}
+	return noteTitle(filename)
+}
+
+// findItem returns the note of a filename
+func (m model) findItem(filename string) (noteItem, bool) {
+	for _, item := range m.items {
+		if item.filename == filename {
+			return item, true
+		}
+	}
+	return noteItem{}, false
+}
+
+func (m model) updateRelations(msg tea.Msg) (tea.Model, tea.Cmd) {
+	v := &m.relations
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if v.attaching != "" {
+		if ok {
+			switch keyMsg.String() {
+			case "ctrl+c":
+				m.quitting = true
+				return m, tea.Quit
+			case "esc":
+				v.attaching = ""
+				v.input.Blur()
+				return m, nil
+			case "enter":
+				kind, name := v.attaching, strings.TrimSpace(v.input.Value())
+				v.attaching = ""
+				v.input.Blur()
+				if name == "" {
+					return m, nil
+				}
+				return m.attach(kind, name)
+			}
+		}
+		var cmd tea.Cmd
+		v.input, cmd = v.input.Update(msg)
+		return m, cmd
+	}
+	if !ok {
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if v.cursor > 0 {
+			v.cursor--
+		}
+	case "down", "j":
+		if v.cursor < len(v.entries)-1 {
+			v.cursor++
+		}
+	case "enter":
+		if len(v.entries) > 0 {
+			v.visit(v.entries[v.cursor].filename)
+		}
+	case "e":
+		return m, m.openNote(v.current)
+	case "p":
+		return m.startAttach(relationParent)
+	case "r":
+		return m.startAttach(relationRelated)
+	case "x":
+		return m.detach()
+	case "esc", "q":
+		// Leave the list on the note shown last
+		m.selectNote(v.current)
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m model) viewRelations() string {
+	v := m.relations
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Relationships of "+m.titleOf(v.current)) + "\n\n")
+	width := max(min(m.width-12, 70), 20)
+	arrows := map[string]string{relationParent: "↑", relationChild: "↓", relationRelated: "↔"}
+	for i, entry := range v.entries {
+		line := truncateWidth(arrows[entry.kind]+" "+m.titleOf(entry.filename), width) + "  " + mutedStyle.Render(entry.kind)
+		if i == v.cursor {
+			b.WriteString(selectedItemStyle.Render("> " + line))
+		} else {
+			b.WriteString(itemStyle.Render(line))
+		}
+		b.WriteString("\n")
+	}
+	if len(v.entries) == 0 {
+		b.WriteString(itemStyle.Render(mutedStyle.Render("No parent, children or related notes")) + "\n")
+	}
+
+	b.WriteString("\n")
+	if v.attaching != "" {
+		b.WriteString(itemStyle.Render(v.input.View()))
+	} else {
+		b.WriteString(mutedStyle.Copy().PaddingLeft(2).Render("enter go to • e edit • p set parent • r relate • x detach • esc close"))
+	}
+
+	box := menuStyle.Render(b.String())
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// transfer is a file rsync copies, or would copy
+type transfer struct {
+	filename string
+	// created is set for files missing on the receiving side, the other
+	// ones being overwritten
+	created bool
+}
+
+// rsyncArgs returns the arguments of rsync copying from to to, the notes
+// directory or the remote. The notes are compared by checksum so that files
+// only touched aren't reported. The .snsm folder stays on each side.
+func rsyncArgs(from, to string, dryRun bool) []string {
+	args := []string{"--archive", "--compress", "--checksum", "--itemize-changes", "--exclude=.git/", "--exclude=/" + snsmDir + "/"}
+	if dryRun {
+		args = append(args, "--dry-run")
+	}
+	return append(args, strings.TrimSuffix(from, "/")+"/", strings.TrimSuffix(to, "/")+"/")
+}
+
+// parseItemized reads the files of the --itemize-changes output of rsync:
+// lines like ">f+++++++++ notes/new.md" for new files, and ">f.st...... a.md"
+// for updated ones. Directories and attribute changes are left out.
+func parseItemized(output string) []transfer {
+	var transfers []transfer
+	for _, line := range strings.Split(output, "\n") {
+		changes, filename, ok := strings.Cut(line, " ")
+		if !ok || len(changes) < 3 || changes[1] != 'f' || (changes[0] != '<' && changes[0] != '>') {
+			continue
+		}
+		transfers = append(transfers, transfer{filename: filename, created: strings.HasPrefix(changes[2:], "+++")})
+	}
+	return transfers
+}
+
+// rsync runs rsync and returns the files it copied, or would copy
+func rsync(args []string) ([]transfer, error) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		return nil, fmt.Errorf("rsync is not installed")
+	}
+
+	cmd := exec.Command("rsync", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("rsync: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseItemized(string(out)), nil
+}
+
+// printTransfers lists the files of a transfer, new files first
+func printTransfers(transfers []transfer) {
+	for _, created := range []bool{true, false} {
+		for _, t := range transfers {
+			if t.created != created {
+				continue
+			}
+			action := "overwrite"
+			if created {
+				action = "new      "
+			}
+			fmt.Printf("  %s  %s\n", action, t.filename)
+		}
+	}
+}
+
+// runTransfer copies the notes to or from the remote, showing what would be
+// copied first and asking before overwriting files
+func runTransfer(cfg config, notesDir string, args []string, push bool) error {
+	name := "pull"
+	if push {
+		name = "push"
+	}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	remote := flags.String("remote", cfg.Remote, "remote notes directory, e.g. user@host:notes")
+	dryRun := flags.Bool("dry-run", false, "only list the notes that would be copied")
+	yes := flags.Bool("yes", false, "overwrite files without asking")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *remote == "" {
+		return fmt.Errorf("no remote, set remote in the config or pass --remote user@host:path")
+	}
+
+	from, to := *remote, notesDir
+	if push {
+		from, to = notesDir, *remote
+	}
+
+	transfers, err := rsync(rsyncArgs(from, to, true))
+	if err != nil {
+		return err
+	}
+	if len(transfers) == 0 {
+		fmt.Println("Already up to date")
+		return nil
+	}
+
+	overwrites := 0
+	for _, t := range transfers {
+		if !t.created {
+			overwrites++
+		}
+	}
+	verb := "would be copied to"
+	if !*dryRun {
+		verb = "to copy to"
+	}
+	fmt.Printf("%d files %s %s:\n", len(transfers), verb, to)
+	printTransfers(transfers)
+	if *dryRun {
+		return nil
+	}
+
+	if overwrites > 0 && !*yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("%d files would be overwritten, pass --yes to overwrite them", overwrites)
+		}
+		if !askForConfirmation(fmt.Sprintf("Overwrite %d files?", overwrites)) {
+			return fmt.Errorf("canceled")
+		}
+	}
+
+	copied, err := rsync(rsyncArgs(from, to, false))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Copied %d files\n", len(copied))
+	return nil
+}
+
+func runPush(cfg config, notesDir string, args []string) error {
+	return runTransfer(cfg, notesDir, args, true)
+}
+
+func runPull(cfg config, notesDir string, args []string) error {
+	return runTransfer(cfg, notesDir, args, false)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// pageCSS is the stylesheet embedded in every rendered page
+const pageCSS = `body { margin: 0; font: 16px/1.6 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #24292f; background: #fff; }
+main { max-width: 46em; margin: 2em auto; padding: 0 1em; }
+pre, code { font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 90%; background: #f3f4f6; border-radius: 4px; }
+code { padding: .1em .3em; }
+pre { padding: 1em; overflow-x: auto; }
+pre code { padding: 0; background: none; }
+blockquote { margin: 0; padding: 0 1em; color: #57606a; border-left: .25em solid #d0d7de; }
+table { border-collapse: collapse; }
+th, td { padding: .3em .8em; border: 1px solid #d0d7de; }
+img { max-width: 100%; }
+a { color: #0969da; }
+.wikilink { color: #8250df; }
+.tags { margin: 0 0 1em; }
+nav { margin: 1em 0; }
+.tag { display: inline-block; padding: 0 .6em; margin-right: .3em; color: #fff; background: #005fd7; border-radius: 1em; font-size: 85%; text-decoration: none; }
+@media (prefers-color-scheme: dark) {
+	body { color: #e6edf3; background: #0d1117; }
+	pre, code { background: #161b22; }
+	blockquote { color: #8d96a0; border-color: #30363d; }
+	th, td { border-color: #30363d; }
+	a { color: #4493f8; }
+	.wikilink { color: #ab7df8; }
+}
+`
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+{{if .Base}}<base href="{{.Base}}">
+{{end}}<style>{{.CSS}}</style>
+</head>
+<body>
+<main>
+{{if or .Home .Edit}}<nav>{{if .Home}}<a href="{{.Home}}">Index</a>{{end}}{{if .Edit}} · <a href="{{.Edit}}">Edit</a>{{end}}</nav>
+{{end}}{{if .Tags}}<p class="tags">{{range .Tags}}{{if .Href}}<a class="tag" href="{{.Href}}">{{.Name}}</a>{{else}}<span class="tag">{{.Name}}</span>{{end}}{{end}}</p>
+{{end}}{{.Body}}</main>
+{{if .LiveReload}}<script>
+let version = null;
+setInterval(async () => {
+	try {
+		const current = await (await fetch("/_snsm/version")).text();
+		if (version !== null && current !== version) location.reload();
+		version = current;
+	} catch (e) {}
+}, 1000);
+</script>
+{{end}}</body>
+</html>
+`))
+
+// page holds the values of pageTemplate
+type page struct {
+	Title string
+	Tags  []pageTag
+	// Home links to the index of a published site
+	Home string
+	// Edit links to the form editing the note, in the web UI
+	Edit string
+	// Base is the URL the relative links resolve against, when the page
+	// isn't next to the note
+	Base       template.URL
+	CSS        template.CSS
+	Body       template.HTML
+	LiveReload bool
+}
+
+// pageTag is a tag pill, linking to the page of the tag when Href is set
+type pageTag struct {
+	Name string
+	Href string
+}
+
+// htmlSite renders notes to HTML pages, mirroring the folders of the notes
+type htmlSite struct {
+	notesDir   string
+	outDir     string
+	index      noteIndex
+	folders    *folderConfigs
+	liveReload bool
+	// css replaces the default stylesheet when set
+	css string
+	// published pages link to the index and to the pages of their tags
+	published bool
+	// editable pages link to the form editing their note
+	editable bool
+	// base is the URL the links of the pages resolve against, set when the
+	// pages aren't written next to the notes
+	base string
+}
+
+// htmlPath returns the path of the page of a note, relative to the site
+func htmlPath(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".html"
+}
+
+// renderNote writes the page of a note
+func (s htmlSite) renderNote(filename string) error {
+	outPath := filepath.Join(s.outDir, htmlPath(filename))
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return s.writePage(file, filename)
+}
+
+// writePage renders the page of a note to w
+func (s htmlSite) writePage(w io.Writer, filename string) error {
+	data, err := os.ReadFile(filepath.Join(s.notesDir, filename))
+	if err != nil {
+		return err
+	}
+
+	tags, body := splitTagLine(string(data))
+	body = stripFrontMatter(body)
+	tags = mergeTags(tags, s.folders.tags(filename))
+
+	renderer := htmlRenderer{resolveWikilink: func(target string) string {
+		linked, ok := s.index.resolve(target)
+		if !ok {
+			return ""
+		}
+		return relativeHref(filename, htmlPath(linked))
+	}}
+
+	var pageTags []pageTag
+	for _, tag := range strings.Fields(tags) {
+		t := pageTag{Name: strings.TrimPrefix(tag, "+")}
+		if s.published {
+			t.Href = relativeHref(filename, tagPagePath(t.Name))
+		}
+		pageTags = append(pageTags, t)
+	}
+
+	home := ""
+	if s.published {
+		home = relativeHref(filename, "index.html")
+	}
+
+	edit := ""
+	if s.editable {
+		edit = "?edit"
+	}
+
+	css := s.css
+	if css == "" {
+		css = pageCSS
+	}
+
+	return pageTemplate.Execute(w, page{
+		Title:      documentTitle(filename, body),
+		Tags:       pageTags,
+		Home:       home,
+		Edit:       edit,
+		Base:       template.URL(s.base),
+		CSS:        template.CSS(css),
+		Body:       template.HTML(renderer.markdownToHTML(body)),
+		LiveReload: s.liveReload,
+	})
+}
+
+// documentTitle is the title of a note page: its first heading, or its
+// filename when it has none
+func documentTitle(filename, body string) string {
+	if title := firstHeading(parseMarkdown(body)); title != "" {
+		return title
+	}
+	return noteTitle(filename)
+}
+
+// relativeHref returns the link from the page of a note to a path of the site
+func relativeHref(filename, target string) string {
+	href, err := filepath.Rel(filepath.Dir(filename), target)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(href)
+}
+
+// removeNote deletes the page of a removed note
+func (s htmlSite) removeNote(filename string) error {
+	err := os.Remove(filepath.Join(s.outDir, htmlPath(filename)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func runRender(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	out := flags.String("out", "./site", "directory to write the HTML pages to")
+	watch := flags.Bool("watch", false, "keep running and render the notes as they change")
+	interval := flags.Duration("interval", time.Second, "how often to check for changes with --watch")
+	serve := flags.String("serve", "", "serve the pages on this address (e.g. localhost:8080) and reload them on change")
+	withPprof := flags.Bool("pprof", false, "expose the Go profiling endpoints under /debug/pprof/ with --serve")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *withPprof && *serve == "" {
+		return fmt.Errorf("--pprof needs --serve")
+	}
+
+	watcher := newNoteWatcher(notesDir)
+	changed, _, err := watcher.poll()
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+
+	site := htmlSite{
+		notesDir:   notesDir,
+		outDir:     *out,
+		index:      newNoteIndex(watcher.filenames()),
+		folders:    newFolderConfigs(notesDir),
+		liveReload: *serve != "",
+	}
+
+	// Only render the notes given as arguments
+	if flags.NArg() > 0 {
+		if *watch {
+			return fmt.Errorf("--watch renders every note, it can't be given notes")
+		}
+		changed = nil
+		for _, filename := range flags.Args() {
+			changed = append(changed, noteFilename(notesDir, filename))
+		}
+	}
+
+	if err := renderNotes(site, changed, nil); err != nil && !*watch {
+		return err
+	}
+	if !*watch {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// version changes every time pages are rendered, reloading the browser
+	var version atomic.Int64
+	if *serve != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/", http.FileServer(http.Dir(*out)))
+		mux.HandleFunc("/_snsm/version", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, strconv.FormatInt(version.Load(), 10))
+		})
+		if *withPprof {
+			handlePprof(mux)
+		}
+
+		server := &http.Server{Addr: *serve, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Fprintf(os.Stderr, "Error serving pages: %v\n", err)
+				stop()
+			}
+		}()
+		defer server.Close()
+		fmt.Printf("Serving %s on http://%s\n", *out, *serve)
+	}
+
+	fmt.Printf("Watching %s for changes, press ctrl+c to stop\n", notesDir)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		changed, removed, err := watcher.poll()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing notes: %v\n", err)
+			continue
+		}
+		if len(changed) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		newIndex := newNoteIndex(watcher.filenames())
+		// Links to added or removed notes change, render everything again
+		if len(newIndex.byPath) != len(site.index.byPath) || len(removed) > 0 {
+			changed = watcher.filenames()
+		}
+		site.index = newIndex
+		// Folder settings may have changed too
+		site.folders = newFolderConfigs(notesDir)
+
+		renderNotes(site, changed, removed)
+		version.Add(1)
+	}
+}
+
+// renderNotes renders the changed notes and deletes the pages of the removed
+// ones, printing every file processed. It returns the last error.
+func renderNotes(site htmlSite, changed, removed []string) error {
+	var lastErr error
+
+	for _, filename := range changed {
+		if err := site.renderNote(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering %s: %v\n", filename, describeError(err))
+			lastErr = fmt.Errorf("failed to render %s: %v", filename, describeError(err))
+			continue
+		}
+		fmt.Printf("Rendered %s\n", filepath.Join(site.outDir, htmlPath(filename)))
+	}
+
+	for _, filename := range removed {
+		if err := site.removeNote(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", htmlPath(filename), err)
+			lastErr = err
+			continue
+		}
+		fmt.Printf("Removed %s\n", filepath.Join(site.outDir, htmlPath(filename)))
+	}
+
+	return lastErr
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// lineChange is a line of a note changed by a replacement
+type lineChange struct {
+	// line of the note, starting at 1
+	line     int
+	old, new string
+}
+
+// fileChanges are the lines of a note changed by a replacement
+type fileChanges struct {
+	item    noteItem
+	changes []lineChange
+}
+
+// planReplace returns the lines of the notes the pattern would change. The
+// pattern is applied line by line, it can't match across lines.
+func planReplace(items []noteItem, pattern *regexp.Regexp, with string) []fileChanges {
+	var planned []fileChanges
+	for _, item := range items {
+		data, err := os.ReadFile(item.path)
+		if err != nil {
+			continue
+		}
+
+		var changes []lineChange
+		for i, line := range strings.Split(string(data), "\n") {
+			if replaced := pattern.ReplaceAllString(line, with); replaced != line {
+				changes = append(changes, lineChange{line: i + 1, old: line, new: replaced})
+			}
+		}
+		if len(changes) > 0 {
+			planned = append(planned, fileChanges{item: item, changes: changes})
+		}
+	}
+	return planned
+}
+
+// applyReplace writes the planned changes of a note, failing if the note
+// changed since they were planned
+func applyReplace(f fileChanges) error {
+	data, err := os.ReadFile(f.item.path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for _, c := range f.changes {
+		if c.line > len(lines) || lines[c.line-1] != c.old {
+			return fmt.Errorf("the note changed, run the replacement again")
+		}
+		lines[c.line-1] = c.new
+	}
+
+	info, err := os.Stat(f.item.path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.item.path, []byte(strings.Join(lines, "\n")), info.Mode())
+}
+
+// changeLines returns the preview of the changes of a note, every changed
+// line shown before and after the replacement
+func (f fileChanges) changeLines(removed, added lipgloss.Style) []string {
+	var lines []string
+	for _, c := range f.changes {
+		lines = append(lines,
+			mutedStyle.Render(fmt.Sprintf("%s:%d", f.item.filename, c.line)),
+			removed.Render("- "+strings.TrimRight(c.old, "\r")),
+			added.Render("+ "+strings.TrimRight(c.new, "\r")))
+	}
+	return lines
+}
+
+func runReplace(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("replace", flag.ContinueOnError)
+	patternFlag := flags.String("pattern", "", "regular expression to replace, matched line by line")
+	with := flags.String("with", "", "replacement, $1 inserting the first group of the pattern")
+	tag := flags.String("tag", "", "only replace in the notes with this tag")
+	dryRun := flags.Bool("dry-run", false, "only print the lines that would change")
+	yes := flags.Bool("yes", false, "replace in every note without asking")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *patternFlag == "" {
+		return fmt.Errorf("--pattern is required")
+	}
+	pattern, err := regexp.Compile(*patternFlag)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+	if *tag != "" {
+		wanted := canonicalTag(*tag)
+		var tagged []noteItem
+		for _, item := range items {
+			if hasTag(strings.Fields(item.allTags()), wanted) {
+				tagged = append(tagged, item)
+			}
+		}
+		items = tagged
+	}
+
+	planned := planReplace(items, pattern, *with)
+	if len(planned) == 0 {
+		fmt.Fprintln(os.Stderr, "No matches")
+		return nil
+	}
+
+	interactive := term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+	if *dryRun || (!*yes && !interactive) {
+		for _, f := range planned {
+			for _, line := range f.changeLines(lipgloss.NewStyle(), lipgloss.NewStyle()) {
+				fmt.Println(line)
+			}
+		}
+		if !*dryRun {
+			return fmt.Errorf("not a terminal, pass --yes to replace without confirmation")
+		}
+		return nil
+	}
+
+	if cfg.Backup.Auto {
+		if _, err := backup(cfg.Backup, notesDir); err != nil {
+			return err
+		}
+	}
+
+	if *yes {
+		replaced := 0
+		for _, f := range planned {
+			if err := applyReplace(f); err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot replace in %s: %v\n", f.item.filename, err)
+				continue
+			}
+			replaced++
+			fmt.Printf("Replaced %d lines in %s\n", len(f.changes), f.item.filename)
+		}
+		fmt.Printf("%d of %d notes changed\n", replaced, len(planned))
+		return nil
+	}
+
+	t, err := resolveTheme(cfg)
+	if err != nil {
+		return err
+	}
+	applyTheme(t)
+
+	final, err := runProgram(replaceReview{files: planned}, tea.WithAltScreen())
+	if err != nil {
+		return err
+	}
+	for _, line := range final.(replaceReview).log {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// replaceReview asks for the confirmation of the changes of every note, one
+// note at a time
+type replaceReview struct {
+	files   []fileChanges
+	current int
+	// all replaces in the remaining notes without asking
+	all    bool
+	scroll int
+	width  int
+	height int
+	// log reports what was done, printed on exit
+	log []string
+}
+
+func (r replaceReview) Init() tea.Cmd {
+	return nil
+}
+
+// next applies or skips the changes of the current note and shows the next
+// one, quitting after the last one
+func (r replaceReview) next(apply bool) (tea.Model, tea.Cmd) {
+	f := r.files[r.current]
+	if apply {
+		if err := applyReplace(f); err != nil {
+			r.log = append(r.log, fmt.Sprintf("Cannot replace in %s: %v", f.item.filename, err))
+		} else {
+			r.log = append(r.log, fmt.Sprintf("Replaced %d lines in %s", len(f.changes), f.item.filename))
+		}
+	}
+
+	r.current++
+	r.scroll = 0
+	if r.current == len(r.files) {
+		return r, tea.Quit
+	}
+	if r.all {
+		return r.next(true)
+	}
+	return r, nil
+}
+
+func (r replaceReview) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.width, r.height = msg.Width, msg.Height
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "y":
+			return r.next(true)
+		case "n":
+			return r.next(false)
+		case "a":
+			r.all = true
+			return r.next(true)
+		case "down", "j":
+			r.scroll++
+		case "up", "k":
+			r.scroll = max(r.scroll-1, 0)
+		case "q", "esc", "ctrl+c":
+			return r, tea.Quit
+		}
+	}
+	return r, nil
+}
+
+func (r replaceReview) View() string {
+	if r.current >= len(r.files) {
+		return ""
+	}
+	f := r.files[r.current]
+
+	var b strings.Builder
+	b.WriteString("\n" + titleStyle.Render(fmt.Sprintf("Replace in %s? (%d/%d)", f.item.filename, r.current+1, len(r.files))) + "\n\n")
+
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Error))
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Success))
+	lines := f.changeLines(removed, added)
+
+	height := max(r.height-6, 1)
+	offset := min(r.scroll, max(len(lines)-height, 0))
+	end := min(offset+height, len(lines))
+	for _, line := range lines[offset:end] {
+		b.WriteString("  " + truncateWidth(line, max(r.width-4, 10)) + "\n")
+	}
+
+	view := b.String()
+	if gap := r.height - lipgloss.Height(view) - 2; gap > 0 {
+		view += strings.Repeat("\n", gap)
+	}
+	return view + "\n" + helpStyle.Render("y replace • n skip • a replace in all • j/k scroll • q stop")
+}
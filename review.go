@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+// reviewTag marks the notes to review with spaced repetition
+const reviewTag = "+review"
+
+// Grades of a review, as the quality of the recall in SM-2
+const (
+	gradeAgain = 1
+	gradeGood  = 4
+	gradeEasy  = 5
+)
+
+// questionLine and answerLine are the lines of the Q/A blocks of a note:
+// "Q: question" then "A: answer"
+var (
+	questionLine = regexp.MustCompile(`(?m)^\s*Q:\s`)
+	answerLine   = regexp.MustCompile(`^(\s*A:\s*)(.*)$`)
+)
+
+// reviewCard is the scheduling of the review of a note with SM-2
+type reviewCard struct {
+	Due      time.Time `yaml:"due"`
+	Interval int       `yaml:"interval"`
+	Ease     float64   `yaml:"ease"`
+	Reps     int       `yaml:"reps"`
+}
+
+// reviewCards holds the cards of the notes, by notes directory and filename
+type reviewCards map[string]map[string]reviewCard
+
+// reviewsPath returns the location of the review cards, next to the state
+func reviewsPath() string {
+	return filepath.Join(filepath.Dir(statePath()), "reviews.yaml")
+}
+
+// loadReviews reads the review cards, a missing or broken file giving none
+func loadReviews() reviewCards {
+	cards := reviewCards{}
+
+	data, err := os.ReadFile(reviewsPath())
+	if err != nil {
+		return cards
+	}
+	yaml.Unmarshal(data, &cards)
+
+	return cards
+}
+
+// grade schedules the next review of a card after a review of a given
+// quality, the SM-2 way: a failed recall starts over, a good one multiplies
+// the interval by the ease, which follows the quality of the recalls
+func (c reviewCard) grade(quality int, now time.Time) reviewCard {
+	if c.Ease == 0 {
+		c.Ease = 2.5
+	}
+
+	if quality < 3 {
+		c.Reps = 0
+		c.Interval = 1
+	} else {
+		c.Reps++
+		switch c.Reps {
+		case 1:
+			c.Interval = 1
+		case 2:
+			c.Interval = 6
+		default:
+			c.Interval = int(math.Round(float64(c.Interval) * c.Ease))
+		}
+	}
+
+	q := float64(5 - quality)
+	c.Ease = math.Max(1.3, c.Ease+0.1-q*(0.08+q*0.02))
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	c.Due = today.AddDate(0, 0, c.Interval)
+	return c
+}
+
+// isReviewed reports whether a note is reviewed: tagged +review or holding
+// Q/A blocks
+func isReviewed(item noteItem) bool {
+	if hasTag(strings.Fields(item.allTags()), reviewTag) {
+		return true
+	}
+	return questionLine.MatchString(noteContents.get(item))
+}
+
+// dueNotes returns the reviewed notes due by now, the ones never reviewed
+// being due
+func dueNotes(items []noteItem, cards map[string]reviewCard, now time.Time) []noteItem {
+	var due []noteItem
+	for _, item := range items {
+		if !isReviewed(item) {
+			continue
+		}
+		if card, ok := cards[item.filename]; ok && card.Due.After(now) {
+			continue
+		}
+		due = append(due, item)
+	}
+	return due
+}
+
+// hideAnswers replaces the answers of the Q/A blocks of a note by a
+// placeholder, until they are revealed
+func hideAnswers(body string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if match := answerLine.FindStringSubmatch(line); match != nil {
+			lines[i] = match[1] + "…"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reviewSession is the queue of the notes being reviewed
+type reviewSession struct {
+	cards    reviewCards
+	queue    []noteItem
+	reviewed int
+	revealed bool
+	scroll   int
+}
+
+// openReview starts reviewing the due notes
+func (m model) openReview() (tea.Model, tea.Cmd) {
+	cards := loadReviews()
+	queue := dueNotes(m.items, cards[m.notesDir], time.Now())
+	if len(queue) == 0 {
+		return m, m.setInfo("No notes to review, tag them %s or add Q: and A: lines", reviewTag)
+	}
+
+	m.review = reviewSession{cards: cards, queue: queue}
+	m.mode = modeReview
+	return m, nil
+}
+
+// gradeReview schedules the next review of the current note and shows the
+// next one. Notes to review again come back at the end of the queue.
+func (m model) gradeReview(quality int) (tea.Model, tea.Cmd) {
+	r := &m.review
+	item := r.queue[0]
+
+	if r.cards[m.notesDir] == nil {
+		r.cards[m.notesDir] = map[string]reviewCard{}
+	}
+	card := r.cards[m.notesDir][item.filename].grade(quality, time.Now())
+	r.cards[m.notesDir][item.filename] = card
+	// The cards are read again for the reviews of other instances, and
+	// those of an encrypted vault stay in memory
+	var err error
+	if !inUnlockedVault(m.notesDir) {
+		err = withFileLock(reviewsPath(), func() error {
+			saved := loadReviews()
+			if saved[m.notesDir] == nil {
+				saved[m.notesDir] = map[string]reviewCard{}
+			}
+			saved[m.notesDir][item.filename] = card
+			return writeStateFile(reviewsPath(), saved)
+		})
+	}
+	if err != nil {
+		return m, m.setError("Cannot save the review: %v", err)
+	}
+
+	r.queue = r.queue[1:]
+	if quality < 3 {
+		r.queue = append(r.queue, item)
+	} else {
+		r.reviewed++
+	}
+	r.revealed = false
+	r.scroll = 0
+
+	if len(r.queue) == 0 {
+		m.mode = modeList
+		return m, m.setInfo("Review done, %d notes reviewed", r.reviewed)
+	}
+	return m, nil
+}
+
+func (m model) updateReview(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case " ":
+		m.review.revealed = true
+	case "down", "j":
+		m.review.scroll++
+	case "up", "k":
+		m.review.scroll = max(m.review.scroll-1, 0)
+	case "1", "a":
+		return m.gradeReview(gradeAgain)
+	case "2", "g":
+		return m.gradeReview(gradeGood)
+	case "3", "e":
+		return m.gradeReview(gradeEasy)
+	case "enter":
+		return m, m.openNote(m.review.queue[0].filename)
+	case "esc", "q":
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m model) viewReview() string {
+	r := m.review
+	item := r.queue[0]
+
+	var b strings.Builder
+	done := r.reviewed + 1
+	b.WriteString("\n" + titleStyle.Render(fmt.Sprintf("Review %d/%d", done, r.reviewed+len(r.queue))) +
+		"  " + mutedStyle.Render(item.filename) + "\n\n")
+
+	_, body := splitTagLine(noteContents.get(item))
+	body = stripFrontMatter(body)
+	hidden := !r.revealed && questionLine.MatchString(body)
+	if hidden {
+		body = hideAnswers(body)
+	}
+
+	width := max(m.width-4, 20)
+	lines := termRenderer{width: width}.render(body)
+
+	height := max(m.height-6, 1)
+	offset := min(r.scroll, max(len(lines)-height, 0))
+	end := min(offset+height, len(lines))
+	for i, line := range lines[offset:end] {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("  " + line)
+	}
+
+	help := "1 again • 2 good • 3 easy • enter edit • esc stop"
+	if hidden {
+		help = "space show answers • " + help
+	}
+	return m.withHelp(b.String(), help)
+}
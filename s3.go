@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Store keeps the notes in an S3 bucket, or any S3 compatible storage,
+// under a prefix. Requests are signed with AWS Signature Version 4.
+type s3Store struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func newS3Store(endpoint, region, bucket, prefix, accessKey, secretKey string) *s3Store {
+	if endpoint == "" {
+		endpoint = "https://s3.amazonaws.com"
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		u = &url.URL{Scheme: "https", Host: endpoint}
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	return &s3Store{
+		endpoint: u, region: region, bucket: bucket, prefix: prefix,
+		accessKey: accessKey, secretKey: secretKey,
+		client: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// s3Escape escapes a path or a query value the way S3 signs them: everything
+// but the unreserved characters, and the slashes of paths
+func s3Escape(s string, path bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', path && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// request sends a signed request on a key of the bucket, the bucket itself
+// for an empty key
+func (s *s3Store) request(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	path := s.endpoint.Path + "/" + s.bucket
+	if key != "" {
+		path += "/" + key
+	}
+	escapedPath := s3Escape(path, true)
+
+	// Query values are sorted by key, then value
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, s3Escape(name, false)+"="+s3Escape(value, false))
+		}
+	}
+	sort.Strings(params)
+	rawQuery := strings.Join(params, "&")
+
+	req, err := http.NewRequest(method, s.endpoint.Scheme+"://"+s.endpoint.Host+escapedPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = rawQuery
+
+	s.sign(req, body, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var s3Err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if xml.Unmarshal(data, &s3Err) == nil && s3Err.Code != "" {
+			return nil, fmt.Errorf("%s %s: %s: %s", method, key, s3Err.Code, s3Err.Message)
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, key, resp.Status)
+	}
+	return resp, nil
+}
+
+// sign adds the headers of AWS Signature Version 4 to a request, whose URL
+// path and query are escaped already
+func (s *s3Store) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256.Sum256(body)
+	payload := hex.EncodeToString(payloadHash[:])
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payload + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payload,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func (s *s3Store) list() (map[string]remoteObject, error) {
+	objects := map[string]remoteObject{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if s.prefix != "" {
+			query.Set("prefix", s.prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.request(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				ETag         string    `xml:"ETag"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid listing: %v", err)
+		}
+
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, s.prefix)
+			if name == "" || strings.HasSuffix(name, "/") {
+				continue
+			}
+			objects[name] = remoteObject{etag: object.ETag, modTime: object.LastModified}
+		}
+		if !result.IsTruncated {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *s3Store) get(name string) ([]byte, remoteObject, error) {
+	resp, err := s.request(http.MethodGet, s.prefix+name, nil, nil)
+	if err != nil {
+		return nil, remoteObject{}, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, remoteObject{}, err
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return data, remoteObject{etag: resp.Header.Get("ETag"), modTime: modTime}, nil
+}
+
+func (s *s3Store) put(name string, data []byte) (string, error) {
+	resp, err := s.request(http.MethodPut, s.prefix+name, nil, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *s3Store) delete(name string) error {
+	resp, err := s.request(http.MethodDelete, s.prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
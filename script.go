@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaScript is the init.lua of the user, registering functions that format
+// the list, filter notes, name new notes and run on keys:
+//
+//	snsm.format(function(note) return note.title .. " (" .. #note.tags .. ")" end)
+//	snsm.filter("long", function(note) return #note.title > 40 end)
+//	snsm.new_note_name(function(new) return os.date("%Y%m%d") .. "-" .. new.title end)
+//	snsm.bind("W", "word count", function(note) return "..." end)
+//
+// The functions get the notes as tables of filename, path, title, tags,
+// label and modified, a unix time.
+type luaScript struct {
+	// mu serializes the calls, the list filtering in the background
+	mu       sync.Mutex
+	state    *lua.LState
+	format   *lua.LFunction
+	filters  map[string]*lua.LFunction
+	noteName *lua.LFunction
+	bindings []scriptBinding
+}
+
+// scriptBinding is a key bound by the script to a function run on the
+// selected note
+type scriptBinding struct {
+	key  string
+	help string
+	fn   *lua.LFunction
+}
+
+// userScript is the loaded init.lua, nil when there is none
+var userScript *luaScript
+
+// scriptPath returns the location of init.lua, next to the config file
+func scriptPath() string {
+	return filepath.Join(filepath.Dir(configPath()), "init.lua")
+}
+
+// loadScript runs init.lua, if any, for it to register its functions
+func loadScript(path string) (*luaScript, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	s := &luaScript{state: lua.NewState(), filters: map[string]*lua.LFunction{}}
+	module := s.state.NewTable()
+	s.state.SetFuncs(module, map[string]lua.LGFunction{
+		"format": func(L *lua.LState) int {
+			s.format = L.CheckFunction(1)
+			return 0
+		},
+		"filter": func(L *lua.LState) int {
+			s.filters[L.CheckString(1)] = L.CheckFunction(2)
+			return 0
+		},
+		"new_note_name": func(L *lua.LState) int {
+			s.noteName = L.CheckFunction(1)
+			return 0
+		},
+		"bind": func(L *lua.LState) int {
+			s.bindings = append(s.bindings, scriptBinding{key: L.CheckString(1), help: L.CheckString(2), fn: L.CheckFunction(3)})
+			return 0
+		},
+	})
+	s.state.SetGlobal("snsm", module)
+
+	if err := s.state.DoFile(path); err != nil {
+		s.state.Close()
+		message, _, _ := strings.Cut(err.Error(), "\n")
+		return nil, fmt.Errorf("failed to run %s", message)
+	}
+	return s, nil
+}
+
+// noteTable passes a note to the script
+func (s *luaScript) noteTable(item noteItem) *lua.LTable {
+	t := s.state.NewTable()
+	path := item.path
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	t.RawSetString("filename", lua.LString(filepath.ToSlash(item.filename)))
+	t.RawSetString("path", lua.LString(path))
+	t.RawSetString("title", lua.LString(item.Title()))
+	t.RawSetString("label", lua.LString(item.label))
+	t.RawSetString("modified", lua.LNumber(item.modTime.Unix()))
+	tags := s.state.NewTable()
+	for _, tag := range strings.Fields(item.allTags()) {
+		tags.Append(lua.LString(strings.TrimPrefix(tag, "+")))
+	}
+	t.RawSetString("tags", tags)
+	return t
+}
+
+// call runs a function of the script and returns its result
+func (s *luaScript) call(fn *lua.LFunction, args ...lua.LValue) (lua.LValue, error) {
+	if err := s.state.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, args...); err != nil {
+		// Leave out the stack trace
+		message, _, _ := strings.Cut(err.Error(), "\n")
+		return lua.LNil, fmt.Errorf("%s", message)
+	}
+	ret := s.state.Get(-1)
+	s.state.Pop(1)
+	return ret, nil
+}
+
+// formatItem returns the text of a note in the list, when the script formats
+// it
+func (s *luaScript) formatItem(item noteItem) (string, bool) {
+	if s == nil || s.format == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret, err := s.call(s.format, s.noteTable(item))
+	if text, ok := ret.(lua.LString); ok && err == nil {
+		return string(text), true
+	}
+	return "", false
+}
+
+// filter returns the filter of the script with a name, for the filter:name
+// term of the queries
+func (s *luaScript) filter(name string) (func(item noteItem) bool, bool) {
+	if s == nil {
+		return nil, false
+	}
+	fn, ok := s.filters[name]
+	if !ok {
+		return nil, false
+	}
+	return func(item noteItem) bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		ret, err := s.call(fn, s.noteTable(item))
+		return err == nil && lua.LVAsBool(ret)
+	}, true
+}
+
+// newNoteName lets the script rename a new note from its typed title, folder
+// and tags
+func (s *luaScript) newNoteName(title, folder, tags string) (string, error) {
+	if s == nil || s.noteName == nil {
+		return title, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.state.NewTable()
+	t.RawSetString("title", lua.LString(title))
+	t.RawSetString("folder", lua.LString(folder))
+	t.RawSetString("tags", lua.LString(tags))
+	ret, err := s.call(s.noteName, t)
+	if err != nil {
+		return "", err
+	}
+	if name, ok := ret.(lua.LString); ok && name != "" {
+		return string(name), nil
+	}
+	return title, nil
+}
+
+// binding returns the function the script bound to a key
+func (s *luaScript) binding(key string) (scriptBinding, bool) {
+	if s == nil {
+		return scriptBinding{}, false
+	}
+	for _, b := range s.bindings {
+		if b.key == key {
+			return b, true
+		}
+	}
+	return scriptBinding{}, false
+}
+
+// runBinding runs the function of a key on the selected note, showing the
+// text it returns
+func (m model) runBinding(b scriptBinding) (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	s := userScript
+	s.mu.Lock()
+	ret, err := s.call(b.fn, s.noteTable(item))
+	s.mu.Unlock()
+
+	if err != nil {
+		return m, tea.Batch(m.reloadNotes(), m.setError("%s failed: %v", b.help, err))
+	}
+	if text, ok := ret.(lua.LString); ok && text != "" {
+		return m, tea.Batch(m.reloadNotes(), m.setInfo("%s", string(text)))
+	}
+	return m, m.reloadNotes()
+}
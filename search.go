@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// runSearch prints the notes matching a query, one per line
+func runSearch(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	long := flags.Bool("l", false, "also print the modification time and the tags of the notes")
+	semantic := flags.String("semantic", "", "find the notes about a text by meaning, the most relevant first")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	search := strings.Join(flags.Args(), " ")
+	if *semantic != "" {
+		search = strings.TrimSpace(`about:"` + strings.ReplaceAll(*semantic, `"`, "") + `" ` + search)
+	}
+	q, err := parseQuery(search)
+	if err != nil {
+		return err
+	}
+
+	files, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+	if err := q.prepare(files); err != nil {
+		return err
+	}
+
+	var matched []noteItem
+	for _, item := range files {
+		if q.matches(item) {
+			matched = append(matched, item)
+		}
+	}
+	q.rank(matched)
+
+	for _, item := range matched {
+		if *long {
+			fmt.Printf("%s\t%s\t%s\n", item.modTime.Format("2006-01-02 15:04"), item.filename, item.allTags())
+		} else {
+			fmt.Println(item.filename)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// savedSearch is a named query from the config, quickly applied from the
+// saved searches menu
+type savedSearch struct {
+	Name  string `yaml:"name"`
+	Query string `yaml:"query"`
+	// Sort orders the notes of the search rather than the sort of the list
+	Sort string `yaml:"sort"`
+}
+
+// search returns the saved search with a name
+func (m model) savedSearch(name string) (savedSearch, bool) {
+	for _, search := range m.savedSearches() {
+		if search.Name == name && name != "" {
+			return search, true
+		}
+	}
+	return savedSearch{}, false
+}
+
+// savedSearches returns the saved searches of the config, followed by the
+// Meetings view once there are meeting notes and the Stale view once there
+// are stale notes, unless the config has a search of their name
+func (m model) savedSearches() []savedSearch {
+	searches := m.cfg.Searches
+	if meetings, ok := m.meetingsView(); ok && !hasSearch(searches, meetingsSearch) {
+		searches = append(append([]savedSearch(nil), searches...), meetings)
+	}
+	if stale, ok := m.staleView(); ok && !hasSearch(searches, staleSearch) {
+		searches = append(append([]savedSearch(nil), searches...), stale)
+	}
+	return searches
+}
+
+// hasSearch reports whether there is a saved search with a name
+func hasSearch(searches []savedSearch, name string) bool {
+	for _, search := range searches {
+		if search.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// meetingsView returns the search listing the meeting notes, the most recent
+// first, when there are some
+func (m model) meetingsView() (savedSearch, bool) {
+	tag := meetingTag(m.cfg.Meetings)
+	if tag == "" {
+		return savedSearch{}, false
+	}
+	for _, item := range m.items {
+		if containsTag(strings.Fields(item.allTags()), "+"+tag) {
+			return savedSearch{Name: meetingsSearch, Query: "tag:" + tag, Sort: sortDate}, true
+		}
+	}
+	return savedSearch{}, false
+}
+
+// openSearches shows the menu of the saved searches
+func (m model) openSearches() (tea.Model, tea.Cmd) {
+	searches := m.savedSearches()
+	if len(searches) == 0 {
+		return m, m.setError("No saved searches configured")
+	}
+
+	m.searchMenu = menu{title: "Saved searches"}
+	for i, search := range searches {
+		m.searchMenu.items = append(m.searchMenu.items, menuItem{label: search.Name, hint: search.Query})
+		if search.Name == m.search.Name {
+			m.searchMenu.cursor = i
+		}
+	}
+
+	m.mode = modeSearches
+	return m, nil
+}
+
+func (m model) updateSearches(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.searchMenu.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+
+	m.mode = modeList
+	return m, m.applySearch(m.savedSearches()[m.searchMenu.cursor])
+}
+
+// applySearch only lists the notes matching a saved search, an empty search
+// listing them all again
+func (m *model) applySearch(search savedSearch) tea.Cmd {
+	q, err := parseQuery(search.Query)
+	if err != nil {
+		return m.setError("Invalid search %s: %v", search.Name, err)
+	}
+
+	m.search = search
+	m.searchQuery = q
+	m.list.ResetFilter()
+	m.list.Title = m.listTitle()
+	return m.setListItems()
+}
+
+// clearSearch lists all the notes again
+func (m *model) clearSearch() tea.Cmd {
+	return m.applySearch(savedSearch{})
+}
+
+// setListItems fills the list with the notes matching the active search
+func (m *model) setListItems() tea.Cmd {
+	if err := m.searchQuery.prepare(m.items); err != nil {
+		return m.setError("Cannot search %s: %v", m.search.Name, err)
+	}
+
+	mode := m.sortMode
+	if m.search.Sort != "" {
+		mode = m.search.Sort
+	}
+	var notes []noteItem
+	for _, item := range sortNotes(m.items, mode, m.history[m.notesDir]) {
+		if m.searchQuery.matches(item) && inFolder(item.filename, m.columns.folder) {
+			notes = append(notes, item)
+		}
+	}
+	m.searchQuery.rank(notes)
+	if m.browsing {
+		items, shown := m.browseItems(notes)
+		m.shown.set(shown)
+		return m.list.SetItems(items)
+	}
+	if m.group != "" {
+		items, shown := m.groupedItems(notes)
+		m.shown.set(shown)
+		return m.list.SetItems(items)
+	}
+	items := []list.Item{}
+	for _, item := range notes {
+		items = append(items, item)
+	}
+
+	m.shown.set(notes)
+	return m.list.SetItems(items)
+}
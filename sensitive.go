@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sensitiveTag marks the notes that are shredded rather than trashed, and
+// kept out of the versions, the header cache and the embeddings
+const sensitiveTag = "+sensitive"
+
+// sensitive reports whether a note is tagged +sensitive, or a tag nested
+// under it, directly or through its folder
+func (i noteItem) sensitive() bool {
+	for _, tag := range strings.Fields(i.allTags()) {
+		if tag == sensitiveTag || strings.HasPrefix(tag, sensitiveTag+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheable reports whether the title, tags and embedding of a note can be
+// kept in the caches of the state directory: not for sensitive notes, nor
+// for the decrypted notes of an encrypted vault
+func (i noteItem) cacheable() bool {
+	return !i.sensitive() && !inUnlockedVault(i.path)
+}
+
+// sensitiveFile reports whether a note of the notes directory is sensitive,
+// reading its tags
+func sensitiveFile(notesDir, filename string) bool {
+	path := filepath.Join(notesDir, filename)
+	tags, _, err := readHeader(path)
+	if err != nil {
+		return false
+	}
+	item := noteItem{path: path, filename: filename, tags: tags, folderTags: newFolderConfigs(notesDir).tags(filename)}
+	return item.sensitive()
+}
+
+// shredFile overwrites a file with random data before deleting it under a
+// random name, so that neither its content nor its name stay on the disk.
+// It returns a warning when the filesystem keeps copies of the blocks that
+// overwriting can't reach.
+func shredFile(path string) (string, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return "", err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+
+	buf := make([]byte, 32<<10)
+	for left := info.Size(); left > 0; {
+		n := int(min(left, int64(len(buf))))
+		rand.Read(buf[:n])
+		if _, err := f.Write(buf[:n]); err != nil {
+			f.Close()
+			return "", err
+		}
+		left -= int64(n)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	name := make([]byte, 8)
+	rand.Read(name)
+	hidden := filepath.Join(filepath.Dir(path), "."+hex.EncodeToString(name))
+	if err := os.Rename(path, hidden); err == nil {
+		path = hidden
+	}
+	if err := os.Remove(path); err != nil {
+		return "", err
+	}
+
+	var warning string
+	if fs := copyOnWriteFS(filepath.Dir(path)); fs != "" {
+		warning = fmt.Sprintf("%s is copy-on-write, older copies of the note may remain on the disk", fs)
+	}
+	return warning, nil
+}
+
+// shredNote shreds a note and its versions, and forgets it in the caches.
+// Nothing goes to the trash: it can't be undone. The backups have no copy of
+// sensitive notes, but keep the notes that weren't sensitive when backed up.
+func shredNote(notesDir, filename string) (string, error) {
+	path := filepath.Join(notesDir, filename)
+	versions, _ := listVersions(notesDir, filename)
+	warning, err := shredFile(path)
+	if err != nil {
+		return "", describeError(err)
+	}
+	for _, v := range versions {
+		shredFile(v.path)
+	}
+	os.Remove(filepath.Join(notesDir, filepath.FromSlash(versionsDir), filename))
+
+	headers.mu.Lock()
+	headers.load()
+	headers.forget(absPath(path))
+	headers.save()
+	headers.mu.Unlock()
+	embeddings.forget(absPath(path))
+	return warning, nil
+}
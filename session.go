@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// session is where the list of a vault was left, restored on the next start
+type session struct {
+	// Selected is the filename of the selected note
+	Selected string `yaml:"selected,omitempty"`
+	// Filter is the text of the applied filter
+	Filter string `yaml:"filter,omitempty"`
+	// Search is the name of the active saved search
+	Search string `yaml:"search,omitempty"`
+	Sort   string `yaml:"sort,omitempty"`
+	// Density is compact or comfortable, as toggled with z
+	Density string `yaml:"density,omitempty"`
+	// Group is how the notes are grouped, and Collapsed the sections
+	// collapsed
+	Group     string   `yaml:"group,omitempty"`
+	Collapsed []string `yaml:"collapsed,omitempty"`
+	// Browsing is set when browsing the folders, Folder being the one
+	// listed
+	Browsing bool   `yaml:"browsing,omitempty"`
+	Folder   string `yaml:"folder,omitempty"`
+}
+
+// currentSession returns the state of the list to restore on the next start
+func (m model) currentSession() session {
+	s := session{Search: m.search.Name, Sort: m.sortMode, Density: listLayout.density()}
+	if item, ok := m.list.SelectedItem().(noteItem); ok {
+		s.Selected = item.filename
+	}
+	s.Browsing, s.Folder = m.browsing, m.browseDir
+	if m.group != "" {
+		s.Group = m.group
+		for name, collapsed := range m.collapsed {
+			if collapsed {
+				s.Collapsed = append(s.Collapsed, name)
+			}
+		}
+		sort.Strings(s.Collapsed)
+	}
+	if m.list.FilterState() != list.Unfiltered {
+		s.Filter = m.list.FilterValue()
+	}
+	return s
+}
+
+// saveSession remembers the state of the list of the vault, but for an
+// encrypted vault whose note names would be saved in clear
+func (m model) saveSession() error {
+	if inUnlockedVault(m.notesDir) {
+		return nil
+	}
+	s := m.currentSession()
+	return updateState(func(state *appState) {
+		if state.Sessions == nil {
+			state.Sessions = map[string]session{}
+		}
+		state.Sessions[m.notesDir] = s
+	})
+}
+
+// restoreSession puts the list back in the state of a session. A filter is
+// applied once the list has filtered the notes, by finishRestore.
+func (m *model) restoreSession(s session) tea.Cmd {
+	if s.Sort != "" {
+		m.sortMode = s.Sort
+	}
+	if s.Density != "" {
+		m.setDensity(s.Density)
+	}
+	if s.Browsing {
+		m.browsing, m.browseDir = true, s.Folder
+		m.list.Title = m.listTitle()
+	}
+	if s.Group == groupTag || s.Group == groupFolder {
+		m.group = s.Group
+		for _, name := range s.Collapsed {
+			m.collapsed[name] = true
+		}
+	}
+
+	var cmds []tea.Cmd
+	if search, ok := m.savedSearch(s.Search); ok {
+		cmds = append(cmds, m.applySearch(search))
+	} else {
+		cmds = append(cmds, m.setListItems())
+	}
+
+	if s.Filter == "" {
+		m.selectNote(s.Selected)
+		return tea.Batch(cmds...)
+	}
+
+	m.restoring = &s
+	filtered, cmd := m.filterWith(s.Filter)
+	*m = filtered.(model)
+	return tea.Batch(append(cmds, cmd)...)
+}
+
+// finishRestore applies the filter of the session being restored, once the
+// list has filtered the notes, and selects its note
+func (m *model) finishRestore() tea.Cmd {
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m.selectNote(m.restoring.Selected)
+	m.restoring = nil
+	return cmd
+}
+
+// selectNote moves the cursor to a note of the list, if shown
+func (m *model) selectNote(filename string) {
+	if filename == "" {
+		return
+	}
+	for i, listItem := range m.list.VisibleItems() {
+		if item, ok := listItem.(noteItem); ok && item.filename == filename {
+			m.list.Select(i)
+			return
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// shareConfig sets where notes are uploaded to be shared
+type shareConfig struct {
+	// Service is gist, the default, uploading secret gists, or paste
+	Service string `yaml:"service"`
+	// PasteURL is the paste service receiving the note as the file field of
+	// a form and answering its URL, https://0x0.st by default
+	PasteURL string `yaml:"paste_url"`
+}
+
+// shareClient uploads the notes, with a timeout
+var shareClient = &http.Client{Timeout: 30 * time.Second}
+
+// githubToken returns the token creating gists: $GITHUB_TOKEN, or the one of
+// the GitHub CLI
+func githubToken() (string, error) {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token, nil
+	}
+	if _, err := exec.LookPath("gh"); err == nil {
+		if out, err := exec.Command("gh", "auth", "token").Output(); err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	return "", fmt.Errorf("set GITHUB_TOKEN to a token with the gist scope, or log in with gh auth login")
+}
+
+// uploadGist uploads a note as a secret gist and returns its URL
+func uploadGist(filename, description string, content []byte) (string, error) {
+	token, err := githubToken()
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"description": description,
+		"public":      false,
+		"files": map[string]any{
+			filepath.Base(filename): map[string]string{"content": string(content)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://api.github.com/gists", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := shareClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var gist struct {
+		HTMLURL string `json:"html_url"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&gist); err != nil {
+		return "", fmt.Errorf("unexpected answer from GitHub: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("GitHub refused the gist: %s", gist.Message)
+	}
+	return gist.HTMLURL, nil
+}
+
+// uploadPaste uploads a note to a paste service like 0x0.st and returns its
+// URL
+func uploadPaste(endpoint, filename string, content []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return "", err
+	}
+	part.Write(content)
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("User-Agent", "snsm")
+
+	resp, err := shareClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	url := strings.TrimSpace(string(answer))
+	if resp.StatusCode >= 300 || !strings.HasPrefix(url, "http") {
+		return "", fmt.Errorf("%s refused the note: %s", endpoint, resp.Status)
+	}
+	return url, nil
+}
+
+// destination describes where a note is uploaded, for the confirmation
+func (c shareConfig) destination() string {
+	if c.Service == "paste" {
+		return c.pasteURL()
+	}
+	return "a secret GitHub gist"
+}
+
+func (c shareConfig) pasteURL() string {
+	if c.PasteURL != "" {
+		return c.PasteURL
+	}
+	return "https://0x0.st"
+}
+
+// shareNote uploads a note and returns its URL
+func shareNote(c shareConfig, notesDir, filename string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(notesDir, filename))
+	if err != nil {
+		return "", err
+	}
+	if c.Service == "paste" {
+		return uploadPaste(c.pasteURL(), filename, content)
+	}
+	return uploadGist(filename, noteTitle(filename), content)
+}
+
+// shareFinishedMsg is sent when a note was uploaded
+type shareFinishedMsg struct {
+	filename string
+	url      string
+	err      error
+}
+
+// confirmShare asks before uploading the selected note
+func (m model) confirmShare() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	m.sharing = item.filename
+	m.shareMenu = menu{
+		title: "Upload " + item.filename + " to " + m.cfg.Share.destination() + "?",
+		items: []menuItem{
+			{label: "Upload", hint: "anyone with the link can read it"},
+			{label: "Cancel"},
+		},
+	}
+	m.mode = modeShare
+	return m, nil
+}
+
+func (m model) updateShare(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.shareMenu.update(msg)
+	if closed || (chosen && m.shareMenu.cursor == 1) {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+
+	m.mode = modeList
+	c, notesDir, filename := m.cfg.Share, m.notesDir, m.sharing
+	upload := func() tea.Msg {
+		url, err := shareNote(c, notesDir, filename)
+		return shareFinishedMsg{filename: filename, url: url, err: err}
+	}
+	return m, tea.Batch(m.setInfo("Uploading %s...", filename), upload)
+}
+
+// shared reports the URL of an uploaded note, copied to the clipboard
+func (m model) shared(msg shareFinishedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		return m, m.setError("Cannot share %s: %v", msg.filename, describeError(msg.err))
+	}
+	if err := clipboard.WriteAll(msg.url); err != nil {
+		return m, m.setInfo("Shared at %s", msg.url)
+	}
+	return m, m.setInfo("Shared at %s, copied to the clipboard", msg.url)
+}
+
+func runShare(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("share", flag.ContinueOnError)
+	service := flags.String("service", cfg.Share.Service, "gist or paste")
+	yes := flags.Bool("yes", false, "upload without asking")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected the note to share")
+	}
+	c := cfg.Share
+	c.Service = *service
+	if c.Service != "" && c.Service != "gist" && c.Service != "paste" {
+		return fmt.Errorf("unknown service %q, expected gist or paste", c.Service)
+	}
+
+	filename := noteFilename(notesDir, flags.Arg(0))
+	if _, err := os.Stat(filepath.Join(notesDir, filename)); err != nil {
+		return err
+	}
+	if !*yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("not a terminal, pass --yes to upload %s without confirmation", filename)
+		}
+		if !askForConfirmation(fmt.Sprintf("Upload %s to %s? Anyone with the link can read it.", filename, c.destination())) {
+			return fmt.Errorf("canceled")
+		}
+	}
+
+	url, err := shareNote(c, notesDir, filename)
+	if err != nil {
+		return err
+	}
+	fmt.Println(url)
+	if clipboard.WriteAll(url) == nil {
+		fmt.Fprintln(os.Stderr, "Copied to the clipboard")
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import "syscall"
+
+// copyOnWriteFS returns the name of the filesystem of a directory when it
+// writes changes to new blocks, leaving the former ones on the disk
+func copyOnWriteFS(dir string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return ""
+	}
+	var name []byte
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	if string(name) == "apfs" {
+		return "APFS"
+	}
+	return ""
+}
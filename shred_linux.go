@@ -0,0 +1,21 @@
+package main
+
+import "syscall"
+
+// copyOnWriteMagics are the copy-on-write filesystems, by the magic number
+// of statfs
+var copyOnWriteMagics = map[uint32]string{
+	0x9123683e: "btrfs",
+	0x2fc12fc1: "zfs",
+	0xca451a4e: "bcachefs",
+}
+
+// copyOnWriteFS returns the name of the filesystem of a directory when it
+// writes changes to new blocks, leaving the former ones on the disk
+func copyOnWriteFS(dir string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return ""
+	}
+	return copyOnWriteMagics[uint32(st.Type)]
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !linux
+
+package main
+
+// copyOnWriteFS can't tell the filesystem on this system, overwriting being
+// best effort
+func copyOnWriteFS(dir string) string {
+	return ""
+}
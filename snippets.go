@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// snippetsDir returns the directory holding the snippets
+func (cfg config) snippetsDir(notesDir string) string {
+	if cfg.SnippetsDir != "" {
+		return expandTilde(cfg.SnippetsDir)
+	}
+	return filepath.Join(notesDir, ".snippets")
+}
+
+// snippet is a piece of text to insert into notes, like a checklist
+type snippet struct {
+	name string
+	path string
+}
+
+// listSnippets returns the snippets of a directory, named after their file
+// without its extension
+func listSnippets(dir string) []snippet {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var snippets []snippet
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if !seen[name] {
+			seen[name] = true
+			snippets = append(snippets, snippet{name: name, path: filepath.Join(dir, entry.Name())})
+		}
+	}
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].name < snippets[j].name })
+	return snippets
+}
+
+// expandSnippet reads a snippet and fills in its placeholders: {{title}} and
+// {{filename}} of the note it is inserted into, {{date}}, {{time}} and
+// {{datetime}}
+func expandSnippet(s snippet, item noteItem, now time.Time) (string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read snippet %s: %v", s.name, err)
+	}
+	return strings.NewReplacer(
+		"{{title}}", item.Title(),
+		"{{filename}}", item.filename,
+		"{{datetime}}", now.Format("2006-01-02 15:04"),
+		"{{date}}", now.Format("2006-01-02"),
+		"{{time}}", now.Format("15:04"),
+	).Replace(string(data)), nil
+}
+
+// snippetHint returns the first line of a snippet, shown next to its name
+func snippetHint(s snippet) string {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return truncateWidth(line, 40)
+		}
+	}
+	return ""
+}
+
+// appendSnippet appends text at the end of a note, after a blank line
+func appendSnippet(path, text string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	note := string(content)
+	switch {
+	case note == "":
+	case strings.HasSuffix(note, "\n\n"):
+	case strings.HasSuffix(note, "\n"):
+		note += "\n"
+	default:
+		note += "\n\n"
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return os.WriteFile(path, []byte(note+text), 0644)
+}
+
+// openSnippets shows the menu of the snippets, to insert one into the
+// selected note, or at the cursor of the built-in editor
+func (m model) openSnippets() (tea.Model, tea.Cmd) {
+	if m.mode != modeEditor {
+		if _, ok := m.list.SelectedItem().(noteItem); !ok {
+			return m, nil
+		}
+	}
+	dir := m.cfg.snippetsDir(m.notesDir)
+	m.snippets = listSnippets(dir)
+	if len(m.snippets) == 0 {
+		return m, m.setError("No snippets in %s", dir)
+	}
+
+	m.snippetMenu = menu{title: "Insert a snippet"}
+	for _, s := range m.snippets {
+		m.snippetMenu.items = append(m.snippetMenu.items, menuItem{label: s.name, hint: snippetHint(s)})
+	}
+	m.snippetReturn = m.mode
+	m.mode = modeSnippets
+	return m, nil
+}
+
+func (m model) updateSnippets(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.snippetMenu.update(msg)
+	if closed {
+		m.mode = m.snippetReturn
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+	m.mode = m.snippetReturn
+	return m.insertSnippet(m.snippets[m.snippetMenu.cursor])
+}
+
+// insertSnippet inserts a snippet at the cursor of the built-in editor, or
+// at the end of the selected note
+func (m model) insertSnippet(s snippet) (tea.Model, tea.Cmd) {
+	if m.mode == modeEditor {
+		item, _ := m.findItem(m.editor.filename)
+		item.filename = m.editor.filename
+		text, err := expandSnippet(s, item, time.Now())
+		if err != nil {
+			return m, m.setError("%v", err)
+		}
+		m.editor.area.InsertString(text)
+		return m, nil
+	}
+
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	text, err := expandSnippet(s, item, time.Now())
+	if err != nil {
+		return m, m.setError("%v", err)
+	}
+	before, err := os.ReadFile(item.path)
+	if err != nil {
+		return m, m.setError("Cannot insert into %s: %v", item.filename, describeError(err))
+	}
+	saveVersion(m.notesDir, item.filename, time.Now())
+	if err := appendSnippet(item.path, text); err != nil {
+		return m, m.setError("Cannot insert into %s: %v", item.filename, describeError(err))
+	}
+	m.pushUndo("inserting "+s.name+" into "+item.filename, restoreContent(m.notesDir, item.filename, before))
+	done := fmt.Sprintf("Inserted %s into %s", s.name, item.filename)
+	return m, tea.Batch(m.reloadNotes(), m.setInfo("%s", done), m.noteSavedCmd("post_edit", item.filename, done))
+}
+
+// exSnippetCandidates returns the names of the snippets
+func exSnippetCandidates(m model) []string {
+	var names []string
+	for _, s := range listSnippets(m.cfg.snippetsDir(m.notesDir)) {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// exSnippet inserts a snippet into the selected note, or shows the menu of
+// the snippets without a name
+func exSnippet(m model, args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 {
+		return m.openSnippets()
+	}
+	if _, err := m.selectedNote("snippet"); err != nil {
+		return m, m.setError("%v", err)
+	}
+	name := strings.Join(args, " ")
+	for _, s := range listSnippets(m.cfg.snippetsDir(m.notesDir)) {
+		if s.name == name {
+			return m.insertSnippet(s)
+		}
+	}
+	return m, m.setError("No snippet named %s", name)
+}
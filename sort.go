@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Orders of the notes of the list
+const (
+	sortName     = "name"
+	sortModified = "modified"
+	sortFrecency = "frecency"
+	sortDue      = "due"
+	sortDate     = "date"
+)
+
+// sortModes are the orders the sort key cycles through, the first one being
+// the default
+var sortModes = []string{sortName, sortModified, sortFrecency, sortDue, sortDate}
+
+// sortNotes returns the notes in the order of a sort mode, opens being when
+// the notes were opened, for the frecency
+func sortNotes(items []noteItem, mode string, opens map[string][]time.Time) []noteItem {
+	sorted := append([]noteItem(nil), items...)
+	switch mode {
+	case sortFrecency:
+		now := time.Now()
+		scores := map[string]float64{}
+		for _, item := range sorted {
+			scores[item.filename] = frecency(opens[item.filename], now)
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return scores[sorted[i].filename] > scores[sorted[j].filename]
+		})
+	case sortModified:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].modTime.After(sorted[j].modTime)
+		})
+	case sortDue:
+		// Notes without due date come last
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, b := sorted[i].due, sorted[j].due
+			if a.IsZero() || b.IsZero() {
+				return !a.IsZero() && b.IsZero()
+			}
+			return a.Before(b)
+		})
+	case sortDate:
+		// Notes without date come last, by name
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, b := sorted[i].date, sorted[j].date
+			if a.IsZero() || b.IsZero() {
+				return !a.IsZero() && b.IsZero()
+			}
+			return a.After(b)
+		})
+	default:
+		// The notes are scanned in the order of their path
+	}
+	return sorted
+}
+
+// sortDescription tells how the notes are sorted, for the status bar
+func sortDescription(mode string) string {
+	switch mode {
+	case sortModified:
+		return "last modified first"
+	case sortFrecency:
+		return "most used first"
+	case sortDue:
+		return "due date"
+	case sortDate:
+		return "date, newest first"
+	default:
+		return "name"
+	}
+}
+
+// cycleSort sorts the notes in the next sort mode, keeping the selection
+func (m model) cycleSort() (tea.Model, tea.Cmd) {
+	next := 0
+	for i, mode := range sortModes {
+		if mode == m.sortMode || (m.sortMode == "" && mode == sortName) {
+			next = (i + 1) % len(sortModes)
+		}
+	}
+	return m.sortBy(sortModes[next])
+}
+
+// sortBy sorts the notes in a sort mode, keeping the selection
+func (m model) sortBy(mode string) (tea.Model, tea.Cmd) {
+	m.sortMode = mode
+
+	var selected string
+	if item, ok := m.list.SelectedItem().(noteItem); ok {
+		selected = item.filename
+	}
+	cmd := m.setListItems()
+	m.selectNote(selected)
+
+	return m, tea.Batch(cmd, m.setInfo("Sorted by %s", sortDescription(m.sortMode)))
+}
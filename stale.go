@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// staleSearch is the name of the saved search listing the stale notes
+const staleSearch = "Stale"
+
+// defaultStaleAfter is how long notes stay unmodified before being stale
+const defaultStaleAfter = "1y"
+
+// staleAge is the age of the notes dimmed in the list as stale, set from the
+// config, 0 when stale_after is off
+var staleAge time.Duration
+
+// parseStaleAfter parses stale_after, an age like 1y or 26w, or off
+func parseStaleAfter(value string) (time.Duration, error) {
+	if value == "off" {
+		return 0, nil
+	}
+	return parseAge(value)
+}
+
+// isStale reports whether a note wasn't modified for stale_after
+func isStale(modTime, now time.Time) bool {
+	return staleAge > 0 && !modTime.IsZero() && now.Sub(modTime) > staleAge
+}
+
+// ageBadge tells how long a stale note has been left alone, like "2y old"
+// or "14mo old", dimmed
+func ageBadge(modTime, now time.Time) string {
+	if !isStale(modTime, now) {
+		return ""
+	}
+	days := int(now.Sub(modTime).Hours() / 24)
+	if days >= 365 {
+		return mutedStyle.Render(fmt.Sprintf("%dy old", days/365))
+	}
+	return mutedStyle.Render(fmt.Sprintf("%dmo old", max(days/30, 1)))
+}
+
+// staleView returns the search listing the stale notes, the least recently
+// modified last, when there are some
+func (m model) staleView() (savedSearch, bool) {
+	now := time.Now()
+	for _, item := range m.items {
+		if isStale(item.modTime, now) {
+			return savedSearch{Name: staleSearch, Query: "modified>" + m.cfg.StaleAfter, Sort: sortModified}, true
+		}
+	}
+	return savedSearch{}, false
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/yaml.v3"
+)
+
+// appState is what snsm remembers between sessions
+type appState struct {
+	// Vault is the name of the last used vault
+	Vault string `yaml:"vault,omitempty"`
+	// Sessions are where the list was left, by notes directory
+	Sessions map[string]session `yaml:"sessions,omitempty"`
+}
+
+// statePath returns the location of the state file, following the XDG base
+// directory specification
+func statePath() string {
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if stateDir == "" && runtime.GOOS == "windows" {
+		stateDir = os.Getenv("LOCALAPPDATA")
+	}
+	if stateDir == "" {
+		stateDir = expandTilde("~/.local/state")
+	}
+	return filepath.Join(stateDir, "snsm", "state.yaml")
+}
+
+// loadState reads the state file, a missing or broken file gives an empty state
+func loadState() appState {
+	var state appState
+
+	data, err := os.ReadFile(statePath())
+	if err != nil {
+		return state
+	}
+	yaml.Unmarshal(data, &state)
+
+	return state
+}
+
+// updateState changes the state file, read again while locked so that the
+// changes of other instances are kept
+func updateState(change func(state *appState)) error {
+	return withFileLock(statePath(), func() error {
+		state := loadState()
+		change(&state)
+		return writeStateFile(statePath(), state)
+	})
+}
+
+// writeStateFile writes a file of the state directory as YAML, atomically.
+// Files changed by several instances are written while locked with
+// withFileLock.
+func writeStateFile(path string, v any) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to save %s: %v", filepath.Base(path), err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to save %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
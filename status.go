@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// statusTimeout is how long a message stays in the status bar
+const statusTimeout = 4 * time.Second
+
+// statusMessage is a transient message shown at the bottom of the screen
+type statusMessage struct {
+	text    string
+	isError bool
+}
+
+// clearStatusMsg clears the status bar, unless a newer message replaced the
+// one it was scheduled for
+type clearStatusMsg struct {
+	id int
+}
+
+// setStatus shows a message in the status bar and schedules its removal
+func (m *model) setStatus(text string, isError bool) tea.Cmd {
+	m.statusID++
+	m.status = statusMessage{text: text, isError: isError}
+
+	id := m.statusID
+	return tea.Tick(statusTimeout, func(time.Time) tea.Msg {
+		return clearStatusMsg{id: id}
+	})
+}
+
+// setInfo shows a confirmation in the status bar
+func (m *model) setInfo(format string, args ...any) tea.Cmd {
+	return m.setStatus(fmt.Sprintf(format, args...), false)
+}
+
+// setError shows an error in the status bar
+func (m *model) setError(format string, args ...any) tea.Cmd {
+	debugLog.Error(fmt.Sprintf(format, args...), "mode", m.mode)
+	return m.setStatus(fmt.Sprintf(format, args...), true)
+}
+
+// describeError drops the path of file errors, which is already displayed
+func describeError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+func (m model) statusView() string {
+	if m.status.text == "" {
+		return ""
+	}
+
+	style := statusStyle
+	if m.status.isError {
+		style = statusErrorStyle
+	}
+	return style.Render(m.status.text)
+}
+
+// withStatus places the status bar below a view, on the last line
+func (m model) withStatus(view string) string {
+	status := m.statusView()
+	if status == "" && m.mode == modeList {
+		status = m.infoBar()
+	}
+	if status == "" {
+		return view
+	}
+
+	// Keep the status bar on the last line of the screen
+	gap := m.height - lipgloss.Height(view)
+	if gap < 1 {
+		gap = 1
+	}
+	return view + strings.Repeat("\n", gap) + status
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// repoStatus is the git status of the notes directory, shown in the status
+// bar
+type repoStatus struct {
+	// repo is set when the notes directory is in a git repository
+	repo          bool
+	branch        string
+	upstream      bool
+	ahead, behind int
+	changed       int
+}
+
+// gitStatusMsg carries the git status of a notes directory, read in the
+// background
+type gitStatusMsg struct {
+	notesDir string
+	status   repoStatus
+}
+
+// parseGitStatus reads the output of git status --porcelain --branch, whose
+// first line is like ## main...origin/main [ahead 1, behind 2]
+func parseGitStatus(out string) repoStatus {
+	status := repoStatus{repo: true}
+	for _, line := range strings.Split(out, "\n") {
+		branch, ok := strings.CutPrefix(line, "## ")
+		if !ok {
+			if strings.TrimSpace(line) != "" {
+				status.changed++
+			}
+			continue
+		}
+
+		branch = strings.TrimPrefix(branch, "No commits yet on ")
+		branch, counts, _ := strings.Cut(branch, " [")
+		status.branch, _, status.upstream = strings.Cut(branch, "...")
+		for _, part := range strings.Split(strings.TrimSuffix(counts, "]"), ", ") {
+			if n, ok := strings.CutPrefix(part, "ahead "); ok {
+				status.ahead, _ = strconv.Atoi(n)
+			}
+			if n, ok := strings.CutPrefix(part, "behind "); ok {
+				status.behind, _ = strconv.Atoi(n)
+			}
+		}
+	}
+	return status
+}
+
+// readGitStatus reads the git status of the notes directory in the
+// background, leaving out the directories that aren't repositories
+func readGitStatus(notesDir string) tea.Cmd {
+	return func() tea.Msg {
+		out, err := git(notesDir, "status", "--porcelain", "--branch", "--", ".")
+		if err != nil {
+			return gitStatusMsg{notesDir: notesDir}
+		}
+		return gitStatusMsg{notesDir: notesDir, status: parseGitStatus(out)}
+	}
+}
+
+// describe tells where the notes stand against their remote, like
+// "main ↑2 ↓1, 3 changed"
+func (s repoStatus) describe() string {
+	text := s.branch
+	if s.ahead > 0 {
+		text += fmt.Sprintf(" ↑%d", s.ahead)
+	}
+	if s.behind > 0 {
+		text += fmt.Sprintf(" ↓%d", s.behind)
+	}
+	if s.upstream && s.ahead == 0 && s.behind == 0 {
+		text += " ✓"
+	}
+	if s.changed > 0 {
+		text += fmt.Sprintf(", %d changed", s.changed)
+	}
+	return text
+}
+
+// filterTags returns the tag terms of the saved search and of the filter
+func (m model) filterTags() []string {
+	var tags []string
+	for _, text := range []string{m.search.Query, m.list.FilterValue()} {
+		words, err := splitQuery(text)
+		if err != nil {
+			continue
+		}
+		for _, word := range words {
+			if strings.HasPrefix(strings.TrimPrefix(word, "-"), "tag:") {
+				tags = append(tags, word)
+			}
+		}
+	}
+	return tags
+}
+
+// infoBar describes the list on the last line of the screen when there is
+// no message: the vault, the number of notes, the sort, the tags filtered on
+// and the git status
+func (m model) infoBar() string {
+	var parts []string
+	if m.vaultName != "" {
+		parts = append(parts, m.vaultName)
+	}
+
+	shown := 0
+	for _, listItem := range m.list.VisibleItems() {
+		if _, ok := listItem.(noteItem); ok {
+			shown++
+		}
+	}
+	if shown == len(m.items) {
+		parts = append(parts, fmt.Sprintf("%d notes", len(m.items)))
+	} else {
+		parts = append(parts, fmt.Sprintf("%d of %d notes", shown, len(m.items)))
+	}
+
+	if marked := len(m.markedFilenames()); marked > 0 {
+		parts = append(parts, fmt.Sprintf("%d marked", marked))
+	}
+
+	mode := m.sortMode
+	if m.search.Sort != "" {
+		mode = m.search.Sort
+	}
+	parts = append(parts, "by "+sortDescription(mode))
+
+	if tags := m.filterTags(); len(tags) > 0 {
+		parts = append(parts, strings.Join(tags, " "))
+	}
+	if m.syncing {
+		parts = append(parts, "syncing")
+	} else if m.gitStatus.repo {
+		parts = append(parts, m.gitStatus.describe())
+	}
+
+	return mutedStyle.Copy().PaddingLeft(2).Render(truncateWidth(strings.Join(parts, " • "), max(m.width-4, 10)))
+}
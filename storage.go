@@ -0,0 +1,403 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// storageConfig sets the object storage snsm sync-storage syncs the notes
+// with: an S3 bucket or a WebDAV folder, like Nextcloud's
+type storageConfig struct {
+	// Type is s3 or webdav
+	Type string `yaml:"type"`
+	// URL is the folder of the notes on the WebDAV server, or the endpoint
+	// of the S3 service, https://s3.amazonaws.com by default
+	URL string `yaml:"url"`
+	// User and Password log in to the WebDAV server. PasswordEnv names the
+	// environment variable holding the password, to keep it out of the
+	// config.
+	User        string `yaml:"user"`
+	Password    string `yaml:"password"`
+	PasswordEnv string `yaml:"password_env"`
+	// Bucket, Region and Prefix locate the notes on S3. The keys are read
+	// from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY when left out.
+	Bucket    string `yaml:"bucket"`
+	Region    string `yaml:"region"`
+	Prefix    string `yaml:"prefix"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+}
+
+// remoteObject is a file of the object storage
+type remoteObject struct {
+	etag    string
+	modTime time.Time
+}
+
+// objectStore is an object storage the notes are synced with, files being
+// named by their path relative to the notes directory
+type objectStore interface {
+	list() (map[string]remoteObject, error)
+	get(name string) ([]byte, remoteObject, error)
+	// put returns the etag of the uploaded file
+	put(name string, data []byte) (string, error)
+	delete(name string) error
+}
+
+// newObjectStore returns the object storage of the config
+func (c storageConfig) newObjectStore() (objectStore, error) {
+	switch c.Type {
+	case "webdav":
+		password := c.Password
+		if c.PasswordEnv != "" {
+			password = os.Getenv(c.PasswordEnv)
+		}
+		if c.URL == "" {
+			return nil, fmt.Errorf("storage.url is required for webdav")
+		}
+		return newWebDAVStore(c.URL, c.User, password), nil
+	case "s3":
+		accessKey, secretKey := c.AccessKey, c.SecretKey
+		if accessKey == "" {
+			accessKey, secretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		if c.Bucket == "" || accessKey == "" || secretKey == "" {
+			return nil, fmt.Errorf("storage.bucket and the access keys are required for s3")
+		}
+		return newS3Store(c.URL, c.Region, c.Bucket, c.Prefix, accessKey, secretKey), nil
+	case "":
+		return nil, fmt.Errorf("no storage, set storage in the config")
+	}
+	return nil, fmt.Errorf("unknown storage type %q, expected s3 or webdav", c.Type)
+}
+
+// syncedFile is the state of a file after the last sync: the etag of the
+// remote copy and the hash of the local one
+type syncedFile struct {
+	ETag string `yaml:"etag"`
+	Hash string `yaml:"hash"`
+}
+
+// storageStates holds the synced files, by notes directory and filename
+type storageStates map[string]map[string]syncedFile
+
+// storagePath returns the location of the sync states, next to the state
+func storagePath() string {
+	return filepath.Join(filepath.Dir(statePath()), "storage.yaml")
+}
+
+// loadStorageStates reads the sync states, a missing or broken file giving
+// none, which makes the next sync compare every file
+func loadStorageStates() storageStates {
+	states := storageStates{}
+	data, err := os.ReadFile(storagePath())
+	if err != nil {
+		return states
+	}
+	yaml.Unmarshal(data, &states)
+	return states
+}
+
+// localFile is a file of the notes directory
+type localFile struct {
+	hash    string
+	modTime time.Time
+}
+
+// unsafeRemoteName tells why a file of the storage can't be synced: its name
+// leads out of the notes directory, or into the .git or .snsm folders the sync
+// leaves alone
+func unsafeRemoteName(name string) string {
+	path := filepath.FromSlash(name)
+	if !filepath.IsLocal(path) {
+		return "outside the notes directory"
+	}
+	for _, part := range strings.Split(path, string(filepath.Separator)) {
+		if part == ".git" || part == snsmDir {
+			return "in a " + part + " folder"
+		}
+	}
+	return ""
+}
+
+// listLocalFiles returns the files of the notes directory, except the ones
+// of git and of the .snsm folder
+func listLocalFiles(notesDir string) (map[string]localFile, error) {
+	files := map[string]localFile{}
+	stateDir := filepath.Join(notesDir, snsmDir)
+	err := filepath.WalkDir(notesDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" || path == stateDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(notesDir, path)
+		files[filepath.ToSlash(name)] = localFile{hash: hashContent(data), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Actions of a sync on a file
+const (
+	actionUpload         = "upload"
+	actionDownload       = "download"
+	actionDeleteRemote   = "delete remote"
+	actionDeleteLocal    = "delete local"
+	actionConflictLocal  = "conflict, keep local"
+	actionConflictRemote = "conflict, keep remote"
+)
+
+// syncAction is what a sync does to a file
+type syncAction struct {
+	name   string
+	action string
+}
+
+// planStorageSync compares the local and remote files with their state at the
+// last sync. Files changed on one side are copied to the other, files deleted
+// on one side and unchanged on the other are deleted, and files changed on
+// both sides are won by the last one written, the other one being kept as a
+// conflict copy.
+func planStorageSync(local map[string]localFile, remote map[string]remoteObject, synced map[string]syncedFile) []syncAction {
+	names := map[string]bool{}
+	for name := range local {
+		names[name] = true
+	}
+	for name := range remote {
+		names[name] = true
+	}
+
+	var actions []syncAction
+	for name := range names {
+		l, hasLocal := local[name]
+		r, hasRemote := remote[name]
+		s, wasSynced := synced[name]
+		localChanged := !wasSynced || !hasLocal || l.hash != s.Hash
+		remoteChanged := !wasSynced || !hasRemote || r.etag != s.ETag
+
+		action := ""
+		switch {
+		case hasLocal && !hasRemote:
+			action = actionUpload
+			if wasSynced && !localChanged {
+				action = actionDeleteLocal
+			}
+		case !hasLocal && hasRemote:
+			action = actionDownload
+			if wasSynced && !remoteChanged {
+				action = actionDeleteRemote
+			}
+		case localChanged && remoteChanged:
+			action = actionConflictRemote
+			if l.modTime.After(r.modTime) {
+				action = actionConflictLocal
+			}
+		case localChanged:
+			action = actionUpload
+		case remoteChanged:
+			action = actionDownload
+		}
+		if action != "" {
+			actions = append(actions, syncAction{name: name, action: action})
+		}
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].name < actions[j].name
+	})
+	return actions
+}
+
+// conflictName returns the name of the conflict copy of a file
+func conflictName(name string, now time.Time) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + " (conflict " + now.Format("2006-01-02 150405") + ")" + ext
+}
+
+// applySyncAction runs an action of a sync, updating the synced state of its
+// file
+func applySyncAction(store objectStore, notesDir string, a syncAction, synced map[string]syncedFile, now time.Time) error {
+	path := filepath.Join(notesDir, filepath.FromSlash(a.name))
+
+	upload := func() error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		etag, err := store.put(a.name, data)
+		if err != nil {
+			return err
+		}
+		synced[a.name] = syncedFile{ETag: etag, Hash: hashContent(data)}
+		return nil
+	}
+	download := func() error {
+		data, object, err := store.get(a.name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+		if !object.modTime.IsZero() {
+			os.Chtimes(path, object.modTime, object.modTime)
+		}
+		synced[a.name] = syncedFile{ETag: object.etag, Hash: hashContent(data)}
+		return nil
+	}
+
+	switch a.action {
+	case actionUpload:
+		return upload()
+	case actionDownload:
+		return download()
+	case actionDeleteRemote:
+		delete(synced, a.name)
+		return store.delete(a.name)
+	case actionDeleteLocal:
+		delete(synced, a.name)
+		return os.Remove(path)
+	case actionConflictLocal:
+		// The remote copy is kept next to the local one, uploaded next time
+		data, _, err := store.get(a.name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(notesDir, filepath.FromSlash(conflictName(a.name, now))), data, 0644); err != nil {
+			return err
+		}
+		return upload()
+	case actionConflictRemote:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(notesDir, filepath.FromSlash(conflictName(a.name, now))), data, 0644); err != nil {
+			return err
+		}
+		return download()
+	}
+	return nil
+}
+
+func runSyncStorage(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("sync-storage", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only list what would be synced")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := cfg.Storage.newObjectStore()
+	if err != nil {
+		return err
+	}
+
+	local, err := listLocalFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list the notes: %v", err)
+	}
+	remote, err := store.list()
+	if err != nil {
+		return fmt.Errorf("failed to list the storage: %v", err)
+	}
+	// A name of the storage is a path written in the notes directory
+	for name := range remote {
+		if problem := unsafeRemoteName(name); problem != "" {
+			fmt.Fprintf(os.Stderr, "Skipping %s of the storage: %s\n", name, problem)
+			delete(remote, name)
+		}
+	}
+
+	states := loadStorageStates()
+	synced := states[notesDir]
+	if synced == nil {
+		synced = map[string]syncedFile{}
+	}
+	// Files on both sides never synced are compared, identical ones being
+	// synced already rather than conflicting, like on the first sync
+	for name, l := range local {
+		if _, ok := synced[name]; ok {
+			continue
+		}
+		if _, ok := remote[name]; !ok {
+			continue
+		}
+		data, object, err := store.get(name)
+		if err == nil && hashContent(data) == l.hash {
+			synced[name] = syncedFile{ETag: object.etag, Hash: l.hash}
+		}
+	}
+
+	actions := planStorageSync(local, remote, synced)
+	if len(actions) == 0 {
+		fmt.Println("Already up to date")
+		return nil
+	}
+
+	now := time.Now()
+	failed := 0
+	for _, a := range actions {
+		fmt.Printf("  %-21s  %s\n", a.action, a.name)
+		if *dryRun {
+			continue
+		}
+		if err := applySyncAction(store, notesDir, a, synced, now); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot %s %s: %v\n", a.action, a.name, err)
+			failed++
+		}
+	}
+	if *dryRun {
+		return nil
+	}
+
+	// Only the states of this notes directory change, the ones of a sync
+	// of another directory running meanwhile are kept
+	err = withFileLock(storagePath(), func() error {
+		states := loadStorageStates()
+		states[notesDir] = synced
+		return writeStateFile(storagePath(), states)
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to sync", failed, len(actions))
+	}
+	fmt.Printf("Synced %d files\n", len(actions))
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// rewriteTags replaces the tags of a note with the ones retag returns from
+// its current tags, in its tag line or its org-mode #+FILETAGS keyword. A
+// note left without tags loses its tag line.
+func rewriteTags(path string, retag func(existing string) string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content := string(data)
+
+	if strings.ToLower(filepath.Ext(path)) == ".org" {
+		lines := strings.Split(content, "\n")
+		found := false
+		for i, line := range lines {
+			if key, value, ok := orgKeyword(strings.TrimSpace(line)); ok && key == "FILETAGS" {
+				if tags := retag(orgTags(value)); tags != "" {
+					lines[i] = "#+FILETAGS: " + formatOrgTags(tags)
+				} else {
+					lines = append(lines[:i], lines[i+1:]...)
+				}
+				found = true
+				break
+			}
+		}
+		if found {
+			content = strings.Join(lines, "\n")
+		} else if tags := retag(""); tags != "" {
+			content = withOrgTags(content, tags)
+		}
+	} else {
+		first, rest, _ := strings.Cut(content, "\n")
+		existing, body := "", content
+		if tags, ok := parseTagLine(path, first); ok {
+			existing, body = tags, rest
+		}
+		if tags := retag(existing); tags != "" {
+			content = "// " + tags + lineEnding(first) + body
+		} else {
+			content = body
+		}
+	}
+
+	if content == string(data) {
+		return nil
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// retag replaces the tags of a note, saving a version first, u putting the
+// former ones back
+func (m *model) retag(item noteItem, tags string) error {
+	before, err := readForUndo(m.notesDir, item.filename)
+	if err != nil {
+		return err
+	}
+	if err := rewriteTags(item.path, func(string) string { return tags }); err != nil {
+		return err
+	}
+	m.pushUndo("tagging "+item.filename, restoreNotes(m.notesDir, before))
+	return nil
+}
+
+// startTagEdit asks for the tags of the selected note, the current ones
+// filled in
+func (m model) startTagEdit() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+
+	m.retagging = item
+	_, body := splitTagLine(noteContents.get(item))
+	m.tagSuggestions = suggestTags(m.items, item.Title()+"\n"+stripFrontMatter(body))
+	m.tagInput.SetValue(strings.ReplaceAll(item.tags, "+", ""))
+	m.tagInput.CursorEnd()
+	m.tagInput.Focus()
+	m.mode = modeTagEdit
+	return m, textinput.Blink
+}
+
+func (m model) updateTagEdit(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "ctrl+c":
+			m.quitting = true
+			return m, tea.Quit
+		case "esc":
+			m.tagInput.Blur()
+			m.mode = modeList
+			return m, nil
+		case "enter":
+			m.tagInput.Blur()
+			m.mode = modeList
+			item := m.retagging
+			tags := formatTagsWithPlus(m.tagInput.Value())
+			if tags == formatTagsWithPlus(item.tags) {
+				return m, nil
+			}
+			if err := m.retag(item, tags); err != nil {
+				return m, m.setError("Cannot tag %s: %v", item.filename, describeError(err))
+			}
+			return m, tea.Batch(m.reloadNotes(), m.noteSavedCmd("post_edit", item.filename, "Tagged "+item.filename))
+		case "tab":
+			m.acceptSuggestion()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.tagInput, cmd = m.tagInput.Update(msg)
+	return m, cmd
+}
+
+func (m model) viewTagEdit() string {
+	prompt := fmt.Sprintf("Enter the tags of %s, separated by spaces:", m.retagging.Title())
+	return fmt.Sprintf("\n\n  %s\n\n  %s\n\n", prompt, m.tagInput.View()+m.viewSuggestions()) + "  (press ESC to cancel)"
+}
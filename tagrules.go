@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tagRulesConfig normalizes the tags of the notes, so that tags written
+// differently over time are listed and filtered on as one
+type tagRulesConfig struct {
+	// Lowercase folds the case of the tags, +Work being +work
+	Lowercase bool `yaml:"lowercase"`
+	// Aliases replace tags with others, for synonyms like todo: task and
+	// renamed tags. The tags nested under an alias move along, with
+	// projects: work/projects +projects/alpha is +work/projects/alpha.
+	Aliases map[string]string `yaml:"aliases"`
+}
+
+// tagRules are the rules of the config, applied to the tags read from the
+// notes and to the typed ones
+var tagRules tagRulesConfig
+
+// tagName matches a tag without its +, nested or not
+var tagName = regexp.MustCompile(`^\w+(?:/\w+)*$`)
+
+// validate checks the aliases are tags
+func (r tagRulesConfig) validate() error {
+	for from, to := range r.Aliases {
+		for _, tag := range []string{from, to} {
+			if !tagName.MatchString(normalizeTag(strings.TrimPrefix(tag, "+"))) {
+				return fmt.Errorf("invalid tag alias %s: %s, expected tags like work/projects", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// setTagRules sets the rules of the config, their aliases written like the
+// tags they apply to
+func setTagRules(r tagRulesConfig) {
+	tagRules = tagRulesConfig{Lowercase: r.Lowercase, Aliases: map[string]string{}}
+	for from, to := range r.Aliases {
+		tagRules.Aliases[tagRules.fold(from)] = tagRules.fold(to)
+	}
+}
+
+// fold returns a tag without its + and with the case folded by the rules
+func (r tagRulesConfig) fold(tag string) string {
+	tag = normalizeTag(strings.TrimPrefix(tag, "+"))
+	if r.Lowercase {
+		tag = strings.ToLower(tag)
+	}
+	return tag
+}
+
+// canonicalTag returns the tag a tag is normalized to, with its +
+func canonicalTag(tag string) string {
+	name := tagRules.fold(tag)
+	if to, ok := tagRules.Aliases[name]; ok {
+		return "+" + to
+	}
+	// The longest alias of a parent wins
+	parents := tagParents(name)
+	for i := len(parents) - 1; i >= 0; i-- {
+		if to, ok := tagRules.Aliases[parents[i]]; ok {
+			return "+" + to + strings.TrimPrefix(name, parents[i])
+		}
+	}
+	return "+" + name
+}
+
+// normalizeTags normalizes the tags of a tag line, dropping the ones that
+// became duplicates
+func normalizeTags(tags string) string {
+	if !tagRules.Lowercase && len(tagRules.Aliases) == 0 {
+		return tags
+	}
+	var normalized []string
+	for _, tag := range strings.Fields(tags) {
+		if tag = canonicalTag(tag); !containsTag(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return strings.Join(normalized, " ")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxTagSuggestions is the number of tags suggested for a note
+const maxTagSuggestions = 5
+
+// suggestTags returns the tags of the notes a text is most likely about,
+// scoring every tag by how often its words appear in the text. The tags used
+// by more notes win ties.
+func suggestTags(items []noteItem, text string) []string {
+	frequency := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		frequency[word]++
+	}
+
+	used := map[string]int{}
+	for _, item := range items {
+		for _, tag := range strings.Fields(item.allTags()) {
+			used[tag]++
+		}
+	}
+
+	scores := map[string]int{}
+	for tag := range used {
+		score := 0
+		for _, word := range tagWords(tag) {
+			// Plurals count too, +book being suggested by books
+			score += frequency[word] + frequency[word+"s"] + frequency[word+"es"]
+		}
+		if score > 0 {
+			scores[tag] = score
+		}
+	}
+
+	tags := make([]string, 0, len(scores))
+	for tag := range scores {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		a, b := tags[i], tags[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if used[a] != used[b] {
+			return used[a] > used[b]
+		}
+		return a < b
+	})
+	return tags[:min(len(tags), maxTagSuggestions)]
+}
+
+// tagWords returns the words of a tag looked for in a text, leaving out the
+// short ones like the "to" of +how_to
+func tagWords(tag string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(strings.TrimPrefix(tag, "+")), func(r rune) bool {
+		return r == '/' || r == '_'
+	}) {
+		if len([]rune(word)) >= 3 {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// remainingSuggestions returns the suggested tags not typed in the tag input
+// yet
+func (m model) remainingSuggestions() []string {
+	typed := strings.Fields(formatTagsWithPlus(m.tagInput.Value()))
+	var remaining []string
+	for _, tag := range m.tagSuggestions {
+		if !containsTag(typed, tag) {
+			remaining = append(remaining, tag)
+		}
+	}
+	return remaining
+}
+
+// acceptSuggestion adds the first remaining suggestion to the tag input
+func (m *model) acceptSuggestion() {
+	remaining := m.remainingSuggestions()
+	if len(remaining) == 0 {
+		return
+	}
+	value := strings.TrimRight(m.tagInput.Value(), " ")
+	if value != "" {
+		value += " "
+	}
+	m.tagInput.SetValue(value + strings.TrimPrefix(remaining[0], "+") + " ")
+	m.tagInput.CursorEnd()
+}
+
+// viewSuggestions shows the suggested tags below the tag input
+func (m model) viewSuggestions() string {
+	remaining := m.remainingSuggestions()
+	if len(remaining) == 0 {
+		return ""
+	}
+	var labels []string
+	for _, tag := range remaining {
+		labels = append(labels, tagLabel(tag))
+	}
+	return "\n\n  " + mutedStyle.Render("Suggested: "+strings.Join(labels, ", ")+" (tab adds "+labels[0]+")")
+}
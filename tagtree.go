@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// normalizeTag turns the name of a quoted tag into a tag without spaces,
+// +"machine learning" being +machine_learning
+func normalizeTag(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
+
+// tagLabel returns a tag as shown: without its + and with the words of a
+// multi-word tag separated by spaces
+func tagLabel(tag string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(tag, "+"), "_", " ")
+}
+
+// hasTag reports whether a tag is among tags, or one nested under it:
+// +work matches +work/projects/alpha
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag || strings.HasPrefix(t, tag+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// tagParents returns the tags a nested tag is under, +work and
+// +work/projects for +work/projects/alpha
+func tagParents(tag string) []string {
+	var parents []string
+	for i := range tag {
+		if tag[i] == '/' {
+			parents = append(parents, tag[:i])
+		}
+	}
+	return parents
+}
+
+// tagNode is a tag of the tree, counting the notes having it or a tag nested
+// under it
+type tagNode struct {
+	tag      string
+	count    int
+	depth    int
+	children bool
+}
+
+// name returns the last part of a nested tag
+func (n tagNode) name() string {
+	name := n.tag
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return tagLabel(name)
+}
+
+// tagTreeView browses the tags by their hierarchy, the nested tags being
+// hidden until their parent is expanded
+type tagTreeView struct {
+	nodes    []tagNode
+	expanded map[string]bool
+	cursor   int
+}
+
+// newTagTree builds the tree of the tags of the notes
+func newTagTree(items []noteItem) tagTreeView {
+	counts := map[string]int{}
+	for _, item := range items {
+		seen := map[string]bool{}
+		for _, tag := range strings.Fields(item.allTags()) {
+			for _, t := range append(tagParents(tag), tag) {
+				if !seen[t] {
+					seen[t] = true
+					counts[t]++
+				}
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	// / sorts before the characters of a tag, so nested tags follow their
+	// parent
+	sort.Strings(tags)
+
+	v := tagTreeView{expanded: map[string]bool{}}
+	for i, tag := range tags {
+		v.nodes = append(v.nodes, tagNode{
+			tag:      tag,
+			count:    counts[tag],
+			depth:    strings.Count(tag, "/"),
+			children: i+1 < len(tags) && strings.HasPrefix(tags[i+1], tag+"/"),
+		})
+	}
+	return v
+}
+
+// visible returns the nodes whose parents are all expanded
+func (v tagTreeView) visible() []tagNode {
+	var nodes []tagNode
+	for _, n := range v.nodes {
+		shown := true
+		for _, parent := range tagParents(n.tag) {
+			if !v.expanded[parent] {
+				shown = false
+				break
+			}
+		}
+		if shown {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// openTagTree shows the tags of the notes as a tree
+func (m model) openTagTree() (tea.Model, tea.Cmd) {
+	tree := newTagTree(m.items)
+	if len(tree.nodes) == 0 {
+		return m, m.setInfo("No tags")
+	}
+	// Keep the expanded tags from the last time
+	if m.tagTree.expanded != nil {
+		tree.expanded = m.tagTree.expanded
+	}
+	m.tagTree = tree
+	m.mode = modeTagTree
+	return m, nil
+}
+
+func (m model) updateTagTree(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	nodes := m.tagTree.visible()
+	if len(nodes) == 0 {
+		m.mode = modeList
+		return m, nil
+	}
+	v := &m.tagTree
+	v.cursor = min(v.cursor, len(nodes)-1)
+	selected := nodes[v.cursor]
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if v.cursor > 0 {
+			v.cursor--
+		}
+	case "down", "j":
+		if v.cursor < len(nodes)-1 {
+			v.cursor++
+		}
+	case "right", "l":
+		if selected.children {
+			v.expanded[selected.tag] = true
+		}
+	case "left", "h":
+		// Collapse the tag, or go up to its parent
+		if selected.children && v.expanded[selected.tag] {
+			delete(v.expanded, selected.tag)
+			break
+		}
+		if parents := tagParents(selected.tag); len(parents) > 0 {
+			parent := parents[len(parents)-1]
+			for i, n := range nodes {
+				if n.tag == parent {
+					v.cursor = i
+				}
+			}
+		}
+	case " ", "tab":
+		if selected.children {
+			v.expanded[selected.tag] = !v.expanded[selected.tag]
+		}
+	case "enter":
+		// Filtering on a tag lists the notes of the tags nested under it
+		m.mode = modeList
+		return m.filterWith("tag:" + quoteValue(strings.TrimPrefix(selected.tag, "+")))
+	case "esc", "q", "#":
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m model) viewTagTree() string {
+	var b strings.Builder
+	v := m.tagTree
+	nodes := v.visible()
+	cursor := min(v.cursor, len(nodes)-1)
+	b.WriteString("\n" + titleStyle.Render(fmt.Sprintf("Tags (%d)", len(v.nodes))) + "\n\n")
+
+	var lines []string
+	for i, n := range nodes {
+		marker := "  "
+		if n.children && v.expanded[n.tag] {
+			marker = "▾ "
+		} else if n.children {
+			marker = "▸ "
+		}
+		line := strings.Repeat("  ", n.depth) + marker + n.name() + "  " + mutedStyle.Render(fmt.Sprint(n.count))
+		if i == cursor {
+			lines = append(lines, selectedItemStyle.Render("> "+line))
+		} else {
+			lines = append(lines, itemStyle.Render(line))
+		}
+	}
+
+	// Scroll to keep the cursor visible
+	b.WriteString(strings.Join(scrollLines(lines, cursor, max(m.height-6, 1)), "\n"))
+
+	return m.withHelp(b.String(), "l/h expand/collapse • enter filter • esc back")
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// taskLine is a markdown checkbox: "- [ ] task" or "- [x] task"
+var taskLine = regexp.MustCompile(`^(\s*(?:[-*+]|\d{1,9}[.)])\s+\[)([ xX])(\]\s+)(.*)$`)
+
+// task is a checkbox of a note
+type task struct {
+	filename string
+	// line of the task in the note, starting at 1
+	line int
+	text string
+	done bool
+}
+
+// readTasks returns the checkboxes of a note, outside of its code blocks
+func readTasks(item noteItem) ([]task, error) {
+	file, err := os.Open(item.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var tasks []task
+	fence := ""
+	scanner := newLineScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if match := fenceRegex.FindStringSubmatch(text); match != nil {
+			if fence == "" {
+				fence = match[1]
+			} else if strings.HasPrefix(strings.TrimSpace(text), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		if match := taskLine.FindStringSubmatch(text); match != nil {
+			tasks = append(tasks, task{
+				filename: item.filename,
+				line:     line,
+				text:     strings.TrimSpace(match[4]),
+				done:     match[2] != " ",
+			})
+		}
+	}
+
+	return tasks, scanError(scanner.Err())
+}
+
+// toggleTask checks or unchecks a task by rewriting its line in the note,
+// failing if the note changed since the task was read
+func toggleTask(notesDir string, t task) error {
+	path := filepath.Join(notesDir, t.filename)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if t.line > len(lines) {
+		return fmt.Errorf("the note changed, reopen the tasks")
+	}
+
+	line := strings.TrimSuffix(lines[t.line-1], "\r")
+	match := taskLine.FindStringSubmatch(line)
+	if match == nil || strings.TrimSpace(match[4]) != t.text {
+		return fmt.Errorf("the note changed, reopen the tasks")
+	}
+
+	mark := "x"
+	if t.done {
+		mark = " "
+	}
+	lines[t.line-1] = taskLine.ReplaceAllString(lines[t.line-1], "${1}"+mark+"${3}${4}")
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode())
+}
+
+// taskView lists the tasks of every note, grouped by note
+type taskView struct {
+	tasks []task
+	// cursor is the index of the selected task among the shown ones
+	cursor   int
+	showDone bool
+}
+
+// shown returns the tasks listed, the open ones unless showDone is set
+func (v taskView) shown() []task {
+	if v.showDone {
+		return v.tasks
+	}
+	var open []task
+	for _, t := range v.tasks {
+		if !t.done {
+			open = append(open, t)
+		}
+	}
+	return open
+}
+
+// openTasks scans the listed notes for tasks and shows them
+func (m model) openTasks() (tea.Model, tea.Cmd) {
+	var tasks []task
+	for _, item := range m.items {
+		noteTasks, err := readTasks(item)
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, noteTasks...)
+	}
+
+	m.tasks = taskView{tasks: tasks, showDone: m.tasks.showDone}
+	m.mode = modeTasks
+	return m, nil
+}
+
+func (m model) updateTasks(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	shown := m.tasks.shown()
+	switch keyMsg.String() {
+	case "up", "k":
+		if m.tasks.cursor > 0 {
+			m.tasks.cursor--
+		}
+	case "down", "j":
+		if m.tasks.cursor < len(shown)-1 {
+			m.tasks.cursor++
+		}
+	case "a":
+		m.tasks.showDone = !m.tasks.showDone
+		m.tasks.cursor = 0
+	case "x", " ":
+		if len(shown) == 0 {
+			return m, nil
+		}
+		t := shown[m.tasks.cursor]
+		if err := toggleTask(m.notesDir, t); err != nil {
+			return m, m.setError("Cannot update %s: %v", t.filename, describeError(err))
+		}
+		for i := range m.tasks.tasks {
+			if m.tasks.tasks[i].filename == t.filename && m.tasks.tasks[i].line == t.line {
+				m.tasks.tasks[i].done = !t.done
+			}
+		}
+		// Completed tasks leave the list, keep the cursor in it
+		if count := len(m.tasks.shown()); m.tasks.cursor >= count && count > 0 {
+			m.tasks.cursor = count - 1
+		}
+	case "enter":
+		if len(shown) == 0 {
+			return m, nil
+		}
+		t := shown[m.tasks.cursor]
+		return m, m.openNoteAt(t.filename, t.line)
+	case "esc", "q", "t":
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m model) viewTasks() string {
+	var b strings.Builder
+	shown := m.tasks.shown()
+
+	title := "Open tasks"
+	if m.tasks.showDone {
+		title = "All tasks"
+	}
+	b.WriteString("\n" + titleStyle.Render(fmt.Sprintf("%s (%d)", title, len(shown))) + "\n\n")
+
+	// Lines of the tasks, with a header line for every note
+	var lines []string
+	cursorLine := 0
+	previous := ""
+	for i, t := range shown {
+		if t.filename != previous {
+			if previous != "" {
+				lines = append(lines, "")
+			}
+			lines = append(lines, itemStyle.Copy().PaddingLeft(2).Bold(true).Render(noteTitle(t.filename))+
+				"  "+mutedStyle.Render(t.filename))
+			previous = t.filename
+		}
+
+		box := "[ ]"
+		if t.done {
+			box = "[x]"
+		}
+		line := box + " " + t.text
+		if i == m.tasks.cursor {
+			cursorLine = len(lines)
+			lines = append(lines, selectedItemStyle.Render("> "+line))
+		} else {
+			lines = append(lines, itemStyle.Render(line))
+		}
+	}
+	if len(shown) == 0 {
+		lines = append(lines, itemStyle.Render(mutedStyle.Render("No tasks")))
+	}
+
+	// Scroll to keep the cursor visible
+	b.WriteString(strings.Join(scrollLines(lines, cursorLine, max(m.height-6, 1)), "\n"))
+
+	help := "x toggle • enter edit • a show done • esc back"
+	if m.tasks.showDone {
+		help = "x toggle • enter edit • a hide done • esc back"
+	}
+	return m.withHelp(b.String(), help)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// taskSyncConfig sets where snsm sync-tasks copies the tasks of the notes
+type taskSyncConfig struct {
+	// TodoTxt is the todo.txt file the tasks are synced with
+	TodoTxt string `yaml:"todo_txt"`
+	// Taskwarrior syncs the tasks with Taskwarrior, through the task command
+	Taskwarrior bool `yaml:"taskwarrior"`
+}
+
+// syncedTask is a task of a note with the tags of its note, to copy
+type syncedTask struct {
+	task
+	tags []string
+}
+
+// id identifies a task of a note outside of snsm, from its note and its text
+func (t syncedTask) id() []byte {
+	sum := sha1.Sum([]byte(t.filename + "\n" + t.text))
+	return sum[:]
+}
+
+// syncStats counts what a sync changed
+type syncStats struct {
+	added, doneInNotes, doneOutside int
+}
+
+// describe reports the changes of a sync with target
+func (s syncStats) describe(target string) string {
+	return fmt.Sprintf("%d tasks added to %s, %d completed in the notes, %d completed in %s",
+		s.added, target, s.doneInNotes, s.doneOutside, target)
+}
+
+// readSyncedTasks returns the tasks of all the notes
+func readSyncedTasks(notesDir string) ([]syncedTask, error) {
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes: %v", err)
+	}
+
+	var tasks []syncedTask
+	for _, item := range items {
+		noteTasks, err := readTasks(item)
+		if err != nil {
+			continue
+		}
+		var tags []string
+		for _, tag := range strings.Fields(item.allTags()) {
+			tags = append(tags, strings.TrimPrefix(tag, "+"))
+		}
+		for _, t := range noteTasks {
+			tasks = append(tasks, syncedTask{task: t, tags: tags})
+		}
+	}
+	return tasks, nil
+}
+
+// todoKey is the key of the todo.txt lines holding the id of their task
+const todoKey = "snsm:"
+
+// todoLine formats an open task as a line of todo.txt: its text, the tags of
+// its note as projects, its note and its id
+func todoLine(t syncedTask, now time.Time) string {
+	parts := []string{now.Format("2006-01-02"), t.text}
+	for _, tag := range t.tags {
+		parts = append(parts, "+"+tag)
+	}
+	parts = append(parts, "note:"+url.PathEscape(t.filename), todoKey+hex.EncodeToString(t.id()[:6]))
+	return strings.Join(parts, " ")
+}
+
+// todoID returns the id of the task of a todo.txt line, if it comes from a note
+func todoID(line string) (string, bool) {
+	for _, word := range strings.Fields(line) {
+		if strings.HasPrefix(word, todoKey) {
+			return strings.TrimPrefix(word, todoKey), true
+		}
+	}
+	return "", false
+}
+
+// completeTodo marks a todo.txt line as done, dropping its priority
+func completeTodo(line string, now time.Time) string {
+	if len(line) > 4 && line[0] == '(' && line[2] == ')' && line[3] == ' ' {
+		line = line[4:]
+	}
+	return "x " + now.Format("2006-01-02") + " " + line
+}
+
+// syncTodoTxt syncs the tasks with a todo.txt file: tasks checked on one side
+// are checked on the other, and open tasks missing from the file are added
+func syncTodoTxt(notesDir, path string, tasks []syncedTask, now time.Time) (syncStats, error) {
+	var stats syncStats
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return stats, err
+	}
+
+	byID := map[string]syncedTask{}
+	for _, t := range tasks {
+		byID[hex.EncodeToString(t.id()[:6])] = t
+	}
+
+	var lines []string
+	if text := strings.TrimRight(string(data), "\n"); text != "" {
+		lines = strings.Split(text, "\n")
+	}
+	known := map[string]bool{}
+	for i, line := range lines {
+		id, ok := todoID(line)
+		if !ok {
+			continue
+		}
+		known[id] = true
+		t, ok := byID[id]
+		if !ok {
+			continue
+		}
+
+		done := strings.HasPrefix(line, "x ")
+		switch {
+		case done && !t.done:
+			if err := toggleTask(notesDir, t.task); err != nil {
+				return stats, fmt.Errorf("failed to complete %q in %s: %v", t.text, t.filename, err)
+			}
+			stats.doneInNotes++
+		case !done && t.done:
+			lines[i] = completeTodo(line, now)
+			stats.doneOutside++
+		}
+	}
+
+	for _, t := range tasks {
+		if !t.done && !known[hex.EncodeToString(t.id()[:6])] {
+			lines = append(lines, todoLine(t, now))
+			stats.added++
+		}
+	}
+
+	if stats.added == 0 && stats.doneOutside == 0 {
+		return stats, nil
+	}
+	return stats, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// taskwarriorTask is a task as exported and imported by Taskwarrior
+type taskwarriorTask struct {
+	UUID        string                  `json:"uuid"`
+	Description string                  `json:"description,omitempty"`
+	Status      string                  `json:"status"`
+	Entry       string                  `json:"entry,omitempty"`
+	Tags        []string                `json:"tags,omitempty"`
+	Annotations []taskwarriorAnnotation `json:"annotations,omitempty"`
+}
+
+type taskwarriorAnnotation struct {
+	Entry       string `json:"entry"`
+	Description string `json:"description"`
+}
+
+// taskwarriorTime is the format of the dates of Taskwarrior
+const taskwarriorTime = "20060102T150405Z"
+
+// taskUUID returns the uuid of a task in Taskwarrior, the same every sync
+func taskUUID(t syncedTask) string {
+	return nameUUID(t.id())
+}
+
+// nameUUID formats the first 16 bytes of a sha1 hash as a name based uuid
+func nameUUID(sum []byte) string {
+	id := append([]byte(nil), sum[:16]...)
+	// Version 5 and variant bits, like a name based uuid
+	id[6] = id[6]&0x0f | 0x50
+	id[8] = id[8]&0x3f | 0x80
+	h := hex.EncodeToString(id)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// taskCommand runs the task command of Taskwarrior without confirmations,
+// with stdin as input, and returns its output
+func taskCommand(stdin []byte, args ...string) ([]byte, error) {
+	args = append([]string{"rc.confirmation=off", "rc.verbose=nothing"}, args...)
+	cmd := exec.Command("task", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("task %s: %v: %s", strings.Join(args[2:], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// syncTaskwarrior syncs the tasks with Taskwarrior: tasks completed on one
+// side are completed on the other, and open tasks are imported, annotated
+// with their note
+func syncTaskwarrior(notesDir string, tasks []syncedTask, now time.Time) (syncStats, error) {
+	var stats syncStats
+	if _, err := exec.LookPath("task"); err != nil {
+		return stats, fmt.Errorf("taskwarrior is not installed")
+	}
+
+	out, err := taskCommand(nil, "export")
+	if err != nil {
+		return stats, err
+	}
+	var exported []taskwarriorTask
+	if err := json.Unmarshal(out, &exported); err != nil {
+		return stats, fmt.Errorf("failed to read the tasks of taskwarrior: %v", err)
+	}
+	status := map[string]string{}
+	for _, t := range exported {
+		status[t.UUID] = t.Status
+	}
+
+	var imports []taskwarriorTask
+	for _, t := range tasks {
+		uuid := taskUUID(t)
+		switch s, ok := status[uuid]; {
+		case !ok && !t.done:
+			imports = append(imports, taskwarriorTask{
+				UUID:        uuid,
+				Description: t.text,
+				Status:      "pending",
+				Entry:       now.UTC().Format(taskwarriorTime),
+				Tags:        t.tags,
+				Annotations: []taskwarriorAnnotation{{Entry: now.UTC().Format(taskwarriorTime), Description: "snsm: " + t.filename}},
+			})
+			stats.added++
+		case s == "completed" && !t.done:
+			if err := toggleTask(notesDir, t.task); err != nil {
+				return stats, fmt.Errorf("failed to complete %q in %s: %v", t.text, t.filename, err)
+			}
+			stats.doneInNotes++
+		case s == "pending" && t.done:
+			if _, err := taskCommand(nil, uuid, "done"); err != nil {
+				return stats, err
+			}
+			stats.doneOutside++
+		}
+	}
+
+	if len(imports) > 0 {
+		data, err := json.Marshal(imports)
+		if err != nil {
+			return stats, err
+		}
+		if _, err := taskCommand(data, "import", "-"); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+func runSyncTasks(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("sync-tasks", flag.ContinueOnError)
+	todoTxt := flags.String("todo-txt", cfg.TaskSync.TodoTxt, "todo.txt file to sync the tasks with")
+	taskwarrior := flags.Bool("taskwarrior", cfg.TaskSync.Taskwarrior, "sync the tasks with Taskwarrior")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *todoTxt == "" && !*taskwarrior {
+		return fmt.Errorf("nothing to sync with, pass --todo-txt or --taskwarrior, or set task_sync in the config")
+	}
+
+	tasks, err := readSyncedTasks(notesDir)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if *todoTxt != "" {
+		stats, err := syncTodoTxt(notesDir, expandTilde(*todoTxt), tasks, now)
+		if err != nil {
+			return fmt.Errorf("failed to sync with %s: %v", *todoTxt, err)
+		}
+		fmt.Println(stats.describe(*todoTxt))
+		if *taskwarrior && stats.doneInNotes > 0 {
+			// Taskwarrior sees the tasks completed from todo.txt
+			if tasks, err = readSyncedTasks(notesDir); err != nil {
+				return err
+			}
+		}
+	}
+	if *taskwarrior {
+		stats, err := syncTaskwarrior(notesDir, tasks, now)
+		if err != nil {
+			return fmt.Errorf("failed to sync with taskwarrior: %v", err)
+		}
+		fmt.Println(stats.describe("Taskwarrior"))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// termRenderer renders parsed markdown to styled lines for the terminal,
+// wrapped to a width
+type termRenderer struct {
+	width int
+	// image is called for paragraphs made of a single image, returning the
+	// lines drawn in their place. Images are shown by their alt text when nil.
+	image func(span mdInline, width int) []string
+	// links collects the links rendered when set, the label of each one
+	// following a linkMarker so that the line it lands on can be found
+	links *[]mdInline
+	// selected is the index of the link highlighted among links, -1 for none
+	selected int
+}
+
+// linkMarker precedes the links collected by the renderer, taking no room
+const linkMarker = "\u200b"
+
+// outlineEntry is a heading of a rendered document, starting on line
+type outlineEntry struct {
+	level int
+	text  string
+	line  int
+}
+
+// render converts a markdown document to lines
+func (r termRenderer) render(src string) []string {
+	lines, _ := r.renderOutline(src)
+	return lines
+}
+
+// renderOutline converts a markdown document to lines, also returning its
+// headings with the line each one is rendered on
+func (r termRenderer) renderOutline(src string) ([]string, []outlineEntry) {
+	var lines []string
+	var outline []outlineEntry
+	for _, block := range parseMarkdown(src) {
+		if block.kind == blockHeading {
+			outline = append(outline, outlineEntry{level: block.level, text: plainText(parseInline(block.text)), line: len(lines)})
+		}
+		r.renderBlocks(&lines, []mdBlock{block}, r.width, "")
+	}
+	// Drop the blank line following the last block
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, outline
+}
+
+// renderBlocks appends the lines of blocks, each prefixed with indent and
+// wrapped to width
+func (r termRenderer) renderBlocks(lines *[]string, blocks []mdBlock, width int, indent string) {
+	if width < 10 {
+		width = 10
+	}
+
+	for _, block := range blocks {
+		switch block.kind {
+		case blockParagraph:
+			spans := parseInline(block.text)
+			if len(spans) == 1 && spans[0].kind == inlineImage && r.image != nil {
+				for _, line := range r.image(spans[0], width) {
+					*lines = append(*lines, indent+line)
+				}
+			} else {
+				r.appendWrapped(lines, r.renderInline(spans, lipgloss.NewStyle()), width, indent)
+			}
+			*lines = append(*lines, "")
+
+		case blockHeading:
+			style := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(currentTheme.Title))
+			prefix := strings.Repeat("#", block.level) + " "
+			if block.level == 1 {
+				style = style.Underline(true)
+			}
+			r.appendWrapped(lines, mutedStyle.Render(prefix)+r.renderInline(parseInline(block.text), style), width, indent)
+			*lines = append(*lines, "")
+
+		case blockCode:
+			style := lipgloss.NewStyle().Foreground(lipgloss.Color(currentTheme.Input))
+			for _, line := range strings.Split(block.text, "\n") {
+				line = strings.ReplaceAll(line, "\t", "    ")
+				*lines = append(*lines, indent+mutedStyle.Render("│ ")+style.Render(truncateWidth(line, width-2)))
+			}
+			*lines = append(*lines, "")
+
+		case blockQuote:
+			var quoted []string
+			r.renderBlocks(&quoted, block.children, width-2, "")
+			trimBlankLines(&quoted)
+			for _, line := range quoted {
+				*lines = append(*lines, indent+mutedStyle.Render("┃ ")+line)
+			}
+			*lines = append(*lines, "")
+
+		case blockList:
+			for i, item := range block.children {
+				marker := "• "
+				if block.ordered {
+					marker = fmt.Sprintf("%d. ", block.start+i)
+				}
+				if item.task {
+					marker = "☐ "
+					if item.checked {
+						marker = "☑ "
+					}
+				}
+
+				var content []string
+				r.renderBlocks(&content, item.children, width-lipgloss.Width(marker), "")
+				trimBlankLines(&content)
+				if len(content) == 0 {
+					content = []string{""}
+				}
+				for j, line := range content {
+					if j == 0 {
+						*lines = append(*lines, indent+mutedStyle.Render(marker)+line)
+					} else {
+						*lines = append(*lines, indent+strings.Repeat(" ", lipgloss.Width(marker))+line)
+					}
+				}
+			}
+			*lines = append(*lines, "")
+
+		case blockRule:
+			*lines = append(*lines, indent+mutedStyle.Render(strings.Repeat("─", width)), "")
+
+		case blockTable:
+			for i, row := range block.rows {
+				var cells []string
+				for _, cell := range row {
+					style := lipgloss.NewStyle()
+					if i == 0 {
+						style = style.Bold(true)
+					}
+					cells = append(cells, r.renderInline(parseInline(cell), style))
+				}
+				*lines = append(*lines, indent+truncateWidth(strings.Join(cells, mutedStyle.Render(" │ ")), width))
+			}
+			*lines = append(*lines, "")
+		}
+	}
+}
+
+// appendWrapped appends text wrapped to width, keeping its line breaks
+func (r termRenderer) appendWrapped(lines *[]string, text string, width int, indent string) {
+	wrapped := lipgloss.NewStyle().Width(width).Render(text)
+	for _, line := range strings.Split(wrapped, "\n") {
+		*lines = append(*lines, indent+strings.TrimRight(line, " "))
+	}
+}
+
+// renderInline styles spans, base being the style of their text
+func (r termRenderer) renderInline(spans []mdInline, base lipgloss.Style) string {
+	var b strings.Builder
+	for _, span := range spans {
+		switch span.kind {
+		case inlineText:
+			b.WriteString(base.Render(span.text))
+		case inlineBreak:
+			b.WriteString("\n")
+		case inlineCode:
+			b.WriteString(base.Copy().Foreground(lipgloss.Color(currentTheme.Input)).Render(span.text))
+		case inlineStrong:
+			b.WriteString(r.renderInline(span.children, base.Copy().Bold(true)))
+		case inlineEmphasis:
+			b.WriteString(r.renderInline(span.children, base.Copy().Italic(true)))
+		case inlineStrike:
+			b.WriteString(r.renderInline(span.children, base.Copy().Strikethrough(true)))
+		case inlineLink:
+			label := r.collectLink(&b, span, base.Copy().Underline(true).Foreground(lipgloss.Color(currentTheme.Tag)))
+			if plainText(span.children) != span.url {
+				label += " " + mutedStyle.Render("("+span.url+")")
+			}
+			b.WriteString(label)
+		case inlineImage:
+			b.WriteString(mutedStyle.Render("[image: " + imageLabel(span) + "]"))
+		case inlineWikilink:
+			b.WriteString(r.collectLink(&b, span, base.Copy().Foreground(lipgloss.Color(currentTheme.Selected))))
+		}
+	}
+	return b.String()
+}
+
+// collectLink returns the styled label of a link, collecting the link with
+// its marker when the renderer collects them
+func (r termRenderer) collectLink(b *strings.Builder, span mdInline, style lipgloss.Style) string {
+	if r.links != nil {
+		if len(*r.links) == r.selected {
+			style = style.Copy().Reverse(true)
+		}
+		*r.links = append(*r.links, span)
+		b.WriteString(linkMarker)
+	}
+	return r.renderInline(span.children, style)
+}
+
+// imageLabel describes an image by its alt text, or its file name
+func imageLabel(span mdInline) string {
+	if span.text != "" {
+		return span.text
+	}
+	return span.url
+}
+
+// truncateWidth cuts a styled line to a width
+func truncateWidth(line string, width int) string {
+	if lipgloss.Width(line) <= width {
+		return line
+	}
+	return lipgloss.NewStyle().MaxWidth(width).Render(line)
+}
+
+func trimBlankLines(lines *[]string) {
+	for len(*lines) > 0 && (*lines)[len(*lines)-1] == "" {
+		*lines = (*lines)[:len(*lines)-1]
+	}
+}
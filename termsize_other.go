@@ -0,0 +1,9 @@
+//go:build !darwin && !linux
+
+package main
+
+// cellSize returns the size of a cell of the terminal in pixels, guessed as
+// it isn't reported on this system
+func cellSize() (width, height int) {
+	return 8, 16
+}
@@ -0,0 +1,19 @@
+//go:build darwin || linux
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cellSize returns the size of a cell of the terminal in pixels, guessing
+// when the terminal doesn't report it
+func cellSize() (width, height int) {
+	size, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil || size.Xpixel == 0 || size.Ypixel == 0 || size.Col == 0 || size.Row == 0 {
+		return 8, 16
+	}
+	return int(size.Xpixel / size.Col), int(size.Ypixel / size.Row)
+}
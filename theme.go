@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// theme is the color palette used by every style of the UI. Colors are
+// anything lipgloss.Color accepts: ANSI numbers ("27") or hex ("#5f87ff").
+type theme struct {
+	Title           string `yaml:"title"`
+	Text            string `yaml:"text"`
+	Selected        string `yaml:"selected"`
+	Muted           string `yaml:"muted"`
+	Input           string `yaml:"input"`
+	Success         string `yaml:"success"`
+	Error           string `yaml:"error"`
+	Tag             string `yaml:"tag"`
+	TagText         string `yaml:"tag_text"`
+	SelectedTag     string `yaml:"selected_tag"`
+	SelectedTagText string `yaml:"selected_tag_text"`
+}
+
+// Built-in themes, selectable with the "theme" config key
+var themes = map[string]theme{
+	"dark": {
+		Title:           "15",
+		Text:            "255",
+		Selected:        "10",
+		Muted:           "241",
+		Input:           "205",
+		Success:         "10",
+		Error:           "9",
+		Tag:             "27",
+		TagText:         "255",
+		SelectedTag:     "39",
+		SelectedTagText: "255",
+	},
+	"light": {
+		Title:           "235",
+		Text:            "235",
+		Selected:        "28",
+		Muted:           "245",
+		Input:           "161",
+		Success:         "28",
+		Error:           "160",
+		Tag:             "25",
+		TagText:         "255",
+		SelectedTag:     "31",
+		SelectedTagText: "255",
+	},
+	"high-contrast": {
+		Title:           "15",
+		Text:            "15",
+		Selected:        "11",
+		Muted:           "15",
+		Input:           "14",
+		Success:         "10",
+		Error:           "9",
+		Tag:             "15",
+		TagText:         "0",
+		SelectedTag:     "11",
+		SelectedTagText: "0",
+	},
+}
+
+// currentTheme is the palette applied by the last call to applyTheme
+var currentTheme = themes["dark"]
+
+var (
+	titleStyle        lipgloss.Style
+	itemStyle         lipgloss.Style
+	selectedItemStyle lipgloss.Style
+	paginationStyle   lipgloss.Style
+	helpStyle         lipgloss.Style
+	quitTextStyle     lipgloss.Style
+	inputStyle        lipgloss.Style
+	statusStyle       lipgloss.Style
+	statusErrorStyle  lipgloss.Style
+	mutedStyle        lipgloss.Style
+	menuStyle         lipgloss.Style
+
+	// Pill styling
+	tagPillStyle         lipgloss.Style
+	selectedTagPillStyle lipgloss.Style
+	// Tags inherited from the folder of a note
+	inheritedTagPillStyle lipgloss.Style
+
+	// Circle styling - foreground matches the background of the pill
+	circleStyle          lipgloss.Style
+	selectedCircleStyle  lipgloss.Style
+	inheritedCircleStyle lipgloss.Style
+)
+
+// resolveTheme picks the theme named in the config, detecting the terminal
+// background when set to "auto", and applies the color overrides on top.
+func resolveTheme(cfg config) (theme, error) {
+	name := cfg.Theme
+	if name == "" || name == "auto" {
+		name = "dark"
+		if !lipgloss.HasDarkBackground() {
+			name = "light"
+		}
+	}
+
+	t, ok := themes[name]
+	if !ok {
+		return themes["dark"], fmt.Errorf("unknown theme %q", cfg.Theme)
+	}
+
+	return t.merge(cfg.Colors), nil
+}
+
+// merge returns a copy of t with every non-empty color of overrides applied
+func (t theme) merge(overrides theme) theme {
+	pick := func(base, override string) string {
+		if override != "" {
+			return override
+		}
+		return base
+	}
+
+	return theme{
+		Title:           pick(t.Title, overrides.Title),
+		Text:            pick(t.Text, overrides.Text),
+		Selected:        pick(t.Selected, overrides.Selected),
+		Muted:           pick(t.Muted, overrides.Muted),
+		Input:           pick(t.Input, overrides.Input),
+		Success:         pick(t.Success, overrides.Success),
+		Error:           pick(t.Error, overrides.Error),
+		Tag:             pick(t.Tag, overrides.Tag),
+		TagText:         pick(t.TagText, overrides.TagText),
+		SelectedTag:     pick(t.SelectedTag, overrides.SelectedTag),
+		SelectedTagText: pick(t.SelectedTagText, overrides.SelectedTagText),
+	}
+}
+
+// applyTheme rebuilds all the styles from the given palette
+func applyTheme(t theme) {
+	// https://no-color.org: strip every color but keep the layout
+	if os.Getenv("NO_COLOR") != "" {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
+	currentTheme = t
+
+	titleStyle = lipgloss.NewStyle().MarginLeft(2).Foreground(lipgloss.Color(t.Title))
+	itemStyle = lipgloss.NewStyle().PaddingLeft(4)
+	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color(t.Selected))
+	paginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4).Foreground(lipgloss.Color(t.Muted))
+	helpStyle = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1).Foreground(lipgloss.Color(t.Muted))
+	quitTextStyle = lipgloss.NewStyle().Margin(1, 0, 2, 4)
+	inputStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Input))
+	statusStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color(t.Success))
+	statusErrorStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color(t.Error)).Bold(true)
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Muted))
+	menuStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color(t.Muted)).Padding(1, 2)
+
+	tagPillStyle = lipgloss.NewStyle().Background(lipgloss.Color(t.Tag)).Foreground(lipgloss.Color(t.TagText))
+	selectedTagPillStyle = lipgloss.NewStyle().Background(lipgloss.Color(t.SelectedTag)).Foreground(lipgloss.Color(t.SelectedTagText)).Bold(true)
+	inheritedTagPillStyle = lipgloss.NewStyle().Background(lipgloss.Color(t.Muted)).Foreground(lipgloss.Color(t.TagText))
+
+	circleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Tag))
+	selectedCircleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.SelectedTag))
+	inheritedCircleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Muted))
+}
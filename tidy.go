@@ -0,0 +1,229 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// tidyConfig sets the rules snsm tidy moves notes out of the way with
+type tidyConfig struct {
+	// Rules are tried in order, a note being moved by the first one it
+	// matches
+	Rules []tidyRule `yaml:"rules"`
+	// OnStartup applies the rules when the picker starts, once the moves
+	// are confirmed
+	OnStartup bool `yaml:"on_startup"`
+}
+
+// tidyRule moves the notes matching a search to a folder
+type tidyRule struct {
+	// Query is the search of the notes to move, like "tag:done
+	// modified>30d"
+	Query string `yaml:"query"`
+	// MoveTo is the folder they go to, relative to the notes directory,
+	// %t being replaced by the current date
+	MoveTo string `yaml:"move_to"`
+}
+
+// tidyMove is a note to move and the folder it goes to
+type tidyMove struct {
+	filename string
+	folder   string
+}
+
+// tidyMoved is a note moved by the rules, from its filename to the new one
+type tidyMoved struct {
+	from string
+	to   string
+}
+
+// tidyFolder returns the folder of a rule on a day, %t being the date
+func tidyFolder(moveTo string, now time.Time) string {
+	folder, _ := cleanFolder(strings.ReplaceAll(moveTo, "%t", now.Format("2006-01-02")))
+	return folder
+}
+
+// inTidyFolder reports whether a note is already in the folder of a rule,
+// or under it, whichever date %t was replaced by when it was moved there
+func inTidyFolder(filename, moveTo string) bool {
+	// The date is kept out of the cleaning as a NUL, which no path has
+	folder, _ := cleanFolder(strings.ReplaceAll(moveTo, "%t", "\x00"))
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(filepath.ToSlash(folder)), "\x00", `\d{4}-\d{2}-\d{2}`)
+	return regexp.MustCompile("^" + pattern + "(/|$)").MatchString(filepath.ToSlash(filepath.Dir(filename)))
+}
+
+// validate checks the rules of the config
+func (c tidyConfig) validate() error {
+	for _, rule := range c.Rules {
+		if strings.TrimSpace(rule.Query) == "" {
+			return fmt.Errorf("tidy rules need a query")
+		}
+		if _, err := parseQuery(rule.Query); err != nil {
+			return fmt.Errorf("invalid tidy rule %q: %v", rule.Query, err)
+		}
+		if folder, ok := cleanFolder(rule.MoveTo); !ok || folder == "" {
+			return fmt.Errorf("invalid tidy rule %q: move_to must be a folder of the notes directory", rule.Query)
+		}
+	}
+	return nil
+}
+
+// planTidy returns the notes the rules move on a day, leaving out the ones
+// already in the folder of their rule and the ones open in an editor
+func planTidy(rules []tidyRule, notesDir string, items []noteItem, now time.Time) ([]tidyMove, error) {
+	queries := make([]query, len(rules))
+	for i, rule := range rules {
+		q, err := parseQuery(rule.Query)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.prepare(items); err != nil {
+			return nil, err
+		}
+		queries[i] = q
+	}
+
+	var moves []tidyMove
+	for _, item := range items {
+		if item.readErr != nil {
+			continue
+		}
+		for i, q := range queries {
+			if !q.matches(item) {
+				continue
+			}
+			if inTidyFolder(item.filename, rules[i].MoveTo) {
+				break
+			}
+			if _, ok := heldLock(notesDir, item.filename); !ok {
+				moves = append(moves, tidyMove{filename: item.filename, folder: tidyFolder(rules[i].MoveTo, now)})
+			}
+			break
+		}
+	}
+	return moves, nil
+}
+
+// printTidy lists the planned moves
+func printTidy(moves []tidyMove) {
+	fmt.Printf("%s to move:\n", pluralNotes(len(moves)))
+	for _, move := range moves {
+		fmt.Printf("  %s → %s/\n", move.filename, filepath.ToSlash(move.folder))
+	}
+}
+
+// applyTidy moves the notes, updating the links to them, and returns the
+// notes moved
+func applyTidy(notesDir string, moves []tidyMove, items []noteItem) ([]tidyMoved, error) {
+	var filenames []string
+	for _, item := range items {
+		filenames = append(filenames, item.filename)
+	}
+
+	var moved []tidyMoved
+	links := 0
+	for _, move := range moves {
+		to, n, err := moveNote(notesDir, move.filename, move.folder, filenames)
+		if err != nil {
+			return moved, fmt.Errorf("cannot move %s: %v", move.filename, err)
+		}
+		moved = append(moved, tidyMoved{from: move.filename, to: to})
+		links += n
+	}
+	fmt.Printf("Moved %s, %d links updated\n", pluralNotes(len(moved)), links)
+	return moved, nil
+}
+
+// undoTidy moves the tidied notes back where they were, for u
+func undoTidy(notesDir string, moved []tidyMoved) func() error {
+	return func() error {
+		items, err := findMarkdownFiles(notesDir)
+		if err != nil {
+			return err
+		}
+		var filenames []string
+		for _, item := range items {
+			filenames = append(filenames, item.filename)
+		}
+		for _, move := range moved {
+			if _, _, err := moveNote(notesDir, move.to, filepath.Dir(move.from), filenames); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// tidyOnStartup applies the rules before the picker starts, once the moves
+// are confirmed, and returns the notes moved, u moving them back
+func tidyOnStartup(cfg config, notesDir string, items []noteItem) []tidyMoved {
+	if !cfg.Tidy.OnStartup || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	moves, err := planTidy(cfg.Tidy.Rules, notesDir, items, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot tidy the notes: %v\n", err)
+		return nil
+	}
+	if len(moves) == 0 {
+		return nil
+	}
+
+	printTidy(moves)
+	if !askForConfirmation("Move them now? u moves them back") {
+		return nil
+	}
+	moved, err := applyTidy(notesDir, moves, items)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	return moved
+}
+
+func runTidy(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("tidy", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only print the notes that would be moved")
+	yes := flags.Bool("yes", false, "move the notes without asking")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(cfg.Tidy.Rules) == 0 {
+		return fmt.Errorf("no tidy rules in the config")
+	}
+
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %v", err)
+	}
+	moves, err := planTidy(cfg.Tidy.Rules, notesDir, items, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(moves) == 0 {
+		fmt.Println("Nothing to tidy")
+		return nil
+	}
+
+	printTidy(moves)
+	if *dryRun {
+		return nil
+	}
+	if !*yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("not a terminal, pass --yes to move the notes without confirmation")
+		}
+		// Only the picker keeps an undo history
+		if !askForConfirmation("Move them? This can't be undone with u") {
+			return fmt.Errorf("canceled")
+		}
+	}
+	_, err = applyTidy(notesDir, moves, items)
+	return err
+}
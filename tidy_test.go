@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestPlanTidyDatedFolder runs the rules on two days: the note moved to the
+// folder of the first day stays there on the second one
+func TestPlanTidyDatedFolder(t *testing.T) {
+	notesDir := t.TempDir()
+	write := func(filename, content string) {
+		path := filepath.Join(notesDir, filename)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("standup.md", "// +meeting\n# Standup\n")
+	write("archive/meetings/2026-10-01/retro.md", "// +meeting\n# Retro\n")
+	write("plan.md", "# Plan\n")
+
+	rules := []tidyRule{{Query: "tag:meeting", MoveTo: "archive/meetings/%t"}}
+	day1 := time.Date(2026, 10, 14, 9, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+
+	items, err := findMarkdownFiles(notesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves, err := planTidy(rules, notesDir, items, day1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := tidyMove{filename: "standup.md", folder: filepath.Join("archive", "meetings", "2026-10-14")}
+	if len(moves) != 1 || moves[0] != want {
+		t.Fatalf("moves on the first day = %v, want [%v]", moves, want)
+	}
+	if _, err := applyTidy(notesDir, moves, items); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err = findMarkdownFiles(notesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves, err = planTidy(rules, notesDir, items, day2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 0 {
+		t.Fatalf("moves on the second day = %v, want none", moves)
+	}
+}
+
+func TestInTidyFolder(t *testing.T) {
+	tests := []struct {
+		filename string
+		moveTo   string
+		want     bool
+	}{
+		{"archive/old.md", "archive", true},
+		{"archive/2024/old.md", "archive", true},
+		{"archived/old.md", "archive", false},
+		{"old.md", "archive", false},
+		{"archive/meetings/2026-10-14/standup.md", "archive/meetings/%t", true},
+		{"archive/meetings/standup.md", "archive/meetings/%t", false},
+		{"2026-10-14/standup.md", "%t", true},
+		{"standup.md", "%t", false},
+		{"archive-2026-10-14/standup.md", "archive-%t", true},
+	}
+	for _, tt := range tests {
+		if got := inTidyFolder(filepath.FromSlash(tt.filename), tt.moveTo); got != tt.want {
+			t.Errorf("inTidyFolder(%q, %q) = %v, want %v", tt.filename, tt.moveTo, got, tt.want)
+		}
+	}
+}
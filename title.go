@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// titleConfig sets the title of the list and the one of the terminal window,
+// {{vault}}, {{dir}} and {{note}} being replaced by the vault, the notes
+// directory and the selected note
+type titleConfig struct {
+	// List is the title of the list, before the folder browsed and the
+	// saved search
+	List string `yaml:"list"`
+	// Terminal is the title of the terminal window, off to leave it alone
+	Terminal string `yaml:"terminal"`
+}
+
+// defaultTerminalTitle is the title of the terminal window without config
+const defaultTerminalTitle = "snsm — {{vault}} — {{note}}"
+
+// expandTitle fills in the placeholders of a title format. The separators
+// left at either end by empty placeholders are dropped.
+func (m model) expandTitle(format string) string {
+	vault := m.vaultName
+	if vault == "" {
+		vault = filepath.Base(m.notesDir)
+	}
+	var note string
+	if item, ok := m.list.SelectedItem().(noteItem); ok {
+		note = item.Title()
+	}
+	title := strings.NewReplacer(
+		"{{vault}}", vault,
+		"{{dir}}", m.notesDir,
+		"{{note}}", note,
+	).Replace(format)
+	return strings.Trim(title, " —-|:")
+}
+
+// baseListTitle is the title of the list before the folder browsed and the
+// saved search: the one of the config, or the notes directory and its vault
+func (m model) baseListTitle() string {
+	switch {
+	case m.cfg.Title.List != "":
+		return m.expandTitle(m.cfg.Title.List)
+	case m.vaultName != "":
+		return fmt.Sprintf("%s notes at %s", capitalizeFirstLetter(m.vaultName), m.notesDir)
+	default:
+		return fmt.Sprintf("Notes at %s", m.notesDir)
+	}
+}
+
+// terminalTitle returns the title of the terminal window, empty when left
+// alone
+func (m model) terminalTitle() string {
+	switch m.cfg.Title.Terminal {
+	case "off":
+		return ""
+	case "":
+		return m.expandTitle(defaultTerminalTitle)
+	default:
+		return m.expandTitle(m.cfg.Title.Terminal)
+	}
+}
+
+// syncTerminalTitle sets the title of the terminal window when it changed,
+// like when another note is selected
+func (m model) syncTerminalTitle(cmd tea.Cmd) (tea.Model, tea.Cmd) {
+	title := m.terminalTitle()
+	if title == m.shownTitle || m.quitting {
+		return m, cmd
+	}
+	m.shownTitle = title
+	return m, tea.Batch(cmd, tea.SetWindowTitle(title))
+}
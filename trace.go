@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"sync"
+	"time"
+)
+
+// startupTrace measures the phases of the startup, for --trace-startup
+type startupTrace struct {
+	enabled bool
+	start   time.Time
+	last    time.Time
+	phases  []tracePhase
+	// pending is the time recorded by add since the last mark
+	pending time.Duration
+	// rendered is set once the first frame was drawn
+	rendered bool
+
+	// timings records the durations of the work done all along the run, for
+	// --timings. The filtering runs in the background, hence the lock.
+	timings bool
+	mu      sync.Mutex
+	session map[string]*sessionTiming
+}
+
+// sessionTiming sums the durations of a kind of work
+type sessionTiming struct {
+	count      int
+	total, max time.Duration
+}
+
+type tracePhase struct {
+	name     string
+	duration time.Duration
+}
+
+// trace is the startup trace of the program, recording nothing unless enabled
+var trace startupTrace
+
+func (t *startupTrace) begin() {
+	t.enabled = true
+	t.start = time.Now()
+	t.last = t.start
+}
+
+// mark ends the current phase, which started at the end of the previous one.
+// The time recorded with add in the meantime isn't counted twice.
+func (t *startupTrace) mark(name string) {
+	if !t.enabled {
+		return
+	}
+	now := time.Now()
+	t.phases = append(t.phases, tracePhase{name: name, duration: now.Sub(t.last) - t.pending})
+	t.last = now
+	t.pending = 0
+}
+
+// add records a phase measured separately, e.g. spread over a loop
+func (t *startupTrace) add(name string, duration time.Duration) {
+	if !t.enabled {
+		return
+	}
+	t.pending += duration
+	for i := range t.phases {
+		if t.phases[i].name == name {
+			t.phases[i].duration += duration
+			return
+		}
+	}
+	t.phases = append(t.phases, tracePhase{name: name, duration: duration})
+}
+
+// record adds the duration of a kind of work done during the run, with
+// --timings
+func (t *startupTrace) record(name string, duration time.Duration) {
+	if !t.timings {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.session == nil {
+		t.session = map[string]*sessionTiming{}
+	}
+	timing, ok := t.session[name]
+	if !ok {
+		timing = &sessionTiming{}
+		t.session[name] = timing
+	}
+	timing.count++
+	timing.total += duration
+	timing.max = max(timing.max, duration)
+}
+
+// since records the duration of a kind of work started at start, deferred
+// like defer trace.since("scan", time.Now())
+func (t *startupTrace) since(name string, start time.Time) {
+	if t.timings {
+		t.record(name, time.Since(start))
+	}
+}
+
+// firstRender ends the startup once the first frame is drawn
+func (t *startupTrace) firstRender() {
+	if t.enabled && !t.rendered {
+		t.rendered = true
+		t.mark("first render")
+	}
+}
+
+func (t *startupTrace) report(w io.Writer) {
+	if !t.enabled {
+		return
+	}
+
+	fmt.Fprintln(w, "Startup trace:")
+	var total time.Duration
+	for _, phase := range t.phases {
+		fmt.Fprintf(w, "  %-14s %10s\n", phase.name, phase.duration.Round(time.Microsecond))
+		total += phase.duration
+	}
+	fmt.Fprintf(w, "  %-14s %10s\n", "total", total.Round(time.Microsecond))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.session) == 0 {
+		return
+	}
+	names := make([]string, 0, len(t.session))
+	for name := range t.session {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "Timings:")
+	fmt.Fprintf(w, "  %-14s %6s %10s %10s %10s\n", "", "count", "total", "average", "max")
+	for _, name := range names {
+		timing := t.session[name]
+		fmt.Fprintf(w, "  %-14s %6d %10s %10s %10s\n", name, timing.count,
+			timing.total.Round(time.Microsecond),
+			(timing.total / time.Duration(timing.count)).Round(time.Microsecond),
+			timing.max.Round(time.Microsecond))
+	}
+}
+
+// handlePprof exposes the runtime profiles under /debug/pprof/
+func handlePprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// trashDir holds the files deleted from snsm, in a folder per deletion, for
+// undo to bring them back
+const trashDir = ".snsm/trash"
+
+// keptTrash is how long the deleted files stay in the trash
+const keptTrash = 30 * 24 * time.Hour
+
+// maxUndo is the number of actions that can be undone
+const maxUndo = 50
+
+// undoEntry is an action of the session that can be undone
+type undoEntry struct {
+	// description tells what was done, like "deleting ideas.md"
+	description string
+	at          time.Time
+	undo        func() error
+}
+
+// pushUndo remembers how to undo an action, forgetting the oldest ones past
+// maxUndo
+func (m *model) pushUndo(description string, undo func() error) {
+	m.undo = append(m.undo, undoEntry{description: description, at: time.Now(), undo: undo})
+	if len(m.undo) > maxUndo {
+		m.undo = append([]undoEntry(nil), m.undo[len(m.undo)-maxUndo:]...)
+	}
+}
+
+// undoLast undoes the last action, kept when undoing it fails
+func (m model) undoLast() (tea.Model, tea.Cmd) {
+	if len(m.undo) == 0 {
+		return m, m.setInfo("Nothing to undo")
+	}
+	last := m.undo[len(m.undo)-1]
+	if err := last.undo(); err != nil {
+		return m, m.setError("Cannot undo %s: %v", last.description, err)
+	}
+	m.undo = m.undo[:len(m.undo)-1]
+	return m, tea.Batch(m.reloadNotes(), m.setInfo("Undid %s", last.description))
+}
+
+// openUndoHistory lists the actions that can be undone, the next one first
+func (m model) openUndoHistory() (tea.Model, tea.Cmd) {
+	if len(m.undo) == 0 {
+		return m, m.setInfo("Nothing to undo")
+	}
+	var lines []string
+	for i := len(m.undo) - 1; i >= 0; i-- {
+		e := m.undo[i]
+		line := mutedStyle.Render(e.at.Format("15:04:05")) + "  " + e.description
+		if i == len(m.undo)-1 {
+			line += mutedStyle.Render("  (u to undo)")
+		}
+		lines = append(lines, line)
+	}
+	return m.openPager(fmt.Sprintf("Undo history (%d)", len(m.undo)), lines)
+}
+
+// deleteFile moves a file of the notes directory to the trash once the
+// notes are backed up, u bringing it back, the pre_delete hook having agreed
+// with beforeDelete. Sensitive notes are shredded instead. It returns the
+// message telling what was done.
+func (m *model) deleteFile(filename string) (string, error) {
+	if sensitiveFile(m.notesDir, filename) {
+		return m.shredFile(filename)
+	}
+	if err := m.backupBeforeChange(); err != nil {
+		return "", err
+	}
+	trashed, err := trashFile(m.notesDir, filename, time.Now())
+	if err != nil {
+		return "", describeError(err)
+	}
+	path := filepath.Join(m.notesDir, filename)
+	m.pushUndo("deleting "+filename, func() error { return moveBack(trashed, path) })
+	return fmt.Sprintf("Deleted %s, u to undo", filename), nil
+}
+
+// shredFile shreds a note rather than trashing it, which can't be undone
+func (m *model) shredFile(filename string) (string, error) {
+	warning, err := shredNote(m.notesDir, filename)
+	if err != nil {
+		return "", err
+	}
+	if warning != "" {
+		return fmt.Sprintf("Shredded %s, but %s", filename, warning), nil
+	}
+	return fmt.Sprintf("Shredded %s", filename), nil
+}
+
+// trashFile moves a file of the notes directory to the trash and returns
+// its path there, deleting the files trashed more than keptTrash ago
+func trashFile(notesDir, filename string, now time.Time) (string, error) {
+	root := filepath.Join(notesDir, filepath.FromSlash(trashDir))
+	pruneTrash(root, now)
+
+	// Files of the same name deleted in the same second go to other folders
+	name := now.Format(versionTime)
+	dest := filepath.Join(root, name, filename)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(dest); os.IsNotExist(err) {
+			break
+		}
+		dest = filepath.Join(root, fmt.Sprintf("%s-%d", name, i), filename)
+	}
+
+	if err := makeSnsmDir(notesDir, filepath.Dir(dest)); err != nil {
+		return "", err
+	}
+	if err := os.Rename(filepath.Join(notesDir, filename), dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// pruneTrash deletes the folders of the trash older than keptTrash
+func pruneTrash(root string, now time.Time) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if len(name) > len(versionTime) {
+			name = name[:len(versionTime)]
+		}
+		trashedAt, err := time.ParseInLocation(versionTime, name, time.Local)
+		if err == nil && entry.IsDir() && now.Sub(trashedAt) > keptTrash {
+			os.RemoveAll(filepath.Join(root, entry.Name()))
+		}
+	}
+}
+
+// moveBack moves a file back where it was, refusing to replace another one
+func moveBack(from, to string) error {
+	if _, err := os.Lstat(to); err == nil {
+		return fmt.Errorf("%s exists again", filepath.Base(to))
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+// restoreContent returns the undo of a change of a note, writing back its
+// former content once the current one is saved as a version
+func restoreContent(notesDir, filename string, before []byte) func() error {
+	return func() error {
+		saveVersion(notesDir, filename, time.Now())
+		return os.WriteFile(filepath.Join(notesDir, filename), before, 0644)
+	}
+}
+
+// readForUndo reads the notes about to be changed, saving them as versions,
+// for restoreNotes to write them back
+func readForUndo(notesDir string, filenames ...string) (map[string][]byte, error) {
+	before := map[string][]byte{}
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filepath.Join(notesDir, filename))
+		if err != nil {
+			return nil, err
+		}
+		saveVersion(notesDir, filename, time.Now())
+		before[filename] = data
+	}
+	return before, nil
+}
+
+// restoreNotes returns the undo of a change of notes read by readForUndo
+func restoreNotes(notesDir string, before map[string][]byte) func() error {
+	return func() error {
+		for filename, data := range before {
+			if err := restoreContent(notesDir, filename, data)(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// vault is a named notes directory
+type vault struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	// Encrypted vaults keep their notes in an encrypted file at Path,
+	// decrypted in memory while snsm runs
+	Encrypted bool `yaml:"encrypted"`
+}
+
+// resolveVault returns the vault to open: the one named in SNSM_VAULT, the
+// last used one or the first configured one. Without vaults in the config,
+// the default notes directory is used and the name is empty.
+func resolveVault(cfg config, state appState) (name string, dir string, err error) {
+	if len(cfg.Vaults) == 0 {
+		return "", defaultNotesDir(), nil
+	}
+
+	if requested := os.Getenv("SNSM_VAULT"); requested != "" {
+		v, ok := cfg.vault(requested)
+		if !ok {
+			return "", "", fmt.Errorf("unknown vault %q", requested)
+		}
+		return v.Name, expandTilde(v.Path), nil
+	}
+
+	if v, ok := cfg.vault(state.Vault); ok {
+		return v.Name, expandTilde(v.Path), nil
+	}
+
+	return cfg.Vaults[0].Name, expandTilde(cfg.Vaults[0].Path), nil
+}
+
+// vault returns the vault with the given name
+func (cfg config) vault(name string) (vault, bool) {
+	for _, v := range cfg.Vaults {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return vault{}, false
+}
+
+// listTitle is the title of the notes list, naming the vault and the active
+// saved search if any
+func (m model) listTitle() string {
+	title := m.baseListTitle()
+	// The breadcrumb of the folder browsed
+	for _, folder := range splitFolder(m.browseDir) {
+		title += " › " + folder
+	}
+	if m.search.Name != "" {
+		title += fmt.Sprintf(" — %s (esc to clear)", m.search.Name)
+	}
+	return title
+}
+
+// openVaultSwitcher shows the menu of the configured vaults
+func (m model) openVaultSwitcher() (tea.Model, tea.Cmd) {
+	if len(m.cfg.Vaults) == 0 {
+		return m, m.setError("No vaults configured")
+	}
+
+	m.vaultMenu = menu{title: "Switch vault"}
+	for i, v := range m.cfg.Vaults {
+		m.vaultMenu.items = append(m.vaultMenu.items, menuItem{label: v.Name, hint: v.Path})
+		if v.Name == m.vaultName {
+			m.vaultMenu.cursor = i
+		}
+	}
+
+	m.mode = modeVaultSwitcher
+	return m, nil
+}
+
+func (m model) updateVaultSwitcher(msg tea.Msg) (tea.Model, tea.Cmd) {
+	chosen, closed := m.vaultMenu.update(msg)
+	if closed {
+		m.mode = modeList
+		return m, nil
+	}
+	if !chosen {
+		return m, nil
+	}
+
+	m.mode = modeList
+	return m, m.switchVault(m.cfg.Vaults[m.vaultMenu.cursor])
+}
+
+// switchVault lists the notes of another vault and remembers it for the next
+// sessions
+func (m *model) switchVault(v vault) tea.Cmd {
+	dir := expandTilde(v.Path)
+	if v.Encrypted {
+		return m.setError("Vault %s is encrypted, quit and run SNSM_VAULT=%s snsm to unlock it", v.Name, v.Name)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return m.setError("Cannot open vault %s: %v", v.Name, describeError(err))
+	}
+
+	m.vaultName = v.Name
+	m.notesDir = dir
+	markedNotes = map[string]bool{}
+	// The backup and the undo history are of the vault left
+	m.backedUp = false
+	m.undo = nil
+	m.list.ResetFilter()
+	m.search = savedSearch{}
+	m.searchQuery = query{}
+	m.list.Title = m.listTitle()
+
+	if err := updateState(func(state *appState) { state.Vault = v.Name }); err != nil {
+		return m.setError("%v", err)
+	}
+
+	return tea.Batch(m.setInfo("Switched to %s", v.Name), m.reloadNotes())
+}
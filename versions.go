@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// versionsDir holds the copies of the notes saved before editing, in a
+// folder per note
+const versionsDir = ".snsm/versions"
+
+// keptVersions is the number of versions kept per note, the oldest being
+// deleted
+const keptVersions = 50
+
+// versionTime is the format of the names of the versions
+const versionTime = "2006-01-02-150405"
+
+// noteVersion is a copy of a note saved before editing it
+type noteVersion struct {
+	path    string
+	savedAt time.Time
+}
+
+// listVersions returns the versions of a note, newest first
+func listVersions(notesDir, filename string) ([]noteVersion, error) {
+	dir := filepath.Join(notesDir, filepath.FromSlash(versionsDir), filename)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var versions []noteVersion
+	for _, entry := range entries {
+		name := entry.Name()
+		savedAt, err := time.ParseInLocation(versionTime, strings.TrimSuffix(name, filepath.Ext(name)), time.Local)
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		versions = append(versions, noteVersion{path: filepath.Join(dir, name), savedAt: savedAt})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].savedAt.After(versions[j].savedAt)
+	})
+	return versions, nil
+}
+
+// saveVersion copies a note into its versions, unless it didn't change since
+// the last one or it is sensitive, and deletes the oldest versions past
+// keptVersions
+func saveVersion(notesDir, filename string, now time.Time) error {
+	if sensitiveFile(notesDir, filename) {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(notesDir, filename))
+	if err != nil {
+		return err
+	}
+	versions, err := listVersions(notesDir, filename)
+	if err != nil {
+		return err
+	}
+	if len(versions) > 0 {
+		if last, err := os.ReadFile(versions[0].path); err == nil && bytes.Equal(last, data) {
+			return nil
+		}
+	}
+
+	dir := filepath.Join(notesDir, filepath.FromSlash(versionsDir), filename)
+	if err := makeSnsmDir(notesDir, dir); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, now.Format(versionTime)+filepath.Ext(filename)), data, 0644); err != nil {
+		return err
+	}
+
+	// The new version is not listed yet
+	for i := keptVersions - 1; i < len(versions); i++ {
+		os.Remove(versions[i].path)
+	}
+	return nil
+}
+
+// versionsView lists the versions of a note, and compares the selected one
+// with the note
+type versionsView struct {
+	filename string
+	versions []noteVersion
+	cursor   int
+	// comparing is set while the selected version is compared with the note
+	comparing bool
+	diff      []diffLine
+	offset    int
+}
+
+// openVersions shows the versions of the selected note
+func (m model) openVersions() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	versions, err := listVersions(m.notesDir, item.filename)
+	if err != nil {
+		return m, m.setError("Cannot list the versions of %s: %v", item.filename, describeError(err))
+	}
+	if len(versions) == 0 {
+		return m, m.setInfo("No versions of %s yet, they're saved when it's edited", item.filename)
+	}
+
+	m.versions = versionsView{filename: item.filename, versions: versions}
+	m.mode = modeVersions
+	return m, nil
+}
+
+// compareVersion diffs the selected version with the note
+func (m model) compareVersion() (tea.Model, tea.Cmd) {
+	v := m.versions.versions[m.versions.cursor]
+	old, err := os.ReadFile(v.path)
+	if err != nil {
+		return m, m.setError("Cannot read the version: %v", describeError(err))
+	}
+	current, err := os.ReadFile(filepath.Join(m.notesDir, m.versions.filename))
+	if err != nil && !os.IsNotExist(err) {
+		return m, m.setError("Cannot read %s: %v", m.versions.filename, describeError(err))
+	}
+
+	m.versions.diff = diffLines(splitNoteLines(old), splitNoteLines(current))
+	m.versions.offset = 0
+	m.versions.comparing = true
+	return m, nil
+}
+
+// restoreVersion replaces the note with the selected version, saving the
+// note as a version first so that the restore can be undone
+func (m model) restoreVersion() (tea.Model, tea.Cmd) {
+	v := m.versions.versions[m.versions.cursor]
+	filename := m.versions.filename
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return m, m.setError("Cannot read the version: %v", describeError(err))
+	}
+	if err := saveVersion(m.notesDir, filename, time.Now()); err != nil && !os.IsNotExist(err) {
+		return m, m.setError("Cannot save %s before restoring it: %v", filename, describeError(err))
+	}
+	if err := os.WriteFile(filepath.Join(m.notesDir, filename), data, 0644); err != nil {
+		return m, m.setError("Cannot restore %s: %v", filename, describeError(err))
+	}
+
+	m.mode = modeList
+	return m, tea.Batch(m.reloadNotes(), m.setInfo("Restored %s from %s", filename, v.savedAt.Format("2006-01-02 15:04")))
+}
+
+func (m model) updateVersions(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	v := &m.versions
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if v.comparing {
+			v.offset = max(v.offset-1, 0)
+		} else if v.cursor > 0 {
+			v.cursor--
+		}
+	case "down", "j":
+		if v.comparing {
+			v.offset = min(v.offset+1, max(len(v.diff)-1, 0))
+		} else if v.cursor < len(v.versions)-1 {
+			v.cursor++
+		}
+	case "enter":
+		if !v.comparing {
+			return m.compareVersion()
+		}
+	case "d":
+		version := v.versions[v.cursor]
+		return m.openDiff(version.path, filepath.Join(m.notesDir, v.filename),
+			v.filename+" ("+version.savedAt.Format("2006-01-02 15:04")+")", v.filename)
+	case "r":
+		return m.restoreVersion()
+	case "esc", "q", "H":
+		if v.comparing {
+			v.comparing = false
+			return m, nil
+		}
+		m.mode = modeList
+		return m, nil
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m model) viewVersions() string {
+	var b strings.Builder
+	v := m.versions
+	selected := v.versions[v.cursor]
+
+	var lines []string
+	cursorLine := 0
+	help := "enter compare • d unified diff • r restore • esc back"
+	if v.comparing {
+		b.WriteString("\n" + titleStyle.Render("Compare "+v.filename) + "\n\n")
+		lines = sideBySide(v.diff, "saved "+selected.savedAt.Format("2006-01-02 15:04"), "current", m.width)
+		b.WriteString(lines[0] + "\n")
+		lines = lines[1:]
+		cursorLine = min(v.offset, max(len(lines)-1, 0))
+		help = "j/k scroll • d unified diff • r restore • esc back"
+	} else {
+		b.WriteString("\n" + titleStyle.Render(fmt.Sprintf("Versions of %s (%d)", v.filename, len(v.versions))) + "\n\n")
+		for i, version := range v.versions {
+			line := version.savedAt.Format("Mon 2006-01-02 15:04:05")
+			if info, err := os.Stat(version.path); err == nil {
+				line += "  " + mutedStyle.Render(fmt.Sprintf("%d bytes", info.Size()))
+			}
+			if i == v.cursor {
+				cursorLine = len(lines)
+				lines = append(lines, selectedItemStyle.Render("> "+line))
+			} else {
+				lines = append(lines, itemStyle.Render(line))
+			}
+		}
+	}
+
+	// Scroll to keep the cursor visible, or from the offset of the diff
+	height := max(m.height-7, 1)
+	offset := 0
+	if v.comparing {
+		offset = min(cursorLine, max(len(lines)-height, 0))
+	} else if cursorLine >= height {
+		offset = cursorLine - height + 1
+	}
+	end := min(offset+height, len(lines))
+	b.WriteString(strings.Join(lines[offset:end], "\n"))
+
+	return m.withHelp(b.String(), help)
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// imageMarker starts the first line of an image in the lines of the viewer,
+// followed by the index of the image
+const imageMarker = "\x00"
+
+// noteViewer shows a note full screen with its images drawn inline by the
+// graphics protocol of the terminal. It runs outside of the program, which
+// can't draw images, like the editor does.
+type noteViewer struct {
+	item     noteItem
+	notesDir string
+	protocol string
+	// outline shows the headings of the note next to it, to jump to them
+	outline bool
+	// notes are the notes wikilinks lead to
+	notes []noteItem
+	// selected is the index of the link selected with tab, -1 for none
+	selected int
+	// history holds the notes left by following links, to go back to them
+	history []noteItem
+	// status reports the link just opened, shown until the next key
+	status string
+
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// viewerImage is an image of the note, drawn over a box of cells
+type viewerImage struct {
+	path       string
+	label      string
+	cols, rows int
+}
+
+// viewerPage is a note rendered for the viewer
+type viewerPage struct {
+	lines    []string
+	images   []viewerImage
+	headings []outlineEntry
+	links    []viewerLink
+}
+
+// viewerLink is a link of the note, its label starting on line
+type viewerLink struct {
+	span mdInline
+	line int
+}
+
+func (v *noteViewer) SetStdin(r io.Reader)  { v.stdin = r }
+func (v *noteViewer) SetStdout(w io.Writer) { v.stdout = w }
+func (v *noteViewer) SetStderr(io.Writer)   {}
+
+// viewerClosedMsg is sent when the viewer exits
+type viewerClosedMsg struct {
+	err error
+}
+
+// viewNote opens the selected note in the viewer, with its outline shown
+// when outline is set
+func (m model) viewNote(outline bool) (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(noteItem)
+	if !ok {
+		return m, nil
+	}
+	return m.viewItem(item, outline)
+}
+
+// viewItem opens a note in the viewer
+func (m model) viewItem(item noteItem, outline bool) (tea.Model, tea.Cmd) {
+
+	viewer := &noteViewer{item: item, notesDir: m.notesDir, protocol: m.imageProtocol, outline: outline, notes: m.items, selected: -1}
+	return m, tea.Exec(viewer, func(err error) tea.Msg {
+		return viewerClosedMsg{err: err}
+	})
+}
+
+// Run shows the note until q or esc is pressed
+func (v *noteViewer) Run() error {
+	in, ok := v.stdin.(*os.File)
+	if !ok {
+		return fmt.Errorf("the viewer needs a terminal")
+	}
+	out, ok := v.stdout.(*os.File)
+	if !ok {
+		return fmt.Errorf("the viewer needs a terminal")
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(in.Fd()), state)
+
+	width, height, err := term.GetSize(int(out.Fd()))
+	if err != nil {
+		return err
+	}
+
+	// Use the alternate screen and hide the cursor
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[2J\x1b[?25h\x1b[?1049l")
+
+	// The last line of the screen shows the help
+	pageHeight := height - 1
+
+	var body string
+	var page viewerPage
+	offset, cursor := 0, 0
+	// open shows a note from its top, with its outline when it has headings
+	open := func(item noteItem) {
+		v.item = item
+		_, body = splitTagLine(noteContents.get(item))
+		body = stripFrontMatter(body)
+		v.selected = -1
+		page = v.render(body, width)
+		if len(page.headings) == 0 && v.outline {
+			v.outline = false
+			page = v.render(body, width)
+		}
+		offset, cursor = 0, 0
+	}
+	open(v.item)
+
+	// toggleOutline shows or hides the outline, rendering the text again at
+	// its new width. The viewer stays on the section read, or goes to the
+	// heading chosen in the outline when jump is set.
+	toggleOutline := func(jump bool) {
+		section := currentHeading(page.headings, offset)
+		if jump {
+			section = cursor
+		}
+		v.outline = !v.outline
+		page = v.render(body, width)
+		if section >= 0 {
+			offset = page.headings[section].line
+		}
+		cursor = max(section, 0)
+	}
+
+	// selectLink highlights another link, scrolling to it when off the
+	// screen
+	selectLink := func(step int) {
+		if len(page.links) == 0 {
+			return
+		}
+		if v.selected < 0 && step < 0 {
+			v.selected = 0
+		}
+		v.selected = (v.selected + step + len(page.links)) % len(page.links)
+		page = v.render(body, width)
+		if line := page.links[v.selected].line; line < offset || line >= offset+pageHeight {
+			offset = line - pageHeight/2
+		}
+	}
+
+	buf := make([]byte, 16)
+	for {
+		v.draw(out, page, offset, cursor, width, pageHeight)
+		v.status = ""
+
+		n, err := in.Read(buf)
+		if err != nil {
+			return err
+		}
+
+		switch string(buf[:n]) {
+		case "q", "v", "\x03":
+			return nil
+		case "\x1b":
+			switch {
+			case v.outline:
+				toggleOutline(false)
+			case v.selected >= 0:
+				v.selected = -1
+				page = v.render(body, width)
+			default:
+				return nil
+			}
+		case "o", "t":
+			if len(page.headings) > 0 {
+				toggleOutline(false)
+			}
+		case "\t":
+			selectLink(1)
+		case "\x1b[Z":
+			selectLink(-1)
+		case "\r":
+			switch {
+			case v.outline:
+				toggleOutline(true)
+			case v.selected >= 0:
+				if item, ok := v.follow(page.links[v.selected].span); ok {
+					v.history = append(v.history, v.item)
+					open(item)
+				}
+			default:
+				offset++
+			}
+		case "\x7f", "\b":
+			if len(v.history) > 0 {
+				item := v.history[len(v.history)-1]
+				v.history = v.history[:len(v.history)-1]
+				open(item)
+			}
+		case "j", "\x1b[B":
+			if v.outline {
+				cursor = min(cursor+1, len(page.headings)-1)
+				offset = page.headings[cursor].line
+			} else {
+				offset++
+			}
+		case "k", "\x1b[A":
+			if v.outline {
+				cursor = max(cursor-1, 0)
+				offset = page.headings[cursor].line
+			} else {
+				offset--
+			}
+		case " ", "f", "\x1b[6~":
+			offset += pageHeight
+		case "b", "\x1b[5~":
+			offset -= pageHeight
+		case "g", "\x1b[H":
+			offset = 0
+		case "G", "\x1b[F":
+			offset = len(page.lines)
+		}
+
+		offset = min(offset, len(page.lines)-pageHeight)
+		offset = max(offset, 0)
+	}
+}
+
+// follow acts on a link: the notes it leads to are returned to be shown in
+// the viewer, other files and URLs are opened by the system
+func (v *noteViewer) follow(span mdInline) (noteItem, bool) {
+	if span.kind == inlineWikilink {
+		if filename, ok := newNoteIndex(itemFilenames(v.notes)).withAliases(v.notes).resolve(span.text); ok {
+			return v.note(filename)
+		}
+		v.status = "No note named " + span.text
+		return noteItem{}, false
+	}
+
+	target := span.url
+	if u, err := url.Parse(target); err == nil && (u.Scheme == "" || u.Scheme == "file") {
+		path, ok := resolveImage(v.notesDir, v.item.filename, u.Path)
+		if !ok {
+			v.status = "Cannot open " + target
+			return noteItem{}, false
+		}
+		if rel, err := filepath.Rel(v.notesDir, path); err == nil {
+			if item, ok := v.note(rel); ok {
+				return item, true
+			}
+		}
+		if _, err := os.Stat(path); err != nil {
+			v.status = "No file " + path
+			return noteItem{}, false
+		}
+		target = path
+	}
+
+	if err := openExternal(target); err != nil {
+		v.status = err.Error()
+	} else {
+		v.status = "Opened " + target
+	}
+	return noteItem{}, false
+}
+
+// note returns the note of a filename relative to the notes directory
+func (v *noteViewer) note(filename string) (noteItem, bool) {
+	for _, item := range v.notes {
+		if filepath.Clean(item.filename) == filepath.Clean(filename) {
+			return item, true
+		}
+	}
+	return noteItem{}, false
+}
+
+// outlineWidth returns the width of the outline on a screen of width cells,
+// its border included
+func outlineWidth(width int) int {
+	return min(30, width/3)
+}
+
+// textWidth returns the width left to the text of the note, as wide as the
+// screen without the outline
+func (v *noteViewer) textWidth(width int) int {
+	if v.outline {
+		return width - outlineWidth(width)
+	}
+	return width
+}
+
+// currentHeading returns the index of the heading of the section shown at
+// offset, -1 above the first heading
+func currentHeading(headings []outlineEntry, offset int) int {
+	current := -1
+	for i, heading := range headings {
+		if heading.line > offset {
+			break
+		}
+		current = i
+	}
+	return current
+}
+
+// render renders a note for a screen of width cells, the first line of every
+// image holding its marker followed by blank lines for the rest of the image
+func (v *noteViewer) render(body string, width int) viewerPage {
+	var images []viewerImage
+	var links []mdInline
+
+	renderer := termRenderer{width: v.textWidth(width) - 4, links: &links, selected: v.selected, image: func(span mdInline, width int) []string {
+		placeholder := []string{mutedStyle.Render("[image: " + imageLabel(span) + "]")}
+		if v.protocol == imagesOff {
+			return placeholder
+		}
+
+		path, ok := resolveImage(v.notesDir, v.item.filename, span.url)
+		if !ok {
+			return placeholder
+		}
+		cols, rows, ok := imageCells(path, width)
+		if !ok {
+			return placeholder
+		}
+
+		images = append(images, viewerImage{path: path, label: imageLabel(span), cols: cols, rows: rows})
+		lines := []string{imageMarker + strconv.Itoa(len(images)-1)}
+		for i := 1; i < rows; i++ {
+			lines = append(lines, "")
+		}
+		return lines
+	}}
+
+	title := titleStyle.Copy().Bold(true).Render(documentTitle(v.item.filename, body))
+	text, headings := renderer.renderOutline(body)
+	page := viewerPage{lines: append([]string{"", title, ""}, text...), images: images, headings: headings}
+	for i := range page.headings {
+		page.headings[i].line += 3
+	}
+
+	// Find the line of every link by its marker, which isn't drawn
+	for i, line := range page.lines {
+		for n := strings.Count(line, linkMarker); n > 0 && len(page.links) < len(links); n-- {
+			page.links = append(page.links, viewerLink{span: links[len(page.links)], line: i})
+		}
+		page.lines[i] = strings.ReplaceAll(line, linkMarker, "")
+	}
+	return page
+}
+
+// outlineRows returns the rows of the outline, scrolled to keep the selected
+// heading on the screen, each exactly width cells wide with its border
+func outlineRows(headings []outlineEntry, cursor, width, height int) []string {
+	top := 6
+	for _, heading := range headings {
+		top = min(top, heading.level)
+	}
+	start := max(min(cursor-height/2, len(headings)-height), 0)
+
+	rows := make([]string, height)
+	for row := range rows {
+		text := ""
+		if i := start + row; i < len(headings) {
+			text = truncateWidth(strings.Repeat("  ", headings[i].level-top)+headings[i].text, width-4)
+			if i == cursor {
+				text = selectedItemStyle.Copy().PaddingLeft(0).Render(text)
+			}
+		}
+		rows[row] = " " + text + strings.Repeat(" ", max(width-4-lipgloss.Width(text), 0)) + " " + mutedStyle.Render("│") + " "
+	}
+	return rows
+}
+
+// draw shows the lines of the note from offset next to its outline when
+// shown, then the images entirely visible on the screen
+func (v *noteViewer) draw(out io.Writer, page viewerPage, offset, cursor, width, height int) {
+	var b bytes.Buffer
+
+	b.WriteString("\x1b[2J\x1b[H")
+	if v.protocol == imagesKitty {
+		b.WriteString("\x1b_Ga=d,d=a,q=2\x1b\\")
+	}
+
+	type drawn struct {
+		image    viewerImage
+		row, col int
+	}
+	var toDraw []drawn
+
+	margin := make([]string, height)
+	for row := range margin {
+		margin[row] = "  "
+	}
+	if v.outline {
+		margin = outlineRows(page.headings, cursor, outlineWidth(width)+2, height)
+	}
+	left := lipgloss.Width(margin[0])
+
+	end := min(offset+height, len(page.lines))
+	for row := 0; row < height; row++ {
+		if offset+row >= end {
+			b.WriteString(margin[row] + "\x1b[K\r\n")
+			continue
+		}
+		line := page.lines[offset+row]
+		if i := strings.Index(line, imageMarker); i >= 0 {
+			index, _ := strconv.Atoi(line[i+len(imageMarker):])
+			image := page.images[index]
+			prefix := line[:i]
+			if row+image.rows <= height {
+				toDraw = append(toDraw, drawn{image: image, row: row, col: left + lipgloss.Width(prefix)})
+				line = prefix
+			} else {
+				line = prefix + mutedStyle.Render("[image: "+image.label+"]")
+			}
+		}
+		b.WriteString(margin[row] + line + "\x1b[K\r\n")
+	}
+
+	// The help stays on the last line
+	help := "q back • j/k scroll • space/b page"
+	switch {
+	case v.outline:
+		help = "j/k heading • enter jump • esc close outline • q back"
+	case len(page.headings) > 0:
+		help += " • o outline"
+	}
+	switch {
+	case v.selected >= 0 && !v.outline:
+		help = "enter open " + linkDestination(page.links[v.selected].span) + " • tab next link • esc back"
+	case len(page.links) > 0 && !v.outline:
+		help += " • tab links"
+	}
+	if len(v.history) > 0 && !v.outline {
+		help += " • backspace previous note"
+	}
+	if len(page.lines) > height {
+		help += fmt.Sprintf(" • %d%%", min(100, (offset+height)*100/len(page.lines)))
+	}
+	if v.status != "" {
+		help = v.status
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H%s", height+1, helpStyle.Copy().PaddingBottom(0).Render(truncateWidth(help, width-4)))
+
+	for _, d := range toDraw {
+		data, err := encodeImage(v.protocol, d.image.path, d.image.cols, d.image.rows)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "\x1b[%d;%dH", d.row+1, d.col+1)
+		b.Write(data)
+	}
+
+	out.Write(b.Bytes())
+}
+
+// linkDestination describes where a link leads
+func linkDestination(span mdInline) string {
+	if span.kind == inlineWikilink {
+		return "[[" + span.text + "]]"
+	}
+	return span.url
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// noteWatcher detects the notes added, modified or removed in a directory by
+// polling their modification times, which works on every filesystem
+// including network mounts
+type noteWatcher struct {
+	dir    string
+	mtimes map[string]time.Time
+}
+
+func newNoteWatcher(dir string) *noteWatcher {
+	return &noteWatcher{dir: dir, mtimes: map[string]time.Time{}}
+}
+
+// poll returns the filenames of the notes added or modified and of the notes
+// removed since the previous call. The first call reports every note as added.
+func (w *noteWatcher) poll() (changed, removed []string, err error) {
+	seen := map[string]time.Time{}
+
+	err = walkNotes(w.dir, func(path, filename string, entry fs.DirEntry) error {
+		info, err := entry.Info()
+		if err != nil {
+			// Removed while walking, it will be reported on the next poll
+			return nil
+		}
+
+		seen[filename] = info.ModTime()
+		if previous, ok := w.mtimes[filename]; !ok || !previous.Equal(info.ModTime()) {
+			changed = append(changed, filename)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for filename := range w.mtimes {
+		if _, ok := seen[filename]; !ok {
+			removed = append(removed, filename)
+		}
+	}
+	sort.Strings(removed)
+
+	w.mtimes = seen
+	return changed, removed, nil
+}
+
+// filenames returns every note known after the last poll
+func (w *noteWatcher) filenames() []string {
+	filenames := make([]string, 0, len(w.mtimes))
+	for filename := range w.mtimes {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// webdavStore keeps the notes in a folder of a WebDAV server
+type webdavStore struct {
+	base     *url.URL
+	user     string
+	password string
+	client   *http.Client
+}
+
+func newWebDAVStore(base, user, password string) *webdavStore {
+	u, err := url.Parse(strings.TrimSuffix(base, "/") + "/")
+	if err != nil {
+		u = &url.URL{Path: "/"}
+	}
+	return &webdavStore{base: u, user: user, password: password, client: &http.Client{Timeout: time.Minute}}
+}
+
+// request sends a request on a file or folder of the notes, relative to the
+// base folder
+func (s *webdavStore) request(method, name string, header map[string]string, body []byte) (*http.Response, error) {
+	u := *s.base
+	u.Path = s.base.Path + name
+	u.RawPath = ""
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.user != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	return s.client.Do(req)
+}
+
+// propfindBody asks for the properties the sync needs
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getetag/><d:getlastmodified/><d:resourcetype/></d:prop></d:propfind>`
+
+// davResponse is a resource of a PROPFIND multistatus
+type davResponse struct {
+	Href string `xml:"href"`
+	Prop struct {
+		ETag         string `xml:"getetag"`
+		LastModified string `xml:"getlastmodified"`
+		ResourceType struct {
+			Collection *struct{} `xml:"collection"`
+		} `xml:"resourcetype"`
+	} `xml:"propstat>prop"`
+}
+
+// propfind lists a folder, relative to the base folder and ending with a
+// slash, or a file
+func (s *webdavStore) propfind(name string) ([]davResponse, error) {
+	resp, err := s.request("PROPFIND", name, map[string]string{
+		"Depth":        "1",
+		"Content-Type": "application/xml",
+	}, []byte(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("PROPFIND %s: %s", name, resp.Status)
+	}
+
+	var multistatus struct {
+		Responses []davResponse `xml:"response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&multistatus); err != nil {
+		return nil, fmt.Errorf("invalid listing of %s: %v", name, err)
+	}
+	return multistatus.Responses, nil
+}
+
+// relative returns the name of a href relative to the base folder
+func (s *webdavStore) relative(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	name, ok := strings.CutPrefix(u.Path, s.base.Path)
+	return name, ok
+}
+
+func (s *webdavStore) list() (map[string]remoteObject, error) {
+	objects := map[string]remoteObject{}
+	folders := []string{""}
+	for len(folders) > 0 {
+		folder := folders[0]
+		folders = folders[1:]
+
+		responses, err := s.propfind(folder)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range responses {
+			name, ok := s.relative(r.Href)
+			// The folder itself is listed too
+			if !ok || strings.TrimSuffix(name, "/") == strings.TrimSuffix(folder, "/") {
+				continue
+			}
+			if r.Prop.ResourceType.Collection != nil {
+				if path.Base(strings.TrimSuffix(name, "/")) != ".git" {
+					folders = append(folders, strings.TrimSuffix(name, "/")+"/")
+				}
+				continue
+			}
+			modTime, _ := http.ParseTime(r.Prop.LastModified)
+			objects[name] = remoteObject{etag: r.Prop.ETag, modTime: modTime}
+		}
+	}
+	return objects, nil
+}
+
+func (s *webdavStore) get(name string) ([]byte, remoteObject, error) {
+	resp, err := s.request(http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, remoteObject{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, remoteObject{}, fmt.Errorf("GET %s: %s", name, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, remoteObject{}, err
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return data, remoteObject{etag: resp.Header.Get("ETag"), modTime: modTime}, nil
+}
+
+func (s *webdavStore) put(name string, data []byte) (string, error) {
+	// The parent folders are created first, the existing ones refusing
+	folder := ""
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "." {
+			break
+		}
+		folder += part + "/"
+		resp, err := s.request("MKCOL", folder, nil, nil)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := s.request(http.MethodPut, name, nil, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("PUT %s: %s", name, resp.Status)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag, nil
+	}
+
+	// Some servers leave the etag out of the response of a PUT
+	responses, err := s.propfind(name)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range responses {
+		if r.Prop.ResourceType.Collection == nil {
+			return r.Prop.ETag, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *webdavStore) delete(name string) error {
+	resp, err := s.request(http.MethodDelete, name, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("DELETE %s: %s", name, resp.Status)
+	}
+	return nil
+}
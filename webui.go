@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// serveConfig sets the login of snsm serve
+type serveConfig struct {
+	// User and Password are asked by the browser when set. PasswordEnv
+	// names the environment variable holding the password, to keep it out
+	// of the config.
+	User        string `yaml:"user"`
+	Password    string `yaml:"password"`
+	PasswordEnv string `yaml:"password_env"`
+}
+
+// password returns the password of the login
+func (c serveConfig) password() string {
+	if c.PasswordEnv != "" {
+		return os.Getenv(c.PasswordEnv)
+	}
+	return c.Password
+}
+
+// webUI serves the notes to a browser, rendered on every request: an index
+// searching them, a page per tag and a page per note, like a published site
+type webUI struct {
+	notesDir string
+	// edit allows changing the notes from the browser
+	edit bool
+	css  string
+	// user and password are the login, when set
+	user     string
+	password string
+	// token is put in the edit forms for the saves to come from them rather
+	// than from the forms of other sites, changing on every run
+	token string
+}
+
+// isLoopback reports whether an address to listen on only takes the
+// connections of this machine
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// addressedByIP reports whether a request names the server localhost or by
+// its IP address, like http://192.168.1.5:8080 from a phone. A site pointing
+// its own domain at this machine, a DNS rebinding, sends its domain instead.
+func addressedByIP(r *http.Request) bool {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil
+}
+
+// loggedIn reports whether a request has the login, when one is set
+func (u webUI) loggedIn(r *http.Request) bool {
+	if u.user == "" {
+		return true
+	}
+	user, password, ok := r.BasicAuth()
+	return ok && subtle.ConstantTimeCompare([]byte(user), []byte(u.user)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(u.password)) == 1
+}
+
+// fromEditForm reports whether a save comes from an edit form of this run:
+// with its token, and from a page of the web UI when the browser tells the
+// origin of the form
+func (u webUI) fromEditForm(r *http.Request) bool {
+	if subtle.ConstantTimeCompare([]byte(r.PostFormValue("token")), []byte(u.token)) != 1 {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == r.Host
+}
+
+// site returns the site rendering the notes as they are now
+func (u webUI) site(items []noteItem) htmlSite {
+	return htmlSite{
+		notesDir:  u.notesDir,
+		index:     newNoteIndex(itemFilenames(items)).withAliases(items),
+		folders:   newFolderConfigs(u.notesDir),
+		css:       u.css,
+		published: true,
+		editable:  u.edit,
+	}
+}
+
+// matchesSearch reports whether a note matches the search of the web UI: a
+// query like in the list, or words all found in its name, tags or content
+func matchesSearch(item noteItem, search string) bool {
+	if isQuery(search) {
+		q, err := parseQuery(search)
+		return err == nil && q.matches(item)
+	}
+
+	text := strings.ToLower(item.filename + " " + item.allTags() + " " + noteContents.get(item))
+	for _, word := range strings.Fields(strings.ToLower(search)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// publishedNotes returns the entries of the notes for the list pages
+func publishedNotes(items []noteItem) []publishedNote {
+	var notes []publishedNote
+	for _, item := range items {
+		_, body := splitTagLine(noteContents.get(item))
+		note := publishedNote{filename: item.filename, title: documentTitle(item.filename, stripFrontMatter(body))}
+		for _, tag := range strings.Fields(item.allTags()) {
+			note.tags = append(note.tags, strings.TrimPrefix(tag, "+"))
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+func (u webUI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !u.loggedIn(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="snsm", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// Without a login, the pages of other sites could read the notes
+	// through a domain of theirs resolving to this machine
+	if u.user == "" && !addressedByIP(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	items, err := findMarkdownFiles(u.notesDir)
+	if err != nil {
+		http.Error(w, "Cannot list the notes", http.StatusInternalServerError)
+		return
+	}
+	// The last modified notes come first
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].modTime.After(items[j].modTime)
+	})
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	switch {
+	case name == "" || name == "index.html":
+		u.serveIndex(w, r, items)
+		return
+	case strings.HasPrefix(name, "tags/"):
+		u.serveTag(w, name, items)
+		return
+	}
+
+	for _, item := range items {
+		if filepath.ToSlash(htmlPath(item.filename)) != name {
+			continue
+		}
+		if r.Method == http.MethodPost || r.URL.Query().Has("edit") {
+			u.serveEdit(w, r, item, items)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := u.site(items).writePage(w, item.filename); err != nil {
+			http.Error(w, "Cannot render the note", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Other files are the images and attachments of the notes, but the
+	// hidden ones, like .git
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	http.ServeFile(w, r, filepath.Join(u.notesDir, filepath.FromSlash(name)))
+}
+
+// serveIndex lists the tags and the notes, or the notes found by a search
+func (u webUI) serveIndex(w http.ResponseWriter, r *http.Request, items []noteItem) {
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "<form><input type=\"search\" name=\"q\" value=\"%s\" placeholder=\"Search, e.g. tag:work meeting\" autofocus> <button>Search</button></form>\n",
+		html.EscapeString(search))
+
+	title := "Notes"
+	if search != "" {
+		var found []noteItem
+		for _, item := range items {
+			if matchesSearch(item, search) {
+				found = append(found, item)
+			}
+		}
+		title = fmt.Sprintf("Search: %s (%d)", search, len(found))
+		writeNoteList(&body, "index.html", publishedNotes(found))
+	} else {
+		counts := map[string]int{}
+		for _, item := range items {
+			for _, tag := range strings.Fields(item.allTags()) {
+				counts[strings.TrimPrefix(tag, "+")]++
+			}
+		}
+		tags := make([]string, 0, len(counts))
+		for tag := range counts {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+
+		if len(tags) > 0 {
+			body.WriteString("<p class=\"tags\">")
+			for _, tag := range tags {
+				fmt.Fprintf(&body, "<a class=\"tag\" href=\"%s\">%s (%d)</a>",
+					html.EscapeString(filepath.ToSlash(tagPagePath(tag))), html.EscapeString(tag), counts[tag])
+			}
+			body.WriteString("</p>\n")
+		}
+		writeNoteList(&body, "index.html", publishedNotes(items))
+	}
+
+	home := ""
+	if search != "" {
+		home = "index.html"
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	u.site(items).writeList(w, title, home, body.String())
+}
+
+// serveTag lists the notes of a tag
+func (u webUI) serveTag(w http.ResponseWriter, name string, items []noteItem) {
+	var tagged []noteItem
+	tag := ""
+	for _, item := range items {
+		for _, t := range strings.Fields(item.allTags()) {
+			t = strings.TrimPrefix(t, "+")
+			if filepath.ToSlash(tagPagePath(t)) == name {
+				tagged = append(tagged, item)
+				tag = t
+				break
+			}
+		}
+	}
+	if len(tagged) == 0 {
+		http.Error(w, "No such tag", http.StatusNotFound)
+		return
+	}
+
+	var body strings.Builder
+	writeNoteList(&body, name, publishedNotes(tagged))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	u.site(items).writeList(w, "Tag: "+tag, "../index.html", body.String())
+}
+
+// serveEdit shows the form editing a note, and saves it. The note is saved
+// as a version first, like before editing it in the terminal, and notes
+// open in an editor aren't changed.
+func (u webUI) serveEdit(w http.ResponseWriter, r *http.Request, item noteItem, items []noteItem) {
+	if !u.edit {
+		http.Error(w, "The notes are read-only, run snsm serve --edit to edit them", http.StatusForbidden)
+		return
+	}
+	page := path.Base(filepath.ToSlash(htmlPath(item.filename)))
+	if lock, held := heldLock(u.notesDir, item.filename); held {
+		http.Error(w, fmt.Sprintf("%s is being edited by %s", item.filename, lock.describe()), http.StatusConflict)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !u.fromEditForm(r) {
+			http.Error(w, "The note can only be saved from its edit page, reload it", http.StatusForbidden)
+			return
+		}
+		// Browsers send the lines of text areas ended with CRLF
+		content := strings.ReplaceAll(r.PostFormValue("content"), "\r\n", "\n")
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		saveVersion(u.notesDir, item.filename, time.Now())
+		if err := os.WriteFile(item.path, []byte(content), 0644); err != nil {
+			http.Error(w, "Cannot save the note", http.StatusInternalServerError)
+			return
+		}
+		if err := noteSaved("post_edit", u.notesDir, item.filename); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		http.Redirect(w, r, page, http.StatusSeeOther)
+		return
+	}
+
+	data, err := os.ReadFile(item.path)
+	if err != nil {
+		http.Error(w, "Cannot read the note", http.StatusInternalServerError)
+		return
+	}
+	body := fmt.Sprintf("<form method=\"post\"><input type=\"hidden\" name=\"token\" value=\"%s\"><textarea name=\"content\" rows=\"30\" style=\"width: 100%%; font-family: monospace\">%s</textarea>\n<p><button>Save</button> <a href=\"%s\">Cancel</a></p></form>\n",
+		u.token, html.EscapeString(string(data)), html.EscapeString(page))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	u.site(items).writeList(w, "Edit "+item.filename, relativeHref(item.filename, "index.html"), body)
+}
+
+func runServe(cfg config, notesDir string, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8080", "address to serve the notes on, e.g. :8080 for the whole network")
+	edit := flags.Bool("edit", false, "allow editing the notes from the browser")
+	css := flags.String("css", cfg.Export.CSS, "stylesheet replacing the default one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ui := webUI{notesDir: notesDir, edit: *edit, user: cfg.Serve.User, password: cfg.Serve.password()}
+	if ui.user != "" && ui.password == "" {
+		return fmt.Errorf("serve.user is set without a password")
+	}
+	if *edit {
+		// Anyone reaching the address could change the notes otherwise
+		if !isLoopback(*addr) && ui.user == "" {
+			return fmt.Errorf("editing the notes on %s needs a login, set serve.user and serve.password_env in the config", *addr)
+		}
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+		ui.token = hex.EncodeToString(token)
+	}
+	if *css != "" {
+		data, err := os.ReadFile(expandTilde(*css))
+		if err != nil {
+			return fmt.Errorf("failed to read stylesheet: %v", err)
+		}
+		ui.css = string(data)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	server := &http.Server{Addr: *addr, Handler: ui}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	mode := "read-only"
+	if *edit {
+		mode = "editable"
+	}
+	fmt.Printf("Serving %s, %s, on http://%s, press ctrl+c to stop\n", notesDir, mode, *addr)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return server.Close()
+	}
+}